@@ -36,7 +36,9 @@
 package llmrouter
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -53,6 +55,8 @@ type Client struct {
 	baseURL    string
 	apiKey     string
 	httpClient *http.Client
+	// request bodies of at least gzipMinBytes are gzipped; 0 disables compression
+	gzipMinBytes int
 }
 
 // AdminClient provides access to administrative endpoints
@@ -86,6 +90,42 @@ func (c *Client) WithHTTPClient(client *http.Client) *Client {
 	return c
 }
 
+// WithRequestCompression gzips inference request bodies of at least minBytes.
+// Responses need no option: net/http asks for gzip and decompresses transparently
+// unless the transport sets DisableCompression.
+func (c *Client) WithRequestCompression(minBytes int) *Client {
+	if minBytes < 1 {
+		minBytes = 1
+	}
+	c.gzipMinBytes = minBytes
+	return c
+}
+
+// newJSONRequest builds a POST carrying body, gzipped when compression applies
+func (c *Client) newJSONRequest(ctx context.Context, url string, body []byte) (*http.Request, error) {
+	gzipped := c.gzipMinBytes > 0 && len(body) >= c.gzipMinBytes
+	if gzipped {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		body = buf.Bytes()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if gzipped {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	return httpReq, nil
+}
+
 // WithHTTPClient allows customization of the underlying HTTP client for admin operations
 func (c *AdminClient) WithHTTPClient(client *http.Client) *AdminClient {
 	c.httpClient = client
@@ -94,21 +134,95 @@ func (c *AdminClient) WithHTTPClient(client *http.Client) *AdminClient {
 
 // InferRequest represents a request to generate LLM inference
 type InferRequest struct {
-	Model           *string `json:"model,omitempty"`
-	Prompt          string  `json:"prompt"`
-	MaxTokens       *int    `json:"max_tokens,omitempty"`
-	Stream          *bool   `json:"stream,omitempty"`
-	Policy          *string `json:"policy,omitempty"`
-	IdempotencyKey  *string `json:"idempotency_key,omitempty"`
+	Model          *string           `json:"model,omitempty"`
+	Models         []string          `json:"models,omitempty"` // acceptable models in preference order, instead of Model
+	Prompt         string            `json:"prompt,omitempty"`
+	Template       string            `json:"template,omitempty"` // server-side prompt template, instead of Prompt
+	Variables      map[string]string `json:"variables,omitempty"`
+	Messages       []Message         `json:"messages,omitempty"`
+	MaxTokens      *int              `json:"max_tokens,omitempty"`
+	Truncate       *bool             `json:"truncate,omitempty"` // trim the oldest turns and the prompt's front to fit the context window
+	Stream         *bool             `json:"stream,omitempty"`
+	Policy         *string           `json:"policy,omitempty"`
+	IdempotencyKey *string           `json:"idempotency_key,omitempty"`
+	Provider       *string           `json:"provider,omitempty"`
+	Priority       *string           `json:"priority,omitempty"`        // high, normal or low
+	ConversationID *string           `json:"conversation_id,omitempty"` // keeps turns on one provider when the server enables stickiness
+	Tools          []Tool            `json:"tools,omitempty"`
+	ToolChoice     *string           `json:"tool_choice,omitempty"` // auto, none, required or a tool name
+}
+
+// Tool is a function the model may call; Parameters is a JSON Schema object
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is the model asking for a tool to be called with JSON Arguments
+type ToolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Message is a chat turn; Role is system, developer, user or assistant
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
 // InferResponse represents the response from an inference request
 type InferResponse struct {
-	Provider  string  `json:"provider"`
-	Text      string  `json:"text"`
-	CostUsd   float64 `json:"cost_usd"`
-	LatencyMs int     `json:"latency_ms"`
-	RequestId string  `json:"request_id"`
+	Provider        string     `json:"provider"`
+	Model           string     `json:"model,omitempty"`
+	Text            string     `json:"text"`
+	CostUsd         float64    `json:"cost_usd"`
+	LatencyMs       int        `json:"latency_ms"`
+	RequestId       string     `json:"request_id"`
+	ToolCalls       []ToolCall `json:"tool_calls,omitempty"`
+	Attempts        int        `json:"attempts"`                   // upstream calls made, retries included
+	FailedProviders []string   `json:"failed_providers,omitempty"` // providers with a failed attempt before the answer
+}
+
+// StreamDone is the final event of a streamed inference, carrying the totals that
+// are only known once the provider finishes
+type StreamDone struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model,omitempty"`
+	CostUsd          float64 `json:"cost_usd"`
+	LatencyMs        int     `json:"latency_ms"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	RequestId        string  `json:"request_id"`
+}
+
+// RouteResponse describes where a request would be routed and its estimated cost
+type RouteResponse struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Policy           string  `json:"policy"`
+	EstimatedCostUsd float64 `json:"estimated_cost_usd"`
+	EstimatedTokens  int64   `json:"estimated_tokens"`
+	RequestId        string  `json:"request_id"`
+}
+
+// EstimateRequest selects what GET /v1/estimate prices; only Prompt is required
+type EstimateRequest struct {
+	Prompt    string
+	Model     *string
+	MaxTokens *int
+	Policy    *string
+}
+
+// EstimateResponse is a token and cost preview for a prompt
+type EstimateResponse struct {
+	Provider                  string  `json:"provider"`
+	Model                     string  `json:"model"`
+	Policy                    string  `json:"policy"`
+	EstimatedPromptTokens     int64   `json:"estimated_prompt_tokens"`
+	EstimatedCompletionTokens int64   `json:"estimated_completion_tokens"`
+	EstimatedCostUsd          float64 `json:"estimated_cost_usd"`
 }
 
 // UsageDaily represents daily usage statistics
@@ -122,6 +236,23 @@ type UsageDaily struct {
 	CostUsd    float64 `json:"cost_usd"`
 }
 
+// TagUsage is a tenant's usage per value of one cost tag, most expensive first
+type TagUsage struct {
+	Tag    string          `json:"tag"`
+	Values []TagUsageValue `json:"values"`
+}
+
+// TagUsageValue totals the requests carrying one value of the tag
+type TagUsageValue struct {
+	Value     string  `json:"value"`
+	Requests  int     `json:"requests"`
+	Successes int     `json:"successes"`
+	Failures  int     `json:"failures"`
+	TokensIn  int     `json:"tokens_in"`
+	TokensOut int     `json:"tokens_out"`
+	CostUsd   float64 `json:"cost_usd"`
+}
+
 // UsageRecentItem represents a recent usage record
 type UsageRecentItem struct {
 	Ts             string  `json:"ts"`
@@ -148,6 +279,14 @@ func (p Problem) Error() string {
 	return fmt.Sprintf("%s (%d): %s", p.Title, p.Status, p.Detail)
 }
 
+// VersionInfo identifies the server build
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"` // RFC3339, or "unknown"
+	GoVersion string `json:"go_version"`
+}
+
 // AdminStatus represents comprehensive system status
 type AdminStatus struct {
 	Build struct {
@@ -155,12 +294,15 @@ type AdminStatus struct {
 		Commit    string    `json:"commit"`
 		BuildDate time.Time `json:"build_date"`
 	} `json:"build"`
-	Uptime             string `json:"uptime"`
-	DefaultPolicy      string `json:"default_policy"`
-	Providers          []Provider `json:"providers"`
-	BurnRates          BurnRates  `json:"burn_rates"`
-	TotalRequests      int        `json:"total_requests"`
-	CanaryStagePercent float64    `json:"canary_stage_percent"`
+	// BuildInfo is the running build as the server reports it
+	BuildInfo          VersionInfo `json:"build_info"`
+	Uptime             string      `json:"uptime"`
+	DefaultPolicy      string      `json:"default_policy"`
+	Providers          []Provider  `json:"providers"`
+	BurnRates          BurnRates   `json:"burn_rates"`
+	TotalRequests      int         `json:"total_requests"`
+	CanaryStagePercent float64     `json:"canary_stage_percent"`
+	Draining           bool        `json:"draining"`
 }
 
 // Provider represents provider status information
@@ -187,6 +329,7 @@ type CanaryStatus struct {
 	Percent    float64 `json:"percent"`
 	Candidate  *string `json:"candidate,omitempty"`
 	Window     int    `json:"window"`
+	MinDwell   string `json:"min_dwell,omitempty"`
 	LastTransition *struct {
 		Ts     time.Time `json:"ts"`
 		Reason string    `json:"reason"`
@@ -195,11 +338,13 @@ type CanaryStatus struct {
 
 // CreateTenantRequest represents a request to create a new tenant
 type CreateTenantRequest struct {
-	Name            string `json:"name"`
-	Plan            string `json:"plan"`
-	RpsLimit        int    `json:"rps_limit"`
-	DailyTokenLimit int64  `json:"daily_token_limit"`
-	Enabled         *bool  `json:"enabled,omitempty"`
+	Name            string   `json:"name"`
+	Plan            string   `json:"plan"`
+	RpsLimit        int      `json:"rps_limit"`
+	DailyTokenLimit int64    `json:"daily_token_limit"`
+	Enabled         *bool    `json:"enabled,omitempty"`
+	AllowedModels   []string `json:"allowed_models,omitempty"`
+	DeniedProviders []string `json:"denied_providers,omitempty"`
 }
 
 // CreateTenantResponse represents the response when creating a tenant
@@ -211,6 +356,8 @@ type CreateTenantResponse struct {
 	RpsLimit        int       `json:"rps_limit"`
 	DailyTokenLimit int64     `json:"daily_token_limit"`
 	Enabled         bool      `json:"enabled"`
+	AllowedModels   []string  `json:"allowed_models,omitempty"`
+	DeniedProviders []string  `json:"denied_providers,omitempty"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -232,12 +379,11 @@ func (c *Client) Infer(ctx context.Context, req InferRequest, opts ...InferOptio
 		return nil, fmt.Errorf("marshal request: %w", err)
 	}
 	
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/infer", bytes.NewReader(body))
+	httpReq, err := c.newJSONRequest(ctx, c.baseURL+"/v1/infer", body)
 	if err != nil {
 		return nil, fmt.Errorf("create request: %w", err)
 	}
 	
-	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-API-Key", c.apiKey)
 	
 	if opt.IdempotencyKey != nil {
@@ -262,6 +408,152 @@ func (c *Client) Infer(ctx context.Context, req InferRequest, opts ...InferOptio
 	return &result, nil
 }
 
+// InferStream runs req as a streamed inference, calling onDelta with each chunk of
+// text as it arrives. It returns the final event with cost and token totals
+func (c *Client) InferStream(ctx context.Context, req InferRequest, onDelta func(string)) (*StreamDone, error) {
+	stream := true
+	req.Stream = &stream
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := c.newJSONRequest(ctx, c.baseURL+"/v1/infer", body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	// events are "event: <name>" (absent for deltas) followed by "data: <json>"
+	event := ""
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch event {
+		case "":
+			var d struct {
+				Delta string `json:"delta"`
+			}
+			if err := json.Unmarshal([]byte(data), &d); err != nil {
+				return nil, fmt.Errorf("decode delta: %w", err)
+			}
+			if onDelta != nil {
+				onDelta(d.Delta)
+			}
+		case "done":
+			var done StreamDone
+			if err := json.Unmarshal([]byte(data), &done); err != nil {
+				return nil, fmt.Errorf("decode done event: %w", err)
+			}
+			return &done, nil
+		case "error":
+			var e struct {
+				ErrorKind string `json:"error_kind"`
+			}
+			_ = json.Unmarshal([]byte(data), &e)
+			return nil, fmt.Errorf("stream failed: %s", e.ErrorKind)
+		}
+		event = ""
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read stream: %w", err)
+	}
+	return nil, fmt.Errorf("stream ended without a done event")
+}
+
+// Route performs a dry run of req: the server picks a provider and estimates
+// cost without calling the provider or recording usage
+func (c *Client) Route(ctx context.Context, req InferRequest) (*RouteResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := c.newJSONRequest(ctx, c.baseURL+"/v1/infer?dry_run=1", body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	var result RouteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// Estimate previews tokens and cost for a prompt without calling a provider
+func (c *Client) Estimate(ctx context.Context, req EstimateRequest) (*EstimateResponse, error) {
+	q := url.Values{}
+	q.Set("prompt", req.Prompt)
+	if req.Model != nil {
+		q.Set("model", *req.Model)
+	}
+	if req.MaxTokens != nil {
+		q.Set("max_tokens", strconv.Itoa(*req.MaxTokens))
+	}
+	if req.Policy != nil {
+		q.Set("policy", *req.Policy)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/estimate?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+
+	var result EstimateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
 // GetDailyUsage retrieves daily usage statistics
 func (c *Client) GetDailyUsage(ctx context.Context, days *int) ([]UsageDaily, error) {
 	url := c.baseURL + "/v1/usage/daily"
@@ -321,6 +613,65 @@ func (c *Client) GetRecentUsage(ctx context.Context) ([]UsageRecentItem, error)
 	return result, nil
 }
 
+// GetUsageByTag retrieves the tenant's usage per value of the cost tag over the last
+// days (server default 7, max 30); tags are sent in the X-Cost-Tags request header
+func (c *Client) GetUsageByTag(ctx context.Context, tag string, days *int) (*TagUsage, error) {
+	q := url.Values{}
+	q.Set("tag", tag)
+	if days != nil {
+		q.Set("days", strconv.Itoa(*days))
+	}
+	
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/usage/by-tag?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	
+	httpReq.Header.Set("X-API-Key", c.apiKey)
+	
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+	
+	var result TagUsage
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	
+	return &result, nil
+}
+
+// Version retrieves the server's build information; it needs no credentials
+func (c *Client) Version(ctx context.Context) (*VersionInfo, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/version", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.handleErrorResponse(resp)
+	}
+	
+	var result VersionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	
+	return &result, nil
+}
+
 // GetAdminStatus retrieves comprehensive system status
 func (c *AdminClient) GetAdminStatus(ctx context.Context) (*AdminStatus, error) {
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/admin/status", nil)
@@ -37,7 +37,7 @@ export interface InferRequest {
   prompt: string;
   max_tokens?: number;
   stream?: boolean;
-  policy?: 'cheapest' | 'fastest_p95' | 'slo_burn_aware' | 'canary';
+  policy?: 'cheapest' | 'fastest_p95' | 'slo_burn_aware' | 'canary' | 'latency_slo';
   idempotency_key?: string;
 }
 
@@ -59,6 +59,21 @@ export interface UsageDaily {
   cost_usd: number;
 }
 
+export interface TagUsage {
+  tag: string;
+  values: TagUsageValue[];
+}
+
+export interface TagUsageValue {
+  value: string;
+  requests: number;
+  successes: number;
+  failures: number;
+  tokens_in: number;
+  tokens_out: number;
+  cost_usd: number;
+}
+
 export interface UsageRecentItem {
   ts: string;
   provider: string;
@@ -109,6 +124,7 @@ export interface CanaryStatus {
   percent: number;
   candidate?: string;
   window: number;
+  min_dwell?: string;
   last_transition?: {
     ts: string;
     reason: string;
@@ -232,6 +248,21 @@ export class LLMRouterClient {
     });
   }
 
+  /**
+   * Get usage per value of a cost tag sent in X-Cost-Tags, most expensive first
+   */
+  async getUsageByTag(tag: string, days?: number): Promise<TagUsage> {
+    const params = new URLSearchParams({ tag });
+    if (days) params.set('days', String(days));
+
+    return this.request<TagUsage>('GET', `/v1/usage/by-tag?${params}`, {
+      headers: {
+        'X-API-Key': this.apiKey,
+        ...this.config.headers,
+      },
+    });
+  }
+
   private async request<T>(
     method: string,
     path: string,
@@ -2,35 +2,63 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/go-chi/chi/v5"
-	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/api"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/audit"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/awsconfig"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/docs"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
 
 	// "github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/idempotency"
 	// "github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/rate"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/tables"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
 	// "github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
 )
 
 func main() {
-	// logging
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
-	zerolog.TimeFieldFormat = time.RFC3339
+	checkOnly := flag.Bool("check-config", false, "validate configuration, print any problems and exit")
+	initTables := flag.Bool("init-tables", false, "create the DynamoDB tenants and usage tables that don't exist yet and exit")
+	flag.Parse()
 
 	// config
 	cfg := config.Load()
+	if *checkOnly {
+		if !checkConfig(cfg, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *initTables {
+		if err := createTables(cfg, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// logging
+	logFormat := cfg.LogFormat
+	if logFormat == "" {
+		logFormat = telemetry.DefaultLogFormat(os.Stdout)
+	}
+	log.Logger = telemetry.NewLogger(os.Stdout, logFormat, cfg.LogLevel)
 
 	// validate configuration and log warnings
 	warnings := config.ValidateConfig(cfg)
@@ -44,11 +72,45 @@ func main() {
 	// log effective configuration with secrets masked
 	log.Info().Interface("config", cfg.MaskSecrets()).Msg("loaded configuration")
 
+	// pool settings must be in place before any provider is constructed
+	providers.SetTransportOptions(providers.TransportOptions{
+		MaxIdleConns:        cfg.ProviderMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.ProviderMaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.ProviderIdleConnTimeout,
+	})
+	// and so must the AWS credentials shared by Bedrock and the DynamoDB stores
+	awsconfig.Set(awsconfig.Options{Region: cfg.AWSRegion, Profile: cfg.AWSProfile, RoleARN: cfg.AWSRoleARN})
+
+	// background workers stop when main returns
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+
 	// Initialize auth component first
 	keyManager, err := auth.NewAPIKeyManager(cfg.DDBTenantsTable, cfg.TenantsJSONPath)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to initialize API key manager")
 	}
+	keyManager.SetStaleGrace(cfg.TenantStaleGrace)
+	keyManager.SetKeySources(auth.KeySources{Header: cfg.APIKeyHeader, Bearer: cfg.APIKeyBearer, QueryParam: cfg.APIKeyQueryParam})
+	if cfg.TenantsJSONPath != "" {
+		// pick up tenants added to the JSON file without a restart
+		go keyManager.WatchTenantsJSON(bgCtx, cfg.TenantsJSONPath, cfg.TenantsJSONRefresh)
+	}
+
+	// admin audit trail; without a path entries only live in memory
+	auditLog, err := audit.Open(cfg.AuditLogPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to open audit log")
+	}
+	defer auditLog.Close()
+	audit.SetLog(auditLog)
+
+	// push canary and circuit-breaker events to an external receiver
+	if cfg.EventWebhookURL != "" {
+		hook := events.NewWebhook(cfg.EventWebhookURL)
+		go hook.Run(bgCtx)
+		events.SetWebhook(hook)
+	}
 
 	// Temporarily disabled for debugging
 	// usageStore, err := usage.NewStore(cfg.DDBUsageTable)
@@ -56,12 +118,16 @@ func main() {
 	// 	log.Fatal().Err(err).Msg("failed to initialize usage store")
 	// }
 
-	// idempotencyStore, err := idempotency.NewStore(cfg.DDBUsageTable)
+	// idempotencyStore, err := idempotency.NewStore(cfg.DDBUsageTable, cfg.IdempotencyTTL)
 	// if err != nil {
 	// 	log.Fatal().Err(err).Msg("failed to initialize idempotency store")
 	// }
+	// idempotencyStore.SetMaxResponseSize(cfg.IdempotencyMaxResponseBytes)
+	// idempotencyStore.SetMaxRequestSize(cfg.MaxRequestBodyBytes)
 
 	// rateLimiter := rate.NewLimiter()
+	// rateLimiter.SetSoftLimitPct(cfg.UsageWarningPct)
+	// rateLimiter.SetDailyUsage(api.DailyTokens())
 	// usageHandlers := api.NewUsageHandlers(usageStore)
 	// tenantHandlers := api.NewTenantHandlers(keyManager, usageStore)
 
@@ -77,86 +143,58 @@ func main() {
 	}
 
 	r.Use(telemetry.RequestIDMiddleware)
-	r.Get("/v1/healthz", func(w http.ResponseWriter, _ *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
-	})
-	r.Get("/v1/readyz", func(w http.ResponseWriter, _ *http.Request) {
-		ps := router.GetProviders()
-		if len(ps) == 0 {
-			http.Error(w, "no providers", http.StatusServiceUnavailable)
-			return
-		}
-		// consider ready if any provider CB is not open
-		for _, p := range ps {
-			if p.CBStateValue() > 0 { // half-open or closed
-				w.WriteHeader(http.StatusOK)
-				_, _ = w.Write([]byte("ready"))
-				return
-			}
-		}
-		http.Error(w, "all providers tripped", http.StatusServiceUnavailable)
-	})
+	r.Use(telemetry.AccessLog(cfg.AccessLogSample))
+	r.Use(api.LimitBody(cfg.MaxRequestBodyBytes))
+	r.Use(api.Gzip(cfg.MaxDecompressedBodyBytes))
+	r.Get("/v1/healthz", api.HandleHealthz(cfg.LivenessStallWindow))
+	r.Get("/v1/readyz", api.HandleReadyz())
+	r.Get("/v1/version", api.HandleVersion())
 	r.Handle("/metrics", telemetry.MetricsHandler())
 
+	// global backpressure in front of the provider call, shared fairly across tenants
+	shed := api.GlobalConcurrencyLimit(cfg.MaxGlobalConcurrency, cfg.TenantQueueMaxWait, cfg.MaxRequestBodyBytes)
+	// global breaker on spend, fed by a sampler of the cost counter
+	spend := api.NewSpendGuard(cfg.MaxCostUSDPerMinute)
+	go telemetry.NewSpendSampler(spend.Observe).Run(bgCtx, 5*time.Second)
+
 	// Test multi-tenant with just auth middleware
 	if cfg.EnableUsageTracking || cfg.TenantsJSONPath != "" {
 		r.Route("/v1", func(r chi.Router) {
 			r.Use(keyManager.APIKeyMiddleware)
-			r.Post("/infer", api.HandleInfer(cfg)) // Use basic handler for now
+			r.With(spend.Limit, shed).Post("/infer", api.HandleInfer(cfg)) // Use basic handler for now
+			r.Get("/estimate", api.HandleEstimate(cfg))
+			r.With(spend.Limit, shed).Post("/embeddings", api.HandleEmbeddings(cfg, nil))
 		})
 	} else {
-		r.Post("/v1/infer", api.HandleInfer(cfg))
+		r.With(spend.Limit, shed).Post("/v1/infer", api.HandleInfer(cfg))
+		r.Get("/v1/estimate", api.HandleEstimate(cfg))
+		r.With(spend.Limit, shed).Post("/v1/embeddings", api.HandleEmbeddings(cfg, nil))
 	}
 
 	// Documentation routes (public)
 	r.Mount("/docs", docs.SwaggerUIHandler())
 
-	// Admin API
-	if cfg.AdminToken != "" {
-		admin := chi.NewRouter()
-		admin.Use(func(next http.Handler) http.Handler {
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				auth := r.Header.Get("Authorization")
-				const prefix = "Bearer "
-				if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix || auth[len(prefix):] != cfg.AdminToken {
-					http.Error(w, "unauthorized", http.StatusUnauthorized)
-					return
-				}
-				next.ServeHTTP(w, r)
-			})
-		})
-
-		admin.Get("/status", api.HandleAdminStatus())
-
-		admin.Get("/canary/status", api.HandleCanaryStatus())
-
-		admin.Post("/canary/advance", api.HandleCanaryAdvance())
-
-		admin.Post("/canary/rollback", api.HandleCanaryRollback())
-
-		admin.Post("/policy", api.HandlePolicyUpdate())
-
-		admin.Post("/providers/reload", api.HandleProvidersReload())
-
-		// Tenant management endpoints disabled for debugging
-		// admin.Post("/tenants", tenantHandlers.HandleCreateTenant())
-		// admin.Get("/tenants/{tenant_id}/usage", tenantHandlers.HandleGetTenantUsage())
-
-		r.Mount("/v1/admin", admin)
+	// Admin API (if any admin token is configured)
+	adminTokens, err := api.AdminTokensFromConfig(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to load admin tokens")
 	}
-
-	srv := &http.Server{
-		Addr:         ":" + cfg.Port,
-		Handler:      r,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  90 * time.Second,
+	if len(adminTokens) > 0 {
+		// Tenant management endpoints (admin role) disabled for debugging:
+		// POST /tenants, POST /tenants/import, GET /tenants/export,
+		// GET /tenants/{tenant_id}/usage
+		r.Mount("/v1/admin", api.NewAdminRouter(adminTokens))
 	}
 
+	// export fleet and per-provider burn rates from a background sampler
+	telemetry.SetSLOTarget(cfg.SLOTarget)
+	go telemetry.NewBurnSampler(1-cfg.SLOTarget, router.GetProviders).Run(bgCtx, 5*time.Second)
+
+	srv := api.NewServer(cfg, r)
+
 	// graceful shutdown
 	go func() {
-		log.Info().Str("addr", srv.Addr).Msg("server starting")
+		log.Info().Str("addr", srv.Addr).Bool("h2c", cfg.H2C).Msg("server starting")
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatal().Err(err).Msg("server failed")
 		}
@@ -166,6 +204,15 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
+	// fail readiness first so the load balancer stops routing here; an instance already
+	// drained through the admin API has had its time
+	if !api.Draining() {
+		api.SetDraining(true)
+		log.Info().Dur("delay", cfg.ShutdownDrainDelay).Msg("draining before shutdown")
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	// Shutdown stops accepting connections and waits for in-flight requests
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
@@ -173,3 +220,51 @@ func main() {
 	}
 	log.Info().Msg("server stopped")
 }
+
+// checkConfig prints every configuration problem to out and reports whether the
+// configuration is free of fatal ones
+func checkConfig(cfg config.Config, out io.Writer) bool {
+	problems := config.Check(cfg)
+	if _, err := api.AdminTokensFromConfig(cfg); err != nil {
+		problems = append(problems, config.Problem{Fatal: true, Message: "ADMIN_TOKENS_JSON: " + err.Error()})
+	}
+	ok := true
+	for _, p := range problems {
+		fmt.Fprintln(out, p)
+		if p.Fatal {
+			ok = false
+		}
+	}
+	if ok {
+		fmt.Fprintf(out, "configuration ok (%d warnings)\n", len(problems))
+	}
+	return ok
+}
+
+// createTables creates the configured DynamoDB tables that are missing and prints what
+// it did to out
+func createTables(cfg config.Config, out io.Writer) error {
+	names := tables.Names{Tenants: cfg.DDBTenantsTable, Usage: cfg.DDBUsageTable}
+	if names.Tenants == "" && names.Usage == "" {
+		return fmt.Errorf("set DDB_TENANTS_TABLE and/or DDB_USAGE_TABLE to create tables")
+	}
+	awsconfig.Set(awsconfig.Options{Region: cfg.AWSRegion, Profile: cfg.AWSProfile, RoleARN: cfg.AWSRoleARN})
+	ctx := context.Background()
+	awsCfg, err := awsconfig.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load AWS config: %w", err)
+	}
+	results, err := tables.Ensure(ctx, dynamodb.NewFromConfig(awsCfg), names)
+	for _, r := range results {
+		switch {
+		case r.Created:
+			fmt.Fprintf(out, "%s: created\n", r.Table)
+		default:
+			fmt.Fprintf(out, "%s: already exists\n", r.Table)
+		}
+		if r.TTLEnabled {
+			fmt.Fprintf(out, "%s: enabled TTL on %s\n", r.Table, tables.TTLAttribute)
+		}
+	}
+	return err
+}
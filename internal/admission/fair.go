@@ -0,0 +1,245 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRejected means no slot freed up for the caller within the queue wait
+var ErrRejected = errors.New("admission: no capacity")
+
+// Priority is a request's QoS class. Queued higher classes are dispatched before lower
+// ones regardless of tenant share, and lower classes are shed first.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority maps high, normal or low to a Priority
+func ParsePriority(s string) (Priority, bool) {
+	switch s {
+	case "high":
+		return PriorityHigh, true
+	case "normal":
+		return PriorityNormal, true
+	case "low":
+		return PriorityLow, true
+	}
+	return PriorityNormal, false
+}
+
+// FairQueue shares a fixed number of slots across tenants. While slots are free anyone
+// is admitted; once full, callers queue and each freed slot goes to the waiting tenant
+// with the fewest in-flight requests per unit of weight. A tenant bursting past its
+// share therefore waits behind quieter tenants instead of starving them.
+type FairQueue struct {
+	mu       sync.Mutex
+	capacity int
+	maxWait  time.Duration
+	inflight int
+	seq      uint64
+	tenants  map[string]*tenantState
+}
+
+type tenantState struct {
+	weight   float64
+	inflight int
+	waiters  []*waiter
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+	seq     uint64
+	prio    Priority
+}
+
+// NewFairQueue admits up to capacity concurrent callers; a caller that finds no free
+// slot waits at most maxWait (zero rejects immediately)
+func NewFairQueue(capacity int, maxWait time.Duration) *FairQueue {
+	return &FairQueue{capacity: capacity, maxWait: maxWait, tenants: make(map[string]*tenantState)}
+}
+
+// Acquire blocks until tenant is given a slot, maxWait passes or ctx is done. weight
+// scales the tenant's share relative to other waiting tenants (values <= 0 count as 1).
+// On success the returned release must be called exactly once.
+func (q *FairQueue) Acquire(ctx context.Context, tenant string, weight float64) (release func(), err error) {
+	return q.AcquirePriority(ctx, tenant, weight, PriorityNormal)
+}
+
+// AcquirePriority is Acquire for a request of class prio. A tenant whose queue is full
+// makes room for a higher-class request by shedding its newest lowest-class waiter.
+func (q *FairQueue) AcquirePriority(ctx context.Context, tenant string, weight float64, prio Priority) (release func(), err error) {
+	if weight <= 0 {
+		weight = 1
+	}
+	q.mu.Lock()
+	st := q.tenants[tenant]
+	if st == nil {
+		st = &tenantState{}
+		q.tenants[tenant] = st
+	}
+	st.weight = weight
+	if q.inflight < q.capacity {
+		q.inflight++
+		st.inflight++
+		q.mu.Unlock()
+		return q.releaser(tenant), nil
+	}
+	// a tenant can't queue more requests than there are slots in total
+	if q.maxWait <= 0 || (len(st.waiters) >= q.capacity && !st.shedBelow(prio)) {
+		q.forgetIdleLocked(tenant)
+		q.mu.Unlock()
+		return nil, ErrRejected
+	}
+	q.seq++
+	w := &waiter{ready: make(chan struct{}), seq: q.seq, prio: prio}
+	st.waiters = append(st.waiters, w)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(q.maxWait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		if !w.granted {
+			// shed to make room for a higher-class request; already out of the queue
+			return nil, ErrRejected
+		}
+		return q.releaser(tenant), nil
+	case <-timer.C:
+		err = ErrRejected
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if w.granted {
+		// dispatched while we were giving up; keep the slot
+		return q.releaser(tenant), nil
+	}
+	for i, other := range st.waiters {
+		if other == w {
+			st.waiters = append(st.waiters[:i], st.waiters[i+1:]...)
+			break
+		}
+	}
+	q.forgetIdleLocked(tenant)
+	return nil, err
+}
+
+// Inflight returns how many slots are held in total
+func (q *FairQueue) Inflight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.inflight
+}
+
+func (q *FairQueue) releaser(tenant string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.inflight--
+			if st := q.tenants[tenant]; st != nil {
+				st.inflight--
+			}
+			q.dispatchLocked()
+			q.forgetIdleLocked(tenant)
+		})
+	}
+}
+
+// dispatchLocked hands free slots to the highest-class waiters, and within a class to
+// the most under-served tenants
+func (q *FairQueue) dispatchLocked() {
+	for q.inflight < q.capacity {
+		var next *tenantState
+		nextIdx := -1
+		for _, st := range q.tenants {
+			i := st.head()
+			if i < 0 {
+				continue
+			}
+			if next == nil || less(st, i, next, nextIdx) {
+				next, nextIdx = st, i
+			}
+		}
+		if next == nil {
+			return
+		}
+		w := next.waiters[nextIdx]
+		next.waiters = append(next.waiters[:nextIdx], next.waiters[nextIdx+1:]...)
+		w.granted = true
+		q.inflight++
+		next.inflight++
+		close(w.ready)
+	}
+}
+
+// head returns the index of the tenant's next waiter, its oldest of the highest class,
+// or -1 when none are waiting
+func (st *tenantState) head() int {
+	best := -1
+	for i, w := range st.waiters {
+		if best < 0 || w.prio > st.waiters[best].prio {
+			best = i
+		}
+	}
+	return best
+}
+
+// shedBelow rejects the tenant's newest waiter of the lowest class, if that class is
+// below prio, and reports whether one was shed
+func (st *tenantState) shedBelow(prio Priority) bool {
+	victim := -1
+	for i, w := range st.waiters {
+		if w.prio < prio && (victim < 0 || w.prio <= st.waiters[victim].prio) {
+			victim = i
+		}
+	}
+	if victim < 0 {
+		return false
+	}
+	w := st.waiters[victim]
+	st.waiters = append(st.waiters[:victim], st.waiters[victim+1:]...)
+	close(w.ready)
+	return true
+}
+
+// less orders waiters by class, then their tenants by in-flight per weight, then by
+// who has waited longest
+func less(a *tenantState, ai int, b *tenantState, bi int) bool {
+	wa, wb := a.waiters[ai], b.waiters[bi]
+	if wa.prio != wb.prio {
+		return wa.prio > wb.prio
+	}
+	la, lb := float64(a.inflight)/a.weight, float64(b.inflight)/b.weight
+	if la != lb {
+		return la < lb
+	}
+	return wa.seq < wb.seq
+}
+
+func (q *FairQueue) forgetIdleLocked(tenant string) {
+	if st := q.tenants[tenant]; st != nil && st.inflight == 0 && len(st.waiters) == 0 {
+		delete(q.tenants, tenant)
+	}
+}
@@ -0,0 +1,206 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// queued waits until tenant has n requests waiting for a slot
+func queued(t *testing.T, q *FairQueue, tenant string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		st := q.tenants[tenant]
+		got := 0
+		if st != nil {
+			got = len(st.waiters)
+		}
+		q.mu.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d queued requests for %s", n, tenant)
+}
+
+func TestFairQueueFavoursUnderservedTenant(t *testing.T) {
+	q := NewFairQueue(2, 5*time.Second)
+	ctx := context.Background()
+
+	var held []func()
+	for i := 0; i < 2; i++ {
+		release, err := q.Acquire(ctx, "a", 1)
+		if err != nil {
+			t.Fatalf("expected free slot, got %v", err)
+		}
+		held = append(held, release)
+	}
+
+	admitted := make(chan string, 4)
+	acquire := func(tenant string, weight float64) {
+		if _, err := q.Acquire(ctx, tenant, weight); err != nil {
+			t.Errorf("%s: %v", tenant, err)
+			return
+		}
+		admitted <- tenant
+	}
+	go acquire("a", 1)
+	queued(t, q, "a", 1)
+	go acquire("b", 1)
+	queued(t, q, "b", 1)
+
+	// a queued first, but b has nothing in flight so gets the freed slot
+	held[0]()
+	if got := <-admitted; got != "b" {
+		t.Fatalf("expected b to get the freed slot, got %s", got)
+	}
+	held[1]()
+	if got := <-admitted; got != "a" {
+		t.Fatalf("expected a to get the next slot, got %s", got)
+	}
+}
+
+func TestFairQueueWeightsShare(t *testing.T) {
+	q := NewFairQueue(3, 5*time.Second)
+	ctx := context.Background()
+
+	// light holds one slot, heavy (weight 4) holds two
+	light, _ := q.Acquire(ctx, "light", 1)
+	heavy, _ := q.Acquire(ctx, "heavy", 4)
+	if _, err := q.Acquire(ctx, "heavy", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	admitted := make(chan string, 2)
+	for _, tc := range []struct {
+		tenant string
+		weight float64
+	}{{"light", 1}, {"heavy", 4}} {
+		go func() {
+			if _, err := q.Acquire(ctx, tc.tenant, tc.weight); err == nil {
+				admitted <- tc.tenant
+			}
+		}()
+		queued(t, q, tc.tenant, 1)
+	}
+
+	// both then hold one slot; light queued first, but heavy's weight leaves it further
+	// below its share
+	heavy()
+	if got := <-admitted; got != "heavy" {
+		t.Fatalf("expected heavy tenant to be under its share, got %s", got)
+	}
+	light()
+	if got := <-admitted; got != "light" {
+		t.Fatalf("expected light tenant next, got %s", got)
+	}
+}
+
+func TestFairQueueRejectsAfterMaxWait(t *testing.T) {
+	q := NewFairQueue(1, 20*time.Millisecond)
+	ctx := context.Background()
+	release, err := q.Acquire(ctx, "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Acquire(ctx, "b", 1); !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected ErrRejected after waiting, got %v", err)
+	}
+	if _, ok := q.tenants["b"]; ok {
+		t.Error("expected a rejected tenant to leave no queue state behind")
+	}
+
+	release()
+	if q.Inflight() != 0 {
+		t.Fatalf("expected no slots held, got %d", q.Inflight())
+	}
+	if _, err := q.Acquire(ctx, "b", 1); err != nil {
+		t.Fatalf("expected slot after release, got %v", err)
+	}
+
+	immediate := NewFairQueue(1, 0)
+	if _, err := immediate.Acquire(ctx, "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := immediate.Acquire(ctx, "a", 1); !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected zero maxWait to reject at once, got %v", err)
+	}
+}
+
+func TestFairQueueDispatchesHigherPriorityFirst(t *testing.T) {
+	q := NewFairQueue(1, 5*time.Second)
+	ctx := context.Background()
+	release, _ := q.Acquire(ctx, "a", 1)
+
+	admitted := make(chan Priority, 3)
+	for _, tc := range []struct {
+		tenant string
+		prio   Priority
+	}{{"batch", PriorityLow}, {"web", PriorityNormal}, {"chat", PriorityHigh}} {
+		go func() {
+			rel, err := q.AcquirePriority(ctx, tc.tenant, 1, tc.prio)
+			if err != nil {
+				t.Errorf("%s: %v", tc.prio, err)
+				return
+			}
+			admitted <- tc.prio
+			rel()
+		}()
+		queued(t, q, tc.tenant, 1)
+	}
+
+	// each admitted request releases its slot straight away, handing it to the next
+	release()
+	for _, want := range []Priority{PriorityHigh, PriorityNormal, PriorityLow} {
+		if got := <-admitted; got != want {
+			t.Fatalf("expected %s next, got %s", want, got)
+		}
+	}
+}
+
+func TestFairQueueShedsLowPriorityForHigh(t *testing.T) {
+	q := NewFairQueue(1, 5*time.Second)
+	ctx := context.Background()
+	release, _ := q.Acquire(ctx, "a", 1)
+
+	lowErr := make(chan error, 1)
+	go func() {
+		_, err := q.AcquirePriority(ctx, "a", 1, PriorityLow)
+		lowErr <- err
+	}()
+	queued(t, q, "a", 1)
+
+	// a's queue is full, so another low request is rejected outright...
+	if _, err := q.AcquirePriority(ctx, "a", 1, PriorityLow); !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected a second low request to be rejected, got %v", err)
+	}
+	// ...while a high one takes the queued low request's place
+	highErr := make(chan error, 1)
+	go func() {
+		_, err := q.AcquirePriority(ctx, "a", 1, PriorityHigh)
+		highErr <- err
+	}()
+	if err := <-lowErr; !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected the queued low request to be shed, got %v", err)
+	}
+	release()
+	if err := <-highErr; err != nil {
+		t.Fatalf("expected the high request to be admitted, got %v", err)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for _, s := range []string{"high", "normal", "low"} {
+		p, ok := ParsePriority(s)
+		if !ok || p.String() != s {
+			t.Errorf("%s: got %s, %v", s, p, ok)
+		}
+	}
+	if _, ok := ParsePriority("urgent"); ok {
+		t.Error("expected unknown priority to be rejected")
+	}
+}
@@ -2,42 +2,50 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/admission"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/audit"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/buildinfo"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	startTime = time.Now()
-	buildInfo = struct {
-		Version string `json:"version"`
-		Commit  string `json:"commit"`
-		Date    string `json:"build_date"`
-	}{
-		Version: "dev",
-		Commit:  "unknown",
-		Date:    time.Now().Format(time.RFC3339),
+var startTime = time.Now()
+
+// recordAudit appends an admin action to the audit log; failures are logged, not surfaced
+func recordAudit(r *http.Request, action string, before, after any) {
+	err := audit.GetLog().Record(audit.ActorFrom(r.Context()), action, telemetry.RequestIDFrom(r.Context()), before, after)
+	if err != nil {
+		log.Error().Err(err).Str("action", action).Msg("failed to write audit entry")
 	}
-)
+}
+
+type canaryStageState struct {
+	Stage   int     `json:"stage_index"`
+	Percent float64 `json:"percent"`
+}
 
 // AdminStatusResponse represents the admin status endpoint response
 type AdminStatusResponse struct {
-	BuildInfo struct {
-		Version string `json:"version"`
-		Commit  string `json:"commit"`
-		Date    string `json:"build_date"`
-	} `json:"build_info"`
-	Uptime        string `json:"uptime"`
-	DefaultPolicy string `json:"default_policy"`
+	BuildInfo     buildinfo.Info `json:"build_info"`
+	Uptime        string         `json:"uptime"`
+	DefaultPolicy string         `json:"default_policy"`
 	Providers     []struct {
 		Name         string  `json:"name"`
+		Enabled      bool    `json:"enabled"`
 		CBState      float64 `json:"cb_state"`
 		ErrorRate1m  float64 `json:"error_rate_1m"`
 		ErrorRate5m  float64 `json:"error_rate_5m"`
@@ -52,6 +60,13 @@ type AdminStatusResponse struct {
 		Rate1h float64 `json:"burn_rate_1h"`
 	} `json:"burn_rates"`
 	CanaryStagePercent float64 `json:"canary_stage_percent"`
+	Draining           bool    `json:"draining"`
+}
+
+// DrainResponse reports the drain state and how many infer requests are still running
+type DrainResponse struct {
+	Draining bool `json:"draining"`
+	InFlight int  `json:"in_flight"`
 }
 
 // CanaryStatusResponse represents the canary status endpoint response
@@ -60,8 +75,16 @@ type CanaryStatusResponse struct {
 	Stage             int       `json:"stage_index"`
 	CandidateProvider string    `json:"candidate_provider"`
 	WindowSize        int       `json:"window_size"`
+	MinDwell          string    `json:"min_dwell"`
 	LastTransition    time.Time `json:"last_transition"`
 	LastReason        string    `json:"last_reason"`
+	Mode              string    `json:"mode"`
+	Tenants           []string  `json:"tenants"`
+	// SavingsPct is how much cheaper per request the candidate has been than the
+	// primary this stage; absent until both have billed requests
+	SavingsPct     *float64 `json:"savings_pct,omitempty"`
+	MinSavingsPct  float64  `json:"min_savings_pct"`
+	AdvanceBlocked string   `json:"advance_blocked,omitempty"`
 }
 
 // HandleAdminStatus returns the comprehensive status information
@@ -71,23 +94,26 @@ func HandleAdminStatus() http.HandlerFunc {
 		e := router.GetEngine()
 
 		resp := AdminStatusResponse{
-			BuildInfo:     buildInfo,
+			BuildInfo:     buildinfo.Get(),
 			Uptime:        time.Since(startTime).String(),
 			DefaultPolicy: router.GetDefaultPolicy(),
 		}
 
 		var totalReqs int64
 		var maxBurn1m, maxBurn5m, maxBurn1h float64
+		target := telemetry.SLOTarget()
 
 		for _, p := range ps {
 			er1m := p.Stats().ErrorRateSince(1 * 60 * 1e9)
 			er5m := p.Stats().ErrorRateSince(5 * 60 * 1e9)
 			er1h := p.Stats().ErrorRateSince(60 * 60 * 1e9)
+			// calls still in the provider's rolling stats window
+			calls, _ := p.Stats().CountsSince(60 * 60 * 1e9)
+			totalReqs += int64(calls)
 
-			// Calculate burn rates (assuming 1% SLO target)
-			burn1m := er1m / 0.01
-			burn5m := er5m / 0.01
-			burn1h := er1h / 0.01
+			burn1m := telemetry.ProviderBurnRate(p, time.Minute, target)
+			burn5m := telemetry.ProviderBurnRate(p, 5*time.Minute, target)
+			burn1h := telemetry.ProviderBurnRate(p, time.Hour, target)
 
 			if burn1m > maxBurn1m {
 				maxBurn1m = burn1m
@@ -101,6 +127,7 @@ func HandleAdminStatus() http.HandlerFunc {
 
 			resp.Providers = append(resp.Providers, struct {
 				Name         string  `json:"name"`
+				Enabled      bool    `json:"enabled"`
 				CBState      float64 `json:"cb_state"`
 				ErrorRate1m  float64 `json:"error_rate_1m"`
 				ErrorRate5m  float64 `json:"error_rate_5m"`
@@ -109,6 +136,7 @@ func HandleAdminStatus() http.HandlerFunc {
 				CostPer1k    float64 `json:"cost_per_1k_tokens_usd"`
 			}{
 				Name:         p.Name(),
+				Enabled:      p.Enabled(),
 				CBState:      p.CBStateValue(),
 				ErrorRate1m:  er1m,
 				ErrorRate5m:  er5m,
@@ -126,6 +154,7 @@ func HandleAdminStatus() http.HandlerFunc {
 		if e != nil {
 			resp.CanaryStagePercent = e.CanaryPercent()
 		}
+		resp.Draining = Draining()
 
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -135,6 +164,28 @@ func HandleAdminStatus() http.HandlerFunc {
 	}
 }
 
+// HandleAdminSLO reports fleet-wide availability, burn rate, p95 latency and the
+// remaining error budget over ?window= (default 5m) against SLO_TARGET
+func HandleAdminSLO() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window := 5 * time.Minute
+		if s := r.URL.Query().Get("window"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 {
+				http.Error(w, "window must be a positive duration like 5m", http.StatusBadRequest)
+				return
+			}
+			window = d
+		}
+
+		report := telemetry.ComputeSLO(router.GetProviders(), window, telemetry.SLOTarget())
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.Error().Err(err).Msg("failed to encode slo report")
+		}
+	}
+}
+
 // HandleCanaryStatus returns detailed canary information
 func HandleCanaryStatus() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -148,9 +199,17 @@ func HandleCanaryStatus() http.HandlerFunc {
 			Percent:           e.CanaryPercent(),
 			Stage:             e.CanaryStageIndex(),
 			CandidateProvider: e.CanaryCandidateProvider(),
-			WindowSize:        e.CanaryWindowSize(),
+			WindowSize:        e.CanaryWindow().Calls,
+			MinDwell:          e.CanaryWindow().Dwell.String(),
 			LastTransition:    e.CanaryLastTransition(),
 			LastReason:        e.CanaryLastReason(),
+			Mode:              string(e.CanaryMode()),
+			Tenants:           e.CanaryTenants(),
+			MinSavingsPct:     e.CanaryMinSavingsPct(),
+			AdvanceBlocked:    e.CanaryAdvanceBlocked(),
+		}
+		if pct, ok := e.CanarySavingsPct(); ok {
+			resp.SavingsPct = &pct
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -161,6 +220,12 @@ func HandleCanaryStatus() http.HandlerFunc {
 	}
 }
 
+// writeBodyError answers a request body that didn't decode with a validation problem
+// carrying the parser's reason, e.g. the offending field and type
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	NewResponseWriter(w, r).WriteValidationError("body", "invalid JSON: "+err.Error())
+}
+
 // HandleCanaryAdvance advances canary to next stage with validation
 func HandleCanaryAdvance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -168,7 +233,7 @@ func HandleCanaryAdvance() http.HandlerFunc {
 			Force bool `json:"force"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			writeBodyError(w, r, err)
 			return
 		}
 
@@ -186,7 +251,7 @@ func HandleCanaryAdvance() http.HandlerFunc {
 				ps := router.GetProviders()
 				for _, p := range ps {
 					if p.Name() == candProvider {
-						burnRate := p.Stats().ErrorRate() / 0.01 // 1% SLO
+						burnRate := p.Stats().ErrorRate() / (1 - telemetry.SLOTarget())
 						if burnRate > 2.0 {
 							http.Error(w, fmt.Sprintf("canary burn rate too high: %.2f", burnRate), http.StatusPreconditionFailed)
 							return
@@ -205,17 +270,19 @@ func HandleCanaryAdvance() http.HandlerFunc {
 		newStage := e.CanaryStageIndex()
 		newPercent := e.CanaryPercent()
 
-		// Emit structured canary event log
-		log.Info().
-			Str("event", "canary_advance").
-			Str("provider", e.CanaryCandidateProvider()).
-			Int("old_stage", oldStage).
-			Int("new_stage", newStage).
-			Float64("old_percent", oldPercent).
-			Float64("new_percent", newPercent).
-			Bool("forced", body.Force).
-			Msg("canary stage advanced")
+		// Emit structured canary event log and push it to the event webhook
+		ev := map[string]any{
+			"provider":    e.CanaryCandidateProvider(),
+			"old_stage":   oldStage,
+			"new_stage":   newStage,
+			"old_percent": oldPercent,
+			"new_percent": newPercent,
+			"forced":      body.Force,
+		}
+		log.Info().Str("event", events.CanaryAdvance).Fields(ev).Msg("canary stage advanced")
+		events.Publish(events.CanaryAdvance, ev)
 
+		recordAudit(r, "canary_advance", canaryStageState{oldStage, oldPercent}, canaryStageState{newStage, newPercent})
 		telemetry.AdminActionsTotal.WithLabelValues("canary_advance").Inc()
 		telemetry.CanaryStage.Set(e.CanaryPercent())
 
@@ -237,17 +304,19 @@ func HandleCanaryRollback() http.HandlerFunc {
 
 		e.CanaryRollback()
 
-		// Emit structured canary event log
-		log.Info().
-			Str("event", "canary_rollback").
-			Str("provider", e.CanaryCandidateProvider()).
-			Int("old_stage", oldStage).
-			Int("new_stage", 0).
-			Float64("old_percent", oldPercent).
-			Float64("new_percent", e.CanaryPercent()).
-			Str("reason", "manual_rollback").
-			Msg("canary rolled back")
+		// Emit structured canary event log and push it to the event webhook
+		ev := map[string]any{
+			"provider":    e.CanaryCandidateProvider(),
+			"old_stage":   oldStage,
+			"new_stage":   0,
+			"old_percent": oldPercent,
+			"new_percent": e.CanaryPercent(),
+			"reason":      "manual_rollback",
+		}
+		log.Info().Str("event", events.CanaryRollback).Fields(ev).Msg("canary rolled back")
+		events.Publish(events.CanaryRollback, ev)
 
+		recordAudit(r, "canary_rollback", canaryStageState{oldStage, oldPercent}, canaryStageState{e.CanaryStageIndex(), e.CanaryPercent()})
 		telemetry.AdminActionsTotal.WithLabelValues("canary_rollback").Inc()
 		telemetry.CanaryStage.Set(e.CanaryPercent())
 
@@ -255,6 +324,135 @@ func HandleCanaryRollback() http.HandlerFunc {
 	}
 }
 
+// CanaryConfigRequest replaces the canary stages and tuning at runtime. Window (calls)
+// and MinDwell (a duration like 10m) each keep their current value when omitted; 0
+// drops that half of the evaluation window.
+type CanaryConfigRequest struct {
+	Stages         []float64 `json:"stages"`
+	Window         *int      `json:"window,omitempty"`
+	MinDwell       string    `json:"min_dwell,omitempty"`
+	BurnMultiplier float64   `json:"burn_multiplier,omitempty"`
+	Force          bool      `json:"force,omitempty"`
+}
+
+// CanaryConfigResponse represents the effective canary configuration
+type CanaryConfigResponse struct {
+	Stages         []float64 `json:"stages"`
+	Window         int       `json:"window"`
+	MinDwell       string    `json:"min_dwell"`
+	BurnMultiplier float64   `json:"burn_multiplier"`
+	Stage          int       `json:"stage_index"`
+	Percent        float64   `json:"percent"`
+}
+
+func canaryConfigResponse(e *router.Engine) CanaryConfigResponse {
+	window := e.CanaryWindow()
+	return CanaryConfigResponse{
+		Stages:         e.CanaryStages(),
+		Window:         window.Calls,
+		MinDwell:       window.Dwell.String(),
+		BurnMultiplier: e.CanaryBurnMultiplier(),
+		Stage:          e.CanaryStageIndex(),
+		Percent:        e.CanaryPercent(),
+	}
+}
+
+// HandleCanaryConfigGet returns the current canary configuration
+func HandleCanaryConfigGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := router.GetEngine()
+		if e == nil {
+			http.Error(w, "engine not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(canaryConfigResponse(e)); err != nil {
+			log.Error().Err(err).Msg("failed to encode canary config response")
+		}
+	}
+}
+
+// HandleCanaryConfig replaces canary stages, window, and burn multiplier at runtime
+func HandleCanaryConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body CanaryConfigRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeBodyError(w, r, err)
+			return
+		}
+
+		if err := router.ValidateCanaryStages(body.Stages); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.BurnMultiplier < 0 {
+			http.Error(w, "burn_multiplier must be positive", http.StatusBadRequest)
+			return
+		}
+
+		e := router.GetEngine()
+		if e == nil {
+			http.Error(w, "engine not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		window := e.CanaryWindow()
+		if body.Window != nil {
+			if *body.Window < 0 {
+				http.Error(w, "window must not be negative", http.StatusBadRequest)
+				return
+			}
+			window.Calls = *body.Window
+		}
+		if body.MinDwell != "" {
+			d, err := time.ParseDuration(body.MinDwell)
+			if err != nil || d < 0 {
+				http.Error(w, "min_dwell must be a duration like 10m", http.StatusBadRequest)
+				return
+			}
+			window.Dwell = d
+		}
+		if window.Calls == 0 && window.Dwell == 0 {
+			http.Error(w, "window and min_dwell can't both be 0", http.StatusBadRequest)
+			return
+		}
+
+		// Shrinking the stage list below the active stage drops traffic back; require force
+		before := canaryConfigResponse(e)
+		oldStage := before.Stage
+		oldStages := before.Stages
+		if oldStage >= len(body.Stages) && !body.Force {
+			http.Error(w, fmt.Sprintf("new stages would drop current stage %d; set force to apply", oldStage), http.StatusPreconditionFailed)
+			return
+		}
+
+		e.ConfigureCanary(body.Stages, window, body.BurnMultiplier)
+		resp := canaryConfigResponse(e)
+
+		log.Info().
+			Str("event", "canary_config").
+			Floats64("old_stages", oldStages).
+			Floats64("new_stages", resp.Stages).
+			Int("old_stage", oldStage).
+			Int("new_stage", resp.Stage).
+			Int("window", resp.Window).
+			Str("min_dwell", resp.MinDwell).
+			Float64("burn_multiplier", resp.BurnMultiplier).
+			Bool("forced", body.Force).
+			Msg("canary config updated")
+
+		recordAudit(r, "canary_config", before, resp)
+		telemetry.AdminActionsTotal.WithLabelValues("canary_config").Inc()
+		telemetry.CanaryStage.Set(resp.Percent)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode canary config response")
+		}
+	}
+}
+
 // HandlePolicyUpdate updates the default policy
 func HandlePolicyUpdate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -262,7 +460,7 @@ func HandlePolicyUpdate() http.HandlerFunc {
 			DefaultPolicy string `json:"default_policy"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			writeBodyError(w, r, err)
 			return
 		}
 
@@ -272,6 +470,7 @@ func HandlePolicyUpdate() http.HandlerFunc {
 			"fastest_p95":    true,
 			"slo_burn_aware": true,
 			"canary":         true,
+			"latency_slo":    true,
 		}
 
 		if !validPolicies[body.DefaultPolicy] {
@@ -288,32 +487,422 @@ func HandlePolicyUpdate() http.HandlerFunc {
 			Str("new_policy", body.DefaultPolicy).
 			Msg("default policy updated")
 
+		recordAudit(r, "set_policy", map[string]string{"default_policy": oldPolicy}, map[string]string{"default_policy": body.DefaultPolicy})
 		telemetry.AdminActionsTotal.WithLabelValues("set_policy").Inc()
 
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
-// HandleProvidersReload is a placeholder for future provider hot-reload
+// ProvidersReloadResponse lists the providers in rotation after a reload and which
+// of them kept their previous stats and breaker
+type ProvidersReloadResponse struct {
+	Providers     []string `json:"providers"`
+	PreserveState bool     `json:"preserve_state"`
+	Preserved     []string `json:"preserved"`
+}
+
+// HandleProvidersReload rebuilds providers from the current environment and .env file
+// and swaps them into routing. With preserve_state a rebuilt provider keeps the stats
+// and circuit breaker of the old provider with its name, so a reload is a soft
+// reconfig that can't hide an ongoing outage; without it every provider starts clean.
+// Admin disable/enable state is not carried over.
 func HandleProvidersReload() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			PreserveState bool `json:"preserve_state"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeBodyError(w, r, err)
+			return
+		}
+		e := router.GetEngine()
+		if e == nil {
+			http.Error(w, "engine not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		old := router.GetProviders()
+		provs := BuildProviders(config.Load())
+		if len(provs) == 0 {
+			http.Error(w, "reload found no configured providers; keeping the current ones", http.StatusConflict)
+			return
+		}
+		resp := ProvidersReloadResponse{PreserveState: body.PreserveState, Providers: []string{}, Preserved: []string{}}
+		for _, p := range provs {
+			resp.Providers = append(resp.Providers, p.Name())
+			if !body.PreserveState {
+				continue
+			}
+			for _, o := range old {
+				if o.Name() == p.Name() {
+					p.AdoptState(o)
+					resp.Preserved = append(resp.Preserved, p.Name())
+					break
+				}
+			}
+		}
+		before := make([]string, 0, len(old))
+		for _, o := range old {
+			before = append(before, o.Name())
+		}
+		e.SetProviders(provs)
+		router.SetProviders(provs)
+		for _, p := range provs {
+			telemetry.CBState.WithLabelValues(p.Name()).Set(p.CBStateValue())
+		}
+
 		log.Info().
 			Str("event", "providers_reload").
-			Str("status", "not_implemented").
-			Msg("providers reload requested")
-
+			Strs("before", before).
+			Strs("after", resp.Providers).
+			Bool("preserve_state", body.PreserveState).
+			Strs("preserved", resp.Preserved).
+			Msg("providers reloaded")
+		recordAudit(r, "providers_reload", map[string]any{"providers": before}, map[string]any{"providers": resp.Providers, "preserve_state": body.PreserveState})
 		telemetry.AdminActionsTotal.WithLabelValues("providers_reload").Inc()
-		http.Error(w, "not implemented", http.StatusNotImplemented)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode reload response")
+		}
+	}
+}
+
+// HandleDrain fails readiness so the load balancer stops routing here while in-flight
+// requests finish; poll until in_flight reaches 0 before terminating
+func HandleDrain() http.HandlerFunc {
+	return handleSetDraining(true)
+}
+
+// HandleUndrain makes a drained instance ready again
+func HandleUndrain() http.HandlerFunc {
+	return handleSetDraining(false)
+}
+
+func handleSetDraining(on bool) http.HandlerFunc {
+	action := "undrain"
+	if on {
+		action = "drain"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		was := Draining()
+		SetDraining(on)
+		resp := DrainResponse{Draining: on, InFlight: inferHeartbeat.InFlight()}
+
+		log.Info().
+			Str("event", action).
+			Bool("was_draining", was).
+			Bool("draining", on).
+			Int("in_flight", resp.InFlight).
+			Msg("drain state updated")
+		recordAudit(r, action, map[string]any{"draining": was}, map[string]any{"draining": on})
+		telemetry.AdminActionsTotal.WithLabelValues(action).Inc()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode drain response")
+		}
+	}
+}
+
+// HandleProviderDisable removes a provider from routing rotation
+func HandleProviderDisable() http.HandlerFunc {
+	return handleProviderSetEnabled(false)
+}
+
+// HandleProviderEnable returns a disabled provider to routing rotation
+func HandleProviderEnable() http.HandlerFunc {
+	return handleProviderSetEnabled(true)
+}
+
+func handleProviderSetEnabled(enabled bool) http.HandlerFunc {
+	action := "provider_disable"
+	if enabled {
+		action = "provider_enable"
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "provider name required", http.StatusBadRequest)
+			return
+		}
+
+		var found, wasEnabled bool
+		for _, p := range router.GetProviders() {
+			if p.Name() != name {
+				continue
+			}
+			found = true
+			wasEnabled = p.Enabled()
+			p.SetEnabled(enabled)
+
+			log.Info().
+				Str("event", action).
+				Str("provider", name).
+				Bool("was_enabled", wasEnabled).
+				Bool("enabled", enabled).
+				Msg("provider rotation updated")
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("unknown provider %q", name), http.StatusNotFound)
+			return
+		}
+
+		recordAudit(r, action,
+			map[string]any{"provider": name, "enabled": wasEnabled},
+			map[string]any{"provider": name, "enabled": enabled})
+		telemetry.AdminActionsTotal.WithLabelValues(action).Inc()
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleProviderCircuit forces a provider's circuit breaker open ({action} = open),
+// pulling it from routing until reset, or closes it ({action} = reset)
+func HandleProviderCircuit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, op := chi.URLParam(r, "name"), chi.URLParam(r, "action")
+		if op != "open" && op != "reset" {
+			http.Error(w, "action must be open or reset", http.StatusBadRequest)
+			return
+		}
+		action := "provider_cb_" + op
+
+		var target *providers.ResilientProvider
+		for _, p := range router.GetProviders() {
+			if p.Name() == name {
+				target = p
+			}
+		}
+		if target == nil {
+			http.Error(w, fmt.Sprintf("unknown provider %q", name), http.StatusNotFound)
+			return
+		}
+
+		before := target.CBStateValue()
+		if op == "open" {
+			target.ForceOpenCB()
+		} else {
+			target.ResetCB()
+		}
+		after := target.CBStateValue()
+		telemetry.CBState.WithLabelValues(name).Set(after)
+
+		log.Info().
+			Str("event", action).
+			Str("provider", name).
+			Float64("cb_state_before", before).
+			Float64("cb_state", after).
+			Msg("circuit breaker set manually")
+		recordAudit(r, action,
+			map[string]any{"provider": name, "cb_state": before},
+			map[string]any{"provider": name, "cb_state": after})
+		telemetry.AdminActionsTotal.WithLabelValues(action).Inc()
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RoutingConfigResponse is the effective routing configuration in one place
+type RoutingConfigResponse struct {
+	DefaultPolicy string  `json:"default_policy"`
+	SLOTarget     float64 `json:"slo_target"`
+	FastestP95    struct {
+		MinSamples int    `json:"min_samples"`
+		HalfLife   string `json:"half_life"`
+	} `json:"fastest_p95"`
+	SLOBurnAware struct {
+		ErrorBudget float64 `json:"error_budget"`
+	} `json:"slo_burn_aware"`
+	LatencySLO struct {
+		TargetP95 string `json:"target_p95"`
+	} `json:"latency_slo"`
+	Canary     RoutingCanary `json:"canary"`
+	Stickiness struct {
+		TTL              string `json:"ttl"` // 0s when off
+		MaxConversations int    `json:"max_conversations"`
+	} `json:"stickiness"`
+	Providers []RoutingProvider `json:"providers"`
+}
+
+// RoutingCanary is the canary configuration plus its live split
+type RoutingCanary struct {
+	CanaryConfigResponse
+	Candidate   string   `json:"candidate_provider"`
+	Mode        string   `json:"mode"`
+	MaxP95Ratio float64  `json:"max_p95_ratio"`
+	MinSavings  float64  `json:"min_savings_pct"`
+	Tenants     []string `json:"tenants"`
+}
+
+// RoutingProvider is one provider's standing in rotation
+type RoutingProvider struct {
+	Name         string  `json:"name"`
+	Enabled      bool    `json:"enabled"`
+	CBState      float64 `json:"cb_state"`
+	CBForcedOpen bool    `json:"cb_forced_open"`
+	CostPer1k    float64 `json:"cost_per_1k_tokens_usd"`
+}
+
+// HandleRoutingConfig reports the live default policy, per-policy parameters, canary
+// state, SLO target, stickiness and provider rotation; it changes nothing
+func HandleRoutingConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := router.GetEngine()
+		if e == nil {
+			http.Error(w, "engine not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		resp := RoutingConfigResponse{
+			DefaultPolicy: router.GetDefaultPolicy(),
+			SLOTarget:     telemetry.SLOTarget(),
+			Canary: RoutingCanary{
+				CanaryConfigResponse: canaryConfigResponse(e),
+				Candidate:            e.CanaryCandidateProvider(),
+				Mode:                 string(e.CanaryMode()),
+				MaxP95Ratio:          e.CanaryMaxP95Ratio(),
+				MinSavings:           e.CanaryMinSavingsPct(),
+				Tenants:              e.CanaryTenants(),
+			},
+			Providers: []RoutingProvider{},
+		}
+		minSamples, halfLife := e.FastestP95Options()
+		resp.FastestP95.MinSamples = minSamples
+		resp.FastestP95.HalfLife = halfLife.String()
+		resp.SLOBurnAware.ErrorBudget = e.ErrorBudget()
+		resp.LatencySLO.TargetP95 = e.LatencySLO().String()
+		ttl, maxConversations := e.Stickiness()
+		resp.Stickiness.TTL = ttl.String()
+		resp.Stickiness.MaxConversations = maxConversations
+		for _, p := range router.GetProviders() {
+			resp.Providers = append(resp.Providers, RoutingProvider{
+				Name:         p.Name(),
+				Enabled:      p.Enabled(),
+				CBState:      p.CBStateValue(),
+				CBForcedOpen: p.CBForcedOpen(),
+				CostPer1k:    p.CostPer1kTokensUSD(""),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode routing config")
+		}
+	}
+}
+
+// HandleRouteExplain reports which provider a policy would pick and the signals behind it
+func HandleRouteExplain() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := router.GetEngine()
+		if e == nil {
+			http.Error(w, "engine not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		policy := r.URL.Query().Get("policy")
+		if policy == "" {
+			policy = router.GetDefaultPolicy()
+		}
+		if !config.IsValidPolicy(policy) {
+			http.Error(w, "invalid policy", http.StatusBadRequest)
+			return
+		}
+
+		resp := e.Explain(policy, r.URL.Query().Get("model"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode route explanation")
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// maxSimulationRuns bounds ?n= on the policy simulation endpoint
+const maxSimulationRuns = 100000
+
+// HandlePolicySimulate runs a policy ?n= times (default 1000) against the live provider
+// stats and reports the providers it picked, without consuming canary traffic
+func HandlePolicySimulate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		e := router.GetEngine()
+		if e == nil {
+			http.Error(w, "engine not ready", http.StatusServiceUnavailable)
+			return
+		}
+
+		policy := r.URL.Query().Get("policy")
+		if policy == "" {
+			policy = router.GetDefaultPolicy()
+		}
+		if !config.IsValidPolicy(policy) {
+			http.Error(w, "invalid policy", http.StatusBadRequest)
+			return
+		}
+
+		n := 1000
+		if s := r.URL.Query().Get("n"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v <= 0 || v > maxSimulationRuns {
+				http.Error(w, fmt.Sprintf("n must be between 1 and %d", maxSimulationRuns), http.StatusBadRequest)
+				return
+			}
+			n = v
+		}
+
+		resp := e.Simulate(policy, r.URL.Query().Get("model"), n)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode policy simulation")
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// HandleAuditList returns audit entries at or after ?since= (RFC3339), oldest first, up to ?limit=
+func HandleAuditList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				http.Error(w, "invalid since (RFC3339)", http.StatusBadRequest)
+				return
+			}
+			since = t
+		}
+
+		limit := 100
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 || n > 1000 {
+				http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(audit.GetLog().Since(since, limit)); err != nil {
+			log.Error().Err(err).Msg("failed to encode audit response")
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
 	}
 }
 
 // CreateTenantRequest represents the request to create a new tenant
 type CreateTenantRequest struct {
-	Name            string `json:"name"`
-	Plan            string `json:"plan"`
-	RPSLimit        int    `json:"rps_limit"`
-	DailyTokenLimit int64  `json:"daily_token_limit"`
-	Enabled         bool   `json:"enabled"`
+	Name            string   `json:"name"`
+	Plan            string   `json:"plan"`
+	RPSLimit        int      `json:"rps_limit"`
+	DailyTokenLimit int64    `json:"daily_token_limit"`
+	Enabled         bool     `json:"enabled"`
+	AllowedModels   []string `json:"allowed_models,omitempty"`
+	DeniedProviders []string `json:"denied_providers,omitempty"`
 }
 
 // CreateTenantResponse represents the response when creating a tenant
@@ -351,7 +940,7 @@ func (th *TenantHandlers) HandleCreateTenant() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req CreateTenantRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			writeBodyError(w, r, err)
 			return
 		}
 
@@ -360,33 +949,12 @@ func (th *TenantHandlers) HandleCreateTenant() http.HandlerFunc {
 			http.Error(w, "name and plan are required", http.StatusBadRequest)
 			return
 		}
-
-		// Set defaults
-		if req.RPSLimit <= 0 {
-			switch req.Plan {
-			case "free":
-				req.RPSLimit = 10
-			case "pro":
-				req.RPSLimit = 100
-			case "enterprise":
-				req.RPSLimit = 1000
-			default:
-				req.RPSLimit = 10
-			}
+		if err := ValidateTenantAccessLists(req.AllowedModels, req.DeniedProviders); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		if req.DailyTokenLimit <= 0 {
-			switch req.Plan {
-			case "free":
-				req.DailyTokenLimit = 10000
-			case "pro":
-				req.DailyTokenLimit = 1000000
-			case "enterprise":
-				req.DailyTokenLimit = 10000000
-			default:
-				req.DailyTokenLimit = 10000
-			}
-		}
+		applyTenantDefaults(&req)
 
 		tenant, apiKey, err := th.keyManager.CreateTenant(
 			r.Context(),
@@ -394,6 +962,8 @@ func (th *TenantHandlers) HandleCreateTenant() http.HandlerFunc {
 			req.Plan,
 			req.RPSLimit,
 			req.DailyTokenLimit,
+			req.AllowedModels,
+			req.DeniedProviders,
 		)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to create tenant")
@@ -408,6 +978,8 @@ func (th *TenantHandlers) HandleCreateTenant() http.HandlerFunc {
 			Str("plan", tenant.Plan).
 			Msg("tenant created")
 
+		// never audit the API key itself
+		recordAudit(r, "tenant_create", nil, tenant)
 		telemetry.AdminActionsTotal.WithLabelValues("tenant_create").Inc()
 
 		response := CreateTenantResponse{
@@ -423,6 +995,150 @@ func (th *TenantHandlers) HandleCreateTenant() http.HandlerFunc {
 	}
 }
 
+// applyTenantDefaults fills in the plan's limits where req leaves them unset
+func applyTenantDefaults(req *CreateTenantRequest) {
+	if req.RPSLimit <= 0 {
+		switch req.Plan {
+		case "free":
+			req.RPSLimit = 10
+		case "pro":
+			req.RPSLimit = 100
+		case "enterprise":
+			req.RPSLimit = 1000
+		default:
+			req.RPSLimit = 10
+		}
+	}
+
+	if req.DailyTokenLimit <= 0 {
+		switch req.Plan {
+		case "free":
+			req.DailyTokenLimit = 10000
+		case "pro":
+			req.DailyTokenLimit = 1000000
+		case "enterprise":
+			req.DailyTokenLimit = 10000000
+		default:
+			req.DailyTokenLimit = 10000
+		}
+	}
+}
+
+// maxTenantImport caps the rows one import request may carry
+const maxTenantImport = 1000
+
+// TenantImportResult is the outcome of one import row: the new tenant and its API key,
+// shown only here, or why the row was rejected
+type TenantImportResult struct {
+	Index    int          `json:"index"`
+	Name     string       `json:"name"`
+	TenantID string       `json:"tenant_id,omitempty"`
+	APIKey   string       `json:"api_key,omitempty"`
+	Tenant   *auth.Tenant `json:"tenant,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// TenantImportRow is one tenant of an import: a create request that also keeps the
+// enabled flag and priority of an exported tenant
+type TenantImportRow struct {
+	CreateTenantRequest
+	Enabled  *bool  `json:"enabled,omitempty"`  // absent imports the tenant enabled
+	Priority string `json:"priority,omitempty"` // high, normal or low; empty derives it from the plan
+}
+
+// TenantImportResponse reports every row of an import
+type TenantImportResponse struct {
+	Created int                  `json:"created"`
+	Failed  int                  `json:"failed"`
+	Results []TenantImportResult `json:"results"`
+}
+
+// HandleImportTenants creates a tenant with a fresh API key for each definition in a
+// JSON array, keeping its enabled flag and priority so an export imports back as it
+// was. Rows stand alone: an invalid or failed row is reported and the rest are
+// still created, so a partial import can be fixed up by resubmitting the failures.
+func (th *TenantHandlers) HandleImportTenants() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var reqs []TenantImportRow
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			writeBodyError(w, r, fmt.Errorf("expected an array of tenants: %w", err))
+			return
+		}
+		if len(reqs) == 0 || len(reqs) > maxTenantImport {
+			http.Error(w, fmt.Sprintf("import between 1 and %d tenants", maxTenantImport), http.StatusBadRequest)
+			return
+		}
+
+		resp := TenantImportResponse{Results: make([]TenantImportResult, 0, len(reqs))}
+		for i, row := range reqs {
+			req := row.CreateTenantRequest
+			res := TenantImportResult{Index: i, Name: req.Name}
+			applyTenantDefaults(&req)
+			err := ValidateCreateTenantRequest(&req)
+			if _, ok := admission.ParsePriority(row.Priority); err == nil && row.Priority != "" && !ok {
+				err = fmt.Errorf("priority must be high, normal or low, got %q", row.Priority)
+			}
+			if err != nil {
+				res.Error = err.Error()
+				resp.Failed++
+				resp.Results = append(resp.Results, res)
+				continue
+			}
+			tenant, apiKey, err := th.keyManager.ImportTenant(r.Context(), auth.Tenant{
+				Name:            req.Name,
+				Plan:            req.Plan,
+				RPSLimit:        req.RPSLimit,
+				DailyTokenLimit: req.DailyTokenLimit,
+				Enabled:         row.Enabled == nil || *row.Enabled,
+				AllowedModels:   req.AllowedModels,
+				DeniedProviders: req.DeniedProviders,
+				Priority:        row.Priority,
+			})
+			if err != nil {
+				log.Error().Err(err).Str("name", req.Name).Msg("failed to import tenant")
+				res.Error = "failed to create tenant"
+				resp.Failed++
+				resp.Results = append(resp.Results, res)
+				continue
+			}
+			recordAudit(r, "tenant_import", nil, tenant)
+			res.TenantID, res.APIKey, res.Tenant = tenant.TenantID, apiKey, tenant
+			resp.Created++
+			resp.Results = append(resp.Results, res)
+		}
+
+		log.Info().
+			Str("event", "tenant_import").
+			Int("created", resp.Created).
+			Int("failed", resp.Failed).
+			Msg("tenants imported")
+		telemetry.AdminActionsTotal.WithLabelValues("tenant_import").Inc()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode tenant import response")
+		}
+	}
+}
+
+// HandleExportTenants returns every tenant without key material, in the shape the
+// import accepts plus identifiers and timestamps
+func (th *TenantHandlers) HandleExportTenants() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenants, err := th.keyManager.ListTenants(r.Context())
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list tenants")
+			http.Error(w, "failed to list tenants", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tenants); err != nil {
+			log.Error().Err(err).Msg("failed to encode tenant export response")
+		}
+	}
+}
+
 // HandleGetTenantUsage returns usage data for a specific tenant
 func (th *TenantHandlers) HandleGetTenantUsage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
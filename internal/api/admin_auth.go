@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/audit"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+)
+
+// AdminRole orders admin permissions; each role includes those below it
+type AdminRole int
+
+const (
+	RoleViewer   AdminRole = iota + 1 // read-only status and config
+	RoleOperator                      // canary, policy and provider rotation changes
+	RoleAdmin                         // tenants and the audit trail
+)
+
+func (r AdminRole) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// ParseAdminRole maps a role name to its AdminRole
+func ParseAdminRole(s string) (AdminRole, error) {
+	switch s {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("unknown admin role %q", s)
+}
+
+// AdminToken is a bearer token with a name used as the audit actor
+type AdminToken struct {
+	Name  string
+	Token string
+	Role  AdminRole
+}
+
+// LoadAdminTokens reads [{"name": "...", "token": "...", "role": "viewer|operator|admin"}]
+func LoadAdminTokens(path string) ([]AdminToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Name  string `json:"name"`
+		Token string `json:"token"`
+		Role  string `json:"role"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	tokens := make([]AdminToken, 0, len(entries))
+	for i, e := range entries {
+		if e.Name == "" || e.Token == "" {
+			return nil, fmt.Errorf("%s: entry %d needs name and token", path, i)
+		}
+		role, err := ParseAdminRole(e.Role)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i, err)
+		}
+		tokens = append(tokens, AdminToken{Name: e.Name, Token: e.Token, Role: role})
+	}
+	return tokens, nil
+}
+
+// AdminTokensFromConfig combines ADMIN_TOKEN (as an admin role) with ADMIN_TOKENS_JSON
+func AdminTokensFromConfig(cfg config.Config) ([]AdminToken, error) {
+	var tokens []AdminToken
+	if cfg.AdminToken != "" {
+		// the legacy token has no name, so a fingerprint identifies it in the audit log
+		sum := sha256.Sum256([]byte(cfg.AdminToken))
+		tokens = append(tokens, AdminToken{Name: "admin-token:" + hex.EncodeToString(sum[:4]), Token: cfg.AdminToken, Role: RoleAdmin})
+	}
+	if cfg.AdminTokensJSONPath != "" {
+		fromFile, err := LoadAdminTokens(cfg.AdminTokensJSONPath)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, fromFile...)
+	}
+	return tokens, nil
+}
+
+type contextKey string
+
+const adminRoleKey contextKey = "admin-role"
+
+// AdminAuth requires "Authorization: Bearer <token>" matching one of tokens and
+// records the token's name as the audit actor and its role for RequireRole
+func AdminAuth(tokens []AdminToken) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			const prefix = "Bearer "
+			if len(auth) <= len(prefix) || auth[:len(prefix)] != prefix {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			presented := []byte(auth[len(prefix):])
+			var match *AdminToken
+			for i := range tokens {
+				// compare against every token so timing doesn't reveal which one matched
+				if subtle.ConstantTimeCompare(presented, []byte(tokens[i].Token)) == 1 && match == nil {
+					match = &tokens[i]
+				}
+			}
+			if match == nil {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			ctx := audit.WithActor(r.Context(), match.Name)
+			ctx = context.WithValue(ctx, adminRoleKey, match.Role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole rejects authenticated callers below role with 403
+func RequireRole(role AdminRole) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			have, _ := r.Context().Value(adminRoleKey).(AdminRole)
+			if have < role {
+				http.Error(w, fmt.Sprintf("forbidden: requires %s role", role), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewAdminRouter builds the /v1/admin routes with per-endpoint role requirements
+func NewAdminRouter(tokens []AdminToken) chi.Router {
+	admin := chi.NewRouter()
+	admin.Use(AdminAuth(tokens))
+
+	view := admin.With(RequireRole(RoleViewer))
+	view.Get("/status", HandleAdminStatus())
+	view.Get("/slo", HandleAdminSLO())
+	view.Get("/canary/status", HandleCanaryStatus())
+	view.Get("/canary/config", HandleCanaryConfigGet())
+	view.Get("/route/explain", HandleRouteExplain())
+	view.Get("/policy/simulate", HandlePolicySimulate())
+	view.Get("/routing", HandleRoutingConfig())
+
+	operate := admin.With(RequireRole(RoleOperator))
+	operate.Post("/canary/advance", HandleCanaryAdvance())
+	operate.Post("/canary/rollback", HandleCanaryRollback())
+	operate.Post("/canary/config", HandleCanaryConfig())
+	operate.Post("/policy", HandlePolicyUpdate())
+	operate.Post("/providers/reload", HandleProvidersReload())
+	operate.Post("/providers/{name}/disable", HandleProviderDisable())
+	operate.Post("/providers/{name}/enable", HandleProviderEnable())
+	operate.Post("/providers/{name}/cb/{action}", HandleProviderCircuit())
+	operate.Post("/selftest", HandleAdminSelftest())
+	operate.Post("/drain", HandleDrain())
+	operate.Post("/undrain", HandleUndrain())
+
+	full := admin.With(RequireRole(RoleAdmin))
+	full.Get("/audit", HandleAuditList())
+
+	return admin
+}
@@ -1,12 +1,24 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/audit"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
@@ -153,6 +165,55 @@ func TestAdminStatus(t *testing.T) {
 	}
 }
 
+func TestAdminSLO(t *testing.T) {
+	prev := telemetry.SLOTarget()
+	defer telemetry.SetSLOTarget(prev)
+	telemetry.SetSLOTarget(0.98)
+
+	a := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "a"}), providers.ResilienceOptions{})
+	b := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "b"}), providers.ResilienceOptions{})
+	for i := 1; i <= 60; i++ {
+		a.Stats().Record(int64(i), false)
+	}
+	for i := 0; i < 39; i++ {
+		b.Stats().Record(int64(100+i), false)
+	}
+	b.Stats().Record(5000, true)
+	router.SetProviders([]*providers.ResilientProvider{a, b})
+
+	rr := httptest.NewRecorder()
+	HandleAdminSLO().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/slo?window=1h", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var report telemetry.SLOReport
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 error in 100 calls against a 2% budget burns at half speed
+	if report.Requests != 100 || report.Errors != 1 {
+		t.Errorf("expected 100 requests with 1 error, got %d/%d", report.Requests, report.Errors)
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 1e-9 }
+	if !near(report.Availability, 0.99) || !near(report.ErrorRate, 0.01) {
+		t.Errorf("expected availability 0.99 and error rate 0.01, got %v and %v", report.Availability, report.ErrorRate)
+	}
+	if !near(report.BurnRate, 0.5) || !near(report.BudgetRemainingPct, 50) {
+		t.Errorf("expected burn 0.5 with 50%% budget left, got %v and %v", report.BurnRate, report.BudgetRemainingPct)
+	}
+	// the 95th of 99 successful latencies (1..60 then 100..138), ignoring the failed call
+	if report.P95LatencyMs != 134 {
+		t.Errorf("expected fleet p95 134ms, got %d", report.P95LatencyMs)
+	}
+
+	rr = httptest.NewRecorder()
+	HandleAdminSLO().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/slo?window=soon", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad window, got %d", rr.Code)
+	}
+}
+
 func TestCanaryStatus(t *testing.T) {
 	// Setup test engine
 	mockProvider1 := providers.NewMockProvider(50, 100, 0.01, 0.001)
@@ -169,7 +230,7 @@ func TestCanaryStatus(t *testing.T) {
 
 	provs := []*providers.ResilientProvider{rp1, rp2}
 	eng := router.NewEngine(provs)
-	eng.ConfigureCanary([]float64{1, 5, 25}, 200, 2.0)
+	eng.ConfigureCanary([]float64{1, 5, 25}, router.CanaryWindow{Calls: 200}, 2.0)
 	router.SetEngine(eng)
 
 	req := httptest.NewRequest(http.MethodGet, "/v1/admin/canary/status", nil)
@@ -218,7 +279,7 @@ func TestCanaryAdvance(t *testing.T) {
 
 	provs := []*providers.ResilientProvider{rp1, rp2}
 	eng := router.NewEngine(provs)
-	eng.ConfigureCanary([]float64{1, 5, 25}, 200, 2.0)
+	eng.ConfigureCanary([]float64{1, 5, 25}, router.CanaryWindow{Calls: 200}, 2.0)
 	router.SetEngine(eng)
 
 	// Test force advance
@@ -243,6 +304,58 @@ func TestCanaryAdvance(t *testing.T) {
 	}
 }
 
+func TestCanaryAdvanceWebhook(t *testing.T) {
+	got := make(chan events.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e events.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		got <- e
+	}))
+	defer srv.Close()
+	hook := events.NewWebhook(srv.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go hook.Run(ctx)
+	events.SetWebhook(hook)
+	defer events.SetWebhook(nil)
+
+	cheap := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "cheap", CostPer1k: 0.001}), providers.ResilienceOptions{})
+	cand := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "cand", CostPer1k: 0.002}), providers.ResilienceOptions{})
+	eng := router.NewEngine([]*providers.ResilientProvider{cheap, cand})
+	eng.ConfigureCanary([]float64{1, 5, 25}, router.CanaryWindow{Calls: 200}, 2.0)
+	router.SetEngine(eng)
+
+	rr := httptest.NewRecorder()
+	HandleCanaryAdvance().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/canary/advance", strings.NewReader(`{"force": true}`)))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+
+	select {
+	case e := <-got:
+		if e.Type != events.CanaryAdvance {
+			t.Errorf("expected %s, got %s", events.CanaryAdvance, e.Type)
+		}
+		want := map[string]any{
+			"provider":    eng.CanaryCandidateProvider(),
+			"old_stage":   float64(0),
+			"new_stage":   float64(1),
+			"old_percent": float64(1),
+			"new_percent": float64(5),
+			"forced":      true,
+		}
+		for k, v := range want {
+			if e.Data[k] != v {
+				t.Errorf("%s: expected %v, got %v", k, v, e.Data[k])
+			}
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("canary advance not delivered to the webhook")
+	}
+}
+
 func TestCanaryRollback(t *testing.T) {
 	// Setup test engine
 	mockProvider1 := providers.NewMockProvider(50, 100, 0.01, 0.001)
@@ -259,7 +372,7 @@ func TestCanaryRollback(t *testing.T) {
 
 	provs := []*providers.ResilientProvider{rp1, rp2}
 	eng := router.NewEngine(provs)
-	eng.ConfigureCanary([]float64{1, 5, 25}, 200, 2.0)
+	eng.ConfigureCanary([]float64{1, 5, 25}, router.CanaryWindow{Calls: 200}, 2.0)
 	router.SetEngine(eng)
 
 	// Advance to stage 1 first
@@ -346,18 +459,805 @@ func TestPolicyUpdate(t *testing.T) {
 	}
 }
 
+func TestAdminMalformedBodyIsProblem(t *testing.T) {
+	th := NewTenantHandlers(nil, nil)
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		detail  string
+	}{
+		{"canary advance", HandleCanaryAdvance(), `{"force": "yes"}`, "force"},
+		{"policy update", HandlePolicyUpdate(), `{"default_policy": `, "unexpected EOF"},
+		{"create tenant", th.HandleCreateTenant(), `{"name": "acme", "rps_limit": "ten"}`, "rps_limit"},
+		{"import tenants", th.HandleImportTenants(), `{"tenant_id": "t1"}`, "expected an array of tenants"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/admin", strings.NewReader(tt.body))
+			req.Header.Set("X-Request-ID", "req-malformed")
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("expected application/problem+json, got %q", ct)
+			}
+			var p Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decode problem: %v: %s", err, rr.Body.String())
+			}
+			if p.Type != ProblemTypeValidation || p.RequestID != "req-malformed" {
+				t.Errorf("expected a validation problem for req-malformed, got %+v", p)
+			}
+			if !strings.Contains(p.Detail, tt.detail) {
+				t.Errorf("expected the detail to mention %q, got %q", tt.detail, p.Detail)
+			}
+		})
+	}
+}
+
+func TestRoutingConfigReflectsPolicyUpdate(t *testing.T) {
+	a := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "a", CostPer1k: 0.001}), providers.ResilienceOptions{CBWindowSize: 20})
+	b := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "b", CostPer1k: 0.002}), providers.ResilienceOptions{CBWindowSize: 20})
+	provs := []*providers.ResilientProvider{a, b}
+	router.SetProviders(provs)
+	defer router.SetProviders(nil)
+	eng := router.NewEngine(provs)
+	eng.SetStickiness(time.Minute, 50)
+	router.SetEngine(eng)
+	router.SetDefaultPolicy("cheapest")
+	defer router.SetDefaultPolicy("cheapest")
+	b.SetEnabled(false)
+
+	routing := func() RoutingConfigResponse {
+		rr := httptest.NewRecorder()
+		HandleRoutingConfig().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/routing", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp RoutingConfigResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	if got := routing().DefaultPolicy; got != "cheapest" {
+		t.Fatalf("expected cheapest before the update, got %q", got)
+	}
+	rr := httptest.NewRecorder()
+	HandlePolicyUpdate().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/policy", strings.NewReader(`{"default_policy": "slo_burn_aware"}`)))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("policy update: expected 204, got %d", rr.Code)
+	}
+
+	resp := routing()
+	if resp.DefaultPolicy != "slo_burn_aware" {
+		t.Errorf("expected the updated policy, got %q", resp.DefaultPolicy)
+	}
+	if resp.Canary.Candidate != "b" || resp.Canary.Percent != 1 || len(resp.Canary.Stages) != 3 {
+		t.Errorf("expected the default canary with b as candidate, got %+v", resp.Canary)
+	}
+	if resp.FastestP95.MinSamples != router.DefaultMinP95Samples || resp.Stickiness.TTL != "1m0s" || resp.Stickiness.MaxConversations != 50 {
+		t.Errorf("unexpected policy parameters %+v %+v", resp.FastestP95, resp.Stickiness)
+	}
+	want := []RoutingProvider{
+		{Name: "a", Enabled: true, CBState: 2, CostPer1k: 0.001},
+		{Name: "b", Enabled: false, CBState: 2, CostPer1k: 0.002},
+	}
+	if len(resp.Providers) != 2 || resp.Providers[0] != want[0] || resp.Providers[1] != want[1] {
+		t.Errorf("expected %+v, got %+v", want, resp.Providers)
+	}
+}
+
 func TestProvidersReload(t *testing.T) {
-	req := httptest.NewRequest(http.MethodPost, "/v1/admin/providers/reload", nil)
+	t.Setenv("ENABLE_MOCK_PROVIDER", "1")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("LOCAL_LLM_BASE_URL", "")
+
+	for _, preserve := range []bool{true, false} {
+		t.Run(fmt.Sprintf("preserve_state=%v", preserve), func(t *testing.T) {
+			old := providers.WithResilience(providers.NewMockProvider(10, 20, 0, 0.001), providers.ResilienceOptions{CBWindowSize: 10})
+			old.Stats().Record(10, true)
+			old.ForceOpenCB()
+			router.SetProviders([]*providers.ResilientProvider{old})
+			router.SetEngine(router.NewEngine([]*providers.ResilientProvider{old}))
+
+			body := fmt.Sprintf(`{"preserve_state": %v}`, preserve)
+			rr := httptest.NewRecorder()
+			HandleProvidersReload().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/providers/reload", strings.NewReader(body)))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var resp ProvidersReloadResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Providers) != 1 || resp.Providers[0] != "mock" || resp.PreserveState != preserve {
+				t.Fatalf("unexpected response %+v", resp)
+			}
+
+			ps := router.GetProviders()
+			if len(ps) != 1 || ps[0] == old {
+				t.Fatalf("expected a rebuilt mock provider in the registry, got %v", ps)
+			}
+			rebuilt := ps[0]
+			if preserve {
+				if rebuilt.CBStateValue() != 0 || rebuilt.Stats().ErrorRate() == 0 || len(resp.Preserved) != 1 {
+					t.Errorf("expected the old open breaker and error stats carried over, got state %v error rate %v", rebuilt.CBStateValue(), rebuilt.Stats().ErrorRate())
+				}
+				return
+			}
+			if rebuilt.CBStateValue() != 2 || rebuilt.Stats().ErrorRate() != 0 || len(resp.Preserved) != 0 {
+				t.Errorf("expected a clean breaker and stats, got state %v error rate %v", rebuilt.CBStateValue(), rebuilt.Stats().ErrorRate())
+			}
+			if got := router.GetEngine().Choose("cheapest", ""); got != rebuilt {
+				t.Errorf("expected the engine to route to the rebuilt provider, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCanaryConfig(t *testing.T) {
+	newEngine := func() *router.Engine {
+		rp1 := providers.WithResilience(providers.NewMockProvider(50, 100, 0.01, 0.001), providers.ResilienceOptions{CBWindowSize: 20})
+		rp2 := providers.WithResilience(providers.NewMockProvider(60, 120, 0.01, 0.002), providers.ResilienceOptions{CBWindowSize: 20})
+		eng := router.NewEngine([]*providers.ResilientProvider{rp1, rp2})
+		eng.ConfigureCanary([]float64{1, 5, 25}, router.CanaryWindow{Calls: 200}, 2.0)
+		return eng
+	}
+
+	tests := []struct {
+		name           string
+		advance        int
+		body           string
+		expectedStatus int
+		expectedStages []float64
+		expectedStage  int
+	}{
+		{
+			name:           "insert intermediate stage",
+			advance:        1,
+			body:           `{"stages": [1, 5, 10, 25], "window": 100, "burn_multiplier": 3}`,
+			expectedStatus: http.StatusOK,
+			expectedStages: []float64{1, 5, 10, 25},
+			expectedStage:  1,
+		},
+		{
+			name:           "non-monotonic stages",
+			body:           `{"stages": [5, 1, 25]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "stage out of range",
+			body:           `{"stages": [1, 150]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "empty stages",
+			body:           `{"stages": []}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "window with neither calls nor dwell",
+			body:           `{"stages": [1, 5, 25], "window": 0, "min_dwell": "0s"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "bad min_dwell",
+			body:           `{"stages": [1, 5, 25], "min_dwell": "soon"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "shrink below current stage without force",
+			advance:        2,
+			body:           `{"stages": [1, 5]}`,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "shrink below current stage with force clamps",
+			advance:        2,
+			body:           `{"stages": [1, 5], "force": true}`,
+			expectedStatus: http.StatusOK,
+			expectedStages: []float64{1, 5},
+			expectedStage:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eng := newEngine()
+			for i := 0; i < tt.advance; i++ {
+				eng.CanaryAdvance()
+			}
+			router.SetEngine(eng)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/admin/canary/config", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			HandleCanaryConfig().ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var resp CanaryConfigResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Stages) != len(tt.expectedStages) {
+				t.Fatalf("expected stages %v, got %v", tt.expectedStages, resp.Stages)
+			}
+			for i := range resp.Stages {
+				if math.Abs(resp.Stages[i]-tt.expectedStages[i]) > 1e-9 {
+					t.Errorf("expected stages %v, got %v", tt.expectedStages, resp.Stages)
+					break
+				}
+			}
+			if resp.Stage != tt.expectedStage || eng.CanaryStageIndex() != tt.expectedStage {
+				t.Errorf("expected stage index %d, got %d", tt.expectedStage, resp.Stage)
+			}
+		})
+	}
+}
+
+func TestProviderDisableEnable(t *testing.T) {
+	rp := providers.WithResilience(providers.NewMockProvider(50, 100, 0.0, 0.001), providers.ResilienceOptions{CBWindowSize: 20})
+	provs := []*providers.ResilientProvider{rp}
+	router.SetProviders(provs)
+	eng := router.NewEngine(provs)
+	router.SetEngine(eng)
+
+	r := chi.NewRouter()
+	r.Post("/v1/admin/providers/{name}/disable", HandleProviderDisable())
+	r.Post("/v1/admin/providers/{name}/enable", HandleProviderEnable())
+
 	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/providers/mock/disable", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+	if rp.Enabled() {
+		t.Fatal("expected provider to be disabled")
+	}
+	if got := eng.Choose("cheapest", ""); got != nil {
+		t.Fatalf("expected no provider while disabled, got %s", got.Name())
+	}
 
-	handler := HandleProvidersReload()
-	handler.ServeHTTP(rr, req)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/providers/mock/enable", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rr.Code)
+	}
+	if got := eng.Choose("cheapest", ""); got == nil || got.Name() != "mock" {
+		t.Fatalf("expected mock after re-enable, got %v", got)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/providers/unknown/disable", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown provider, got %d", rr.Code)
+	}
+}
+
+func TestProviderCircuitOpenReset(t *testing.T) {
+	a := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "a", CostPer1k: 0.001}), providers.ResilienceOptions{CBWindowSize: 20})
+	b := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "b", CostPer1k: 0.002}), providers.ResilienceOptions{CBWindowSize: 20})
+	provs := []*providers.ResilientProvider{a, b}
+	router.SetProviders(provs)
+	defer router.SetProviders(nil)
+	eng := router.NewEngine(provs)
+	router.SetEngine(eng)
+
+	r := chi.NewRouter()
+	r.Post("/v1/admin/providers/{name}/cb/{action}", HandleProviderCircuit())
+	post := func(path string) int {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, nil))
+		return rr.Code
+	}
+	readiness := func() ReadinessResponse {
+		req := httptest.NewRequest(http.MethodGet, "/v1/readyz", nil)
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		HandleReadyz().ServeHTTP(rr, req)
+		var resp ReadinessResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
 
-	if rr.Code != http.StatusNotImplemented {
-		t.Errorf("expected status 501, got %d", rr.Code)
+	if code := post("/v1/admin/providers/a/cb/open"); code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", code)
+	}
+	if got := eng.Choose("cheapest", ""); got == nil || got.Name() != "b" {
+		t.Fatalf("expected b while a is tripped, got %v", got)
+	}
+	if got := testutil.ToFloat64(telemetry.CBState.WithLabelValues("a")); got != 0 {
+		t.Errorf("expected cb_state gauge 0 (open), got %v", got)
+	}
+	if resp := readiness(); resp.Providers[0].Healthy || resp.Providers[0].CBState != 0 || !resp.Ready {
+		t.Errorf("expected a reported open and unhealthy with b still serving, got %+v", resp)
+	}
+
+	if code := post("/v1/admin/providers/a/cb/reset"); code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", code)
+	}
+	if got := eng.Choose("cheapest", ""); got == nil || got.Name() != "a" {
+		t.Fatalf("expected a after reset, got %v", got)
+	}
+	if got := testutil.ToFloat64(telemetry.CBState.WithLabelValues("a")); got != 2 {
+		t.Errorf("expected cb_state gauge 2 (closed), got %v", got)
+	}
+	if resp := readiness(); !resp.Providers[0].Healthy {
+		t.Errorf("expected a healthy after reset, got %+v", resp)
+	}
+
+	if code := post("/v1/admin/providers/a/cb/half"); code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown action, got %d", code)
+	}
+	if code := post("/v1/admin/providers/unknown/cb/open"); code != http.StatusNotFound {
+		t.Errorf("expected status 404 for unknown provider, got %d", code)
+	}
+}
+
+// gateProvider holds each call until release is closed, signalling started first
+type gateProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (g *gateProvider) Name() string                        { return "gate" }
+func (g *gateProvider) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (g *gateProvider) Complete(ctx context.Context, _ providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	g.started <- struct{}{}
+	select {
+	case <-g.release:
+		return providers.CompletionResponse{Text: "done"}, 0.0001, 1, nil
+	case <-ctx.Done():
+		return providers.CompletionResponse{}, 0, 0, ctx.Err()
+	}
+}
+
+func TestDrainFailsReadinessButFinishesInFlight(t *testing.T) {
+	defer SetDraining(false)
+	gate := &gateProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	infer := handleInfer(config.Config{DefaultPolicy: "cheapest"},
+		[]*providers.ResilientProvider{providers.WithResilience(gate, providers.ResilienceOptions{CBWindowSize: 10})})
+	defer router.SetProviders(nil)
+
+	readyz := func() int {
+		rr := httptest.NewRecorder()
+		HandleReadyz().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/readyz", nil))
+		return rr.Code
+	}
+	setDrain := func(h http.HandlerFunc) DrainResponse {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/drain", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp DrainResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		infer(inFlight, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"model": "gpt-4o", "prompt": "hi"}`)))
+	}()
+	<-gate.started
+
+	if resp := setDrain(HandleDrain()); !resp.Draining || resp.InFlight != 1 {
+		t.Errorf("expected draining with one request in flight, got %+v", resp)
+	}
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected readyz 503 while draining, got %d", code)
+	}
+
+	close(gate.release)
+	<-done
+	if inFlight.Code != http.StatusOK {
+		t.Errorf("expected the in-flight request to complete after drain, got %d: %s", inFlight.Code, inFlight.Body.String())
+	}
+	if resp := setDrain(HandleDrain()); resp.InFlight != 0 {
+		t.Errorf("expected nothing in flight once it finished, got %d", resp.InFlight)
+	}
+
+	if resp := setDrain(HandleUndrain()); resp.Draining {
+		t.Errorf("expected undrain to clear draining, got %+v", resp)
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Errorf("expected readyz 200 after undrain, got %d", code)
+	}
+}
+
+func TestRouteExplain(t *testing.T) {
+	cheap := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "cheap", CostPer1k: 0.001}), providers.ResilienceOptions{CBWindowSize: 20})
+	pricey := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "pricey", CostPer1k: 0.01}), providers.ResilienceOptions{CBWindowSize: 20})
+	provs := []*providers.ResilientProvider{cheap, pricey}
+	router.SetProviders(provs)
+	eng := router.NewEngine(provs)
+	router.SetEngine(eng)
+	router.SetDefaultPolicy("cheapest")
+
+	rr := httptest.NewRecorder()
+	HandleRouteExplain().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/route/explain?model=gpt-4o", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var resp router.ChoiceExplanation
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Policy != "cheapest" || resp.Model != "gpt-4o" {
+		t.Errorf("expected default policy and requested model, got %q/%q", resp.Policy, resp.Model)
+	}
+	if got := eng.Choose("cheapest", "gpt-4o"); resp.Chosen != got.Name() {
+		t.Errorf("explanation chose %q, engine chooses %q", resp.Chosen, got.Name())
+	}
+	if len(resp.Providers) != 2 {
+		t.Errorf("expected signals for 2 providers, got %d", len(resp.Providers))
+	}
+
+	rr = httptest.NewRecorder()
+	HandleRouteExplain().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/route/explain?policy=bogus", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid policy, got %d", rr.Code)
+	}
+}
+
+func TestPolicySimulate(t *testing.T) {
+	cheap := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "cheap", CostPer1k: 0.001}), providers.ResilienceOptions{CBWindowSize: 20})
+	pricey := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "pricey", CostPer1k: 0.01}), providers.ResilienceOptions{CBWindowSize: 20})
+	provs := []*providers.ResilientProvider{cheap, pricey}
+	router.SetProviders(provs)
+	eng := router.NewEngine(provs)
+	router.SetEngine(eng)
+	router.SetDefaultPolicy("cheapest")
+
+	simulate := func(query string) (int, router.Simulation) {
+		rr := httptest.NewRecorder()
+		HandlePolicySimulate().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/policy/simulate"+query, nil))
+		var sim router.Simulation
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&sim); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return rr.Code, sim
+	}
+
+	code, sim := simulate("?n=200")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if sim.Policy != "cheapest" || sim.Runs != 200 {
+		t.Errorf("expected 200 runs of the default policy, got %d of %q", sim.Runs, sim.Policy)
+	}
+	if sim.Selected["cheap"] != 200 || sim.Selected["pricey"] != 0 {
+		t.Errorf("expected every run on cheap, got %v", sim.Selected)
+	}
+	if sim.AvgCostPer1kUSD != 0.001 {
+		t.Errorf("expected average cost 0.001, got %v", sim.AvgCostPer1kUSD)
+	}
+
+	// with cheap's breaker open every run falls to pricey
+	cheap.ForceOpenCB()
+	code, sim = simulate("")
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if sim.Runs != 1000 || sim.Selected["pricey"] != 1000 {
+		t.Errorf("expected the default 1000 runs all on pricey, got %d: %v", sim.Runs, sim.Selected)
+	}
+
+	// simulating the canary policy leaves its stage untouched
+	stage := eng.CanaryStageIndex()
+	if code, _ = simulate("?policy=canary"); code != http.StatusOK {
+		t.Fatalf("expected status 200 for canary, got %d", code)
+	}
+	if eng.CanaryStageIndex() != stage {
+		t.Error("expected simulation not to move the canary")
+	}
+
+	for _, q := range []string{"?policy=bogus", "?n=0", "?n=abc", "?n=100001"} {
+		if code, _ := simulate(q); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", q, code)
+		}
+	}
+}
+
+func TestAuditPolicyUpdate(t *testing.T) {
+	prev := audit.GetLog()
+	defer audit.SetLog(prev)
+	audit.SetLog(&audit.Log{})
+	router.SetDefaultPolicy("cheapest")
+
+	admin := chi.NewRouter()
+	admin.Use(telemetry.RequestIDMiddleware, AdminAuth([]AdminToken{{Name: "ops", Token: "secret", Role: RoleAdmin}}))
+	admin.Post("/policy", HandlePolicyUpdate())
+	admin.Get("/audit", HandleAuditList())
+
+	start := time.Now().Add(-time.Second)
+	req := httptest.NewRequest(http.MethodPost, "/policy", strings.NewReader(`{"default_policy": "fastest_p95"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Request-ID", "req-123")
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("policy update: expected 204, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/audit?since="+start.UTC().Format(time.RFC3339)+"&limit=10", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("audit list: expected 200, got %d", rr.Code)
 	}
 
-	if !strings.Contains(rr.Body.String(), "not implemented") {
-		t.Errorf("expected 'not implemented' in response body, got %q", rr.Body.String())
+	var entries []audit.Entry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Action != "set_policy" || e.RequestID != "req-123" || e.Actor != "ops" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if string(e.Before) != `{"default_policy":"cheapest"}` || string(e.After) != `{"default_policy":"fastest_p95"}` {
+		t.Errorf("unexpected before/after %s -> %s", e.Before, e.After)
+	}
+
+	// entries older than since are excluded
+	req = httptest.NewRequest(http.MethodGet, "/audit?since="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	if strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("expected no entries in the future, got %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/audit?limit=0", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for limit=0, got %d", rr.Code)
+	}
+}
+
+func TestTenantImportExport(t *testing.T) {
+	prev := audit.GetLog()
+	defer audit.SetLog(prev)
+	audit.SetLog(&audit.Log{})
+	keys, err := auth.NewAPIKeyManager("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	th := NewTenantHandlers(keys, nil)
+
+	body := `[
+		{"name": "acme", "plan": "enterprise", "allowed_models": ["gpt-4o"]},
+		{"name": "not a valid name!", "plan": "free"},
+		{"name": "globex", "plan": "free", "rps_limit": 5}
+	]`
+	rr := httptest.NewRecorder()
+	th.HandleImportTenants()(rr, httptest.NewRequest(http.MethodPost, "/tenants/import", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("import: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var imported TenantImportResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &imported); err != nil {
+		t.Fatal(err)
+	}
+	if imported.Created != 2 || imported.Failed != 1 || len(imported.Results) != 3 {
+		t.Fatalf("expected 2 created and 1 failed, got %+v", imported)
+	}
+	if bad := imported.Results[1]; bad.Index != 1 || !strings.Contains(bad.Error, "name can only contain") || bad.APIKey != "" {
+		t.Errorf("expected the invalid row rejected by validation, got %+v", bad)
+	}
+	acme, globex := imported.Results[0], imported.Results[2]
+	if acme.APIKey == "" || globex.APIKey == "" || acme.TenantID == globex.TenantID {
+		t.Fatalf("expected distinct tenants with keys, got %+v and %+v", acme, globex)
+	}
+	if acme.Tenant.RPSLimit != 1000 || globex.Tenant.RPSLimit != 5 {
+		t.Errorf("expected plan defaults only where unset, got %d and %d", acme.Tenant.RPSLimit, globex.Tenant.RPSLimit)
+	}
+	if n := len(audit.GetLog().Since(time.Time{}, 10)); n != 2 {
+		t.Errorf("expected an audit entry per created tenant, got %d", n)
+	}
+
+	rr = httptest.NewRecorder()
+	th.HandleExportTenants()(rr, httptest.NewRequest(http.MethodGet, "/tenants/export", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("export: expected 200, got %d", rr.Code)
+	}
+	for _, secret := range []string{acme.APIKey, globex.APIKey, "api_key_hash", "salt"} {
+		if strings.Contains(rr.Body.String(), secret) {
+			t.Fatalf("export leaked %q: %s", secret, rr.Body.String())
+		}
+	}
+	var exported []auth.Tenant
+	if err := json.Unmarshal(rr.Body.Bytes(), &exported); err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 2 {
+		t.Fatalf("expected the 2 imported tenants, got %+v", exported)
+	}
+	for _, tn := range exported {
+		if tn.Name == "acme" && (tn.TenantID != acme.TenantID || len(tn.AllowedModels) != 1) {
+			t.Errorf("expected acme exported as imported, got %+v", tn)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	th.HandleImportTenants()(rr, httptest.NewRequest(http.MethodPost, "/tenants/import", strings.NewReader(`{"name": "acme"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-array body, got %d", rr.Code)
+	}
+}
+
+func TestTenantExportImportRoundTrip(t *testing.T) {
+	prev := audit.GetLog()
+	defer audit.SetLog(prev)
+	audit.SetLog(&audit.Log{})
+	from, err := auth.NewAPIKeyManager("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := from.ImportTenant(context.Background(), auth.Tenant{Name: "paused", Plan: "growth", RPSLimit: 50, DailyTokenLimit: 5000, Priority: "low"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := from.ImportTenant(context.Background(), auth.Tenant{Name: "active", Plan: "free", RPSLimit: 5, DailyTokenLimit: 1000, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	NewTenantHandlers(from, nil).HandleExportTenants()(rr, httptest.NewRequest(http.MethodGet, "/tenants/export", nil))
+	to, err := auth.NewAPIKeyManager("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	NewTenantHandlers(to, nil).HandleImportTenants()(rec, httptest.NewRequest(http.MethodPost, "/tenants/import", rr.Body))
+	var imported TenantImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &imported); err != nil || imported.Created != 2 {
+		t.Fatalf("expected both exported tenants imported, got %v: %s", err, rec.Body.String())
+	}
+
+	tenants, err := to.ListTenants(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]*auth.Tenant{}
+	for _, tn := range tenants {
+		byName[tn.Name] = tn
+	}
+	if p := byName["paused"]; p == nil || p.Enabled || p.Priority != "low" || p.RPSLimit != 50 {
+		t.Errorf("expected the disabled tenant back disabled with its priority, got %+v", p)
+	}
+	if a := byName["active"]; a == nil || !a.Enabled || a.Priority != "" {
+		t.Errorf("expected the active tenant back enabled, got %+v", a)
+	}
+
+	// enabled defaults to true when left out, and an unknown priority is rejected
+	rec = httptest.NewRecorder()
+	body := `[{"name": "fresh", "plan": "free"}, {"name": "odd", "plan": "free", "priority": "urgent"}]`
+	NewTenantHandlers(to, nil).HandleImportTenants()(rec, httptest.NewRequest(http.MethodPost, "/tenants/import", strings.NewReader(body)))
+	if err := json.Unmarshal(rec.Body.Bytes(), &imported); err != nil {
+		t.Fatal(err)
+	}
+	if imported.Created != 1 || !imported.Results[0].Tenant.Enabled || !strings.Contains(imported.Results[1].Error, "priority") {
+		t.Errorf("expected an enabled default and a rejected priority, got %+v", imported)
+	}
+}
+
+func TestAdminRoleMatrix(t *testing.T) {
+	prev := audit.GetLog()
+	defer audit.SetLog(prev)
+	audit.SetLog(&audit.Log{})
+
+	mockProv := providers.WithResilience(providers.NewMockProvider(10, 20, 0, 0.001), providers.ResilienceOptions{})
+	router.SetProviders([]*providers.ResilientProvider{mockProv})
+	router.SetEngine(router.NewEngine([]*providers.ResilientProvider{mockProv}))
+	router.SetDefaultPolicy("cheapest")
+
+	admin := NewAdminRouter([]AdminToken{
+		{Name: "viewer", Token: "v-token", Role: RoleViewer},
+		{Name: "operator", Token: "o-token", Role: RoleOperator},
+		{Name: "admin", Token: "a-token", Role: RoleAdmin},
+	})
+
+	endpoints := []struct {
+		method, path, body string
+		required           AdminRole
+	}{
+		{http.MethodGet, "/status", "", RoleViewer},
+		{http.MethodGet, "/slo", "", RoleViewer},
+		{http.MethodGet, "/canary/status", "", RoleViewer},
+		{http.MethodGet, "/canary/config", "", RoleViewer},
+		{http.MethodGet, "/route/explain", "", RoleViewer},
+		{http.MethodGet, "/policy/simulate?n=10", "", RoleViewer},
+		{http.MethodGet, "/routing", "", RoleViewer},
+		{http.MethodPost, "/canary/advance", `{"force": true}`, RoleOperator},
+		{http.MethodPost, "/canary/rollback", "", RoleOperator},
+		{http.MethodPost, "/canary/config", `{"stages": [1, 5], "force": true}`, RoleOperator},
+		{http.MethodPost, "/policy", `{"default_policy": "cheapest"}`, RoleOperator},
+		{http.MethodPost, "/providers/reload", "", RoleOperator},
+		{http.MethodPost, "/providers/mock/disable", "", RoleOperator},
+		{http.MethodPost, "/providers/mock/enable", "", RoleOperator},
+		{http.MethodPost, "/providers/mock/cb/reset", "", RoleOperator},
+		{http.MethodPost, "/selftest", "", RoleOperator},
+		{http.MethodPost, "/drain", "", RoleOperator},
+		{http.MethodPost, "/undrain", "", RoleOperator},
+		{http.MethodGet, "/audit", "", RoleAdmin},
+	}
+	tokens := map[AdminRole]string{RoleViewer: "v-token", RoleOperator: "o-token", RoleAdmin: "a-token"}
+
+	for _, ep := range endpoints {
+		for _, role := range []AdminRole{RoleViewer, RoleOperator, RoleAdmin} {
+			t.Run(role.String()+" "+ep.method+" "+ep.path, func(t *testing.T) {
+				req := httptest.NewRequest(ep.method, ep.path, strings.NewReader(ep.body))
+				req.Header.Set("Authorization", "Bearer "+tokens[role])
+				rr := httptest.NewRecorder()
+				admin.ServeHTTP(rr, req)
+
+				if role < ep.required {
+					if rr.Code != http.StatusForbidden {
+						t.Errorf("expected 403, got %d", rr.Code)
+					}
+					return
+				}
+				if rr.Code == http.StatusForbidden || rr.Code == http.StatusUnauthorized {
+					t.Errorf("expected access, got %d", rr.Code)
+				}
+			})
+		}
+	}
+
+	// unknown tokens are unauthenticated, not forbidden
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer nope")
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for unknown token, got %d", rr.Code)
+	}
+}
+
+func TestAdminTokensFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admins.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "alice", "token": "a1", "role": "viewer"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := AdminTokensFromConfig(config.Config{AdminToken: "legacy", AdminTokensJSONPath: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].Role != RoleAdmin || tokens[1].Name != "alice" || tokens[1].Role != RoleViewer {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name": "bob", "token": "b1", "role": "root"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AdminTokensFromConfig(config.Config{AdminTokensJSONPath: path}); err == nil {
+		t.Error("expected error for unknown role")
 	}
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// aliasPrefix marks a model that must resolve through ALIASES rather than pass
+// through as a provider model ID
+const aliasPrefix = "alias:"
+
+// resolveModelAlias maps an ALIASES name to its concrete model. Any other name is
+// taken as a provider model ID, except alias:<name>, which must be configured.
+func resolveModelAlias(aliases map[string]string, model string) (string, error) {
+	name, explicit := strings.CutPrefix(model, aliasPrefix)
+	if m, ok := aliases[name]; ok {
+		return m, nil
+	}
+	if !explicit {
+		return model, nil
+	}
+	names := make([]string, 0, len(aliases))
+	for n := range aliases {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	if len(names) == 0 {
+		return "", fmt.Errorf("unknown model alias %q: no aliases are configured", name)
+	}
+	return "", fmt.Errorf("unknown model alias %q; configured aliases: %s", name, strings.Join(names, ", "))
+}
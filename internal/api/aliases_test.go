@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+func TestInferResolvesModelAliases(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest", ModelAliases: map[string]string{"fast": "gpt-4o-mini", "smart": "gpt-4o"}}
+	rec := &recordingProvider{}
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(rec, providers.ResilienceOptions{CBWindowSize: 10})})
+
+	tests := []struct {
+		name  string
+		model string
+		want  int
+		sent  string
+	}{
+		{name: "alias", model: "fast", want: http.StatusOK, sent: "gpt-4o-mini"},
+		{name: "explicit alias", model: "alias:smart", want: http.StatusOK, sent: "gpt-4o"},
+		{name: "concrete model", model: "anthropic.claude-3-haiku", want: http.StatusOK, sent: "anthropic.claude-3-haiku"},
+		// without the prefix a mistyped alias can't be told from a model ID, so it passes through
+		{name: "bare unknown name", model: "fsat", want: http.StatusOK, sent: "fsat"},
+		{name: "unknown alias", model: "alias:cheap", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"model": "`+tt.model+`", "prompt": "hi"}`)))
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				if !strings.Contains(w.Body.String(), `unknown model alias \"cheap\"; configured aliases: fast, smart`) {
+					t.Errorf("expected the error to name the alias and list the configured ones, got %s", w.Body.String())
+				}
+				return
+			}
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			if rec.got.Model != tt.sent {
+				t.Errorf("expected provider to see model %s, got %s", tt.sent, rec.got.Model)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import "net/http"
+
+// LimitBody caps every request body at maxBytes as read off the wire; reading past it
+// fails with *http.MaxBytesError, which handlers turn into a 413 or a 400
+func LimitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeBodyTooLarge answers 413 for a body that went past its MaxBytesReader
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, err error) {
+	NewResponseWriter(w, r).WriteProblem(ProblemTypeValidation, "Request Body Too Large",
+		http.StatusRequestEntityTooLarge, err.Error())
+}
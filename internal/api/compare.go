@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// ProviderComparison is what a request would have cost on one provider, returned in
+// InferResponse.Comparisons for ?compare=1
+type ProviderComparison struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	P95LatencyMs     int64   `json:"p95_latency_ms"`
+	CBState          float64 `json:"cb_state"`
+}
+
+// wantsComparison reports whether the request asked for ?compare=1
+func wantsComparison(r *http.Request) bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get("compare"))
+	return err == nil && v
+}
+
+// compareProviders prices the request's token counts on every enabled provider the
+// tenant may use, from list prices (or the cost model) and current stats; no provider
+// is called. askedModel is the model the client named; when empty each provider is
+// priced on its own default, else on model.
+func compareProviders(costs CostModel, provs []*providers.ResilientProvider, tenant *auth.Tenant, askedModel, model string, promptTok, completionTok int64) []ProviderComparison {
+	out := make([]ProviderComparison, 0, len(provs))
+	for _, p := range provs {
+		if !p.Enabled() || (tenant != nil && !tenant.ProviderAllowed(p.Name())) {
+			continue
+		}
+		m := askedModel
+		if m == "" {
+			m = defaultModelFor(p, model)
+		}
+		list := p.CostPer1kTokensUSD(m) * float64(promptTok+completionTok) / 1000.0
+		out = append(out, ProviderComparison{
+			Provider:         p.Name(),
+			Model:            m,
+			EstimatedCostUSD: billedCost(costs, p.Name(), m, promptTok, completionTok, list),
+			P95LatencyMs:     p.Stats().P95LatencyMs(),
+			CBState:          p.CBStateValue(),
+		})
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzipRoundTrip(t *testing.T) {
+	echo := Gzip(1 << 20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Error("expected Content-Encoding stripped after inflating")
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+
+	payload := []byte(`{"prompt": "` + strings.Repeat("long prompt ", 500) + `"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/infer", bytes.NewReader(gzipBytes(t, payload)))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	echo.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzipped response, got Content-Encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Body.Len() >= len(payload) {
+		t.Errorf("expected compressed body smaller than %d bytes, got %d", len(payload), rec.Body.Len())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("round-tripped body differs from the original")
+	}
+
+	// without Accept-Encoding the response is left alone
+	req = httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hi"}`))
+	rec = httptest.NewRecorder()
+	echo.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != `{"prompt": "hi"}` {
+		t.Errorf("expected plain passthrough, got %q %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+}
+
+func TestGzipRejectsDecompressionBomb(t *testing.T) {
+	called := false
+	h := Gzip(64 << 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// 16 MiB of zeros compresses to a few KiB
+	bomb := gzipBytes(t, make([]byte, 16<<20))
+	req := httptest.NewRequest(http.MethodPost, "/v1/infer", bytes.NewReader(bomb))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", rec.Code)
+	}
+	if called {
+		t.Error("handler must not run for an oversized body")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader("not gzip"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed gzip, got %d", rec.Code)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"br, GZIP;q=0.5":    true,
+		"gzip;q=0":          false,
+		"deflate, identity": false,
+	}
+	for header, want := range cases {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
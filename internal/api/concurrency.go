@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/admission"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+// GlobalConcurrencyLimit caps in-flight requests across all tenants. Once the cap is
+// reached requests queue for up to maxWait and freed slots go to the tenant using the
+// least of its plan-weighted share, so one noisy tenant can't starve the rest. Requests
+// still waiting after maxWait are shed with 503 and Retry-After. Queued high-priority
+// requests are admitted before normal and low ones, and low ones are shed first. The
+// body is read, up to maxBody bytes, for its priority before admission. limit <= 0
+// disables the limit.
+func GlobalConcurrencyLimit(limit int, maxWait time.Duration, maxBody int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		queue := admission.NewFairQueue(limit, maxWait)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// unauthenticated traffic shares a single queue entry
+			tenantID, plan := "", "none"
+			prio := admission.PriorityNormal
+			if t, ok := auth.GetTenantFromContext(r.Context()); ok {
+				tenantID, plan = t.TenantID, t.Plan
+				prio = tenantPriority(t)
+			}
+			p, ok, err := bodyPriority(w, r, maxBody)
+			if err != nil {
+				writeBodyTooLarge(w, r, err)
+				return
+			}
+			// a request may ask to be less urgent than its tenant, never more
+			if ok && p < prio {
+				prio = p
+			}
+			start := time.Now()
+			release, err := queue.AcquirePriority(r.Context(), tenantID, planWeight(plan), prio)
+			telemetry.TenantQueueWaitMs.WithLabelValues(plan).Observe(float64(time.Since(start).Milliseconds()))
+			if err != nil {
+				telemetry.ShedTotal.Inc()
+				telemetry.RequestsByPriority.WithLabelValues(prio.String(), "shed").Inc()
+				w.Header().Set("Retry-After", "1")
+				NewResponseWriter(w, r).WriteProblem(ProblemTypeOverloaded, "Server Overloaded",
+					http.StatusServiceUnavailable, "too many requests in flight, retry shortly")
+				return
+			}
+			telemetry.RequestsByPriority.WithLabelValues(prio.String(), "admitted").Inc()
+			telemetry.GlobalInflight.Inc()
+			defer func() {
+				telemetry.GlobalInflight.Dec()
+				release()
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// planWeight is a tenant's relative share of global capacity when tenants contend
+func planWeight(plan string) float64 {
+	switch plan {
+	case "starter":
+		return 2
+	case "pro", "growth":
+		return 4
+	case "enterprise":
+		return 8
+	default:
+		return 1
+	}
+}
+
+// tenantPriority is the tenant's default QoS class: its own setting, else one derived
+// from the plan
+func tenantPriority(t *auth.Tenant) admission.Priority {
+	if p, ok := admission.ParsePriority(t.Priority); ok {
+		return p
+	}
+	switch t.Plan {
+	case "enterprise":
+		return admission.PriorityHigh
+	case "free":
+		return admission.PriorityLow
+	default:
+		return admission.PriorityNormal
+	}
+}
+
+// bodyPriority reads the priority an infer body asks for, leaving the body in place for
+// the handler. ok is false when the body doesn't name a valid class; err is set only
+// when the body is over maxBody bytes. The whole body is buffered and held while the
+// request queues. That is deliberate: "priority" may come anywhere in the JSON and the
+// handler reads the full body once admitted anyway, so what queued requests hold is
+// bounded by MAX_REQUEST_BODY_BYTES, the queue's size and TENANT_QUEUE_MAX_WAIT.
+func bodyPriority(w http.ResponseWriter, r *http.Request, maxBody int64) (p admission.Priority, ok bool, err error) {
+	if r.Body == nil {
+		return admission.PriorityNormal, false, nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return admission.PriorityNormal, false, err
+	}
+	if err != nil {
+		return admission.PriorityNormal, false, nil
+	}
+	var peek struct {
+		Priority string `json:"priority"`
+	}
+	if json.Unmarshal(body, &peek) != nil {
+		return admission.PriorityNormal, false, nil
+	}
+	p, ok = admission.ParsePriority(peek.Priority)
+	return p, ok, nil
+}
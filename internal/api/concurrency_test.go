@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/admission"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+func TestGlobalConcurrencyLimitShedsOverflow(t *testing.T) {
+	const limit = 3
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	h := GlobalConcurrencyLimit(limit, 0, 1<<20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+	}))
+
+	shedBefore := testutil.ToFloat64(telemetry.ShedTotal)
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected admitted request to succeed, got %d", rec.Code)
+			}
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		<-entered
+	}
+	if got := testutil.ToFloat64(telemetry.GlobalInflight); got != limit {
+		t.Errorf("expected inflight gauge %d, got %v", limit, got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected request %d to be shed with 503, got %d", limit+1, rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After on a shed request")
+	}
+	if got := testutil.ToFloat64(telemetry.ShedTotal); got != shedBefore+1 {
+		t.Errorf("expected shed counter to increment, went from %v to %v", shedBefore, got)
+	}
+
+	close(release)
+	wg.Wait()
+	if got := testutil.ToFloat64(telemetry.GlobalInflight); got != 0 {
+		t.Errorf("expected inflight gauge back to 0, got %v", got)
+	}
+
+	// slots are free again
+	go func() { <-entered }()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected request after release to be admitted, got %d", rec.Code)
+	}
+}
+
+func TestGlobalConcurrencyLimitAdmitsQuietTenantDuringBurst(t *testing.T) {
+	const limit, burst = 2, 4
+	entered := make(chan string, burst+1)
+	release := make(chan struct{})
+	h := GlobalConcurrencyLimit(limit, 5*time.Second, 1<<20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ := auth.GetTenantFromContext(r.Context())
+		entered <- tenant.TenantID
+		<-release
+	}))
+	send := func(tenant *auth.Tenant) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/infer", nil)
+		h.ServeHTTP(rec, req.WithContext(auth.WithTenant(req.Context(), tenant)))
+		if rec.Code != http.StatusOK {
+			t.Errorf("expected %s to be admitted, got %d", tenant.TenantID, rec.Code)
+		}
+	}
+	noisy := &auth.Tenant{TenantID: "t-noisy", Plan: "free"}
+	quiet := &auth.Tenant{TenantID: "t-quiet", Plan: "free"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() { defer wg.Done(); send(noisy) }()
+	}
+	for i := 0; i < limit; i++ {
+		if got := <-entered; got != noisy.TenantID {
+			t.Fatalf("expected the burst to take the first slots, got %s", got)
+		}
+	}
+	// the rest of the burst is now queued; the quiet tenant lines up behind it
+	wg.Add(1)
+	go func() { defer wg.Done(); send(quiet) }()
+	time.Sleep(50 * time.Millisecond)
+
+	noisyAhead := 0
+	for {
+		release <- struct{}{}
+		if <-entered == quiet.TenantID {
+			break
+		}
+		noisyAhead++
+	}
+	if noisyAhead >= burst-limit {
+		t.Errorf("quiet tenant waited behind the whole burst (%d noisy requests admitted first)", noisyAhead)
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestGlobalConcurrencyLimitAdmitsHighPriorityOverLow(t *testing.T) {
+	entered := make(chan string, 3)
+	release := make(chan struct{})
+	h := GlobalConcurrencyLimit(1, 300*time.Millisecond, 1<<20)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body must still be readable after admission peeked at it
+		var req InferRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		entered <- req.Priority
+		<-release
+	}))
+	codes := make(map[string]int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// an enterprise tenant may ask for high; the others lower themselves
+	tenant := &auth.Tenant{TenantID: "t-prio", Plan: "enterprise"}
+	send := func(prio string) {
+		defer wg.Done()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt":"hi","priority":"`+prio+`"}`))
+		h.ServeHTTP(rec, req.WithContext(auth.WithTenant(req.Context(), tenant)))
+		mu.Lock()
+		codes[prio] = rec.Code
+		mu.Unlock()
+	}
+	shedLow := telemetry.RequestsByPriority.WithLabelValues("low", "shed")
+	shedBefore := testutil.ToFloat64(shedLow)
+
+	// saturate, then queue a low request ahead of a high one
+	wg.Add(3)
+	go send("normal")
+	<-entered
+	go send("low")
+	time.Sleep(20 * time.Millisecond)
+	go send("high")
+	time.Sleep(20 * time.Millisecond)
+
+	release <- struct{}{}
+	if got := <-entered; got != "high" {
+		t.Fatalf("expected the high-priority request to get the freed slot, got %s", got)
+	}
+	// high holds the slot past low's queue wait
+	time.Sleep(400 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if codes["high"] != http.StatusOK || codes["low"] != http.StatusServiceUnavailable {
+		t.Errorf("expected high admitted and low shed, got %v", codes)
+	}
+	if got := testutil.ToFloat64(shedLow); got != shedBefore+1 {
+		t.Errorf("expected one low-priority shed, went from %v to %v", shedBefore, got)
+	}
+}
+
+func TestTenantPriority(t *testing.T) {
+	for _, tc := range []struct {
+		tenant auth.Tenant
+		want   admission.Priority
+	}{
+		{auth.Tenant{Plan: "enterprise"}, admission.PriorityHigh},
+		{auth.Tenant{Plan: "pro"}, admission.PriorityNormal},
+		{auth.Tenant{Plan: "free"}, admission.PriorityLow},
+		{auth.Tenant{Plan: "free", Priority: "high"}, admission.PriorityHigh},
+	} {
+		if got := tenantPriority(&tc.tenant); got != tc.want {
+			t.Errorf("%+v: expected %s, got %s", tc.tenant, tc.want, got)
+		}
+	}
+}
+
+func TestBodyPriorityOnlyLowersTenantClass(t *testing.T) {
+	h := GlobalConcurrencyLimit(1, 0, 64)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	admit := func(plan, prio string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt":"hi","priority":"`+prio+`"}`))
+		req = req.WithContext(auth.WithTenant(req.Context(), &auth.Tenant{TenantID: "t-" + plan, Plan: plan}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for _, tc := range []struct {
+		plan, asked, want string
+	}{
+		{"free", "high", "low"},
+		{"pro", "high", "normal"},
+		{"enterprise", "low", "low"},
+		{"enterprise", "high", "high"},
+	} {
+		counter := telemetry.RequestsByPriority.WithLabelValues(tc.want, "admitted")
+		before := testutil.ToFloat64(counter)
+		if code := admit(tc.plan, tc.asked); code != http.StatusOK {
+			t.Fatalf("%s asking %s: expected 200, got %d", tc.plan, tc.asked, code)
+		}
+		if got := testutil.ToFloat64(counter); got != before+1 {
+			t.Errorf("%s asking %s: expected admission as %s", tc.plan, tc.asked, tc.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt":"`+strings.Repeat("x", 100)+`"}`)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a body over the limit, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+)
+
+// defaultContextWindows are published context windows in tokens, keyed by model name
+// prefix like the request limits
+var defaultContextWindows = map[string]int{
+	"gpt-4o":             128000,
+	"gpt-4-turbo":        128000,
+	"gpt-4":              8192,
+	"gpt-3.5-turbo":      16385,
+	"claude-3":           200000,
+	"anthropic.claude-3": 200000,
+}
+
+// contextWindows sizes each model's context window; fallback covers models missing
+// from perModel and 0 leaves them unchecked
+type contextWindows struct {
+	perModel map[string]int
+	fallback int
+}
+
+// buildContextWindows overlays CONTEXT_WINDOWS on the built-in table
+func buildContextWindows(cfg config.Config) contextWindows {
+	perModel := maps.Clone(defaultContextWindows)
+	maps.Copy(perModel, cfg.ModelContextWindows)
+	return contextWindows{perModel: perModel, fallback: cfg.ContextWindow}
+}
+
+// window returns model's context window in tokens, 0 when unknown
+func (c contextWindows) window(model string) int {
+	return modelLimit(c.perModel, model, c.fallback)
+}
+
+// overflow is how many tokens req's prompt plus max_tokens go past window
+func overflow(estimator *usage.TokenEstimator, req InferRequest, window int) int64 {
+	return estimatePromptTokens(estimator, req) + int64(req.MaxTok) - int64(window)
+}
+
+// fit checks req against its model's context window before any provider call. With
+// truncate set, an oversized request loses its oldest turns and then the front of
+// its final text until it fits. It returns the window and how far past it req still
+// is; over is positive only when req can't be sent.
+func (c contextWindows) fit(estimator *usage.TokenEstimator, req *InferRequest) (window int, over int64) {
+	window = c.window(req.Model)
+	if window <= 0 {
+		return 0, 0
+	}
+	over = overflow(estimator, *req, window)
+	if over <= 0 || !req.Truncate {
+		return window, over
+	}
+
+	needed := over
+	trimmed := *req
+	trimmed.Messages = slices.Clone(req.Messages)
+	for over > 0 {
+		i := oldestTurn(trimmed)
+		if i < 0 {
+			break
+		}
+		trimmed.Messages = slices.Delete(trimmed.Messages, i, i+1)
+		over = overflow(estimator, trimmed, window)
+	}
+	if over > 0 {
+		over = trimFront(estimator, &trimmed, window)
+	}
+	if over > 0 {
+		// report the original shortfall; req is left as sent
+		return window, needed
+	}
+	*req = trimmed
+	return window, 0
+}
+
+// oldestTurn is the index of the earliest message truncation may drop: system and
+// developer instructions stay, as does the final message when there is no prompt
+func oldestTurn(req InferRequest) int {
+	last := len(req.Messages)
+	if req.Prompt == "" {
+		last--
+	}
+	for i := 0; i < last; i++ {
+		switch req.Messages[i].Role {
+		case "system", "developer":
+		default:
+			return i
+		}
+	}
+	return -1
+}
+
+// trimFront cuts the fewest leading characters from req's final text, the prompt or
+// else the last message, that bring it within window. Some text must remain, so it
+// returns the overflow left when even a single character doesn't fit.
+func trimFront(estimator *usage.TokenEstimator, req *InferRequest, window int) int64 {
+	text := &req.Prompt
+	if req.Prompt == "" {
+		if len(req.Messages) == 0 {
+			return overflow(estimator, *req, window)
+		}
+		text = &req.Messages[len(req.Messages)-1].Content
+	}
+	full := *text
+	overAt := func(cut int) int64 {
+		*text = full[cut:]
+		return overflow(estimator, *req, window)
+	}
+	// smallest cut that fits, searched over cuts that keep at least one byte
+	lo, hi := 0, len(full)-1
+	if hi < 0 || overAt(hi) > 0 {
+		over := overAt(0)
+		*text = full
+		return over
+	}
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if overAt(mid) > 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	// never split a character
+	for lo < len(full)-1 && !utf8.RuneStart(full[lo]) {
+		lo++
+	}
+	*text = full[lo:]
+	return 0
+}
+
+// contextWindowMessage explains a request that doesn't fit model's window
+func contextWindowMessage(model string, window int, over int64) string {
+	return fmt.Sprintf("request exceeds the %d-token context window of model %s by %d tokens; shorten the prompt, lower max_tokens or set truncate", window, model, over)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+)
+
+func TestInferContextWindow(t *testing.T) {
+	rec := &recordingProvider{}
+	cfg := config.Config{DefaultPolicy: "cheapest", ModelContextWindows: map[string]int{"tiny": 100}}
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(rec, providers.ResilienceOptions{CBWindowSize: 10})})
+	long := strings.Repeat("word ", 200) + "the question"
+	send := func(body map[string]any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(string(b))))
+		return w
+	}
+	sent := func() providers.CompletionRequest {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.got
+	}
+
+	w := send(map[string]any{"model": "tiny", "prompt": long, "max_tokens": 20})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an oversized prompt, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "100-token context window of model tiny by") {
+		t.Errorf("expected the overflow explained, got %s", w.Body.String())
+	}
+
+	w = send(map[string]any{"model": "tiny", "prompt": long, "max_tokens": 20, "truncate": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected truncation to fit, got %d: %s", w.Code, w.Body.String())
+	}
+	got := sent().Prompt
+	if got == "" || len(got) >= len(long) || !strings.HasSuffix(long, got) {
+		t.Fatalf("expected a shorter tail of the prompt sent, got %d chars", len(got))
+	}
+	if n := usage.NewTokenEstimator().EstimatePromptTokens(got, "tiny"); n+20 > 100 {
+		t.Errorf("expected the truncated prompt within the window, estimated %d tokens", n)
+	}
+
+	// older turns go before the prompt is touched; instructions stay
+	w = send(map[string]any{
+		"model":      "tiny",
+		"max_tokens": 20,
+		"truncate":   true,
+		"prompt":     "and now?",
+		"messages": []map[string]string{
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": long},
+			{"role": "assistant", "content": long},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected dropping old turns to fit, got %d: %s", w.Code, w.Body.String())
+	}
+	if req := sent(); req.Prompt != "and now?" || len(req.Messages) != 1 || req.Messages[0].Role != "system" {
+		t.Errorf("expected only the system message and the prompt kept, got %+v with prompt %q", req.Messages, req.Prompt)
+	}
+
+	// max_tokens alone overflowing can't be fixed by trimming
+	w = send(map[string]any{"model": "tiny", "prompt": "hi", "max_tokens": 200, "truncate": true})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 when max_tokens exceeds the window, got %d", w.Code)
+	}
+
+	// models without a known window aren't checked
+	if w = send(map[string]any{"model": "unlisted", "prompt": long, "max_tokens": 20}); w.Code != http.StatusOK {
+		t.Errorf("expected an unlisted model to pass, got %d", w.Code)
+	}
+}
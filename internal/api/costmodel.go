@@ -0,0 +1,75 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+)
+
+// CostModel prices a successful completion for billing. When set it replaces the
+// provider-reported cost in responses, cost metrics and usage records, so teams
+// reselling the router internally can apply their own chargeback rates
+type CostModel interface {
+	Cost(provider, model string, promptTok, completionTok int64) float64
+}
+
+// MarkupCostModel bills prompt and completion tokens at the provider's list price
+// per 1k tokens, scaled by 1 + Markup
+type MarkupCostModel struct {
+	Markup float64
+	// providers returns the providers whose list prices apply, read per call so
+	// a provider reload is priced at once
+	providers func() []*providers.ResilientProvider
+}
+
+// NewMarkupCostModel prices against the list prices of provs
+func NewMarkupCostModel(markup float64, provs []*providers.ResilientProvider) *MarkupCostModel {
+	return &MarkupCostModel{Markup: markup, providers: func() []*providers.ResilientProvider { return provs }}
+}
+
+func (m *MarkupCostModel) Cost(provider, model string, promptTok, completionTok int64) float64 {
+	for _, p := range m.providers() {
+		if p.Name() == provider {
+			return p.CostPer1kTokensUSD(model) * float64(promptTok+completionTok) / 1000.0 * (1 + m.Markup)
+		}
+	}
+	return 0
+}
+
+var (
+	costModelMu     sync.RWMutex
+	customCostModel CostModel
+)
+
+// SetCostModel installs m for handlers built afterwards, taking precedence over
+// COST_MARKUP; nil goes back to the config
+func SetCostModel(m CostModel) {
+	costModelMu.Lock()
+	defer costModelMu.Unlock()
+	customCostModel = m
+}
+
+// BuildCostModel returns the installed cost model, a markup model over the registered
+// providers when COST_MARKUP is set, or nil to keep the provider-reported cost
+func BuildCostModel(cfg config.Config) CostModel {
+	costModelMu.RLock()
+	m := customCostModel
+	costModelMu.RUnlock()
+	if m != nil {
+		return m
+	}
+	if cfg.CostMarkup > 0 {
+		return &MarkupCostModel{Markup: cfg.CostMarkup, providers: router.GetProviders}
+	}
+	return nil
+}
+
+// billedCost applies costs to a successful completion, or keeps the provider's figure
+func billedCost(costs CostModel, provider, model string, promptTok, completionTok int64, reported float64) float64 {
+	if costs == nil {
+		return reported
+	}
+	return costs.Cost(provider, model, promptTok, completionTok)
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+func TestCostMarkupBillsListPricePlusMarkup(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest", CostMarkup: 0.2}
+	p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "chargeback", MeanMs: 1, P95Ms: 1, CostPer1k: 0.002})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})})
+
+	costBefore := testutil.ToFloat64(telemetry.CostUSDTotal.WithLabelValues("chargeback", "cheapest"))
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"model": "gpt-4o", "prompt": "hello world", "max_tokens": 100}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp InferResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	estimator := BuildTokenEstimator(cfg)
+	tokens := estimator.EstimatePromptTokens("hello world", "gpt-4o") + estimator.EstimateTokens(resp.Text, "gpt-4o")
+	want := 0.002 * float64(tokens) / 1000.0 * 1.2
+	if math.Abs(resp.CostUSD-want) > 1e-12 {
+		t.Errorf("expected cost %v (list price for %d tokens plus 20%%), got %v", want, tokens, resp.CostUSD)
+	}
+	if got := testutil.ToFloat64(telemetry.CostUSDTotal.WithLabelValues("chargeback", "cheapest")) - costBefore; math.Abs(got-want) > 1e-12 {
+		t.Errorf("expected cost counter to grow by %v, grew by %v", want, got)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/cache"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+// dedupedCall is one provider call's outcome, as handed to the requests sharing it
+type dedupedCall struct {
+	provider string
+	out      providers.CompletionResponse
+	cost     float64
+	latency  int64
+	err      error
+}
+
+type contentDedup = cache.Coalescer[dedupedCall]
+
+// newContentDedup returns nil unless ENABLE_CONTENT_DEDUP is set
+func newContentDedup(cfg config.Config) *contentDedup {
+	if !cfg.EnableContentDedup {
+		return nil
+	}
+	return cache.NewCoalescer[dedupedCall](cfg.ContentDedupWindow)
+}
+
+// contentDedupKey returns "" when dedup is off or the request can't share a call
+// (streaming, dry runs, forced providers, tools). Unlike the response cache the
+// policy isn't part of the key: identical content gets the same answer either way.
+// The tenant is, so a shared result only ever answers the tenant whose request paid
+// for it; its duplicates go uncharged, which is the double billing dedup prevents.
+func contentDedupKey(d *contentDedup, r *http.Request, tenantID string, req InferRequest) string {
+	if d == nil || req.Stream || isDryRun(r, req) || requestedProvider(r, req) != "" || len(req.Tools) > 0 {
+		return ""
+	}
+	return tenantID + "/" + cache.Key(req.Model, keyedPrompt(req), req.MaxTok, "")
+}
+
+// completeOnce makes call as p unless an identical request under key is already
+// making it or recently succeeded, in which case shared is true and that request's
+// outcome is returned. The call runs detached from ctx's cancellation so a leader
+// whose client hangs up doesn't fail the requests waiting on it; a waiter that gives
+// up gets its own ctx's error.
+func completeOnce(ctx context.Context, d *contentDedup, key string, p *providers.ResilientProvider, call func(context.Context) (providers.CompletionResponse, float64, int64, error)) (res dedupedCall, shared bool) {
+	if key == "" {
+		res.provider = p.Name()
+		res.out, res.cost, res.latency, res.err = call(ctx)
+		return res, false
+	}
+	res, shared, err := d.Do(ctx, key, func() (dedupedCall, bool) {
+		c := dedupedCall{provider: p.Name()}
+		c.out, c.cost, c.latency, c.err = call(context.WithoutCancel(ctx))
+		return c, c.err == nil
+	})
+	if err != nil {
+		res = dedupedCall{provider: p.Name(), err: err}
+	}
+	if shared {
+		telemetry.ContentDedupTotal.WithLabelValues("hit").Inc()
+	} else {
+		telemetry.ContentDedupTotal.WithLabelValues("miss").Inc()
+	}
+	return res, shared
+}
+
+// countShared records a request answered by an identical request's call. It made no
+// upstream calls of its own, so X-Router-Attempts is 0.
+func countShared(w http.ResponseWriter, r *http.Request, call dedupedCall, policy string) {
+	w.Header().Set(attemptsHeader, "0")
+	code := "200"
+	switch {
+	case call.err != nil && r.Context().Err() != nil:
+		code = "499"
+	case call.err != nil:
+		code = "502"
+	}
+	telemetry.RequestsTotal.WithLabelValues(call.provider, policy, code).Inc()
+}
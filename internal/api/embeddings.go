@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+	"github.com/rs/zerolog/log"
+)
+
+// maxEmbeddingInputs caps the texts in one embeddings request, as OpenAI does
+const maxEmbeddingInputs = 2048
+
+// embeddingsPolicy is the policy label embedding requests carry in metrics; they are
+// always routed to the lowest embedding price
+const embeddingsPolicy = "embeddings"
+
+// EmbeddingsRequest is the body of POST /v1/embeddings; input is one text or an array
+// of them, as in OpenAI's API
+type EmbeddingsRequest struct {
+	Input embeddingInput `json:"input"`
+	Model string         `json:"model,omitempty"` // empty uses EMBEDDING_MODEL
+}
+
+type embeddingInput []string
+
+func (in *embeddingInput) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*in = embeddingInput{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return errors.New("input must be a string or an array of strings")
+	}
+	*in = many
+	return nil
+}
+
+// Embedding is the vector for the input at Index
+type Embedding struct {
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// EmbeddingsResponse carries one embedding per input, in input order
+type EmbeddingsResponse struct {
+	Data      []Embedding `json:"data"`
+	Provider  string      `json:"provider"`
+	Model     string      `json:"model"`  // the embedding model used
+	Tokens    int64       `json:"tokens"` // input tokens billed, as reported by the provider or estimated
+	CostUSD   float64     `json:"cost_usd"`
+	LatencyMs int64       `json:"latency_ms"`
+	RequestID string      `json:"request_id"`
+}
+
+// HandleEmbeddings routes embeddings to the provider with the lowest embedding price
+// among those serving the model that the tenant, if any, may use; a model outside
+// the tenant's allowed models gets 403. It shares the providers, breakers and quotas
+// of the infer handler, so that must be set up first. Tokens count toward the
+// tenant's daily limit and, with a usageStore, are recorded as an embeddings usage
+// row.
+func HandleEmbeddings(cfg config.Config, usageStore *usage.Store) http.HandlerFunc {
+	estimator := BuildTokenEstimator(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		startTime := time.Now()
+		rw := NewResponseWriter(w, r)
+
+		var req EmbeddingsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			rw.WriteValidationError("body", "invalid JSON: "+err.Error())
+			return
+		}
+		if len(req.Input) == 0 || len(req.Input) > maxEmbeddingInputs {
+			rw.WriteValidationError("input", fmt.Sprintf("must hold between 1 and %d texts", maxEmbeddingInputs))
+			return
+		}
+		for i, text := range req.Input {
+			if text == "" {
+				rw.WriteValidationError("input", fmt.Sprintf("text %d is empty", i))
+				return
+			}
+		}
+		if req.Model == "" {
+			req.Model = cfg.EmbeddingModel
+		}
+
+		eng := router.GetEngine()
+		if eng == nil {
+			rw.WriteProviderError("router", fmt.Errorf("engine not ready"))
+			return
+		}
+		tenant, _ := auth.GetTenantFromContext(r.Context())
+		if tenant != nil && !tenant.ModelAllowed(req.Model) {
+			rw.WriteForbiddenError(fmt.Sprintf("model %s is not allowed for this tenant", req.Model))
+			return
+		}
+		if checkDailyTokens(rw, tenant, cfg.UsageWarningPct) {
+			return
+		}
+		var allow func(name string) bool
+		if tenant != nil && len(tenant.DeniedProviders) > 0 {
+			allow = tenant.ProviderAllowed
+		}
+		chosen := eng.ChooseEmbedder(req.Model, allow)
+		var ue *router.UnavailableError
+		if chosen == nil && errors.As(eng.Unavailable(), &ue) {
+			rw.WriteUnavailableError(ue)
+			return
+		}
+		if chosen == nil {
+			rw.WriteProviderError("router", fmt.Errorf("no embedding providers available for model %q", req.Model))
+			return
+		}
+
+		ctx := providers.WithAttemptBudget(r.Context(), providers.NewAttemptBudget(cfg.MaxTotalAttempts))
+		out, cost, latency, err := chosen.Embed(ctx, req.Input, req.Model)
+		cancelled := err != nil && r.Context().Err() != nil
+
+		model := out.Model
+		if model == "" {
+			model = req.Model
+		}
+		tokens := int64(out.Tokens)
+		if tokens == 0 {
+			for _, text := range req.Input {
+				tokens += estimator.EstimateTokens(text, req.Model)
+			}
+		}
+		code, reason := "200", ""
+		switch {
+		case cancelled:
+			code = "499"
+			telemetry.ClientCancellationsTotal.WithLabelValues(chosen.Name(), embeddingsPolicy).Inc()
+		case err != nil:
+			code, reason = "502", string(providers.KindOf(err))
+			telemetry.ErrorsTotal.WithLabelValues(chosen.Name(), reason).Inc()
+		default:
+			telemetry.CostUSDTotal.WithLabelValues(chosen.Name(), embeddingsPolicy).Add(cost)
+			telemetry.RequestCostUSD.WithLabelValues(chosen.Name()).Observe(cost)
+			telemetry.AddTokens(chosen.Name(), tokens, 0)
+		}
+		telemetry.RequestsTotal.WithLabelValues(chosen.Name(), embeddingsPolicy, code).Inc()
+		telemetry.ObserveLatency(ctx, chosen.Name(), embeddingsPolicy, float64(latency))
+		telemetry.CBState.WithLabelValues(chosen.Name()).Set(chosen.CBStateValue())
+
+		if tenant != nil && err == nil {
+			dailyTokens.AddTokens(tenant.TenantID, tokens)
+		}
+		if tenant != nil && usageStore != nil && !cancelled {
+			record := usage.UsageRecord{
+				TenantID:        tenant.TenantID,
+				Timestamp:       startTime,
+				RequestID:       rw.requestID,
+				Provider:        chosen.Name(),
+				Model:           model,
+				Kind:            usage.KindEmbeddings,
+				EstPromptTokens: tokens,
+				CostUSD:         cost,
+				LatencyMs:       latency,
+				Status:          "ok",
+			}
+			if err != nil {
+				record.EstPromptTokens, record.Status, record.ErrorKind = 0, "error", reason
+			}
+			if err := usageStore.RecordUsage(r.Context(), record); err != nil {
+				log.Error().Err(err).Msg("failed to record usage")
+			}
+		}
+
+		if cancelled {
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Str("provider", chosen.Name()).Str("error_kind", reason).Msg("embeddings failed")
+			rw.WriteProviderError(chosen.Name(), err)
+			return
+		}
+		resp := EmbeddingsResponse{
+			Data:      make([]Embedding, len(out.Vectors)),
+			Provider:  chosen.Name(),
+			Model:     model,
+			Tokens:    tokens,
+			CostUSD:   cost,
+			LatencyMs: latency,
+			RequestID: rw.requestID,
+		}
+		for i, v := range out.Vectors {
+			resp.Data[i] = Embedding{Index: i, Embedding: v}
+		}
+		if err := rw.WriteJSON(http.StatusOK, resp); err != nil {
+			log.Error().Err(err).Msg("encode embeddings response")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+)
+
+func TestEmbeddingsRouteToCheapestEmbedder(t *testing.T) {
+	mock := func(name string, per1k, completionPer1k float64) *providers.ResilientProvider {
+		p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: name, MeanMs: 1, P95Ms: 1, CostPer1k: completionPer1k, EmbeddingCostPer1k: per1k})
+		return providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})
+	}
+	// completion prices don't matter: pricey-embed has the cheaper completions
+	provs := []*providers.ResilientProvider{mock("cheap-embed", 0.00002, 0.01), mock("pricey-embed", 0.0001, 0.001)}
+	router.SetProviders(provs)
+	router.SetEngine(router.NewEngine(provs))
+	defer func() {
+		router.SetProviders(nil)
+		router.SetEngine(nil)
+	}()
+	handler := HandleEmbeddings(config.Config{EmbeddingModel: "text-embedding-3-small"}, nil)
+
+	embed := func(body string, tenant *auth.Tenant) (*httptest.ResponseRecorder, EmbeddingsResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+		if tenant != nil {
+			req = req.WithContext(auth.WithTenant(req.Context(), tenant))
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp EmbeddingsResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, resp
+	}
+
+	text := strings.Repeat("abcd", 250) // about 250 tokens
+	rec, resp := embed(`{"input": ["`+text+`", "`+text+`"]}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if resp.Provider != "cheap-embed" || resp.Model != "text-embedding-3-small" {
+		t.Errorf("expected the lower embedding price to win with EMBEDDING_MODEL, got %s %q", resp.Provider, resp.Model)
+	}
+	if len(resp.Data) != 2 || resp.Data[1].Index != 1 || len(resp.Data[0].Embedding) == 0 {
+		t.Fatalf("expected one embedding per input, got %+v", resp.Data)
+	}
+	if resp.Tokens <= 0 {
+		t.Fatalf("expected tokens to be counted, got %d", resp.Tokens)
+	}
+	if want := 0.00002 / 1000 * float64(resp.Tokens); resp.CostUSD < want*0.999 || resp.CostUSD > want*1.001 {
+		t.Errorf("expected cost %v for %d tokens, got %v", want, resp.Tokens, resp.CostUSD)
+	}
+
+	// a single string is one input
+	rec, resp = embed(`{"input": "hello"}`, nil)
+	if rec.Code != http.StatusOK || len(resp.Data) != 1 {
+		t.Fatalf("expected one embedding for a string input, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tenant := &auth.Tenant{TenantID: "t-embed", Enabled: true, DeniedProviders: []string{"cheap-embed"}}
+	before := dailyTokens.GetUsage(tenant.TenantID)
+	rec, resp = embed(`{"input": ["`+text+`"]}`, tenant)
+	if rec.Code != http.StatusOK || resp.Provider != "pricey-embed" {
+		t.Fatalf("expected a denied provider to be skipped, got %d %s: %s", rec.Code, resp.Provider, rec.Body.String())
+	}
+	if got := dailyTokens.GetUsage(tenant.TenantID) - before; got != resp.Tokens {
+		t.Errorf("expected %d tokens toward the daily limit, got %d", resp.Tokens, got)
+	}
+
+	// the tenant's allowed models apply as they do to infer
+	restricted := &auth.Tenant{TenantID: "t-embed-models", Enabled: true, AllowedModels: []string{"text-embedding-3-small"}}
+	if rec, _ := embed(`{"input": "hello"}`, restricted); rec.Code != http.StatusOK {
+		t.Errorf("expected the allowed default model to pass, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec, _ := embed(`{"input": "hello", "model": "text-embedding-3-large"}`, restricted); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a model the tenant may not use, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for name, body := range map[string]string{
+		"malformed":   `{"input": `,
+		"no input":    `{}`,
+		"empty text":  `{"input": ["a", ""]}`,
+		"wrong types": `{"input": [1, 2]}`,
+	} {
+		if rec, _ := embed(body, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestEmbeddingsRouteOnlyToProvidersServingTheModel(t *testing.T) {
+	stub := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "mock", MeanMs: 1, P95Ms: 1, EmbeddingCostPer1k: 0.00001})
+	openai := providers.NewOpenAIProviderWithOptions(providers.OpenAIOptions{Name: "openai-embed", BaseURL: "http://127.0.0.1:1"})
+	provs := []*providers.ResilientProvider{
+		providers.WithResilience(openai, providers.ResilienceOptions{CBWindowSize: 10}),
+		providers.WithResilience(stub, providers.ResilienceOptions{CBWindowSize: 10}),
+	}
+	eng := router.NewEngine(provs)
+
+	if p := eng.ChooseEmbedder("text-embedding-3-large", nil); p == nil || p.Name() != "mock" {
+		t.Errorf("expected the cheaper provider serving the model, got %v", p)
+	}
+	allowOpenAI := func(name string) bool { return name == "openai-embed" }
+	if p := eng.ChooseEmbedder("amazon.titan-embed-text-v2:0", allowOpenAI); p != nil {
+		t.Errorf("expected no provider for a model OpenAI doesn't serve, got %s", p.Name())
+	}
+	if p := eng.ChooseEmbedder("text-embedding-3-large", allowOpenAI); p == nil || p.Name() != "openai-embed" {
+		t.Errorf("expected openai for one of its models, got %v", p)
+	}
+}
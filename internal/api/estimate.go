@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/rs/zerolog/log"
+)
+
+// EstimateResponse is the side-effect-free cost preview from GET /v1/estimate
+type EstimateResponse struct {
+	Provider                  string  `json:"provider"`
+	Model                     string  `json:"model"`
+	Policy                    string  `json:"policy"`
+	EstimatedPromptTokens     int64   `json:"estimated_prompt_tokens"`
+	EstimatedCompletionTokens int64   `json:"estimated_completion_tokens"`
+	EstimatedCostUSD          float64 `json:"estimated_cost_usd"`
+}
+
+// HandleEstimate prices ?prompt= against the provider the policy would pick now for
+// the tenant, skipping its denied providers. Unlike a dry-run infer it uses Explain,
+// so canary traffic splits are not consumed.
+func HandleEstimate(cfg config.Config) http.HandlerFunc {
+	estimator := BuildTokenEstimator(cfg)
+	limits := requestLimits(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := NewResponseWriter(w, r)
+		q := r.URL.Query()
+
+		req := InferRequest{Model: q.Get("model"), Prompt: q.Get("prompt"), Policy: q.Get("policy")}
+		if s := q.Get("max_tokens"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				rw.WriteValidationError("max_tokens", "must be a positive integer")
+				return
+			}
+			req.MaxTok = n
+		}
+		if err := ValidateInferRequest(&req); err != nil {
+			rw.WriteValidationError("request", err.Error())
+			return
+		}
+		model, err := resolveModelAlias(cfg.ModelAliases, req.Model)
+		if err != nil {
+			rw.WriteValidationError("model", err.Error())
+			return
+		}
+		req.Model = model
+		if req.Policy == "" {
+			if p := router.GetDefaultPolicy(); p != "" {
+				req.Policy = p
+			} else {
+				req.Policy = cfg.DefaultPolicy
+			}
+		}
+
+		eng := router.GetEngine()
+		if eng == nil {
+			rw.WriteProviderError("router", fmt.Errorf("engine not ready"))
+			return
+		}
+		// route as infer would for the tenant, so a denied provider is never quoted
+		tenantID := ""
+		var allow func(name string) bool
+		if tenant, ok := auth.GetTenantFromContext(r.Context()); ok {
+			tenantID = tenant.TenantID
+			if len(tenant.DeniedProviders) > 0 {
+				allow = tenant.ProviderAllowed
+			}
+		}
+		ex := eng.ExplainFor(tenantID, req.Policy, req.Model, allow)
+		var chosen *providers.ResilientProvider
+		for _, p := range router.GetProviders() {
+			if p.Name() == ex.Chosen {
+				chosen = p
+			}
+		}
+		var ue *router.UnavailableError
+		if chosen == nil && errors.As(eng.Unavailable(), &ue) {
+			rw.WriteUnavailableError(ue)
+			return
+		}
+		if chosen == nil {
+			rw.WriteProviderError("router", fmt.Errorf("no providers available for model %s", req.Model))
+			return
+		}
+		if req.Model == "" {
+			req.Model = defaultModelFor(chosen, cfg.OpenAIModel)
+		}
+		if err := limits.Check(req); err != nil {
+			rw.WriteValidationError("request", err.Error())
+			return
+		}
+		costPer1k := chosen.CostPer1kTokensUSD(req.Model)
+
+		resp := EstimateResponse{
+			Provider:              chosen.Name(),
+			Model:                 req.Model,
+			Policy:                req.Policy,
+			EstimatedPromptTokens: estimatePromptTokens(estimator, req),
+		}
+		if req.MaxTok > 0 {
+			resp.EstimatedCompletionTokens = int64(req.MaxTok)
+		} else {
+			resp.EstimatedCompletionTokens = estimateCompletionTokens(estimator, req)
+		}
+		resp.EstimatedCostUSD = costPer1k * float64(resp.EstimatedPromptTokens+resp.EstimatedCompletionTokens) / 1000.0
+
+		if err := rw.WriteJSON(http.StatusOK, resp); err != nil {
+			log.Error().Err(err).Msg("encode estimate response")
+		}
+	}
+}
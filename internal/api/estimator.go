@@ -0,0 +1,25 @@
+package api
+
+import (
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+	"github.com/rs/zerolog/log"
+)
+
+// BuildTokenEstimator returns the ratio estimator, upgraded to exact BPE counts
+// for the configured model prefixes when a rank file is available
+func BuildTokenEstimator(cfg config.Config) *usage.TokenEstimator {
+	estimator := usage.NewTokenEstimator()
+	if cfg.TokenizerBPEPath == "" {
+		return estimator
+	}
+	tok, err := usage.LoadBPETokenizer(cfg.TokenizerBPEPath)
+	if err != nil {
+		log.Warn().Err(err).Msg("bpe tokenizer load failed; using ratio estimates")
+		return estimator
+	}
+	for _, prefix := range cfg.TokenizerBPEModels {
+		estimator.RegisterTokenizer(prefix, tok)
+	}
+	return estimator
+}
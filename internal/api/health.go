@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+)
+
+// ProviderReadiness is one provider's contribution to readiness
+type ProviderReadiness struct {
+	Name    string  `json:"name"`
+	CBState float64 `json:"cb_state"` // 0=open, 1=half-open, 2=closed
+	Enabled bool    `json:"enabled"`
+	Healthy bool    `json:"healthy"`
+}
+
+// ReadinessResponse is the JSON form of /v1/readyz
+type ReadinessResponse struct {
+	Ready     bool                `json:"ready"`
+	Reason    string              `json:"reason,omitempty"`
+	Providers []ProviderReadiness `json:"providers"`
+}
+
+// draining fails readiness so load balancers stop sending new work, while requests
+// already accepted are still served
+var draining atomic.Bool
+
+// SetDraining takes the instance out of load balancer rotation, or puts it back
+func SetDraining(on bool) { draining.Store(on) }
+
+// Draining reports whether the instance has been drained
+func Draining() bool { return draining.Load() }
+
+// readiness reports ready when any provider could take a request, by the test routing
+// uses (enabled, with a breaker closed or due a half-open probe), and the instance
+// isn't draining
+func readiness() ReadinessResponse {
+	resp := ReadinessResponse{Providers: []ProviderReadiness{}}
+	for _, p := range router.GetProviders() {
+		pr := ProviderReadiness{Name: p.Name(), CBState: p.CBStateValue(), Enabled: p.Enabled()}
+		pr.Healthy = p.Available()
+		resp.Ready = resp.Ready || pr.Healthy
+		resp.Providers = append(resp.Providers, pr)
+	}
+	switch {
+	case len(resp.Providers) == 0:
+		resp.Reason = "no providers"
+	case !resp.Ready:
+		resp.Reason = "all providers tripped or disabled"
+	}
+	if draining.Load() {
+		resp.Ready = false
+		resp.Reason = "draining"
+	}
+	return resp
+}
+
+// HandleReadyz serves plain text by default and per-provider detail for Accept: application/json
+func HandleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := readiness()
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			if !resp.Ready {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+			return
+		}
+		if !resp.Ready {
+			http.Error(w, resp.Reason, http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ready"))
+	}
+}
+
+// Heartbeat tracks progress on the infer path. It only counts as stalled when
+// requests are in flight and none has completed within the window, so an idle
+// server stays live.
+type Heartbeat struct {
+	mu           sync.Mutex
+	inFlight     int
+	lastProgress time.Time
+	now          func() time.Time
+}
+
+// NewHeartbeat creates a heartbeat using the wall clock
+func NewHeartbeat() *Heartbeat {
+	return &Heartbeat{now: time.Now}
+}
+
+// Begin marks a request in flight; call the returned func when it completes
+func (h *Heartbeat) Begin() func() {
+	h.mu.Lock()
+	if h.inFlight == 0 {
+		// the stall clock starts when work arrives at an idle server
+		h.lastProgress = h.now()
+	}
+	h.inFlight++
+	h.mu.Unlock()
+	return func() {
+		h.mu.Lock()
+		h.inFlight--
+		h.lastProgress = h.now()
+		h.mu.Unlock()
+	}
+}
+
+// InFlight is the number of requests begun and not yet completed
+func (h *Heartbeat) InFlight() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.inFlight
+}
+
+// Stalled reports whether in-flight requests have made no progress for window
+func (h *Heartbeat) Stalled(window time.Duration) (bool, int, time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	since := h.now().Sub(h.lastProgress)
+	return h.inFlight > 0 && since > window, h.inFlight, since
+}
+
+// inferHeartbeat is beaten by both infer handlers and read by /v1/healthz
+var inferHeartbeat = NewHeartbeat()
+
+// HandleHealthz is the liveness probe: it fails only when the infer path is
+// wedged, so provider outages affect readiness but never trigger a restart
+func HandleHealthz(stallWindow time.Duration) http.HandlerFunc {
+	return handleHealthz(inferHeartbeat, stallWindow)
+}
+
+func handleHealthz(hb *Heartbeat, stallWindow time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if stalled, inFlight, since := hb.Stalled(stallWindow); stalled {
+			http.Error(w, fmt.Sprintf("stalled: %d infer requests in flight, none completed for %s", inFlight, since.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}
+}
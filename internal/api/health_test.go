@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+)
+
+func TestReadyzContentTypes(t *testing.T) {
+	a := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "a"}), providers.ResilienceOptions{})
+	b := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "b"}), providers.ResilienceOptions{})
+	router.SetProviders([]*providers.ResilientProvider{a, b})
+	defer router.SetProviders(nil)
+	b.SetEnabled(false)
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/readyz", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		HandleReadyz().ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	if rr.Code != http.StatusOK || rr.Body.String() != "ready" {
+		t.Errorf("plain text: expected 200 ready, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = get("application/json")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("json: expected 200 application/json, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var resp ReadinessResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []ProviderReadiness{
+		{Name: "a", CBState: 2, Enabled: true, Healthy: true},
+		{Name: "b", CBState: 2, Enabled: false, Healthy: false},
+	}
+	if !resp.Ready || len(resp.Providers) != 2 || resp.Providers[0] != want[0] || resp.Providers[1] != want[1] {
+		t.Errorf("unexpected readiness %+v", resp)
+	}
+
+	// with every provider out of rotation both forms report 503
+	a.SetEnabled(false)
+	rr = get("")
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "all providers tripped or disabled") {
+		t.Errorf("plain text: expected 503, got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = get("application/json")
+	resp = ReadinessResponse{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rr.Code != http.StatusServiceUnavailable || resp.Ready || resp.Reason == "" {
+		t.Errorf("json: expected 503 not ready with reason, got %d %+v", rr.Code, resp)
+	}
+}
+
+func TestHealthzFailsOnStalledHeartbeat(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	hb := &Heartbeat{now: func() time.Time { return now }}
+	h := handleHealthz(hb, time.Minute)
+	status := func() int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthz", nil))
+		return rr.Code
+	}
+
+	// an idle server is live no matter how long it has been idle
+	now = now.Add(time.Hour)
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("idle: expected 200, got %d", got)
+	}
+
+	// a slow request inside the window is fine
+	done := hb.Begin()
+	now = now.Add(30 * time.Second)
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("in window: expected 200, got %d", got)
+	}
+
+	// completions from other requests keep it live while one hangs
+	hb.Begin()()
+	now = now.Add(45 * time.Second)
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("after progress: expected 200, got %d", got)
+	}
+
+	// nothing completes past the window: wedged
+	now = now.Add(30 * time.Second)
+	if got := status(); got != http.StatusServiceUnavailable {
+		t.Fatalf("stalled: expected 503, got %d", got)
+	}
+
+	// the stuck request finishing restores liveness
+	done()
+	if got := status(); got != http.StatusOK {
+		t.Fatalf("recovered: expected 200, got %d", got)
+	}
+}
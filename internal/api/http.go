@@ -1,14 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/cache"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
@@ -19,75 +24,241 @@ import (
 )
 
 type InferRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	MaxTok int    `json:"max_tokens,omitempty"`
-	Stream bool   `json:"stream,omitempty"`
-	Policy string `json:"policy,omitempty"` // e.g., cheapest|fastest_p95|slo_burn_aware|canary
+	Model          string              `json:"model"`
+	Models         []string            `json:"models,omitempty"` // acceptable models in preference order, instead of model; the first one usable for this request is used
+	Prompt         string              `json:"prompt"`
+	Template       string              `json:"template,omitempty"`  // named server-side prompt rendered into prompt; excludes prompt
+	Variables      map[string]string   `json:"variables,omitempty"` // fills the template's {{name}} placeholders
+	Messages       []providers.Message `json:"messages,omitempty"`  // system/developer/user/assistant turns; prompt is appended as a final user turn
+	MaxTok         int                 `json:"max_tokens,omitempty"`
+	Truncate       bool                `json:"truncate,omitempty"` // trim the oldest turns and the front of the prompt to fit the model's context window instead of failing with 422
+	Stream         bool                `json:"stream,omitempty"`
+	Policy         string              `json:"policy,omitempty"` // e.g., cheapest|fastest_p95|slo_burn_aware|canary|latency_slo
+	DryRun         bool                `json:"dry_run,omitempty"`
+	Provider       *string             `json:"provider,omitempty"`        // skip the policy and call this provider; also ?provider=
+	Priority       string              `json:"priority,omitempty"`        // high|normal|low; defaults to the tenant's class
+	ConversationID string              `json:"conversation_id,omitempty"` // with STICKY_CONVERSATION_TTL set, turns stay on one provider while it is healthy
+	Tools          []providers.Tool    `json:"tools,omitempty"`           // functions the model may call; only tool-capable providers are routed to
+	ToolChoice     string              `json:"tool_choice,omitempty"`     // auto|none|required or a tool name
 }
 
 type InferResponse struct {
-	Provider  string  `json:"provider"`
-	Text      string  `json:"text"`
-	CostUSD   float64 `json:"cost_usd"`
-	LatencyMs int64   `json:"latency_ms"`
-	RequestID string  `json:"request_id"`
+	Provider        string               `json:"provider"`
+	Model           string               `json:"model,omitempty"` // the model used, which with models set may not be the first
+	Text            string               `json:"text"`
+	CostUSD         float64              `json:"cost_usd"`
+	LatencyMs       int64                `json:"latency_ms"`
+	RequestID       string               `json:"request_id"`
+	ToolCalls       []providers.ToolCall `json:"tool_calls,omitempty"`
+	Comparisons     []ProviderComparison `json:"comparisons,omitempty"`      // ?compare=1: the same tokens priced on every enabled provider
+	Attempts        int                  `json:"attempts"`                   // upstream calls made for this request, retries included; 0 when served from cache or a shared call
+	FailedProviders []string             `json:"failed_providers,omitempty"` // providers with a failed attempt before the answer, in order
 }
 
-func HandleInfer(cfg config.Config) http.HandlerFunc {
-	// Build providers with resilience once per handler creation
-	provs := make([]*providers.ResilientProvider, 0, 2)
-	if cfg.OpenAIKey != "" {
-		op := providers.NewOpenAIProvider(cfg.OpenAIKey)
-		provs = append(provs, providers.WithResilience(op, providers.ResilienceOptions{
-			Timeout:      30 * 1_000_000_000, // 30s
-			MaxRetries:   2,
-			BaseBackoff:  200 * 1_000_000,   // 200ms
-			MaxBackoff:   2 * 1_000_000_000, // 2s
-			JitterFrac:   0.2,
-			CBWindowSize: 20,
-			CBCooldown:   30 * 1_000_000_000, // 30s
-		}))
+// DryRunResponse is returned instead of InferResponse when no provider call is made
+type DryRunResponse struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model"`
+	Policy           string  `json:"policy"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	EstimatedTokens  int64   `json:"estimated_tokens"`
+	RequestID        string  `json:"request_id,omitempty"`
+}
+
+// isDryRun reports whether the request asked for routing only, via body or ?dry_run=1
+func isDryRun(r *http.Request, req InferRequest) bool {
+	if req.DryRun {
+		return true
+	}
+	v, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return err == nil && v
+}
+
+// requestedProvider returns the provider named in the body or ?provider=, if any
+func requestedProvider(r *http.Request, req InferRequest) string {
+	if req.Provider != nil {
+		return *req.Provider
+	}
+	return r.URL.Query().Get("provider")
+}
+
+// forceProvider resolves a requested provider, bypassing the policy. The returned
+// status (404 unknown, 400 disabled or unable to take the request's tools, 403 denied
+// to the tenant) is set on error.
+func forceProvider(eng *router.Engine, tenant *auth.Tenant, name string, tools bool) (*providers.ResilientProvider, int, error) {
+	p, err := eng.Force(name)
+	switch {
+	case errors.Is(err, router.ErrUnknownProvider):
+		return nil, http.StatusNotFound, fmt.Errorf("provider %s", name)
+	case err != nil:
+		return nil, http.StatusBadRequest, fmt.Errorf("provider %s: %w", name, err)
+	}
+	if tenant != nil && !tenant.ProviderAllowed(name) {
+		return nil, http.StatusForbidden, fmt.Errorf("provider %s is not allowed for this tenant", name)
+	}
+	if tools && !p.SupportsTools() {
+		return nil, http.StatusBadRequest, fmt.Errorf("provider %s does not support tools", name)
 	}
-	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_PROFILE") != "" {
-		if br, err := providers.NewBedrockProvider(cfg.BedrockModelID, cfg.BedrockRegion); err == nil {
-			provs = append(provs, providers.WithResilience(br, providers.ResilienceOptions{
-				Timeout:      30 * 1_000_000_000,
-				MaxRetries:   2,
-				BaseBackoff:  200 * 1_000_000,
-				MaxBackoff:   2 * 1_000_000_000,
-				JitterFrac:   0.2,
-				CBWindowSize: 20,
-				CBCooldown:   30 * 1_000_000_000,
-			}))
-		} else {
-			log.Warn().Err(err).Msg("bedrock init failed")
+	return p, 0, nil
+}
+
+// toolCapableProviders reports, by name, which registered providers can take tools
+func toolCapableProviders() map[string]bool {
+	capable := map[string]bool{}
+	for _, p := range router.GetProviders() {
+		capable[p.Name()] = p.SupportsTools()
+	}
+	return capable
+}
+
+// anyToolCapable reports whether any registered provider can take tools at all
+func anyToolCapable() bool {
+	for _, ok := range toolCapableProviders() {
+		if ok {
+			return true
 		}
 	}
-	// Optional Mock provider for local/dev testing
-	if cfg.EnableMockProvider {
-		mp := providers.NewMockProvider(float64(cfg.MockMeanLatencyMs), float64(cfg.MockP95LatencyMs), cfg.MockErrorRate, cfg.MockCostPer1kUSD)
-		provs = append(provs, providers.WithResilience(mp, providers.ResilienceOptions{
-			Timeout:      30 * 1_000_000_000,
-			MaxRetries:   1,
-			BaseBackoff:  100 * 1_000_000,
-			MaxBackoff:   1 * 1_000_000_000,
-			JitterFrac:   0.2,
-			CBWindowSize: 20,
-			CBCooldown:   10 * 1_000_000_000,
-		}))
+	return false
+}
+
+// recordCanaryResult feeds the outcome and, for a success, its billed cost to the
+// engine and logs any canary stage transition it caused
+func recordCanaryResult(eng *router.Engine, provider string, failed bool, cost float64) {
+	before := eng.CanaryLastTransition()
+	if !failed {
+		eng.RecordCanaryCost(provider, cost)
 	}
+	eng.RecordResult(provider, failed)
+	telemetry.CanaryStage.Set(eng.CanaryPercent())
+	if at := eng.CanaryLastTransition(); !at.Equal(before) {
+		ev := map[string]any{
+			"candidate": eng.CanaryCandidateProvider(),
+			"reason":    eng.CanaryLastReason(),
+			"stage":     eng.CanaryStageIndex(),
+			"percent":   eng.CanaryPercent(),
+		}
+		log.Info().Str("event", events.CanaryTransition).Fields(ev).Msg("canary stage changed")
+		events.Publish(events.CanaryTransition, ev)
+	}
+}
+
+// completionRequest maps an API request onto the provider request
+func completionRequest(req InferRequest) providers.CompletionRequest {
+	return providers.CompletionRequest{Model: req.Model, Prompt: req.Prompt, Messages: req.Messages, MaxTok: req.MaxTok, Stream: req.Stream, Tools: req.Tools, ToolChoice: req.ToolChoice}
+}
+
+// messageContents returns the text of every turn sent to the provider
+func messageContents(req InferRequest) []string {
+	msgs := completionRequest(req).ChatMessages()
+	out := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+// estimatePromptTokens estimates input tokens for a single prompt or a multi-turn conversation
+func estimatePromptTokens(estimator *usage.TokenEstimator, req InferRequest) int64 {
+	if len(req.Messages) == 0 {
+		return estimator.EstimatePromptTokens(req.Prompt, req.Model)
+	}
+	return estimator.EstimateMessagesTokens(messageContents(req), req.Model)
+}
+
+// policyTimeout gives the provider calls of a request routed by policy that policy's
+// POLICY_TIMEOUTS entry, if any
+func policyTimeout(ctx context.Context, cfg config.Config, policy string) context.Context {
+	if d, ok := cfg.PolicyTimeouts[policy]; ok {
+		return providers.WithCallTimeout(ctx, d)
+	}
+	return ctx
+}
+
+// estimateCompletionTokens guesses output tokens when the provider response is unavailable
+func estimateCompletionTokens(estimator *usage.TokenEstimator, req InferRequest) int64 {
+	return estimator.EstimateCompletionTokens(strings.Join(messageContents(req), "\n"), req.Model)
+}
+
+// dryRun estimates tokens and cost for the chosen provider without calling it
+func dryRun(estimator *usage.TokenEstimator, chosen *providers.ResilientProvider, req InferRequest) DryRunResponse {
+	tokens := estimatePromptTokens(estimator, req)
+	if req.MaxTok > 0 {
+		tokens += int64(req.MaxTok)
+	} else {
+		tokens += estimateCompletionTokens(estimator, req)
+	}
+	return DryRunResponse{
+		Provider:         chosen.Name(),
+		Model:            req.Model,
+		Policy:           req.Policy,
+		EstimatedCostUSD: chosen.CostPer1kTokensUSD(req.Model) * float64(tokens) / 1000.0,
+		EstimatedTokens:  tokens,
+	}
+}
+
+// chooseProvider routes req with the engine, rerouting around providers the tenant is
+// denied, sending canary-targeted tenants to the candidate and keeping a conversation
+// on its provider when stickiness is on
+func chooseProvider(eng *router.Engine, tenant *auth.Tenant, req InferRequest) *providers.ResilientProvider {
+	conversation := req.ConversationID
+	if conversation != "" && tenant != nil {
+		// one tenant's conversation IDs never pin another's
+		conversation = tenant.TenantID + "/" + conversation
+	}
+	tenantID := ""
+	if tenant != nil {
+		tenantID = tenant.TenantID
+	}
+	restricted := tenant != nil && len(tenant.DeniedProviders) > 0
+	if !restricted && len(req.Tools) == 0 {
+		return eng.ChooseForTenant(tenantID, conversation, req.Policy, req.Model, nil)
+	}
+	// tools narrow the choice to providers that can pass them through
+	toolCapable := toolCapableProviders()
+	return eng.ChooseForTenant(tenantID, conversation, req.Policy, req.Model, func(name string) bool {
+		if restricted && !tenant.ProviderAllowed(name) {
+			return false
+		}
+		return len(req.Tools) == 0 || toolCapable[name]
+	})
+}
+
+// HandleInfer serves /v1/infer without a usage store, so nothing is recorded per
+// request and X-Cost-Tags is ignored; HandleInferWithUsageTracking records both
+func HandleInfer(cfg config.Config) http.HandlerFunc {
+	// Build providers with resilience once per handler creation
+	return handleInfer(cfg, BuildProviders(cfg))
+}
+
+func handleInfer(cfg config.Config, provs []*providers.ResilientProvider) http.HandlerFunc {
 	// publish providers to registry for readiness checks
 	router.SetProviders(provs)
 	eng := router.NewEngine(provs)
-	eng.ConfigureCanary(cfg.CanaryStages, cfg.CanaryWindow, cfg.CanaryBurnMultiplier)
+	eng.ConfigureCanary(cfg.CanaryStages, router.CanaryWindow{Calls: cfg.CanaryWindow, Dwell: cfg.CanaryMinDwell}, cfg.CanaryBurnMultiplier)
+	eng.SetCanaryMaxP95Ratio(cfg.CanaryMaxP95Ratio)
+	eng.SetCanaryMinSavingsPct(cfg.CanaryMinSavingsPct)
+	eng.SetCanaryMode(router.CanaryMode(cfg.CanaryMode))
+	eng.SetCanaryTenants(cfg.CanaryTenants)
+	eng.SetFastestP95Options(cfg.FastestP95MinSamples, cfg.FastestP95HalfLife)
+	eng.SetLatencySLO(cfg.LatencySLOP95)
+	eng.SetStickiness(cfg.StickyConversationTTL, cfg.StickyConversationsMax)
 	router.SetEngine(eng)
 	if cfg.DefaultPolicy != "" {
 		router.SetDefaultPolicy(cfg.DefaultPolicy)
 	}
 	// export initial canary stage metric
 	telemetry.CanaryStage.Set(eng.CanaryPercent())
+	estimator := BuildTokenEstimator(cfg)
+	costs := BuildCostModel(cfg)
+	limits := requestLimits(cfg)
+	windows := buildContextWindows(cfg)
+	fits := viableModel(limits, windows, estimator)
+	respCache := newResponseCache(cfg)
+	dedup := newContentDedup(cfg)
+	templates := BuildPromptTemplates(cfg)
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer inferHeartbeat.Begin()()
+		timing := newServerTiming()
 		rw := NewResponseWriter(w, r)
 		
 		var req InferRequest
@@ -101,6 +272,17 @@ func HandleInfer(cfg config.Config) http.HandlerFunc {
 			rw.WriteValidationError("request", err.Error())
 			return
 		}
+		if err := renderTemplate(templates, &req); err != nil {
+			rw.WriteValidationError("template", err.Error())
+			return
+		}
+		model, err := resolveModelAlias(cfg.ModelAliases, req.Model)
+		if err != nil {
+			rw.WriteValidationError("model", err.Error())
+			return
+		}
+		req.Model = model
+		askedModel := req.Model
 
 		if req.Policy == "" {
 			// use runtime default policy which admin can update
@@ -110,16 +292,122 @@ func HandleInfer(cfg config.Config) http.HandlerFunc {
 				req.Policy = cfg.DefaultPolicy
 			}
 		}
+		// tenant is only set when the route sits behind API key auth
+		tenant, _ := auth.GetTenantFromContext(r.Context())
+		if checkDailyTokens(rw, tenant, cfg.UsageWarningPct) {
+			return
+		}
+
+		var chosen *providers.ResilientProvider
+		if name := requestedProvider(r, req); name != "" {
+			p, status, err := forceProvider(eng, tenant, name, len(req.Tools) > 0)
+			switch status {
+			case http.StatusNotFound:
+				rw.WriteNotFoundError(err.Error())
+				return
+			case http.StatusForbidden:
+				rw.WriteForbiddenError(err.Error())
+				return
+			case http.StatusBadRequest:
+				rw.WriteValidationError("provider", err.Error())
+				return
+			}
+			chosen = p
+			req.Policy = string(router.Forced)
+		}
+		if len(req.Models) > 0 {
+			t0 := time.Now()
+			model, p, err := pickModel(cfg.ModelAliases, eng, tenant, fits, req, chosen)
+			timing.routed(t0)
+			if err != nil {
+				rw.WriteValidationError("models", err.Error())
+				return
+			}
+			req.Model, askedModel = model, model
+			if p != nil {
+				chosen = p
+			}
+		}
+
+		// without a model, route first and use the chosen provider's default
 		if req.Model == "" {
-			req.Model = cfg.OpenAIModel
+			if chosen == nil {
+				t0 := time.Now()
+				chosen = chooseProvider(eng, tenant, req)
+				timing.routed(t0)
+			}
+			req.Model = defaultModelFor(chosen, cfg.OpenAIModel)
+		}
+		if err := limits.Check(req); err != nil {
+			rw.WriteValidationError("request", err.Error())
+			return
+		}
+
+		tenantID := ""
+		if tenant != nil {
+			if !tenant.ModelAllowed(req.Model) {
+				rw.WriteForbiddenError(fmt.Sprintf("model %s is not allowed for this tenant", req.Model))
+				return
+			}
+			tenantID = tenant.TenantID
+		}
+		if window, over := windows.fit(estimator, &req); over > 0 {
+			rw.WriteContextWindowError(req.Model, window, over)
+			return
+		}
+
+		// Serve repeated prompts from the response cache without a provider call or cost
+		cacheKey := responseCacheKey(respCache, r, tenantID, req)
+		if cacheKey != "" {
+			if hit, ok := respCache.Get(cacheKey); ok {
+				telemetry.ResponseCacheTotal.WithLabelValues("hit").Inc()
+				w.Header().Set("X-Cache", "HIT")
+				timing.set(w)
+				resp := InferResponse{Provider: hit.Provider, Model: req.Model, Text: hit.Text, RequestID: rw.requestID}
+				if err := rw.WriteJSON(http.StatusOK, resp); err != nil {
+					log.Error().Err(err).Msg("encode response")
+				}
+				return
+			}
+			telemetry.ResponseCacheTotal.WithLabelValues("miss").Inc()
+			w.Header().Set("X-Cache", "MISS")
 		}
 
 		// Choose provider via policy engine
-		chosen := eng.Choose(req.Policy, req.Model)
+		if chosen == nil {
+			t0 := time.Now()
+			chosen = chooseProvider(eng, tenant, req)
+			timing.routed(t0)
+		}
+		if chosen == nil {
+			var ue *router.UnavailableError
+			if errors.As(eng.Unavailable(), &ue) {
+				rw.WriteUnavailableError(ue)
+				return
+			}
+		}
+		if chosen == nil && tenant != nil && len(tenant.DeniedProviders) > 0 {
+			rw.WriteForbiddenError("no provider permitted for this tenant is available")
+			return
+		}
+		if chosen == nil && len(req.Tools) > 0 && !anyToolCapable() {
+			rw.WriteValidationError("tools", "no configured provider supports tools")
+			return
+		}
 		if chosen == nil {
 			rw.WriteProviderError("router", fmt.Errorf("no providers available for model %s", req.Model))
 			return
 		}
+		telemetry.AnnotateProvider(r.Context(), chosen.Name())
+		if isDryRun(r, req) {
+			resp := dryRun(estimator, chosen, req)
+			resp.RequestID = rw.requestID
+			timing.set(w)
+			if err := rw.WriteJSON(http.StatusOK, resp); err != nil {
+				log.Error().Err(err).Msg("encode response")
+			}
+			return
+		}
 		// Start span
 		tracer := otel.Tracer("llm-router")
 		ctx, span := tracer.Start(r.Context(), "infer")
@@ -128,25 +416,95 @@ func HandleInfer(cfg config.Config) http.HandlerFunc {
 			attribute.String("model", req.Model),
 			attribute.String("provider", chosen.Name()),
 		)
+		span.SetAttributes(promptAttributes(cfg.PromptLogging, req)...)
 		defer span.End()
 		// Call provider
-		pReq := providers.CompletionRequest{Model: req.Model, Prompt: req.Prompt, MaxTok: req.MaxTok, Stream: req.Stream}
-		out, cost, latency, err := chosen.Complete(ctx, pReq)
+		pReq := completionRequest(req)
+		promptTokens := estimatePromptTokens(estimator, req)
+		stream := newSSEStream(w, req)
+		if stream != nil {
+			stream.meter = newStreamMeter(cfg.StreamUsageCheckpointTokens, tenant, estimator, req.Model, promptTokens)
+		}
+		budget := providers.NewAttemptBudget(cfg.MaxTotalAttempts)
+		callStart := time.Now()
+		call, shared := completeOnce(ctx, dedup, contentDedupKey(dedup, r, tenantID, req), chosen, func(ctx context.Context) (providers.CompletionResponse, float64, int64, error) {
+			return stream.complete(policyTimeout(ctx, cfg, req.Policy), w, budget, chosen, pReq)
+		})
+		timing.providerDone(callStart)
+		if shared {
+			// an identical request's call answered this one, so there's nothing to bill
+			w.Header().Set("X-Dedup", "HIT")
+			timing.set(w)
+			countShared(w, r, call, req.Policy)
+			if call.err != nil {
+				if r.Context().Err() == nil {
+					rw.WriteProviderError(call.provider, call.err)
+				}
+				return
+			}
+			resp := InferResponse{Provider: call.provider, Model: req.Model, Text: call.out.Text, RequestID: rw.requestID}
+			if err := rw.WriteJSON(http.StatusOK, resp); err != nil {
+				log.Error().Err(err).Msg("encode response")
+			}
+			return
+		}
+		out, cost, latency, err := call.out, call.cost, call.latency, call.err
 		failed := err != nil
-		eng.RecordResult(chosen.Name(), failed)
-		telemetry.CanaryStage.Set(eng.CanaryPercent())
+		// a client that hung up says nothing about the provider's health
+		cancelled := clientGone(r, err)
+		// nor does a stream we stopped at the tenant's token limit
+		limited := errors.As(err, new(*tokenLimitError))
+		completionTokens := estimator.EstimateTokens(out.Text, req.Model)
+		if stream != nil && stream.meter != nil {
+			completionTokens = stream.meter.completionTokens()
+		}
+		if !failed {
+			cost = billedCost(costs, chosen.Name(), req.Model, promptTokens, completionTokens, cost)
+		}
+		if !cancelled && !limited {
+			recordCanaryResult(eng, chosen.Name(), failed, cost)
+		}
+		countDailyTokens(tenant, stream, promptTokens, completionTokens)
+		// a stream's totals go out in its final event, ahead of metrics
+		streamed := false
+		if stream != nil && !cancelled {
+			streamed = stream.finish(StreamDone{
+				Provider:         chosen.Name(),
+				Model:            req.Model,
+				CostUSD:          cost,
+				LatencyMs:        latency,
+				PromptTokens:     promptTokens,
+				CompletionTokens: completionTokens,
+				RequestID:        rw.requestID,
+			}, err)
+		}
 		// Metrics
 		code := "200"
 		reason := ""
 		if err != nil {
 			code = "502"
-			reason = "provider_error"
+			reason = string(providers.KindOf(err))
+		}
+		if cancelled {
+			code = "499" // client closed request
+			reason = string(providers.KindCancelled)
+		}
+		if limited {
+			code = "429"
+			reason = errorKindTokenLimit
 		}
 		telemetry.RequestsTotal.WithLabelValues(chosen.Name(), req.Policy, code).Inc()
-		telemetry.LatencyMs.WithLabelValues(chosen.Name(), req.Policy).Observe(float64(latency))
-		if !failed {
-			telemetry.CostUSDTotal.WithLabelValues(chosen.Name()).Add(cost)
-		} else {
+		telemetry.ObserveLatency(ctx, chosen.Name(), req.Policy, float64(latency))
+		switch {
+		case !failed:
+			telemetry.CostUSDTotal.WithLabelValues(chosen.Name(), req.Policy).Add(cost)
+			telemetry.RequestCostUSD.WithLabelValues(chosen.Name()).Observe(cost)
+			telemetry.AddTokens(chosen.Name(), promptTokens, completionTokens)
+		case cancelled:
+			telemetry.ClientCancellationsTotal.WithLabelValues(chosen.Name(), req.Policy).Inc()
+		case limited:
+			// the tenant's limit, not a provider error
+		default:
 			telemetry.ErrorsTotal.WithLabelValues(chosen.Name(), reason).Inc()
 		}
 		// Span attrs
@@ -154,42 +512,48 @@ func HandleInfer(cfg config.Config) http.HandlerFunc {
 			attribute.Float64("cost_usd", cost),
 			attribute.Int64("latency_ms", latency),
 			attribute.Bool("success", !failed),
+			attribute.String("error.kind", reason),
 		)
 		// Export CB state gauge
 		if rp, ok := any(chosen).(*providers.ResilientProvider); ok {
 			telemetry.CBState.WithLabelValues(chosen.Name()).Set(rp.CBStateValue())
 		}
-		// Burn rate windows
-		if rp, ok := any(chosen).(*providers.ResilientProvider); ok {
-			er1m := rp.Stats().ErrorRateSince(1 * 60 * 1e9)
-			er5m := rp.Stats().ErrorRateSince(5 * 60 * 1e9)
-			er1h := rp.Stats().ErrorRateSince(60 * 60 * 1e9)
-			// Assume SLO target 1%
-			burn1m := er1m / 0.01
-			burn5m := er5m / 0.01
-			burn1h := er1h / 0.01
-			telemetry.BurnRate.WithLabelValues("1m").Set(burn1m)
-			telemetry.BurnRate.WithLabelValues("5m").Set(burn5m)
-			telemetry.BurnRate.WithLabelValues("1h").Set(burn1h)
-			if burn1m > 1.0 || burn5m > 1.0 || burn1h > 1.0 {
-				log.Warn().Str("provider", chosen.Name()).Float64("burn_1m", burn1m).Float64("burn_5m", burn5m).Float64("burn_1h", burn1h).Msg("error budget burning")
-			}
+		if cancelled {
+			log.Info().Str("provider", chosen.Name()).Msg("client cancelled request; provider call aborted")
+			return
 		}
 		if err != nil {
-			log.Error().Err(err).Str("provider", chosen.Name()).Msg("completion failed")
-			rw.WriteProviderError(chosen.Name(), err)
+			logPrompt(log.Error(), cfg.PromptLogging, req).Err(err).Str("provider", chosen.Name()).Str("error_kind", reason).Msg("completion failed")
+			if !streamed {
+				timing.set(w)
+				rw.WriteProviderError(chosen.Name(), err)
+			}
+			return
+		}
+		if streamed {
 			return
 		}
+		if cacheKey != "" {
+			respCache.Put(cacheKey, cache.CachedResponse{Provider: chosen.Name(), Text: out.Text})
+		}
 		
 		resp := InferResponse{
-			Provider:  chosen.Name(),
-			Text:      out.Text,
-			CostUSD:   cost,
-			LatencyMs: latency,
-			RequestID: rw.requestID,
+			Provider:        chosen.Name(),
+			Model:           req.Model,
+			Text:            out.Text,
+			CostUSD:         cost,
+			LatencyMs:       latency,
+			RequestID:       rw.requestID,
+			ToolCalls:       out.ToolCalls,
+			Attempts:        budget.Used(),
+			FailedProviders: budget.FailedProviders(),
+		}
+		if wantsComparison(r) {
+			resp.Comparisons = compareProviders(costs, router.GetProviders(), tenant, askedModel, req.Model, promptTokens, completionTokens)
 		}
+		timing.set(w)
 
-		if err := rw.WriteJSON(http.StatusOK, resp); err != nil {
+		if err := writeInferResponse(w, resp); err != nil {
 			log.Error().Err(err).Msg("encode response")
 		}
 	}
@@ -198,60 +562,38 @@ func HandleInfer(cfg config.Config) http.HandlerFunc {
 // HandleInferWithUsageTracking is the multi-tenant version with usage tracking
 func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) http.HandlerFunc {
 	// Reuse the same provider setup logic
-	provs := make([]*providers.ResilientProvider, 0, 2)
-	if cfg.OpenAIKey != "" {
-		op := providers.NewOpenAIProvider(cfg.OpenAIKey)
-		provs = append(provs, providers.WithResilience(op, providers.ResilienceOptions{
-			Timeout:      30 * 1_000_000_000,
-			MaxRetries:   2,
-			BaseBackoff:  200 * 1_000_000,
-			MaxBackoff:   2 * 1_000_000_000,
-			JitterFrac:   0.2,
-			CBWindowSize: 20,
-			CBCooldown:   30 * 1_000_000_000,
-		}))
-	}
-	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || os.Getenv("AWS_PROFILE") != "" {
-		if br, err := providers.NewBedrockProvider(cfg.BedrockModelID, cfg.BedrockRegion); err == nil {
-			provs = append(provs, providers.WithResilience(br, providers.ResilienceOptions{
-				Timeout:      30 * 1_000_000_000,
-				MaxRetries:   2,
-				BaseBackoff:  200 * 1_000_000,
-				MaxBackoff:   2 * 1_000_000_000,
-				JitterFrac:   0.2,
-				CBWindowSize: 20,
-				CBCooldown:   30 * 1_000_000_000,
-			}))
-		} else {
-			log.Warn().Err(err).Msg("bedrock init failed")
-		}
-	}
-	if cfg.EnableMockProvider {
-		mp := providers.NewMockProvider(float64(cfg.MockMeanLatencyMs), float64(cfg.MockP95LatencyMs), cfg.MockErrorRate, cfg.MockCostPer1kUSD)
-		provs = append(provs, providers.WithResilience(mp, providers.ResilienceOptions{
-			Timeout:      30 * 1_000_000_000,
-			MaxRetries:   1,
-			BaseBackoff:  100 * 1_000_000,
-			MaxBackoff:   1 * 1_000_000_000,
-			JitterFrac:   0.2,
-			CBWindowSize: 20,
-			CBCooldown:   10 * 1_000_000_000,
-		}))
-	}
-
+	provs := BuildProviders(cfg)
 	router.SetProviders(provs)
 	eng := router.NewEngine(provs)
-	eng.ConfigureCanary(cfg.CanaryStages, cfg.CanaryWindow, cfg.CanaryBurnMultiplier)
+	eng.ConfigureCanary(cfg.CanaryStages, router.CanaryWindow{Calls: cfg.CanaryWindow, Dwell: cfg.CanaryMinDwell}, cfg.CanaryBurnMultiplier)
+	eng.SetCanaryMaxP95Ratio(cfg.CanaryMaxP95Ratio)
+	eng.SetCanaryMinSavingsPct(cfg.CanaryMinSavingsPct)
+	eng.SetCanaryMode(router.CanaryMode(cfg.CanaryMode))
+	eng.SetCanaryTenants(cfg.CanaryTenants)
+	eng.SetFastestP95Options(cfg.FastestP95MinSamples, cfg.FastestP95HalfLife)
+	eng.SetLatencySLO(cfg.LatencySLOP95)
+	eng.SetStickiness(cfg.StickyConversationTTL, cfg.StickyConversationsMax)
 	router.SetEngine(eng)
 	if cfg.DefaultPolicy != "" {
 		router.SetDefaultPolicy(cfg.DefaultPolicy)
 	}
 	telemetry.CanaryStage.Set(eng.CanaryPercent())
 
-	estimator := usage.NewTokenEstimator()
+	estimator := BuildTokenEstimator(cfg)
+	costs := BuildCostModel(cfg)
+	limits := requestLimits(cfg)
+	windows := buildContextWindows(cfg)
+	fits := viableModel(limits, windows, estimator)
+	respCache := newResponseCache(cfg)
+	dedup := newContentDedup(cfg)
+	templates := BuildPromptTemplates(cfg)
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer inferHeartbeat.Begin()()
 		startTime := time.Now()
+		timing := newServerTiming()
+		// the ID in the response header and trace, even when the client sent none
+		requestID := requestIDOf(r)
 
 		// Get tenant from context (added by auth middleware)
 		tenant, ok := auth.GetTenantFromContext(r.Context())
@@ -259,12 +601,31 @@ func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) ht
 			http.Error(w, "no tenant context", http.StatusInternalServerError)
 			return
 		}
+		if checkDailyTokens(NewResponseWriter(w, r), tenant, cfg.UsageWarningPct) {
+			return
+		}
+		costTags, err := usage.ParseCostTags(r.Header.Get(usage.CostTagsHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		var req InferRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
+		if err := renderTemplate(templates, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		model, err := resolveModelAlias(cfg.ModelAliases, req.Model)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Model = model
+		askedModel := req.Model
 
 		if req.Policy == "" {
 			if p := router.GetDefaultPolicy(); p != "" {
@@ -273,18 +634,128 @@ func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) ht
 				req.Policy = cfg.DefaultPolicy
 			}
 		}
+		var chosen *providers.ResilientProvider
+		if name := requestedProvider(r, req); name != "" {
+			p, status, err := forceProvider(eng, tenant, name, len(req.Tools) > 0)
+			if err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+			chosen = p
+			req.Policy = string(router.Forced)
+		}
+		if len(req.Models) > 0 {
+			t0 := time.Now()
+			model, p, err := pickModel(cfg.ModelAliases, eng, tenant, fits, req, chosen)
+			timing.routed(t0)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Model, askedModel = model, model
+			if p != nil {
+				chosen = p
+			}
+		}
 		if req.Model == "" {
-			req.Model = cfg.OpenAIModel
+			if chosen == nil {
+				t0 := time.Now()
+				chosen = chooseProvider(eng, tenant, req)
+				timing.routed(t0)
+			}
+			req.Model = defaultModelFor(chosen, cfg.OpenAIModel)
+		}
+		if err := limits.Check(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !tenant.ModelAllowed(req.Model) {
+			http.Error(w, fmt.Sprintf("model %s is not allowed for this tenant", req.Model), http.StatusForbidden)
+			return
+		}
+		if window, over := windows.fit(estimator, &req); over > 0 {
+			http.Error(w, contextWindowMessage(req.Model, window, over), http.StatusUnprocessableEntity)
+			return
 		}
 
 		// Estimate tokens for usage tracking
-		promptTokens := estimator.EstimatePromptTokens(req.Prompt, req.Model)
+		promptTokens := estimatePromptTokens(estimator, req)
+
+		cacheKey := responseCacheKey(respCache, r, tenant.TenantID, req)
+		if cacheKey != "" {
+			if hit, ok := respCache.Get(cacheKey); ok {
+				telemetry.ResponseCacheTotal.WithLabelValues("hit").Inc()
+				// still billed as usage for limits and reporting, but at no cost
+				if usageStore != nil {
+					rec := usage.UsageRecord{
+						TenantID:            tenant.TenantID,
+						Timestamp:           startTime,
+						RequestID:           requestID,
+						Provider:            hit.Provider,
+						Model:               req.Model,
+						EstPromptTokens:     promptTokens,
+						EstCompletionTokens: estimator.EstimateTokens(hit.Text, req.Model),
+						LatencyMs:           time.Since(startTime).Milliseconds(),
+						Status:              "ok",
+						IdempotencyKey:      r.Header.Get("Idempotency-Key"),
+						Cached:              true,
+						Tags:                costTags,
+					}
+					if err := usageStore.RecordUsage(r.Context(), rec); err != nil {
+						log.Error().Err(err).Msg("failed to record usage")
+					}
+				}
+				w.Header().Set("X-Cache", "HIT")
+				w.Header().Set("Content-Type", "application/json")
+				timing.set(w)
+				resp := InferResponse{Provider: hit.Provider, Model: req.Model, Text: hit.Text, RequestID: requestID}
+				if err := json.NewEncoder(w).Encode(resp); err != nil {
+					log.Error().Err(err).Msg("encode resp")
+				}
+				return
+			}
+			telemetry.ResponseCacheTotal.WithLabelValues("miss").Inc()
+			w.Header().Set("X-Cache", "MISS")
+		}
 
-		chosen := eng.Choose(req.Policy, req.Model)
+		if chosen == nil {
+			t0 := time.Now()
+			chosen = chooseProvider(eng, tenant, req)
+			timing.routed(t0)
+		}
+		if chosen == nil {
+			var ue *router.UnavailableError
+			if errors.As(eng.Unavailable(), &ue) {
+				w.Header().Set("Retry-After", unavailableRetryAfterHeader(ue))
+				http.Error(w, ue.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if chosen == nil && len(tenant.DeniedProviders) > 0 {
+			http.Error(w, "no provider permitted for this tenant is available", http.StatusForbidden)
+			return
+		}
+		if chosen == nil && len(req.Tools) > 0 && !anyToolCapable() {
+			http.Error(w, "no configured provider supports tools", http.StatusBadRequest)
+			return
+		}
 		if chosen == nil {
 			http.Error(w, "no providers available", http.StatusServiceUnavailable)
 			return
 		}
+		telemetry.AnnotateProvider(r.Context(), chosen.Name())
+		if isDryRun(r, req) {
+			// no provider call, so no usage record or cost metrics either
+			resp := dryRun(estimator, chosen, req)
+			resp.RequestID = requestID
+			w.Header().Set("Content-Type", "application/json")
+			timing.set(w)
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				log.Error().Err(err).Msg("encode resp")
+			}
+			return
+		}
 
 		tracer := otel.Tracer("llm-router")
 		ctx, span := tracer.Start(r.Context(), "infer")
@@ -294,39 +765,141 @@ func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) ht
 			attribute.String("provider", chosen.Name()),
 			attribute.String("tenant_id", tenant.TenantID),
 		)
+		span.SetAttributes(promptAttributes(cfg.PromptLogging, req)...)
 		defer span.End()
 
-		pReq := providers.CompletionRequest{Model: req.Model, Prompt: req.Prompt, MaxTok: req.MaxTok, Stream: req.Stream}
-		out, cost, latency, err := chosen.Complete(ctx, pReq)
+		pReq := completionRequest(req)
+		stream := newSSEStream(w, req)
+		if stream != nil {
+			stream.meter = newStreamMeter(cfg.StreamUsageCheckpointTokens, tenant, estimator, req.Model, promptTokens)
+		}
+		if stream != nil && stream.meter != nil && usageStore != nil {
+			// partial rows keep a long stream's usage visible before it ends
+			stream.meter.onCheckpoint = func(n int, promptTok, completionTok int64) {
+				if err := usageStore.RecordUsage(r.Context(), usage.UsageRecord{
+					TenantID:            tenant.TenantID,
+					Timestamp:           startTime,
+					RequestID:           requestID,
+					Provider:            chosen.Name(),
+					Model:               req.Model,
+					EstPromptTokens:     promptTok,
+					EstCompletionTokens: completionTok,
+					Status:              usage.StatusPartial,
+					Checkpoint:          n,
+					Tags:                costTags,
+				}); err != nil {
+					log.Error().Err(err).Msg("failed to record partial usage")
+				}
+			}
+		}
+		budget := providers.NewAttemptBudget(cfg.MaxTotalAttempts)
+		callStart := time.Now()
+		call, shared := completeOnce(ctx, dedup, contentDedupKey(dedup, r, tenant.TenantID, req), chosen, func(ctx context.Context) (providers.CompletionResponse, float64, int64, error) {
+			return stream.complete(policyTimeout(ctx, cfg, req.Policy), w, budget, chosen, pReq)
+		})
+		timing.providerDone(callStart)
+		if shared {
+			// an identical request's call answered this one: usage, but at no cost
+			w.Header().Set("X-Dedup", "HIT")
+			timing.set(w)
+			countShared(w, r, call, req.Policy)
+			if call.err != nil {
+				if r.Context().Err() == nil {
+					NewResponseWriter(w, r).WriteProviderError(call.provider, call.err)
+				}
+				return
+			}
+			if usageStore != nil {
+				rec := usage.UsageRecord{
+					TenantID:            tenant.TenantID,
+					Timestamp:           startTime,
+					RequestID:           requestID,
+					Provider:            call.provider,
+					Model:               req.Model,
+					EstPromptTokens:     promptTokens,
+					EstCompletionTokens: estimator.EstimateTokens(call.out.Text, req.Model),
+					LatencyMs:           time.Since(startTime).Milliseconds(),
+					Status:              "ok",
+					IdempotencyKey:      r.Header.Get("Idempotency-Key"),
+					Cached:              true,
+					Tags:                costTags,
+				}
+				if err := usageStore.RecordUsage(r.Context(), rec); err != nil {
+					log.Error().Err(err).Msg("failed to record usage")
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			resp := InferResponse{Provider: call.provider, Model: req.Model, Text: call.out.Text, RequestID: requestID}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				log.Error().Err(err).Msg("encode resp")
+			}
+			return
+		}
+		out, cost, latency, err := call.out, call.cost, call.latency, call.err
 		failed := err != nil
-		eng.RecordResult(chosen.Name(), failed)
-		telemetry.CanaryStage.Set(eng.CanaryPercent())
+		// a client that hung up says nothing about the provider's health
+		cancelled := clientGone(r, err)
+		// nor does a stream we stopped at the tenant's token limit
+		limited := errors.As(err, new(*tokenLimitError))
 
 		// Estimate completion tokens from actual response
 		var completionTokens int64
-		if out.Text != "" {
+		switch {
+		case stream != nil && stream.meter != nil:
+			completionTokens = stream.meter.completionTokens()
+		case out.Text != "":
 			completionTokens = estimator.EstimateTokens(out.Text, req.Model)
-		} else {
-			completionTokens = estimator.EstimateCompletionTokens(req.Prompt, req.Model)
+		default:
+			completionTokens = estimateCompletionTokens(estimator, req)
+		}
+		if !failed {
+			cost = billedCost(costs, chosen.Name(), req.Model, promptTokens, completionTokens, cost)
+		}
+		if !cancelled && !limited {
+			recordCanaryResult(eng, chosen.Name(), failed, cost)
+		}
+		// the final row carries what the stream's checkpoints haven't recorded yet
+		rowPromptTokens, rowCompletionTokens := countDailyTokens(tenant, stream, promptTokens, completionTokens)
+
+		// usage is recorded only once the stream's final event has gone out
+		streamed := false
+		if stream != nil && !cancelled {
+			streamed = stream.finish(StreamDone{
+				Provider:         chosen.Name(),
+				Model:            req.Model,
+				CostUSD:          cost,
+				LatencyMs:        latency,
+				PromptTokens:     promptTokens,
+				CompletionTokens: completionTokens,
+				RequestID:        requestID,
+			}, err)
 		}
 
 		// Record usage
 		usageRecord := usage.UsageRecord{
 			TenantID:            tenant.TenantID,
 			Timestamp:           startTime,
-			RequestID:           r.Header.Get("X-Request-ID"),
+			RequestID:           requestID,
 			Provider:            chosen.Name(),
 			Model:               req.Model,
-			EstPromptTokens:     promptTokens,
-			EstCompletionTokens: completionTokens,
+			EstPromptTokens:     rowPromptTokens,
+			EstCompletionTokens: rowCompletionTokens,
 			CostUSD:             cost,
 			LatencyMs:           latency,
 			Status:              "ok",
 			IdempotencyKey:      r.Header.Get("Idempotency-Key"),
+			Tags:                costTags,
 		}
 
 		if failed {
 			usageRecord.Status = "error"
+			usageRecord.ErrorKind = string(providers.KindOf(err))
+			if cancelled {
+				usageRecord.ErrorKind = string(providers.KindCancelled)
+			}
+			if limited {
+				usageRecord.ErrorKind = errorKindTokenLimit
+			}
 		}
 
 		if usageStore != nil {
@@ -340,13 +913,28 @@ func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) ht
 		reason := ""
 		if err != nil {
 			code = "502"
-			reason = "provider_error"
+			reason = string(providers.KindOf(err))
+		}
+		if cancelled {
+			code = "499" // client closed request
+			reason = string(providers.KindCancelled)
+		}
+		if limited {
+			code = "429"
+			reason = errorKindTokenLimit
 		}
 		telemetry.RequestsTotal.WithLabelValues(chosen.Name(), req.Policy, code).Inc()
-		telemetry.LatencyMs.WithLabelValues(chosen.Name(), req.Policy).Observe(float64(latency))
-		if !failed {
-			telemetry.CostUSDTotal.WithLabelValues(chosen.Name()).Add(cost)
-		} else {
+		telemetry.ObserveLatency(ctx, chosen.Name(), req.Policy, float64(latency))
+		switch {
+		case !failed:
+			telemetry.CostUSDTotal.WithLabelValues(chosen.Name(), req.Policy).Add(cost)
+			telemetry.RequestCostUSD.WithLabelValues(chosen.Name()).Observe(cost)
+			telemetry.AddTokens(chosen.Name(), promptTokens, completionTokens)
+		case cancelled:
+			telemetry.ClientCancellationsTotal.WithLabelValues(chosen.Name(), req.Policy).Inc()
+		case limited:
+			// the tenant's limit, not a provider error
+		default:
 			telemetry.ErrorsTotal.WithLabelValues(chosen.Name(), reason).Inc()
 		}
 
@@ -354,6 +942,7 @@ func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) ht
 			attribute.Float64("cost_usd", cost),
 			attribute.Int64("latency_ms", latency),
 			attribute.Bool("success", !failed),
+			attribute.String("error.kind", reason),
 			attribute.Int64("prompt_tokens", promptTokens),
 			attribute.Int64("completion_tokens", completionTokens),
 		)
@@ -362,30 +951,31 @@ func HandleInferWithUsageTracking(cfg config.Config, usageStore *usage.Store) ht
 			telemetry.CBState.WithLabelValues(chosen.Name()).Set(rp.CBStateValue())
 		}
 
-		if rp, ok := any(chosen).(*providers.ResilientProvider); ok {
-			er1m := rp.Stats().ErrorRateSince(1 * 60 * 1e9)
-			er5m := rp.Stats().ErrorRateSince(5 * 60 * 1e9)
-			er1h := rp.Stats().ErrorRateSince(60 * 60 * 1e9)
-			burn1m := er1m / 0.01
-			burn5m := er5m / 0.01
-			burn1h := er1h / 0.01
-			telemetry.BurnRate.WithLabelValues("1m").Set(burn1m)
-			telemetry.BurnRate.WithLabelValues("5m").Set(burn5m)
-			telemetry.BurnRate.WithLabelValues("1h").Set(burn1h)
-			if burn1m > 1.0 || burn5m > 1.0 || burn1h > 1.0 {
-				log.Warn().Str("provider", chosen.Name()).Float64("burn_1m", burn1m).Float64("burn_5m", burn5m).Float64("burn_1h", burn1h).Msg("error budget burning")
-			}
+		if cancelled {
+			log.Info().Str("provider", chosen.Name()).Str("tenant", tenant.TenantID).Msg("client cancelled request; provider call aborted")
+			return
 		}
-
 		if err != nil {
-			log.Error().Err(err).Str("provider", chosen.Name()).Str("tenant", tenant.TenantID).Msg("completion failed")
-			http.Error(w, "provider error", http.StatusBadGateway)
+			logPrompt(log.Error(), cfg.PromptLogging, req).Err(err).Str("provider", chosen.Name()).Str("error_kind", reason).Str("tenant", tenant.TenantID).Msg("completion failed")
+			if !streamed {
+				timing.set(w)
+				http.Error(w, "provider error", http.StatusBadGateway)
+			}
+			return
+		}
+		if streamed {
 			return
 		}
+		if cacheKey != "" {
+			respCache.Put(cacheKey, cache.CachedResponse{Provider: chosen.Name(), Text: out.Text})
+		}
 
-		resp := InferResponse{Provider: chosen.Name(), Text: out.Text, CostUSD: cost, LatencyMs: latency}
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(resp); err != nil {
+		resp := InferResponse{Provider: chosen.Name(), Model: req.Model, Text: out.Text, CostUSD: cost, LatencyMs: latency, RequestID: requestID, ToolCalls: out.ToolCalls, Attempts: budget.Used(), FailedProviders: budget.FailedProviders()}
+		if wantsComparison(r) {
+			resp.Comparisons = compareProviders(costs, router.GetProviders(), tenant, askedModel, req.Model, promptTokens, completionTokens)
+		}
+		timing.set(w)
+		if err := writeInferResponse(w, resp); err != nil {
 			log.Error().Err(err).Msg("encode resp")
 		}
 	}
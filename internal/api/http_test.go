@@ -0,0 +1,1212 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInferDryRun(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:      "cheapest",
+		OpenAIModel:        "gpt-4o",
+		EnableMockProvider: true,
+		MockMeanLatencyMs:  1,
+		MockP95LatencyMs:   1,
+		MockErrorRate:      1.0, // a real call would fail
+		MockCostPer1kUSD:   0.002,
+	}
+	handler := HandleInfer(cfg)
+
+	tests := []struct {
+		name string
+		url  string
+		body string
+	}{
+		{name: "body flag", url: "/v1/infer", body: `{"prompt": "hello world", "max_tokens": 100, "dry_run": true}`},
+		{name: "query flag", url: "/v1/infer?dry_run=1", body: `{"prompt": "hello world", "max_tokens": 100}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			costBefore := testutil.ToFloat64(telemetry.CostUSDTotal.WithLabelValues("mock", "cheapest"))
+
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var resp DryRunResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Provider != "mock" || resp.Model != "gpt-4o" || resp.Policy != "cheapest" {
+				t.Errorf("unexpected route %+v", resp)
+			}
+			if resp.EstimatedTokens <= 100 {
+				t.Errorf("expected prompt plus max_tokens estimate, got %d", resp.EstimatedTokens)
+			}
+			if want := 0.002 * float64(resp.EstimatedTokens) / 1000.0; resp.EstimatedCostUSD != want {
+				t.Errorf("expected estimated cost %v, got %v", want, resp.EstimatedCostUSD)
+			}
+
+			if total, _ := router.GetProviders()[0].Stats().CountsSince(time.Hour); total != 0 {
+				t.Errorf("expected no provider call, got %d recorded outcomes", total)
+			}
+			if got := testutil.ToFloat64(telemetry.CostUSDTotal.WithLabelValues("mock", "cheapest")); got != costBefore {
+				t.Errorf("expected cost counter untouched, went from %v to %v", costBefore, got)
+			}
+		})
+	}
+}
+
+func TestInferResponseCache(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:           "cheapest",
+		OpenAIModel:             "gpt-4o",
+		EnableMockProvider:      true,
+		MockMeanLatencyMs:       1,
+		MockP95LatencyMs:        1,
+		MockCostPer1kUSD:        0.002,
+		EnableResponseCache:     true,
+		ResponseCacheTTL:        time.Minute,
+		ResponseCacheMaxEntries: 10,
+	}
+	handler := HandleInfer(cfg)
+	calls := func() int {
+		total, _ := router.GetProviders()[0].Stats().CountsSince(time.Hour)
+		return total
+	}
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"prompt": "hello world", "max_tokens": 10}`)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("first call: expected 200 MISS, got %d %q", rr.Code, rr.Header().Get("X-Cache"))
+	}
+	var first InferResponse
+	if err := json.NewDecoder(rr.Body).Decode(&first); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if calls() != 1 {
+		t.Fatalf("expected 1 provider call, got %d", calls())
+	}
+
+	costBefore := testutil.ToFloat64(telemetry.CostUSDTotal.WithLabelValues("mock", "cheapest"))
+	rr = post(`{"prompt": "  hello   world ", "max_tokens": 10}`)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("repeat call: expected 200 HIT, got %d %q", rr.Code, rr.Header().Get("X-Cache"))
+	}
+	var hit InferResponse
+	if err := json.NewDecoder(rr.Body).Decode(&hit); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if hit.Text != first.Text || hit.Provider != "mock" || hit.CostUSD != 0 {
+		t.Errorf("unexpected cached response %+v", hit)
+	}
+	if calls() != 1 {
+		t.Errorf("cache hit should skip the provider, got %d calls", calls())
+	}
+	if got := testutil.ToFloat64(telemetry.CostUSDTotal.WithLabelValues("mock", "cheapest")); got != costBefore {
+		t.Errorf("cache hit should not add cost, went from %v to %v", costBefore, got)
+	}
+
+	// a different max_tokens is a different request
+	if rr := post(`{"prompt": "hello world", "max_tokens": 20}`); rr.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected MISS for different max_tokens, got %q", rr.Header().Get("X-Cache"))
+	}
+	// streaming requests always reach the provider
+	if rr := post(`{"prompt": "hello world", "max_tokens": 10, "stream": true}`); rr.Header().Get("X-Cache") != "" {
+		t.Errorf("expected streaming request to bypass the cache, got %q", rr.Header().Get("X-Cache"))
+	}
+	if calls() != 3 {
+		t.Errorf("expected 3 provider calls, got %d", calls())
+	}
+}
+
+func TestInferContentDedup(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:      "cheapest",
+		OpenAIModel:        "gpt-4o",
+		EnableMockProvider: true,
+		MockMeanLatencyMs:  200,
+		MockP95LatencyMs:   200,
+		MockCostPer1kUSD:   0.002,
+		EnableContentDedup: true,
+		ContentDedupWindow: time.Minute,
+	}
+	handler := HandleInfer(cfg)
+	postAs := func(tenant *auth.Tenant) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hello world", "max_tokens": 10}`))
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != nil {
+			req = req.WithContext(auth.WithTenant(req.Context(), tenant))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	post := func() *httptest.ResponseRecorder { return postAs(nil) }
+
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 2)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = post()
+		}(i)
+	}
+	wg.Wait()
+
+	if total, _ := router.GetProviders()[0].Stats().CountsSince(time.Hour); total != 1 {
+		t.Fatalf("expected simultaneous duplicates to share 1 provider call, got %d", total)
+	}
+	var texts []string
+	hits := 0
+	for _, rr := range results {
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp InferResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		texts = append(texts, resp.Text)
+		if rr.Header().Get("X-Dedup") == "HIT" {
+			hits++
+			if resp.CostUSD != 0 {
+				t.Errorf("a shared result should cost nothing, got %v", resp.CostUSD)
+			}
+		}
+	}
+	if hits != 1 || texts[0] != texts[1] {
+		t.Errorf("expected one request served by the other's call, got %d hits and texts %q", hits, texts)
+	}
+
+	// within the window a later duplicate is answered too, and still counted
+	served := testutil.ToFloat64(telemetry.RequestsTotal.WithLabelValues("mock", "cheapest", "200"))
+	if rr := post(); rr.Header().Get("X-Dedup") != "HIT" || rr.Header().Get("X-Router-Attempts") != "0" {
+		t.Errorf("expected a recent result reused with no attempts, got %q attempts=%q", rr.Header().Get("X-Dedup"), rr.Header().Get("X-Router-Attempts"))
+	}
+	if got := testutil.ToFloat64(telemetry.RequestsTotal.WithLabelValues("mock", "cheapest", "200")) - served; got != 1 {
+		t.Errorf("expected a shared response counted in router_requests_total, got %v", got)
+	}
+
+	// only a tenant's own duplicates share a call, so each tenant pays for its first
+	// request and its duplicates, which the tenant already paid for, are free
+	acme := &auth.Tenant{TenantID: "t-dedup-acme", Enabled: true}
+	globex := &auth.Tenant{TenantID: "t-dedup-globex", Enabled: true}
+	for _, tn := range []*auth.Tenant{acme, globex} {
+		wg.Add(1)
+		go func(tn *auth.Tenant) {
+			defer wg.Done()
+			if rr := postAs(tn); rr.Code != http.StatusOK || rr.Header().Get("X-Dedup") == "HIT" {
+				t.Errorf("%s: expected its own charged call, got %d X-Dedup=%q", tn.TenantID, rr.Code, rr.Header().Get("X-Dedup"))
+			}
+		}(tn)
+	}
+	wg.Wait()
+	charged := dailyTokens.GetUsage(acme.TenantID)
+	if charged == 0 || dailyTokens.GetUsage(globex.TenantID) != charged {
+		t.Errorf("expected both tenants charged the same tokens, got %d and %d", charged, dailyTokens.GetUsage(globex.TenantID))
+	}
+	if rr := postAs(acme); rr.Header().Get("X-Dedup") != "HIT" || dailyTokens.GetUsage(acme.TenantID) != charged {
+		t.Errorf("expected acme's duplicate shared at no further charge, got X-Dedup=%q and %d tokens", rr.Header().Get("X-Dedup"), dailyTokens.GetUsage(acme.TenantID))
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:      "cheapest",
+		OpenAIModel:        "gpt-4o",
+		EnableMockProvider: true,
+		MockMeanLatencyMs:  1,
+		MockP95LatencyMs:   1,
+		MockCostPer1kUSD:   0.002,
+	}
+	HandleInfer(cfg) // publishes the engine
+	handler := HandleEstimate(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/estimate?prompt=hello+world&max_tokens=100", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp EstimateResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Provider != "mock" || resp.Model != "gpt-4o" || resp.Policy != "cheapest" {
+		t.Errorf("unexpected route %+v", resp)
+	}
+	if resp.EstimatedPromptTokens <= 0 || resp.EstimatedCompletionTokens != 100 {
+		t.Errorf("unexpected token estimate %+v", resp)
+	}
+	want := 0.002 * float64(resp.EstimatedPromptTokens+resp.EstimatedCompletionTokens) / 1000.0
+	if resp.EstimatedCostUSD != want {
+		t.Errorf("expected cost %v, got %v", want, resp.EstimatedCostUSD)
+	}
+	if total, _ := router.GetProviders()[0].Stats().CountsSince(time.Hour); total != 0 {
+		t.Errorf("estimate should not call the provider, got %d outcomes", total)
+	}
+
+	for _, bad := range []string{
+		"/v1/estimate",
+		"/v1/estimate?prompt=hi&max_tokens=abc",
+		"/v1/estimate?prompt=hi&max_tokens=0",
+		"/v1/estimate?prompt=hi&policy=random",
+		"/v1/estimate?prompt=" + strings.Repeat("a", 100001),
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, bad, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%.60s: expected 400, got %d", bad, rr.Code)
+		}
+	}
+}
+
+func TestEstimateSkipsTenantDeniedProviders(t *testing.T) {
+	mock := func(name string, per1k float64) *providers.ResilientProvider {
+		p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: name, MeanMs: 1, P95Ms: 1, CostPer1k: per1k})
+		return providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})
+	}
+	provs := []*providers.ResilientProvider{mock("cheap", 0.001), mock("pricey", 0.01)}
+	router.SetProviders(provs)
+	router.SetEngine(router.NewEngine(provs))
+	defer func() {
+		router.SetProviders(nil)
+		router.SetEngine(nil)
+	}()
+	handler := HandleEstimate(config.Config{DefaultPolicy: "cheapest"})
+
+	estimate := func(tenant *auth.Tenant) EstimateResponse {
+		req := httptest.NewRequest(http.MethodGet, "/v1/estimate?prompt=hello&model=gpt-4o&max_tokens=10", nil)
+		if tenant != nil {
+			req = req.WithContext(auth.WithTenant(req.Context(), tenant))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		var resp EstimateResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return resp
+	}
+	if resp := estimate(nil); resp.Provider != "cheap" {
+		t.Errorf("expected the cheapest provider without a tenant, got %s", resp.Provider)
+	}
+	barred := &auth.Tenant{TenantID: "t-estimate-barred", Enabled: true, DeniedProviders: []string{"cheap"}}
+	if resp := estimate(barred); resp.Provider != "pricey" {
+		t.Errorf("expected the tenant's denied provider skipped as infer would, got %s", resp.Provider)
+	}
+}
+
+func TestInferTenantModelAccess(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:      "cheapest",
+		OpenAIModel:        "gpt-4o-mini",
+		EnableMockProvider: true,
+		MockMeanLatencyMs:  1,
+		MockP95LatencyMs:   1,
+		MockCostPer1kUSD:   0.002,
+	}
+	handler := HandleInfer(cfg)
+
+	free := &auth.Tenant{TenantID: "t-free", Plan: "free", Enabled: true, AllowedModels: []string{"gpt-4o-mini"}}
+	barred := &auth.Tenant{TenantID: "t-compliance", Plan: "enterprise", Enabled: true, DeniedProviders: []string{"mock"}}
+
+	tests := []struct {
+		name   string
+		tenant *auth.Tenant
+		model  string
+		want   int
+	}{
+		{name: "free tenant blocked from gpt-4o", tenant: free, model: "gpt-4o", want: http.StatusForbidden},
+		{name: "free tenant allowed cheap model", tenant: free, model: "gpt-4o-mini", want: http.StatusOK},
+		{name: "only provider denied", tenant: barred, model: "gpt-4o", want: http.StatusForbidden},
+		{name: "no tenant context", tenant: nil, model: "gpt-4o", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hi", "model": "`+tt.model+`", "max_tokens": 10}`))
+			if tt.tenant != nil {
+				req = req.WithContext(auth.WithTenant(req.Context(), tt.tenant))
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidateTenantAccessLists(t *testing.T) {
+	if err := ValidateTenantAccessLists([]string{"gpt-4o-mini"}, []string{"bedrock"}); err != nil {
+		t.Fatalf("expected valid lists, got %v", err)
+	}
+	if err := ValidateTenantAccessLists([]string{"gpt-4o-mini", "gpt-4o-mini"}, nil); err == nil {
+		t.Error("expected duplicate model to be rejected")
+	}
+	if err := ValidateTenantAccessLists(nil, []string{" "}); err == nil {
+		t.Error("expected empty provider name to be rejected")
+	}
+}
+
+func TestEmptyModelUsesChosenProviderDefault(t *testing.T) {
+	// a Bedrock-only deployment: OPENAI_MODEL must not leak into Bedrock requests
+	cfg := config.Config{DefaultPolicy: "cheapest", OpenAIModel: "gpt-4o"}
+	bedrock := providers.NewMockProviderWithOptions(providers.MockOptions{
+		Name:         "bedrock",
+		MeanMs:       1,
+		P95Ms:        1,
+		DefaultModel: "anthropic.claude-3-haiku",
+	})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{
+		providers.WithResilience(bedrock, providers.ResilienceOptions{CBWindowSize: 10}),
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/infer?dry_run=1", strings.NewReader(`{"prompt": "hello", "max_tokens": 10}`))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp DryRunResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Provider != "bedrock" || resp.Model != "anthropic.claude-3-haiku" {
+		t.Errorf("expected bedrock with its default model, got %s/%s", resp.Provider, resp.Model)
+	}
+
+	// an explicit model is passed through untouched
+	req = httptest.NewRequest(http.MethodPost, "/v1/infer?dry_run=1", strings.NewReader(`{"prompt": "hello", "model": "anthropic.claude-3-sonnet", "max_tokens": 10}`))
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	resp = DryRunResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != "anthropic.claude-3-sonnet" {
+		t.Errorf("expected requested model kept, got %s", resp.Model)
+	}
+}
+
+func TestInferForcedProvider(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	mock := func(name string, cost float64) *providers.ResilientProvider {
+		p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: name, MeanMs: 1, P95Ms: 1, CostPer1k: cost})
+		return providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})
+	}
+	off := mock("off", 0.0005)
+	off.SetEnabled(false)
+	handler := handleInfer(cfg, []*providers.ResilientProvider{mock("cheap", 0.001), mock("pricey", 0.01), off})
+
+	forcedBefore := testutil.ToFloat64(telemetry.RequestsTotal.WithLabelValues("pricey", "forced", "200"))
+	tests := []struct {
+		name     string
+		url      string
+		body     string
+		want     int
+		provider string
+	}{
+		{name: "policy picks cheapest", url: "/v1/infer", body: `{"prompt": "hi"}`, want: http.StatusOK, provider: "cheap"},
+		{name: "body forces provider", url: "/v1/infer", body: `{"prompt": "hi", "provider": "pricey"}`, want: http.StatusOK, provider: "pricey"},
+		{name: "query forces provider", url: "/v1/infer?provider=pricey", body: `{"prompt": "hi"}`, want: http.StatusOK, provider: "pricey"},
+		{name: "unknown provider", url: "/v1/infer", body: `{"prompt": "hi", "provider": "nope"}`, want: http.StatusNotFound},
+		{name: "disabled provider", url: "/v1/infer?provider=off", body: `{"prompt": "hi"}`, want: http.StatusBadRequest},
+		{name: "empty provider", url: "/v1/infer", body: `{"prompt": "hi", "provider": ""}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if tt.provider == "" {
+				return
+			}
+			var resp InferResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Provider != tt.provider {
+				t.Errorf("expected provider %s, got %s", tt.provider, resp.Provider)
+			}
+		})
+	}
+	if got := testutil.ToFloat64(telemetry.RequestsTotal.WithLabelValues("pricey", "forced", "200")); got != forcedBefore+2 {
+		t.Errorf("expected forced calls counted under policy=forced, went from %v to %v", forcedBefore, got)
+	}
+}
+
+func TestInferModelFallback(t *testing.T) {
+	// gpt-4o's output budget is too small for the larger requests below
+	cfg := config.Config{
+		DefaultPolicy:        "cheapest",
+		ModelMaxOutputTokens: map[string]int{"gpt-4o": 256, "gpt-4o-mini": 4096},
+	}
+	p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "mock", MeanMs: 1, P95Ms: 1})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})})
+	miniOnly := &auth.Tenant{TenantID: "t-mini", Enabled: true, AllowedModels: []string{"gpt-4o-mini"}}
+
+	tests := []struct {
+		name   string
+		body   string
+		tenant *auth.Tenant
+		want   int
+		model  string
+		detail string
+	}{
+		{name: "first model fits", body: `{"prompt": "hi", "models": ["gpt-4o", "gpt-4o-mini"], "max_tokens": 100}`, want: http.StatusOK, model: "gpt-4o"},
+		{name: "first model over budget", body: `{"prompt": "hi", "models": ["gpt-4o", "gpt-4o-mini"], "max_tokens": 1000}`, want: http.StatusOK, model: "gpt-4o-mini"},
+		{name: "first model not allowed", body: `{"prompt": "hi", "models": ["gpt-4o", "gpt-4o-mini"], "max_tokens": 100}`, tenant: miniOnly, want: http.StatusOK, model: "gpt-4o-mini"},
+		{name: "no model fits", body: `{"prompt": "hi", "models": ["gpt-4o", "gpt-4o-mini"], "max_tokens": 5000}`, want: http.StatusBadRequest, detail: "between 1 and 256 for model gpt-4o"},
+		{name: "model and models", body: `{"prompt": "hi", "model": "gpt-4o", "models": ["gpt-4o-mini"]}`, want: http.StatusBadRequest, detail: "cannot both be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(tt.body))
+			if tt.tenant != nil {
+				req = req.WithContext(auth.WithTenant(req.Context(), tt.tenant))
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if tt.model == "" {
+				if !strings.Contains(rec.Body.String(), tt.detail) {
+					t.Errorf("expected an error mentioning %q, got %s", tt.detail, rec.Body.String())
+				}
+				return
+			}
+			var resp InferResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Model != tt.model {
+				t.Errorf("expected model %s used, got %q", tt.model, resp.Model)
+			}
+		})
+	}
+}
+
+// toolProvider answers every request with a call to its first tool
+type toolProvider struct{}
+
+func (toolProvider) Name() string                        { return "tooly" }
+func (toolProvider) CostPer1kTokensUSD(_ string) float64 { return 0.01 }
+func (toolProvider) SupportsTools() bool                 { return true }
+func (toolProvider) Complete(_ context.Context, req providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	if len(req.Tools) == 0 {
+		return providers.CompletionResponse{Text: "no tools"}, 0.001, 1, nil
+	}
+	call := providers.ToolCall{ID: "call_1", Name: req.Tools[0].Name, Arguments: json.RawMessage(`{"city":"Paris"}`)}
+	return providers.CompletionResponse{ToolCalls: []providers.ToolCall{call}}, 0.001, 1, nil
+}
+
+func TestInferToolsRouteToCapableProvider(t *testing.T) {
+	cheap := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "cheap", MeanMs: 1, P95Ms: 1, CostPer1k: 0.001})
+	handler := handleInfer(config.Config{DefaultPolicy: "cheapest"}, []*providers.ResilientProvider{
+		providers.WithResilience(cheap, providers.ResilienceOptions{CBWindowSize: 10}),
+		providers.WithResilience(toolProvider{}, providers.ResilienceOptions{CBWindowSize: 10}),
+	})
+	tool := `"tools": [{"name": "get_weather", "parameters": {"type": "object"}}]`
+
+	tests := []struct {
+		name     string
+		url      string
+		body     string
+		want     int
+		provider string
+	}{
+		{name: "tools skip the cheaper provider", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "weather?", ` + tool + `}`, want: http.StatusOK, provider: "tooly"},
+		{name: "without tools the cheapest wins", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "hi"}`, want: http.StatusOK, provider: "cheap"},
+		{name: "forced provider without tool support", url: "/v1/infer?provider=cheap", body: `{"model": "gpt-4o", "prompt": "weather?", ` + tool + `}`, want: http.StatusBadRequest},
+		{name: "tool choice names an unknown tool", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "weather?", "tool_choice": "nope", ` + tool + `}`, want: http.StatusBadRequest},
+		{name: "tool choice without tools", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "hi", "tool_choice": "auto"}`, want: http.StatusBadRequest},
+		{name: "parameters not an object", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "hi", "tools": [{"name": "f", "parameters": [1]}]}`, want: http.StatusBadRequest},
+		{name: "duplicate tool names", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "hi", "tools": [{"name": "f"}, {"name": "f"}]}`, want: http.StatusBadRequest},
+		{name: "tools with stream", url: "/v1/infer", body: `{"model": "gpt-4o", "prompt": "hi", "stream": true, ` + tool + `}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if tt.provider == "" {
+				return
+			}
+			var resp InferResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Provider != tt.provider {
+				t.Errorf("expected provider %s, got %s", tt.provider, resp.Provider)
+			}
+			if tt.provider != "tooly" {
+				return
+			}
+			if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" || string(resp.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+				t.Errorf("expected the tool call in the response, got %+v", resp.ToolCalls)
+			}
+		})
+	}
+}
+
+func TestInferCompareListsEnabledProviders(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	mock := func(name string, cost float64) *providers.ResilientProvider {
+		p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: name, MeanMs: 1, P95Ms: 1, CostPer1k: cost})
+		return providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})
+	}
+	off := mock("off", 0.0005)
+	off.SetEnabled(false)
+	handler := handleInfer(cfg, []*providers.ResilientProvider{mock("cheap", 0.001), mock("pricey", 0.01), off})
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hello there", "max_tokens": 50}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "comparisons") {
+		t.Errorf("expected no comparisons without ?compare=1, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer?compare=1", strings.NewReader(`{"prompt": "hello there", "max_tokens": 50}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp InferResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Provider != "cheap" {
+		t.Errorf("expected cheap to serve the request, got %s", resp.Provider)
+	}
+	byName := map[string]ProviderComparison{}
+	for _, c := range resp.Comparisons {
+		byName[c.Provider] = c
+	}
+	if len(byName) != 2 || byName["cheap"].Provider == "" || byName["pricey"].Provider == "" {
+		t.Fatalf("expected comparisons for cheap and pricey only, got %+v", resp.Comparisons)
+	}
+	cheap, pricey := byName["cheap"].EstimatedCostUSD, byName["pricey"].EstimatedCostUSD
+	if cheap <= 0 {
+		t.Fatalf("expected a positive estimate, got %v", cheap)
+	}
+	if ratio := pricey / cheap; ratio < 9.99 || ratio > 10.01 {
+		t.Errorf("expected estimates in proportion to list price, got cheap=%v pricey=%v", cheap, pricey)
+	}
+	if byName["pricey"].CBState != 2 {
+		t.Errorf("expected a closed breaker, got %v", byName["pricey"].CBState)
+	}
+}
+
+func TestPromptLoggingControlsSpanAttributes(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	prevTP := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	defer otel.SetTracerProvider(prevTP)
+
+	const prompt = "my account number is 12345"
+	mock := providers.NewMockProviderWithOptions(providers.MockOptions{MeanMs: 1, P95Ms: 1})
+	provs := []*providers.ResilientProvider{providers.WithResilience(mock, providers.ResilienceOptions{CBWindowSize: 10})}
+
+	for _, mode := range []string{PromptLoggingNone, PromptLoggingHash, PromptLoggingFull} {
+		t.Run(mode, func(t *testing.T) {
+			handler := handleInfer(config.Config{DefaultPolicy: "cheapest", PromptLogging: mode}, provs)
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "`+prompt+`"}`)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			spans := sr.Ended()
+			attrs := map[string]string{}
+			for _, kv := range spans[len(spans)-1].Attributes() {
+				attrs[string(kv.Key)] = kv.Value.Emit()
+			}
+			leaked := false
+			for _, v := range attrs {
+				leaked = leaked || strings.Contains(v, "12345")
+			}
+			switch mode {
+			case PromptLoggingNone:
+				if leaked || attrs["prompt.sha256"] != "" {
+					t.Errorf("expected no prompt data in span, got %v", attrs)
+				}
+			case PromptLoggingHash:
+				if leaked || len(attrs["prompt.sha256"]) != 64 {
+					t.Errorf("expected only a prompt hash in span, got %v", attrs)
+				}
+			case PromptLoggingFull:
+				if attrs["prompt"] != prompt {
+					t.Errorf("expected the prompt text in span, got %v", attrs)
+				}
+			}
+		})
+	}
+}
+
+func TestInferStreamFinalEventTotals(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "mock", MeanMs: 1, P95Ms: 1, CostPer1k: 0.002})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})})
+
+	body := `{"model": "gpt-4o", "prompt": "hello world", "max_tokens": 100, "stream": true}`
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	var text strings.Builder
+	var deltas int
+	var done *StreamDone
+	event := ""
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "":
+				var d struct{ Delta string }
+				if err := json.Unmarshal(data, &d); err != nil {
+					t.Fatal(err)
+				}
+				if done != nil {
+					t.Fatal("delta after done event")
+				}
+				text.WriteString(d.Delta)
+				deltas++
+			case "done":
+				done = &StreamDone{}
+				if err := json.Unmarshal(data, done); err != nil {
+					t.Fatal(err)
+				}
+			default:
+				t.Fatalf("unexpected event %q: %s", event, data)
+			}
+			event = ""
+		}
+	}
+	if done == nil {
+		t.Fatalf("no done event in %q", rec.Body.String())
+	}
+	if deltas < 2 || text.String() != "(mock) hello" {
+		t.Errorf("expected the mock text in several deltas, got %d deltas of %q", deltas, text.String())
+	}
+
+	estimator := BuildTokenEstimator(cfg)
+	if want := estimator.EstimateTokens(text.String(), "gpt-4o"); done.CompletionTokens != want {
+		t.Errorf("expected %d completion tokens for the streamed text, got %d", want, done.CompletionTokens)
+	}
+	if want := estimator.EstimatePromptTokens("hello world", "gpt-4o"); done.PromptTokens != want {
+		t.Errorf("expected %d prompt tokens, got %d", want, done.PromptTokens)
+	}
+	if done.Provider != "mock" || done.CostUSD != 0.002*100/1000.0 {
+		t.Errorf("unexpected totals %+v", done)
+	}
+}
+
+// wordStreamer streams words one delta at a time until the caller stops it
+type wordStreamer struct {
+	words int
+}
+
+func (p *wordStreamer) Name() string                        { return "words" }
+func (p *wordStreamer) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (p *wordStreamer) Complete(_ context.Context, _ providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	return providers.CompletionResponse{Text: strings.Repeat("word ", p.words)}, 0.001, 1, nil
+}
+func (p *wordStreamer) CompleteStream(_ context.Context, _ providers.CompletionRequest, onDelta func(string) error) (providers.CompletionResponse, float64, int64, error) {
+	var text strings.Builder
+	for i := 0; i < p.words; i++ {
+		if err := onDelta("word "); err != nil {
+			return providers.CompletionResponse{Text: text.String()}, 0, 1, err
+		}
+		text.WriteString("word ")
+	}
+	return providers.CompletionResponse{Text: text.String()}, 0.001, 1, nil
+}
+
+func TestInferStreamStopsAtDailyTokenLimit(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest", StreamUsageCheckpointTokens: 5}
+	rp := providers.WithResilience(&wordStreamer{words: 200}, providers.ResilienceOptions{CBWindowSize: 10})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{rp})
+	tenant := &auth.Tenant{TenantID: "t-stream-limit", Plan: "free", Enabled: true, DailyTokenLimit: 40}
+
+	body := `{"model": "gpt-4o", "prompt": "hi", "stream": true}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler(rec, req.WithContext(auth.WithTenant(req.Context(), tenant)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var text strings.Builder
+	var stopped map[string]string
+	event := ""
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "":
+				var d struct{ Delta string }
+				if err := json.Unmarshal(data, &d); err != nil {
+					t.Fatal(err)
+				}
+				text.WriteString(d.Delta)
+			case "error":
+				if err := json.Unmarshal(data, &stopped); err != nil {
+					t.Fatal(err)
+				}
+			default:
+				t.Fatalf("unexpected event %q: %s", event, data)
+			}
+			event = ""
+		}
+	}
+	if stopped["error_kind"] != "token_limit_exceeded" || !strings.Contains(stopped["message"], "40") {
+		t.Fatalf("expected a token_limit_exceeded error event, got %v in %q", stopped, rec.Body.String())
+	}
+	if text.Len() == 0 || text.Len() >= len(strings.Repeat("word ", 200)) {
+		t.Errorf("expected a truncated stream, got %d bytes", text.Len())
+	}
+	if got := dailyTokens.GetUsage(tenant.TenantID); got <= 40 || got > 60 {
+		t.Errorf("expected the daily count just past the limit, got %d", got)
+	}
+	// stopping the stream is not the provider failing
+	if rp.CBStateValue() != 2 {
+		t.Errorf("expected the breaker to stay closed, got state %v", rp.CBStateValue())
+	}
+
+	// the next request is turned away before it reaches a provider
+	req = httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	handler(rec, req.WithContext(auth.WithTenant(req.Context(), tenant)))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After once over the limit, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+// hungUpWriter takes the stream's first event and then fails, like a client that closed
+// the connection before the request context noticed
+type hungUpWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *hungUpWriter) Write(b []byte) (int, error) {
+	if w.writes++; w.writes > 1 {
+		return 0, syscall.EPIPE
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestInferStreamWriteFailureIsClientCancellation(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	rp := providers.WithResilience(&wordStreamer{words: 20}, providers.ResilienceOptions{CBWindowSize: 10})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{rp})
+	cancellations := testutil.ToFloat64(telemetry.ClientCancellationsTotal.WithLabelValues("words", "cheapest"))
+	errs := testutil.ToFloat64(telemetry.ErrorsTotal.WithLabelValues("words", "unknown"))
+
+	body := `{"model": "gpt-4o", "prompt": "hi", "stream": true}`
+	w := &hungUpWriter{ResponseRecorder: httptest.NewRecorder()}
+	handler(w, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body)))
+
+	if got := testutil.ToFloat64(telemetry.ClientCancellationsTotal.WithLabelValues("words", "cheapest")) - cancellations; got != 1 {
+		t.Errorf("expected one client cancellation, got %v", got)
+	}
+	if got := testutil.ToFloat64(telemetry.ErrorsTotal.WithLabelValues("words", "unknown")) - errs; got != 0 {
+		t.Errorf("expected no provider error, got %v", got)
+	}
+	if rp.CBStateValue() != 2 {
+		t.Errorf("expected the breaker to stay closed, got state %v", rp.CBStateValue())
+	}
+	if strings.Contains(w.Body.String(), "event: error") {
+		t.Errorf("expected no error event to a client that hung up, got %q", w.Body.String())
+	}
+}
+
+func TestInferWarnsNearDailyTokenLimit(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest", UsageWarningPct: 80}
+	handler := handleInfer(cfg, []*providers.ResilientProvider{
+		providers.WithResilience(providers.NewMockProvider(1, 1, 0, 0.001), providers.ResilienceOptions{CBWindowSize: 10}),
+	})
+	tenant := &auth.Tenant{TenantID: "t-soft-infer", Plan: "free", Enabled: true, DailyTokenLimit: 1000}
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"model": "gpt-4o", "prompt": "hi"}`))
+		rec := httptest.NewRecorder()
+		handler(rec, req.WithContext(auth.WithTenant(req.Context(), tenant)))
+		return rec
+	}
+	warnings := func() float64 {
+		return testutil.ToFloat64(telemetry.UsageWarningsTotal.WithLabelValues("daily_tokens"))
+	}
+	before := warnings()
+
+	dailyTokens.AddTokens(tenant.TenantID, 500)
+	if rec := send(); rec.Code != http.StatusOK || rec.Header().Get("X-Usage-Warning") != "" {
+		t.Fatalf("expected 200 without a warning at 50%%, got %d %q", rec.Code, rec.Header().Get("X-Usage-Warning"))
+	}
+	dailyTokens.AddTokens(tenant.TenantID, 850-dailyTokens.GetUsage(tenant.TenantID))
+	for i := 0; i < 2; i++ {
+		rec := send()
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Header().Get("X-Usage-Warning"), "of 1000") {
+			t.Fatalf("expected 200 with X-Usage-Warning past 80%%, got %d %q", rec.Code, rec.Header().Get("X-Usage-Warning"))
+		}
+	}
+	if got := warnings() - before; got != 1 {
+		t.Errorf("expected the crossing counted once for the day, got %v", got)
+	}
+}
+
+// deadlineProvider remembers how long its last call had before the context deadline
+type deadlineProvider struct {
+	mu   sync.Mutex
+	left time.Duration
+}
+
+func (p *deadlineProvider) Name() string                        { return "deadline" }
+func (p *deadlineProvider) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (p *deadlineProvider) Complete(ctx context.Context, _ providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.left = 0
+	if deadline, ok := ctx.Deadline(); ok {
+		p.left = time.Until(deadline)
+	}
+	return providers.CompletionResponse{Text: "ok"}, 0.0001, 1, nil
+}
+
+func TestInferTimeoutFollowsPolicy(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:  "cheapest",
+		PolicyTimeouts: map[string]time.Duration{"fastest_p95": 2 * time.Second, "cheapest": time.Minute},
+	}
+	p := &deadlineProvider{}
+	handler := handleInfer(cfg, []*providers.ResilientProvider{
+		providers.WithResilience(p, providers.ResilienceOptions{Timeout: 30 * time.Second, CBWindowSize: 10}),
+	})
+
+	tests := []struct {
+		policy   string
+		min, max time.Duration
+	}{
+		{policy: "fastest_p95", min: time.Second, max: 2 * time.Second},
+		{policy: "cheapest", min: 59 * time.Second, max: time.Minute},
+		{policy: "slo_burn_aware", min: 29 * time.Second, max: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			body := `{"model": "gpt-4o", "prompt": "hi", "policy": "` + tt.policy + `"}`
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.left < tt.min || p.left > tt.max {
+				t.Errorf("expected a deadline between %v and %v away, got %v", tt.min, tt.max, p.left)
+			}
+		})
+	}
+}
+
+func TestInferReportsAttempts(t *testing.T) {
+	for _, tc := range []struct {
+		maxAttempts int
+		want        string
+	}{
+		{maxAttempts: 1, want: "1"}, // the mock's retry is cut off by the budget
+		{maxAttempts: 0, want: "2"}, // unlimited: first call plus one retry
+	} {
+		handler := HandleInfer(config.Config{
+			DefaultPolicy:      "cheapest",
+			EnableMockProvider: true,
+			MockErrorRate:      1.0,
+			MaxTotalAttempts:   tc.maxAttempts,
+		})
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hi"}`)))
+		if rr.Code != http.StatusBadGateway {
+			t.Fatalf("expected 502 from a failing provider, got %d", rr.Code)
+		}
+		if got := rr.Header().Get("X-Router-Attempts"); got != tc.want {
+			t.Errorf("MAX_TOTAL_ATTEMPTS=%d: expected X-Router-Attempts %s, got %q", tc.maxAttempts, tc.want, got)
+		}
+	}
+}
+
+// failOnceProvider fails its first call and answers after that
+type failOnceProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *failOnceProvider) Name() string                        { return "flaky" }
+func (p *failOnceProvider) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (p *failOnceProvider) Complete(_ context.Context, _ providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls == 1 {
+		return providers.CompletionResponse{}, 0, 5, &providers.StatusError{Provider: "flaky", StatusCode: http.StatusServiceUnavailable}
+	}
+	return providers.CompletionResponse{Text: "ok"}, 0.0001, 5, nil
+}
+
+func TestInferResponseReportsRetry(t *testing.T) {
+	p := &failOnceProvider{}
+	handler := handleInfer(config.Config{DefaultPolicy: "cheapest"}, []*providers.ResilientProvider{
+		providers.WithResilience(p, providers.ResilienceOptions{MaxRetries: 1, CBWindowSize: 10}),
+	})
+	send := func() InferResponse {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hi"}`)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200 after a retry, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp InferResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := send()
+	if resp.Attempts != 2 {
+		t.Errorf("expected attempts 2 for a request served after one retry, got %d", resp.Attempts)
+	}
+	if len(resp.FailedProviders) != 1 || resp.FailedProviders[0] != "flaky" {
+		t.Errorf("expected the failed attempt's provider listed, got %v", resp.FailedProviders)
+	}
+
+	if resp = send(); resp.Attempts != 1 || resp.FailedProviders != nil {
+		t.Errorf("expected a clean first attempt reported as such, got attempts %d failed %v", resp.Attempts, resp.FailedProviders)
+	}
+}
+
+// fakeUsageTable answers DynamoDB calls over HTTP, keeping the request_id of every
+// usage row written
+type fakeUsageTable struct {
+	mu         sync.Mutex
+	requestIDs []string
+}
+
+func (f *fakeUsageTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Amz-Target") == "DynamoDB_20120810.PutItem" {
+		var in struct {
+			Item map[string]struct{ S string }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err == nil && strings.HasPrefix(in.Item["pk"].S, "usage#") {
+			f.mu.Lock()
+			f.requestIDs = append(f.requestIDs, in.Item["request_id"].S)
+			f.mu.Unlock()
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	// the SDK verifies DynamoDB's body checksum
+	w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte("{}"))), 10))
+	w.Write([]byte("{}"))
+}
+
+func TestUsageRecordKeepsGeneratedRequestID(t *testing.T) {
+	table := &fakeUsageTable{}
+	ddb := httptest.NewServer(table)
+	defer ddb.Close()
+	t.Setenv("AWS_ENDPOINT_URL_DYNAMODB", ddb.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	store, err := usage.NewStore("usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{
+		DefaultPolicy:      "cheapest",
+		OpenAIModel:        "gpt-4o",
+		EnableMockProvider: true,
+		MockMeanLatencyMs:  1,
+		MockP95LatencyMs:   1,
+		MockCostPer1kUSD:   0.002,
+	}
+	handler := telemetry.RequestIDMiddleware(HandleInferWithUsageTracking(cfg, store))
+	tenant := &auth.Tenant{TenantID: "t-trace", Enabled: true}
+
+	// no X-Request-ID on the way in
+	req := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hi", "max_tokens": 10}`))
+	req = req.WithContext(auth.WithTenant(req.Context(), tenant))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp InferResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	id := rec.Header().Get("X-Request-ID")
+	if id == "" {
+		t.Fatal("expected a generated X-Request-ID")
+	}
+	if resp.RequestID != id {
+		t.Errorf("expected the response body to carry %s, got %q", id, resp.RequestID)
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	if len(table.requestIDs) != 1 || table.requestIDs[0] != id {
+		t.Errorf("expected one usage row with request ID %s, got %q", id, table.requestIDs)
+	}
+}
+
+func TestInferCountsTokens(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(&recordingProvider{}, providers.ResilienceOptions{CBWindowSize: 10})})
+	tokens := func(direction string) float64 {
+		return testutil.ToFloat64(telemetry.TokensTotal.WithLabelValues("recorder", direction))
+	}
+	in, out := tokens("in"), tokens("out")
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "count these tokens please"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	estimator := BuildTokenEstimator(cfg)
+	wantIn := float64(estimator.EstimatePromptTokens("count these tokens please", ""))
+	wantOut := float64(estimator.EstimateTokens("ok", ""))
+	if got := tokens("in") - in; got != wantIn || got == 0 {
+		t.Errorf("expected %v prompt tokens counted as in, got %v", wantIn, got)
+	}
+	if got := tokens("out") - out; got != wantOut || got == 0 {
+		t.Errorf("expected %v completion tokens counted as out, got %v", wantOut, got)
+	}
+}
+
+// countingProvider counts the calls that reach it
+type countingProvider struct {
+	providers.Provider
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *countingProvider) Complete(ctx context.Context, req providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+	return p.Provider.Complete(ctx, req)
+}
+
+func (p *countingProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func TestInferAllProvidersTrippedIs503(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	failing := &countingProvider{Provider: providers.NewMockProviderWithOptions(providers.MockOptions{Name: "failing", MeanMs: 1, P95Ms: 1, ErrorRate: 1})}
+	tripped := providers.WithResilience(failing, providers.ResilienceOptions{CBWindowSize: 2, CBCooldown: time.Minute})
+	for i := 0; i < 2; i++ {
+		_, _, _, _ = tripped.Complete(context.Background(), providers.CompletionRequest{Prompt: "hi"})
+	}
+	if tripped.CBStateValue() != 0 {
+		t.Fatalf("expected the breaker open, got state %v", tripped.CBStateValue())
+	}
+	forced := &countingProvider{Provider: providers.NewMockProviderWithOptions(providers.MockOptions{Name: "forced", MeanMs: 1, P95Ms: 1})}
+	held := providers.WithResilience(forced, providers.ResilienceOptions{CBWindowSize: 10})
+	held.ForceOpenCB()
+	off := &countingProvider{Provider: providers.NewMockProviderWithOptions(providers.MockOptions{Name: "off", MeanMs: 1, P95Ms: 1})}
+	disabled := providers.WithResilience(off, providers.ResilienceOptions{CBWindowSize: 10})
+	disabled.SetEnabled(false)
+
+	handler := handleInfer(cfg, []*providers.ResilientProvider{tripped, held, disabled})
+	before := failing.count()
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "canary", "latency_slo"} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hello", "max_tokens": 10, "policy": "`+policy+`"}`)))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected 503, got %d: %s", policy, rec.Code, rec.Body.String())
+		}
+		// the open breaker is due a probe in about a minute
+		if secs, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || secs < 1 || secs > 60 {
+			t.Errorf("%s: expected Retry-After within the cooldown, got %q", policy, rec.Header().Get("Retry-After"))
+		}
+	}
+	if failing.count() != before || forced.count() != 0 || off.count() != 0 {
+		t.Errorf("expected no provider calls, got %d, %d and %d", failing.count()-before, forced.count(), off.count())
+	}
+	if resp := readiness(); resp.Ready || resp.Reason != "all providers tripped or disabled" {
+		t.Errorf("expected readyz to fail with the tripped providers, got %+v", resp)
+	}
+
+	// with only operator action bringing a provider back, the default retry is suggested
+	handler = handleInfer(cfg, []*providers.ResilientProvider{held, disabled})
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hello", "max_tokens": 10}`)))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected 503 with Retry-After 30, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// no providers at all is a configuration problem, not one to retry
+	handler = handleInfer(cfg, nil)
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hello", "max_tokens": 10}`)))
+	if rec.Code != http.StatusBadGateway || rec.Header().Get("Retry-After") != "" {
+		t.Errorf("expected 502 without Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/rate"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+// newIntegrationServer wires the router the way cmd/server does, with only the
+// mock provider behind it. Idempotency needs a DynamoDB table, so it's left out.
+func newIntegrationServer(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+	tenants := []map[string]any{{
+		"tenant_id":         "t-integration",
+		"name":              "integration",
+		"plan":              "pro",
+		"rps_limit":         2,
+		"daily_token_limit": 1_000_000,
+		"enabled":           true,
+		"api_key_hash":      auth.HashAPIKey(apiKey, ""),
+	}}
+	data, err := json.Marshal(tenants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keyManager, err := auth.NewAPIKeyManager("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{
+		DefaultPolicy:            "cheapest",
+		EnableMockProvider:       true,
+		MockMeanLatencyMs:        1,
+		MockP95LatencyMs:         2,
+		MockCostPer1kUSD:         0.002,
+		MaxDecompressedBodyBytes: 1 << 20,
+		MaxGlobalConcurrency:     8,
+	}
+	limiter := rate.NewLimiter()
+
+	r := chi.NewRouter()
+	r.Use(telemetry.RequestIDMiddleware)
+	r.Use(telemetry.AccessLog(1))
+	r.Use(Gzip(cfg.MaxDecompressedBodyBytes))
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(keyManager.APIKeyMiddleware)
+		r.Use(limiter.RateLimitMiddleware)
+		r.With(GlobalConcurrencyLimit(cfg.MaxGlobalConcurrency, 0, 1<<20)).Post("/infer", HandleInfer(cfg))
+	})
+	r.Mount("/v1/admin", NewAdminRouter([]AdminToken{{Name: "ops", Token: "admin-secret", Role: RoleAdmin}}))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestIntegrationRequestLifecycle(t *testing.T) {
+	const apiKey = "integration-key"
+	srv := newIntegrationServer(t, apiKey)
+
+	infer := func(key string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/infer", strings.NewReader(`{"prompt": "hello there", "max_tokens": 16}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body json.RawMessage
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp, body
+	}
+
+	if resp, _ := infer(""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an API key, got %d", resp.StatusCode)
+	}
+
+	first, firstBody := infer(apiKey)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", first.StatusCode, firstBody)
+	}
+	if first.Header.Get("X-Request-ID") == "" || first.Header.Get("X-RateLimit-Limit") != "2" {
+		t.Errorf("expected request ID and rate limit headers, got %v", first.Header)
+	}
+	var out InferResponse
+	if err := json.Unmarshal(firstBody, &out); err != nil || out.Provider != "mock" || out.Text == "" {
+		t.Fatalf("unexpected infer response %s (%v)", firstBody, err)
+	}
+
+	if second, body := infer(apiKey); second.StatusCode != http.StatusOK {
+		t.Fatalf("expected a second call within the rps limit, got %d: %s", second.StatusCode, body)
+	}
+
+	// the tenant's 2 rps bucket is spent by the two calls
+	if limited, body := infer(apiKey); limited.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the rps limit is spent, got %d: %s", limited.StatusCode, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/admin/status", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected admin status 200, got %d", resp.StatusCode)
+	}
+	var status AdminStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	// the two calls reached the provider; the 429 did not
+	if status.TotalRequests != 2 || len(status.Providers) != 1 || status.Providers[0].Name != "mock" {
+		t.Errorf("expected admin status to show the two provider calls, got %+v", status)
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"unicode/utf8"
+)
+
+// largeTextBytes is the completion size past which InferResponse is encoded in pieces
+const largeTextBytes = 64 << 10
+
+// textChunkBytes is how much of the text is escaped and written at a time
+const textChunkBytes = 16 << 10
+
+// writeInferResponse writes resp as JSON with status 200. A large completion's text is
+// escaped and written a chunk at a time instead of being copied into one encoded
+// buffer, so the response costs little more memory than the text itself; the bytes
+// are the same as json.Encoder's. Big bodies go out chunked either way.
+func writeInferResponse(w http.ResponseWriter, resp InferResponse) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if len(resp.Text) <= largeTextBytes {
+		return json.NewEncoder(w).Encode(resp)
+	}
+
+	text := resp.Text
+	resp.Text = ""
+	shell, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	// text is the first field that can hold a quote, so the first match is the field
+	const empty = `"text":""`
+	at := bytes.Index(shell, []byte(empty))
+	if at < 0 {
+		// can't happen while InferResponse has a text field; fall back to one buffer
+		resp.Text = text
+		return json.NewEncoder(w).Encode(resp)
+	}
+	if _, err := w.Write(shell[:at+len(empty)-1]); err != nil {
+		return err
+	}
+	for len(text) > 0 {
+		n := min(textChunkBytes, len(text))
+		// keep multi-byte runes whole so each chunk is valid UTF-8
+		for n < len(text) && !utf8.RuneStart(text[n]) {
+			n++
+		}
+		quoted, err := json.Marshal(text[:n])
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(quoted[1 : len(quoted)-1]); err != nil {
+			return err
+		}
+		text = text[n:]
+	}
+	if _, err := w.Write(shell[at+len(empty)-1:]); err != nil {
+		return err
+	}
+	_, err = w.Write([]byte("\n"))
+	return err
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+func TestWriteInferResponseMatchesEncoder(t *testing.T) {
+	// multi-byte runes and characters json escapes straddle the chunk boundaries
+	text := strings.Repeat("héllo <wörld> \"quoted\" 日本語\n", 10000)
+	for _, resp := range []InferResponse{
+		{Provider: "mock", Model: "m", Text: "short", CostUSD: 0.001, RequestID: "r1", Attempts: 1},
+		{Provider: "mock", Model: `"text":""`, Text: text, CostUSD: 0.5, LatencyMs: 12, RequestID: "r2", Attempts: 2, FailedProviders: []string{"mock"},
+			ToolCalls: []providers.ToolCall{{ID: "c1", Name: "f", Arguments: json.RawMessage(`{"a":1}`)}}},
+	} {
+		var want bytes.Buffer
+		if err := json.NewEncoder(&want).Encode(resp); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		if err := writeInferResponse(w, resp); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.Body.Bytes(), want.Bytes()) {
+			t.Fatalf("expected the encoder's bytes for a %d-byte text, got a %d-byte body differing from the %d expected", len(resp.Text), w.Body.Len(), want.Len())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected JSON content type, got %q", ct)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+)
+
+// Bounds used when the config leaves them unset
+const (
+	defaultMaxPromptChars  = 100000
+	defaultMaxOutputTokens = 8192
+)
+
+// RequestLimits bounds prompt length and max_tokens. Per-model entries are keyed by
+// model name prefix and the longest matching prefix wins over the defaults.
+type RequestLimits struct {
+	MaxPromptChars    int
+	MaxOutputTokens   int
+	ModelPromptChars  map[string]int
+	ModelOutputTokens map[string]int
+}
+
+// requestLimits reads MAX_PROMPT_CHARS and MAX_OUTPUT_TOKENS from cfg
+func requestLimits(cfg config.Config) RequestLimits {
+	l := RequestLimits{
+		MaxPromptChars:    cfg.MaxPromptChars,
+		MaxOutputTokens:   cfg.MaxOutputTokens,
+		ModelPromptChars:  cfg.ModelMaxPromptChars,
+		ModelOutputTokens: cfg.ModelMaxOutputTokens,
+	}
+	if l.MaxPromptChars <= 0 {
+		l.MaxPromptChars = defaultMaxPromptChars
+	}
+	if l.MaxOutputTokens <= 0 {
+		l.MaxOutputTokens = defaultMaxOutputTokens
+	}
+	return l
+}
+
+// Check validates req against the bounds for req.Model, so it belongs after the
+// model has been resolved
+func (l RequestLimits) Check(req InferRequest) error {
+	forModel := ""
+	if req.Model != "" {
+		forModel = " for model " + req.Model
+	}
+	maxChars := modelLimit(l.ModelPromptChars, req.Model, l.MaxPromptChars)
+	chars := len(req.Prompt)
+	for _, m := range req.Messages {
+		chars += len(m.Content)
+	}
+	if chars > maxChars {
+		return fmt.Errorf("prompt exceeds maximum length of %d characters%s", maxChars, forModel)
+	}
+	maxOut := modelLimit(l.ModelOutputTokens, req.Model, l.MaxOutputTokens)
+	if req.MaxTok > maxOut {
+		return fmt.Errorf("max_tokens must be between 1 and %d%s", maxOut, forModel)
+	}
+	return nil
+}
+
+// modelLimit returns the entry with the longest prefix of model, or def
+func modelLimit(limits map[string]int, model string, def int) int {
+	best, limit := -1, def
+	for prefix, v := range limits {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, limit = len(prefix), v
+		}
+	}
+	return limit
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+func TestRequestLimitsPerModel(t *testing.T) {
+	cfg := config.Config{
+		DefaultPolicy:        "cheapest",
+		MaxPromptChars:       1000,
+		ModelMaxPromptChars:  map[string]int{"long-": 5000, "long-mini": 500},
+		ModelMaxOutputTokens: map[string]int{"long-": 32000},
+	}
+	p := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "mock", MeanMs: 1, P95Ms: 1})
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(p, providers.ResilienceOptions{CBWindowSize: 10})})
+
+	prompt := strings.Repeat("a", 2000)
+	tests := []struct {
+		name   string
+		model  string
+		maxTok int
+		want   int
+	}{
+		{name: "large context model takes a long prompt", model: "long-context-v2", want: http.StatusOK},
+		{name: "small model rejects it", model: "small-v1", want: http.StatusBadRequest},
+		{name: "longest prefix wins", model: "long-mini-v1", want: http.StatusBadRequest},
+		{name: "large output model", model: "long-context-v2", maxTok: 16000, want: http.StatusOK},
+		{name: "default output cap", model: "small-v1", maxTok: 16000, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"model": %q, "prompt": %q}`, tt.model, prompt)
+			if tt.maxTok > 0 {
+				body = fmt.Sprintf(`{"model": %q, "prompt": "hi", "max_tokens": %d}`, tt.model, tt.maxTok)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body)))
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+)
+
+// maxFallbackModels bounds the models list of one request
+const maxFallbackModels = 10
+
+// viableModel reports whether a request, with its model set, fits the request limits
+// and, unless it may be truncated, the model's context window
+func viableModel(limits RequestLimits, windows contextWindows, estimator *usage.TokenEstimator) func(InferRequest) bool {
+	return func(req InferRequest) bool {
+		if limits.Check(req) != nil {
+			return false
+		}
+		if req.Truncate {
+			return true
+		}
+		window := windows.window(req.Model)
+		return window <= 0 || overflow(estimator, req, window) <= 0
+	}
+}
+
+// pickModel walks req.Models in order and returns the first model the tenant may use,
+// that fits passes for and that a provider is available for, with that provider.
+// forced, when set, is the provider the request named and stands in for the routing
+// decision. When no model is viable the first one is returned with a nil provider, so
+// the request fails the way it would have asking for that model alone.
+func pickModel(aliases map[string]string, eng *router.Engine, tenant *auth.Tenant, fits func(InferRequest) bool, req InferRequest, forced *providers.ResilientProvider) (string, *providers.ResilientProvider, error) {
+	first := ""
+	for i, m := range req.Models {
+		model, err := resolveModelAlias(aliases, m)
+		if err != nil {
+			return "", nil, err
+		}
+		if i == 0 {
+			first = model
+		}
+		candidate := req
+		candidate.Model = model
+		if tenant != nil && !tenant.ModelAllowed(model) {
+			continue
+		}
+		if !fits(candidate) {
+			continue
+		}
+		chosen := forced
+		if chosen == nil {
+			chosen = chooseProvider(eng, tenant, candidate)
+		}
+		if chosen != nil {
+			return model, chosen, nil
+		}
+	}
+	return first, nil, nil
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PROMPT_LOGGING modes: none keeps prompts out of spans and logs, hash records a
+// SHA-256 for correlating requests, full records the text (dev only)
+const (
+	PromptLoggingNone = "none"
+	PromptLoggingHash = "hash"
+	PromptLoggingFull = "full"
+)
+
+// promptText joins every turn sent to the provider
+func promptText(req InferRequest) string {
+	return strings.Join(messageContents(req), "\n")
+}
+
+func promptHash(req InferRequest) string {
+	sum := sha256.Sum256([]byte(promptText(req)))
+	return hex.EncodeToString(sum[:])
+}
+
+// promptAttributes returns the span attributes mode allows for the request's prompt
+func promptAttributes(mode string, req InferRequest) []attribute.KeyValue {
+	switch mode {
+	case PromptLoggingHash:
+		return []attribute.KeyValue{attribute.String("prompt.sha256", promptHash(req))}
+	case PromptLoggingFull:
+		return []attribute.KeyValue{attribute.String("prompt", promptText(req))}
+	default:
+		return nil
+	}
+}
+
+// logPrompt adds the prompt field mode allows to a log event
+func logPrompt(ev *zerolog.Event, mode string, req InferRequest) *zerolog.Event {
+	switch mode {
+	case PromptLoggingHash:
+		return ev.Str("prompt_sha256", promptHash(req))
+	case PromptLoggingFull:
+		return ev.Str("prompt", promptText(req))
+	default:
+		return ev
+	}
+}
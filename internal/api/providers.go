@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+	"github.com/rs/zerolog/log"
+)
+
+// BuildProviders constructs every configured provider wrapped with resilience
+func BuildProviders(cfg config.Config) []*providers.ResilientProvider {
+	failureKinds := cbFailureKinds(cfg.CBFailureKinds)
+	remote := providers.ResilienceOptions{
+		Timeout:      30 * 1_000_000_000, // 30s
+		MaxRetries:   2,
+		BaseBackoff:  200 * 1_000_000,   // 200ms
+		MaxBackoff:   2 * 1_000_000_000, // 2s
+		JitterFrac:   0.2,
+		CBWindowSize: 20,
+		CBCooldown:   30 * 1_000_000_000, // 30s
+
+		CBFailureKinds:  failureKinds,
+		OnCircuitChange: publishCircuitChange,
+		OnRetry:         countRetry,
+	}
+
+	provs := make([]*providers.ResilientProvider, 0, 4)
+	if cfg.OpenAIKey != "" {
+		op := providers.NewOpenAIProvider(cfg.OpenAIKey, cfg.OpenAIBaseURL, cfg.OpenAIOrg)
+		op.SetDefaultModel(cfg.OpenAIModel)
+		provs = append(provs, providers.WithResilience(op, withQuota(remote, cfg, op.Name(), 0, 0)))
+	}
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" || cfg.AWSProfile != "" || cfg.AWSRoleARN != "" {
+		br, err := providers.NewBedrockProvider(providers.BedrockOptions{
+			ModelID: cfg.BedrockModelID,
+			Regions: cfg.BedrockRegions,
+			API:     cfg.BedrockAPI,
+			OnRegion: func(region, outcome string) {
+				telemetry.BedrockRegionRequestsTotal.WithLabelValues(region, outcome).Inc()
+			},
+		})
+		if err == nil {
+			provs = append(provs, providers.WithResilience(br, withQuota(remote, cfg, br.Name(), 0, 0)))
+		} else {
+			log.Warn().Err(err).Msg("bedrock init failed")
+		}
+	}
+	// Optional self-hosted OpenAI-compatible endpoint (vLLM, Ollama)
+	if cfg.LocalLLMBaseURL != "" {
+		lp := providers.NewOpenAICompatibleProvider(cfg.LocalLLMName, cfg.LocalLLMBaseURL, cfg.LocalLLMAPIKey,
+			map[string]float64{"default": cfg.LocalLLMCostPer1kUSD})
+		lp.SetDefaultModel(cfg.LocalLLMModel)
+		provs = append(provs, providers.WithResilience(lp, withQuota(remote, cfg, lp.Name(), 0, 0)))
+	}
+	// Optional Mock provider for local/dev testing
+	if cfg.EnableMockProvider {
+		mp := providers.NewMockProvider(float64(cfg.MockMeanLatencyMs), float64(cfg.MockP95LatencyMs), cfg.MockErrorRate, cfg.MockCostPer1kUSD)
+		provs = append(provs, providers.WithResilience(mp, withQuota(providers.ResilienceOptions{
+			Timeout:      30 * 1_000_000_000,
+			MaxRetries:   1,
+			BaseBackoff:  100 * 1_000_000,
+			MaxBackoff:   1 * 1_000_000_000,
+			JitterFrac:   0.2,
+			CBWindowSize: 20,
+			CBCooldown:   10 * 1_000_000_000,
+
+			CBFailureKinds:  failureKinds,
+			OnCircuitChange: publishCircuitChange,
+			OnRetry:         countRetry,
+		}, cfg, mp.Name(), 0, 0)))
+	}
+	// Additional named OpenAI-style endpoints, e.g. per key or region
+	if cfg.OpenAIProvidersPath != "" {
+		specs, err := LoadOpenAIProviders(cfg.OpenAIProvidersPath)
+		if err != nil {
+			log.Warn().Err(err).Msg("OPENAI_PROVIDERS_JSON load failed; no extra OpenAI-style providers")
+		}
+		taken := make(map[string]bool, len(provs))
+		for _, p := range provs {
+			taken[p.Name()] = true
+		}
+		for _, spec := range specs {
+			if taken[spec.Name] {
+				log.Warn().Str("provider", spec.Name).Msg("OPENAI_PROVIDERS_JSON entry reuses another provider's name; skipped")
+				continue
+			}
+			op := providers.NewOpenAIProviderWithOptions(providers.OpenAIOptions{
+				Name:    spec.Name,
+				APIKey:  spec.key(),
+				BaseURL: spec.BaseURL,
+				Org:     spec.Org,
+				Pricing: spec.Pricing,
+
+				EmbeddingPricing: spec.EmbeddingPricing,
+			})
+			op.SetDefaultModel(spec.Model)
+			provs = append(provs, providers.WithResilience(op, withQuota(remote, cfg, spec.Name, spec.RPM, spec.TPM)))
+		}
+	}
+	if cfg.ProviderWarmup {
+		WarmupProviders(context.Background(), provs)
+	}
+	return provs
+}
+
+// OpenAIProviderSpec is one entry of OPENAI_PROVIDERS_JSON: an OpenAI-style endpoint
+// routed as its own provider
+type OpenAIProviderSpec struct {
+	Name   string `json:"name"`
+	APIKey string `json:"api_key,omitempty"`
+	// APIKeyEnv names an environment variable holding the key, keeping it out of the file
+	APIKeyEnv string             `json:"api_key_env,omitempty"`
+	BaseURL   string             `json:"base_url,omitempty"`
+	Org       string             `json:"org,omitempty"`
+	Model     string             `json:"model,omitempty"`   // sent when a request names no model
+	Pricing   map[string]float64 `json:"pricing,omitempty"` // USD per 1k tokens; "default" covers unlisted models
+	// EmbeddingPricing is USD per 1k input tokens by embedding model; an endpoint with
+	// its own pricing is only routed embeddings when this is set
+	EmbeddingPricing map[string]float64 `json:"embedding_pricing,omitempty"`
+	// RPM and TPM are the endpoint's per-minute limits, taking precedence over PROVIDER_RPM/PROVIDER_TPM
+	RPM int `json:"rpm,omitempty"`
+	TPM int `json:"tpm,omitempty"`
+}
+
+func (s OpenAIProviderSpec) key() string {
+	if s.APIKeyEnv != "" {
+		return os.Getenv(s.APIKeyEnv)
+	}
+	return s.APIKey
+}
+
+// LoadOpenAIProviders reads a JSON array of OpenAIProviderSpec. Every entry needs a
+// name, and names must be unique.
+func LoadOpenAIProviders(path string) ([]OpenAIProviderSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var specs []OpenAIProviderSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(specs))
+	for i, s := range specs {
+		if s.Name == "" {
+			return nil, fmt.Errorf("%s: entry %d has no name", path, i)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("%s: duplicate provider name %q", path, s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return specs, nil
+}
+
+// withQuota sets name's RPM/TPM limits on opts: rpm and tpm when positive, else its
+// PROVIDER_RPM/PROVIDER_TPM entry or their default
+func withQuota(opts providers.ResilienceOptions, cfg config.Config, name string, rpm, tpm int) providers.ResilienceOptions {
+	opts.RPM, opts.TPM = rpm, tpm
+	if opts.RPM <= 0 {
+		opts.RPM = providerLimit(cfg.ProviderRPMs, name, cfg.ProviderRPM)
+	}
+	if opts.TPM <= 0 {
+		opts.TPM = providerLimit(cfg.ProviderTPMs, name, cfg.ProviderTPM)
+	}
+	opts.QuotaMaxWait = cfg.ProviderQuotaMaxWait
+	opts.OnQuota = publishQuota
+	return opts
+}
+
+// cbFailureKinds converts CB_FAILURE_KINDS, nil when unset so the providers default
+// applies
+func cbFailureKinds(names []string) []providers.ErrorKind {
+	if len(names) == 0 {
+		return nil
+	}
+	kinds := make([]providers.ErrorKind, 0, len(names))
+	for _, n := range names {
+		kinds = append(kinds, providers.ErrorKind(n))
+	}
+	return kinds
+}
+
+func providerLimit(perProvider map[string]int, name string, def int) int {
+	if v, ok := perProvider[name]; ok {
+		return v
+	}
+	return def
+}
+
+// publishQuota feeds router_provider_quota_saturation
+func publishQuota(provider string, requests, tokens float64) {
+	telemetry.ProviderQuotaSaturation.WithLabelValues(provider, "requests").Set(requests)
+	telemetry.ProviderQuotaSaturation.WithLabelValues(provider, "tokens").Set(tokens)
+}
+
+// countRetry feeds router_provider_retries_total, the failed-attempt waste that
+// router_request_cost_usd alone doesn't show
+func countRetry(provider string) {
+	telemetry.ProviderRetriesTotal.WithLabelValues(provider).Inc()
+}
+
+// publishCircuitChange logs breaker trips and recoveries and pushes them to the event webhook
+func publishCircuitChange(provider string, open bool) {
+	if open {
+		log.Warn().Str("event", events.CircuitOpen).Str("provider", provider).Msg("circuit breaker opened")
+		events.Publish(events.CircuitOpen, map[string]any{"provider": provider})
+		return
+	}
+	log.Info().Str("event", events.CircuitClosed).Str("provider", provider).Msg("circuit breaker closed")
+	events.Publish(events.CircuitClosed, map[string]any{"provider": provider})
+}
+
+// defaultModelFor is the model to send chosen when a request names none: the provider's
+// own default, so one provider's model ID never leaks to another, else fallback
+func defaultModelFor(chosen *providers.ResilientProvider, fallback string) string {
+	if chosen != nil {
+		if m := chosen.DefaultModel(); m != "" {
+			return m
+		}
+	}
+	return fallback
+}
+
+// warmupTimeout bounds how long warmup can delay startup or a reload
+const warmupTimeout = 5 * time.Second
+
+// WarmupProviders primes the connection pools of enabled providers in parallel. Failures
+// are logged and otherwise ignored; a provider that can't be reached is left to the breaker.
+func WarmupProviders(ctx context.Context, provs []*providers.ResilientProvider) {
+	ctx, cancel := context.WithTimeout(ctx, warmupTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, p := range provs {
+		if !p.Enabled() {
+			continue
+		}
+		wg.Add(1)
+		go func(p *providers.ResilientProvider) {
+			defer wg.Done()
+			start := time.Now()
+			if err := p.Warmup(ctx); err != nil {
+				log.Warn().Err(err).Str("provider", p.Name()).Msg("provider warmup failed")
+				return
+			}
+			log.Debug().Str("provider", p.Name()).Dur("took", time.Since(start)).Msg("provider warmed up")
+		}(p)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+func TestBuildProvidersWarmsEachProvider(t *testing.T) {
+	var warmups atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/models" {
+			warmups.Add(1)
+		}
+		w.Write([]byte(`{"data": []}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.Config{
+		LocalLLMBaseURL:    srv.URL + "/v1",
+		LocalLLMName:       "local",
+		EnableMockProvider: true,
+		MockMeanLatencyMs:  1,
+		MockP95LatencyMs:   1,
+	}
+
+	BuildProviders(cfg)
+	if n := warmups.Load(); n != 0 {
+		t.Fatalf("expected no warmup with PROVIDER_WARMUP off, got %d", n)
+	}
+
+	cfg.ProviderWarmup = true
+	provs := BuildProviders(cfg)
+	if len(provs) != 2 {
+		t.Fatalf("expected local and mock providers, got %d", len(provs))
+	}
+	if n := warmups.Load(); n != 1 {
+		t.Errorf("expected one warmup request to the local endpoint, got %d", n)
+	}
+}
+
+func TestBuildProvidersCountsRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	provs := BuildProviders(config.Config{LocalLLMBaseURL: srv.URL + "/v1", LocalLLMName: "flaky"})
+	before := testutil.ToFloat64(telemetry.ProviderRetriesTotal.WithLabelValues("flaky"))
+	if _, _, _, err := provs[0].Complete(context.Background(), providers.CompletionRequest{Model: "llama3", Prompt: "hi"}); err != nil {
+		t.Fatalf("expected success on the third attempt, got %v", err)
+	}
+	if got := testutil.ToFloat64(telemetry.ProviderRetriesTotal.WithLabelValues("flaky")) - before; got != 2 {
+		t.Errorf("expected two retries counted, got %v", got)
+	}
+}
+
+func TestWarmupProvidersSkipsDisabled(t *testing.T) {
+	enabled := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "a"})
+	disabled := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "b"})
+	provs := []*providers.ResilientProvider{
+		providers.WithResilience(enabled, providers.ResilienceOptions{CBWindowSize: 10}),
+		providers.WithResilience(disabled, providers.ResilienceOptions{CBWindowSize: 10}),
+	}
+	provs[1].SetEnabled(false)
+
+	WarmupProviders(context.Background(), provs)
+
+	if enabled.Warmups() != 1 {
+		t.Errorf("expected enabled provider warmed once, got %d", enabled.Warmups())
+	}
+	if disabled.Warmups() != 0 {
+		t.Errorf("expected disabled provider skipped, got %d", disabled.Warmups())
+	}
+}
+
+func TestNamedOpenAIProviders(t *testing.T) {
+	endpoint := func(text string, gotKey *string) *httptest.Server {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*gotKey = r.Header.Get("Authorization")
+			w.Write([]byte(`{"choices":[{"message":{"content":"` + text + `"}}]}`))
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+	var primaryKey, secondaryKey string
+	primary := endpoint("from primary", &primaryKey)
+	secondary := endpoint("from secondary", &secondaryKey)
+	t.Setenv("TEST_SECONDARY_OPENAI_KEY", "sk-secondary")
+
+	path := filepath.Join(t.TempDir(), "openai.json")
+	specs := `[
+		{"name": "openai-primary", "api_key": "sk-primary", "base_url": "` + primary.URL + `/v1", "model": "gpt-4o", "pricing": {"gpt-4o": 5}},
+		{"name": "openai-secondary", "api_key_env": "TEST_SECONDARY_OPENAI_KEY", "base_url": "` + secondary.URL + `/v1", "model": "gpt-4o", "pricing": {"gpt-4o": 4}}
+	]`
+	if err := os.WriteFile(path, []byte(specs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	handler := HandleInfer(config.Config{DefaultPolicy: "cheapest", OpenAIProvidersPath: path})
+
+	var names []string
+	for _, p := range router.GetProviders() {
+		names = append(names, p.Name())
+	}
+	if strings.Join(names, ",") != "openai-primary,openai-secondary" {
+		t.Fatalf("expected both named providers registered, got %v", names)
+	}
+
+	infer := func(url string) InferResponse {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, url, strings.NewReader(`{"prompt": "hi"}`)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", url, rr.Code, rr.Body.String())
+		}
+		var resp InferResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	// cheapest picks the cheaper endpoint, and each can be forced by name
+	if resp := infer("/v1/infer"); resp.Provider != "openai-secondary" || resp.Text != "from secondary" {
+		t.Errorf("expected cheapest to route to openai-secondary, got %+v", resp)
+	}
+	if resp := infer("/v1/infer?provider=openai-primary"); resp.Provider != "openai-primary" || resp.Text != "from primary" {
+		t.Errorf("expected openai-primary when forced, got %+v", resp)
+	}
+	if primaryKey != "Bearer sk-primary" || secondaryKey != "Bearer sk-secondary" {
+		t.Errorf("expected each provider to send its own key, got %q and %q", primaryKey, secondaryKey)
+	}
+}
+
+func TestLoadOpenAIProvidersRejectsDuplicateNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openai.json")
+	os.WriteFile(path, []byte(`[{"name": "a"}, {"name": "a"}]`), 0o600)
+	if _, err := LoadOpenAIProviders(path); err == nil {
+		t.Error("expected duplicate names to be rejected")
+	}
+	os.WriteFile(path, []byte(`[{"base_url": "http://x"}]`), 0o600)
+	if _, err := LoadOpenAIProviders(path); err == nil {
+		t.Error("expected an unnamed entry to be rejected")
+	}
+}
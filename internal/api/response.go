@@ -9,6 +9,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/admission"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
 )
 
 // Problem represents RFC 7807 Problem Details for HTTP APIs
@@ -35,6 +40,10 @@ const (
 	ProblemTypeNotFound      = "https://llm-router.example.com/problems/not-found"
 	ProblemTypeInternal      = "https://llm-router.example.com/problems/internal-error"
 	ProblemTypeUsageExceeded = "https://llm-router.example.com/problems/usage-limit-exceeded"
+	ProblemTypeForbidden     = "https://llm-router.example.com/problems/forbidden"
+	ProblemTypeOverloaded    = "https://llm-router.example.com/problems/overloaded"
+	ProblemTypeContextWindow = "https://llm-router.example.com/problems/context-window-exceeded"
+	ProblemTypeUnavailable   = "https://llm-router.example.com/problems/providers-unavailable"
 )
 
 // ResponseWriter helps write consistent HTTP responses
@@ -44,9 +53,18 @@ type ResponseWriter struct {
 	traceID   string
 }
 
+// requestIDOf returns the ID RequestIDMiddleware gave r, or its X-Request-ID header
+// when the middleware isn't in front of the handler
+func requestIDOf(r *http.Request) string {
+	if id := telemetry.RequestIDFrom(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Request-ID")
+}
+
 // NewResponseWriter creates a new response writer with request tracking
 func NewResponseWriter(w http.ResponseWriter, r *http.Request) *ResponseWriter {
-	requestID := r.Header.Get("X-Request-ID")
+	requestID := requestIDOf(r)
 	if requestID == "" {
 		requestID = uuid.New().String()
 	}
@@ -84,7 +102,9 @@ func (rw *ResponseWriter) WriteProblem(problemType, title string, status int, de
 		TraceID:   rw.traceID,
 	}
 	
-	return rw.WriteJSON(status, problem)
+	rw.w.Header().Set("Content-Type", "application/problem+json")
+	rw.w.WriteHeader(status)
+	return json.NewEncoder(rw.w).Encode(problem)
 }
 
 // WriteValidationError writes a validation error response
@@ -108,6 +128,16 @@ func (rw *ResponseWriter) WriteAuthError(message string) error {
 	)
 }
 
+// WriteForbiddenError writes a 403 for authenticated callers not permitted the request
+func (rw *ResponseWriter) WriteForbiddenError(message string) error {
+	return rw.WriteProblem(
+		ProblemTypeForbidden,
+		"Forbidden",
+		http.StatusForbidden,
+		message,
+	)
+}
+
 // WriteRateLimitError writes a rate limit error response
 func (rw *ResponseWriter) WriteRateLimitError(limit int, windowSec int) error {
 	detail := fmt.Sprintf("Rate limit of %d requests per %d seconds exceeded", limit, windowSec)
@@ -130,6 +160,41 @@ func (rw *ResponseWriter) WriteProviderError(provider string, err error) error {
 	)
 }
 
+// unavailableRetryAfter is suggested when no provider will come back by itself, being
+// disabled or forced open until an operator steps in
+const unavailableRetryAfter = 30 * time.Second
+
+// unavailableRetryAfterHeader is the Retry-After for err in whole seconds, at least 1
+func unavailableRetryAfterHeader(err *router.UnavailableError) string {
+	d := err.RetryAfter
+	if d <= 0 {
+		d = unavailableRetryAfter
+	}
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
+
+// WriteUnavailableError writes a 503 for a request no provider can serve right now,
+// with Retry-After set to when the first breaker is due a probe
+func (rw *ResponseWriter) WriteUnavailableError(err *router.UnavailableError) error {
+	rw.w.Header().Set("Retry-After", unavailableRetryAfterHeader(err))
+	return rw.WriteProblem(
+		ProblemTypeUnavailable,
+		"Providers Unavailable",
+		http.StatusServiceUnavailable,
+		err.Error(),
+	)
+}
+
+// WriteContextWindowError writes a 422 for a request too large for its model's context window
+func (rw *ResponseWriter) WriteContextWindowError(model string, window int, over int64) error {
+	return rw.WriteProblem(
+		ProblemTypeContextWindow,
+		"Context Window Exceeded",
+		http.StatusUnprocessableEntity,
+		contextWindowMessage(model, window, over),
+	)
+}
+
 // WriteNotFoundError writes a not found error response
 func (rw *ResponseWriter) WriteNotFoundError(resource string) error {
 	detail := fmt.Sprintf("Resource not found: %s", resource)
@@ -164,18 +229,41 @@ func (rw *ResponseWriter) WriteUsageExceededError(limitType string, current, lim
 
 // Validation helpers
 
-// ValidateInferRequest validates an InferRequest according to OpenAPI spec
+// ValidateInferRequest validates an InferRequest according to OpenAPI spec. Size
+// bounds depend on the resolved model and are checked by RequestLimits
 func ValidateInferRequest(req *InferRequest) error {
-	if req.Prompt == "" {
-		return fmt.Errorf("prompt is required and cannot be empty")
+	if req.Prompt == "" && req.Template == "" && len(req.Messages) == 0 {
+		return fmt.Errorf("prompt, template or messages is required and cannot be empty")
 	}
-	
-	if len(req.Prompt) > 100000 {
-		return fmt.Errorf("prompt exceeds maximum length of 100,000 characters")
+	if req.Template != "" && req.Prompt != "" {
+		return fmt.Errorf("prompt and template cannot both be set")
+	}
+	if req.Template == "" && len(req.Variables) > 0 {
+		return fmt.Errorf("variables require a template")
+	}
+	if len(req.Models) > 0 {
+		if req.Model != "" {
+			return fmt.Errorf("model and models cannot both be set")
+		}
+		if len(req.Models) > maxFallbackModels {
+			return fmt.Errorf("models accepts at most %d entries", maxFallbackModels)
+		}
+		for i, m := range req.Models {
+			if strings.TrimSpace(m) == "" {
+				return fmt.Errorf("models[%d] cannot be empty", i)
+			}
+		}
 	}
 	
-	if req.MaxTok > 0 && (req.MaxTok < 1 || req.MaxTok > 8192) {
-		return fmt.Errorf("max_tokens must be between 1 and 8192")
+	for i, m := range req.Messages {
+		switch m.Role {
+		case "system", "developer", "user", "assistant":
+		default:
+			return fmt.Errorf("messages[%d].role must be one of: system, developer, user, assistant", i)
+		}
+		if m.Content == "" {
+			return fmt.Errorf("messages[%d].content cannot be empty", i)
+		}
 	}
 	
 	if req.Policy != "" {
@@ -184,12 +272,64 @@ func ValidateInferRequest(req *InferRequest) error {
 			"fastest_p95":      true,
 			"slo_burn_aware":   true,
 			"canary":          true,
+			"latency_slo":      true,
 		}
 		if !validPolicies[req.Policy] {
-			return fmt.Errorf("policy must be one of: cheapest, fastest_p95, slo_burn_aware, canary")
+			return fmt.Errorf("policy must be one of: cheapest, fastest_p95, slo_burn_aware, canary, latency_slo")
+		}
+	}
+
+	if req.Provider != nil && strings.TrimSpace(*req.Provider) == "" {
+		return fmt.Errorf("provider cannot be empty")
+	}
+
+	if len(req.ConversationID) > 256 {
+		return fmt.Errorf("conversation_id exceeds maximum length of 256 characters")
+	}
+
+	if req.Priority != "" {
+		if _, ok := admission.ParsePriority(req.Priority); !ok {
+			return fmt.Errorf("priority must be one of: high, normal, low")
+		}
+	}
+
+	return validateTools(req)
+}
+
+// validateTools checks tool definitions and that tool_choice refers to them
+func validateTools(req *InferRequest) error {
+	if len(req.Tools) == 0 {
+		if req.ToolChoice != "" {
+			return fmt.Errorf("tool_choice requires tools")
+		}
+		return nil
+	}
+	if req.Stream {
+		return fmt.Errorf("tools cannot be used with stream")
+	}
+	names := make(map[string]bool, len(req.Tools))
+	for i, t := range req.Tools {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("tools[%d].name cannot be empty", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("tools[%d].name %q is duplicated", i, t.Name)
+		}
+		names[t.Name] = true
+		if len(t.Parameters) > 0 {
+			var schema map[string]any
+			if err := json.Unmarshal(t.Parameters, &schema); err != nil || schema == nil {
+				return fmt.Errorf("tools[%d].parameters must be a JSON object", i)
+			}
+		}
+	}
+	switch req.ToolChoice {
+	case "", providers.ToolChoiceAuto, providers.ToolChoiceNone, providers.ToolChoiceRequired:
+	default:
+		if !names[req.ToolChoice] {
+			return fmt.Errorf("tool_choice must be auto, none, required or the name of a tool")
 		}
 	}
-	
 	return nil
 }
 
@@ -233,6 +373,35 @@ func ValidateCreateTenantRequest(req *CreateTenantRequest) error {
 		return fmt.Errorf("daily_token_limit must be between 1000 and 100000000")
 	}
 	
+	if err := ValidateTenantAccessLists(req.AllowedModels, req.DeniedProviders); err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// ValidateTenantAccessLists checks a tenant's allowed models and denied providers
+func ValidateTenantAccessLists(allowedModels, deniedProviders []string) error {
+	lists := []struct {
+		name string
+		list []string
+	}{{"allowed_models", allowedModels}, {"denied_providers", deniedProviders}}
+	for _, l := range lists {
+		name, list := l.name, l.list
+		if len(list) > 100 {
+			return fmt.Errorf("%s exceeds maximum of 100 entries", name)
+		}
+		seen := make(map[string]bool, len(list))
+		for _, v := range list {
+			if strings.TrimSpace(v) == "" {
+				return fmt.Errorf("%s cannot contain empty entries", name)
+			}
+			if seen[v] {
+				return fmt.Errorf("%s contains %q more than once", name, v)
+			}
+			seen[v] = true
+		}
+	}
 	return nil
 }
 
@@ -311,6 +480,8 @@ func EnableCORS(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key, Authorization, Idempotency-Key")
 		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Trace-ID")
+		// lets browser devtools on other origins read Server-Timing
+		w.Header().Set("Timing-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 		
 		if r.Method == "OPTIONS" {
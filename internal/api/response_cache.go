@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/cache"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+)
+
+// newResponseCache returns nil unless ENABLE_RESPONSE_CACHE is set
+func newResponseCache(cfg config.Config) *cache.ResponseCache {
+	if !cfg.EnableResponseCache {
+		return nil
+	}
+	return cache.NewResponseCache(cfg.ResponseCacheTTL, cfg.ResponseCacheMaxEntries)
+}
+
+// responseCacheKey returns "" when caching is off or the request must reach a
+// provider (streaming, dry runs, forced providers, tools). Keys are scoped per tenant so one
+// tenant never receives another's completion.
+func responseCacheKey(c *cache.ResponseCache, r *http.Request, tenantID string, req InferRequest) string {
+	if c == nil || req.Stream || isDryRun(r, req) || requestedProvider(r, req) != "" || len(req.Tools) > 0 {
+		return ""
+	}
+	return tenantID + "/" + cache.Key(req.Model, keyedPrompt(req), req.MaxTok, req.Policy)
+}
+
+// keyedPrompt flattens the request's messages into one role-tagged string for keying
+func keyedPrompt(req InferRequest) string {
+	var prompt strings.Builder
+	for _, m := range completionRequest(req).ChatMessages() {
+		prompt.WriteString(m.Role)
+		prompt.WriteString(": ")
+		prompt.WriteString(m.Content)
+		prompt.WriteString("\n")
+	}
+	return prompt.String()
+}
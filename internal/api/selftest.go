@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+// selftestTimeout bounds the whole self-test; providers are probed in parallel
+const selftestTimeout = 10 * time.Second
+
+// selftestPrompt is small enough that a probe costs next to nothing
+const selftestPrompt = "Reply with OK."
+
+// SelftestResult is one provider's probe outcome
+type SelftestResult struct {
+	Provider  string `json:"provider"`
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SelftestResponse is returned by POST /v1/admin/selftest; OK is true when every probe passed
+type SelftestResponse struct {
+	OK      bool             `json:"ok"`
+	Results []SelftestResult `json:"results"`
+}
+
+// HandleAdminSelftest sends a canned prompt straight to each enabled provider, bypassing
+// routing. Probes skip provider stats, the breaker, usage and cost accounting, so running
+// it never shifts routing or bills a tenant.
+func HandleAdminSelftest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), selftestTimeout)
+		defer cancel()
+
+		var enabled []*providers.ResilientProvider
+		for _, p := range router.GetProviders() {
+			if p.Enabled() {
+				enabled = append(enabled, p)
+			}
+		}
+		results := make([]SelftestResult, len(enabled))
+		var wg sync.WaitGroup
+		for i, p := range enabled {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := providers.CompletionRequest{Model: defaultModelFor(p, ""), Prompt: selftestPrompt, MaxTok: 5}
+				lat, err := p.Probe(ctx, req)
+				results[i] = SelftestResult{Provider: p.Name(), OK: err == nil, LatencyMs: lat}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+			}()
+		}
+		wg.Wait()
+
+		resp := SelftestResponse{OK: true, Results: results}
+		for _, res := range results {
+			if !res.OK {
+				resp.OK = false
+			}
+		}
+		log.Info().Str("event", "selftest").Bool("ok", resp.OK).Int("providers", len(results)).Msg("provider self-test run")
+		telemetry.AdminActionsTotal.WithLabelValues("selftest").Inc()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("failed to encode self-test results")
+		}
+	}
+}
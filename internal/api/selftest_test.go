@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/router"
+)
+
+func TestAdminSelftestProbesEachEnabledProvider(t *testing.T) {
+	healthy := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "healthy"}), providers.ResilienceOptions{})
+	brokenMock := providers.NewMockProviderWithOptions(providers.MockOptions{Name: "broken"})
+	brokenMock.FailNext(1)
+	broken := providers.WithResilience(brokenMock, providers.ResilienceOptions{CBWindowSize: 1})
+	off := providers.WithResilience(providers.NewMockProviderWithOptions(providers.MockOptions{Name: "off"}), providers.ResilienceOptions{})
+	off.SetEnabled(false)
+	router.SetProviders([]*providers.ResilientProvider{healthy, broken, off})
+
+	rr := httptest.NewRecorder()
+	HandleAdminSelftest().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/admin/selftest", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp SelftestResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.OK {
+		t.Error("expected overall ok=false with a failing provider")
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("expected results for the two enabled providers, got %+v", resp.Results)
+	}
+	if r := resp.Results[0]; r.Provider != "healthy" || !r.OK || r.Error != "" {
+		t.Errorf("expected healthy to pass, got %+v", r)
+	}
+	if r := resp.Results[1]; r.Provider != "broken" || r.OK || r.Error == "" {
+		t.Errorf("expected broken to fail with an error, got %+v", r)
+	}
+
+	// probes stay out of routing signals
+	for _, p := range []*providers.ResilientProvider{healthy, broken} {
+		if p.Stats().SuccessCount() != 0 || p.Stats().ErrorRate() != 0 {
+			t.Errorf("%s: expected probe not to be recorded in stats", p.Name())
+		}
+	}
+	if broken.CBStateValue() != 2 {
+		t.Error("expected a failed probe to leave the breaker closed")
+	}
+}
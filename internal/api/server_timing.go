@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serverTiming collects where an infer request's time went for its Server-Timing
+// header: route is time in the routing engine, provider the upstream call and total
+// everything since the handler started
+type serverTiming struct {
+	start    time.Time
+	route    time.Duration
+	provider time.Duration
+	called   bool
+}
+
+func newServerTiming() *serverTiming {
+	return &serverTiming{start: time.Now()}
+}
+
+// routed adds the engine time since t0; selection can happen more than once, e.g. per
+// fallback model
+func (t *serverTiming) routed(t0 time.Time) {
+	t.route += time.Since(t0)
+}
+
+// providerDone records the upstream call that began at t0
+func (t *serverTiming) providerDone(t0 time.Time) {
+	t.provider = time.Since(t0)
+	t.called = true
+}
+
+// set writes the header as of now; callers set it just before the response goes out,
+// which a stream has already done by the time the provider answers
+func (t *serverTiming) set(w http.ResponseWriter) {
+	w.Header().Set("Server-Timing", t.String())
+}
+
+// String renders the metrics in milliseconds, leaving provider out when no call was made
+func (t *serverTiming) String() string {
+	parts := []string{"route;dur=" + durationMs(t.route)}
+	if t.called {
+		parts = append(parts, "provider;dur="+durationMs(t.provider))
+	}
+	parts = append(parts, "total;dur="+durationMs(time.Since(t.start)))
+	return strings.Join(parts, ", ")
+}
+
+func durationMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// sleepyProvider takes a fixed time to answer
+type sleepyProvider struct{ d time.Duration }
+
+func (p sleepyProvider) Name() string                        { return "sleepy" }
+func (p sleepyProvider) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (p sleepyProvider) Complete(ctx context.Context, _ providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	select {
+	case <-time.After(p.d):
+	case <-ctx.Done():
+		return providers.CompletionResponse{}, 0, 0, ctx.Err()
+	}
+	return providers.CompletionResponse{Text: "ok"}, 0.0001, p.d.Milliseconds(), nil
+}
+
+func TestInferServerTiming(t *testing.T) {
+	cfg := config.Config{DefaultPolicy: "cheapest"}
+	handler := handleInfer(cfg, []*providers.ResilientProvider{providers.WithResilience(sleepyProvider{d: 40 * time.Millisecond}, providers.ResilienceOptions{CBWindowSize: 10})})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt":"hi","max_tokens":5}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	header := w.Header().Get("Server-Timing")
+	timings := map[string]float64{}
+	for _, metric := range strings.Split(header, ",") {
+		name, dur, ok := strings.Cut(strings.TrimSpace(metric), ";dur=")
+		if !ok {
+			t.Fatalf("malformed Server-Timing metric %q in %q", metric, header)
+		}
+		v, err := strconv.ParseFloat(dur, 64)
+		if err != nil {
+			t.Fatalf("malformed duration in %q: %v", header, err)
+		}
+		timings[name] = v
+	}
+	for _, name := range []string{"route", "provider", "total"} {
+		if _, ok := timings[name]; !ok {
+			t.Fatalf("expected %s in Server-Timing, got %q", name, header)
+		}
+	}
+
+	var resp InferResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(timings["provider"]-float64(resp.LatencyMs)) > 20 {
+		t.Errorf("expected provider timing near the %dms latency, got %q", resp.LatencyMs, header)
+	}
+	if timings["total"] < timings["provider"]+timings["route"] {
+		t.Errorf("expected total to cover route and provider, got %q", header)
+	}
+}
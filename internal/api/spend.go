@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
+)
+
+// SpendGuard is a global breaker on spend: once the last minute's cost passes
+// MAX_COST_USD_PER_MINUTE new infer requests are shed until it falls back under
+type SpendGuard struct {
+	max float64
+
+	mu        sync.RWMutex
+	tripped   bool
+	perMinute float64
+}
+
+// NewSpendGuard sheds above max USD per minute; max <= 0 never sheds
+func NewSpendGuard(max float64) *SpendGuard {
+	return &SpendGuard{max: max}
+}
+
+// Observe takes the latest per-minute spend, tripping or clearing the guard. Both
+// transitions are logged and published as events.
+func (g *SpendGuard) Observe(perMinute float64) {
+	if g.max <= 0 {
+		return
+	}
+	g.mu.Lock()
+	was := g.tripped
+	g.tripped = perMinute > g.max
+	g.perMinute = perMinute
+	now := g.tripped
+	g.mu.Unlock()
+
+	ev := map[string]any{"cost_usd_per_minute": perMinute, "max_cost_usd_per_minute": g.max}
+	switch {
+	case now && !was:
+		log.Error().Str("event", events.SpendTripped).Fields(ev).Msg("spend guardrail tripped; shedding infer requests")
+		events.Publish(events.SpendTripped, ev)
+	case was && !now:
+		log.Warn().Str("event", events.SpendCleared).Fields(ev).Msg("spend guardrail cleared")
+		events.Publish(events.SpendCleared, ev)
+	}
+}
+
+// Tripped reports whether requests are being shed, with the spend that tripped it
+func (g *SpendGuard) Tripped() (bool, float64) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.tripped, g.perMinute
+}
+
+// Limit sheds requests with 503 while the guard is tripped
+func (g *SpendGuard) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tripped, perMinute := g.Tripped(); tripped {
+			w.Header().Set("Retry-After", "60")
+			NewResponseWriter(w, r).WriteProblem(ProblemTypeOverloaded, "Spend Limit Reached", http.StatusServiceUnavailable,
+				fmt.Sprintf("spend of $%.2f in the last minute is over the $%.2f limit, retry later", perMinute, g.max))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+func TestSpendGuardShedsPastPerMinuteLimit(t *testing.T) {
+	guard := NewSpendGuard(1.0)
+	sampler := telemetry.NewSpendSampler(guard.Observe)
+	sampler.Sample()
+	h := guard.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+	infer := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/infer", nil))
+		return rec
+	}
+
+	telemetry.CostUSDTotal.WithLabelValues("spend-guard", "cheapest").Add(0.5)
+	sampler.Sample()
+	if rec := infer(); rec.Code != http.StatusOK {
+		t.Fatalf("expected requests under the limit to pass, got %d", rec.Code)
+	}
+
+	telemetry.CostUSDTotal.WithLabelValues("spend-guard", "cheapest").Add(0.75)
+	sampler.Sample()
+	rec := infer()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 503 with Retry-After once spend passed $1/min, got %d", rec.Code)
+	}
+	if tripped, perMinute := guard.Tripped(); !tripped || math.Abs(perMinute-1.25) > 1e-9 {
+		t.Errorf("expected the guard tripped at 1.25, got %v %v", tripped, perMinute)
+	}
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/rate"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/usage"
+)
+
+// StreamDone is the payload of the final "event: done" of a streamed /v1/infer
+// response. Cost and token counts are only known once the provider finishes
+type StreamDone struct {
+	Provider         string  `json:"provider"`
+	Model            string  `json:"model,omitempty"`
+	CostUSD          float64 `json:"cost_usd"`
+	LatencyMs        int64   `json:"latency_ms"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	RequestID        string  `json:"request_id,omitempty"`
+}
+
+// sseStream writes a streamed /v1/infer response: each chunk of text is a data-only
+// event {"delta": "..."}, and the stream ends with "event: done" carrying the totals,
+// or "event: error" when the provider fails after text went out
+type sseStream struct {
+	w        http.ResponseWriter
+	started  bool
+	attempts *providers.AttemptBudget
+	meter    *streamMeter
+}
+
+// newSSEStream returns nil unless the request asked for streaming
+func newSSEStream(w http.ResponseWriter, req InferRequest) *sseStream {
+	if !req.Stream {
+		return nil
+	}
+	return &sseStream{w: w}
+}
+
+// complete calls the provider under the request's attempt budget, streaming deltas to
+// the client when s is set. The attempts made are reported in X-Router-Attempts.
+func (s *sseStream) complete(ctx context.Context, w http.ResponseWriter, budget *providers.AttemptBudget, p *providers.ResilientProvider, req providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	ctx = providers.WithAttemptBudget(ctx, budget)
+	if s == nil {
+		resp, cost, lat, err := p.Complete(ctx, req)
+		w.Header().Set(attemptsHeader, strconv.Itoa(budget.Used()))
+		return resp, cost, lat, err
+	}
+	// the headers go out with the first delta, after the last attempt has started
+	s.attempts = budget
+	return p.CompleteStream(ctx, req, s.delta)
+}
+
+// attemptsHeader carries how many upstream calls an infer request made
+const attemptsHeader = "X-Router-Attempts"
+
+func (s *sseStream) delta(text string) error {
+	if err := s.event("", map[string]string{"delta": text}); err != nil {
+		return err
+	}
+	if s.meter != nil {
+		return s.meter.add(text)
+	}
+	return nil
+}
+
+// event writes one SSE event, sending the stream headers first if needed
+func (s *sseStream) event(name string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if !s.started {
+		h := s.w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set(attemptsHeader, strconv.Itoa(s.attempts.Used()))
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	if name != "" {
+		if _, err := fmt.Fprintf(s.w, "event: %s\n", name); err != nil {
+			return &clientGoneError{err: err}
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", b); err != nil {
+		return &clientGoneError{err: err}
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// finish ends the stream with the done or error event. It reports whether the
+// response has been written, which is false only when the provider failed before
+// any text was sent and a regular error response is still possible
+func (s *sseStream) finish(done StreamDone, err error) bool {
+	if err != nil {
+		if !s.started {
+			s.w.Header().Set(attemptsHeader, strconv.Itoa(s.attempts.Used()))
+			return false
+		}
+		payload := map[string]string{"error_kind": string(providers.KindOf(err))}
+		var limit *tokenLimitError
+		if errors.As(err, &limit) {
+			payload = map[string]string{"error_kind": errorKindTokenLimit, "message": limit.Error()}
+		}
+		if werr := s.event("error", payload); werr != nil {
+			log.Debug().Err(werr).Msg("write stream error event")
+		}
+		return true
+	}
+	if werr := s.event("done", done); werr != nil {
+		log.Debug().Err(werr).Msg("write stream done event")
+	}
+	return true
+}
+
+// dailyTokens is each tenant's estimated prompt and completion tokens today, across
+// the infer and embeddings handlers
+var dailyTokens = rate.NewDailyUsage()
+
+// DailyTokens is the daily token count the handlers charge and admit against, for a
+// rate.Limiter to share with SetDailyUsage
+func DailyTokens() *rate.DailyUsage {
+	return dailyTokens
+}
+
+// checkDailyTokens turns a tenant already past its daily token limit away with
+// 429 until the count resets, reporting whether it did. A tenant past warnPct percent
+// of it is served with X-Usage-Warning.
+func checkDailyTokens(rw *ResponseWriter, tenant *auth.Tenant, warnPct float64) bool {
+	if tenant == nil || tenant.DailyTokenLimit <= 0 {
+		return false
+	}
+	used := dailyTokens.GetUsage(tenant.TenantID)
+	if used < tenant.DailyTokenLimit {
+		dailyTokens.WarnNearLimit(rw.w, tenant, warnPct)
+		return false
+	}
+	retry := max(1, int(math.Ceil(time.Until(dailyTokens.GetReset(tenant.TenantID)).Seconds())))
+	rw.w.Header().Set("Retry-After", strconv.Itoa(retry))
+	rw.WriteProblem(ProblemTypeUsageExceeded, "Daily Token Limit Exceeded", http.StatusTooManyRequests,
+		fmt.Sprintf("daily token limit of %d exceeded: %d used", tenant.DailyTokenLimit, used))
+	return true
+}
+
+// clientGoneError is a failed write to a streaming client, which has hung up even if
+// the request's context hasn't noticed yet
+type clientGoneError struct {
+	err error
+}
+
+func (e *clientGoneError) Error() string {
+	return "write to client: " + e.err.Error()
+}
+
+func (e *clientGoneError) Unwrap() error {
+	return e.err
+}
+
+// clientGone reports whether a failed call ended because the client went away, which
+// says nothing about the provider's health
+func clientGone(r *http.Request, err error) bool {
+	return err != nil && (r.Context().Err() != nil || errors.As(err, new(*clientGoneError)))
+}
+
+// errorKindTokenLimit is the error_kind of a stream stopped at the daily token limit
+const errorKindTokenLimit = "token_limit_exceeded"
+
+// tokenLimitError stops a stream whose tenant went past its daily token limit
+type tokenLimitError struct {
+	limit int64
+}
+
+func (e *tokenLimitError) Error() string {
+	return fmt.Sprintf("daily token limit of %d exceeded", e.limit)
+}
+
+// streamMeter counts a tenant's tokens while its stream is still running. Every
+// checkpoint tokens it adds the new ones to the daily count, hands them to
+// onCheckpoint and stops the stream once the count passes the tenant's limit.
+type streamMeter struct {
+	tenantID     string
+	limit        int64 // 0 is unlimited
+	every        int64
+	estimate     func(text string) int64
+	onCheckpoint func(n int, promptTokens, completionTokens int64)
+
+	prompt      int64
+	text        strings.Builder
+	pending     int64
+	checkpoints int
+	// tokens already added to the daily count
+	countedPrompt     int64
+	countedCompletion int64
+}
+
+// newStreamMeter returns nil unless the stream has a tenant and checkpoints are on.
+// promptTokens are counted with the first checkpoint.
+func newStreamMeter(every int64, tenant *auth.Tenant, estimator *usage.TokenEstimator, model string, promptTokens int64) *streamMeter {
+	if tenant == nil || every <= 0 {
+		return nil
+	}
+	return &streamMeter{
+		tenantID: tenant.TenantID,
+		limit:    tenant.DailyTokenLimit,
+		every:    every,
+		estimate: func(text string) int64 { return estimator.EstimateTokens(text, model) },
+		prompt:   promptTokens,
+	}
+}
+
+// add counts a delta that went out, checkpointing once enough tokens built up
+func (m *streamMeter) add(delta string) error {
+	m.text.WriteString(delta)
+	m.pending += m.estimate(delta)
+	if m.pending < m.every {
+		return nil
+	}
+	m.pending = 0
+	// re-estimated on the whole text, so the counts add up to the final estimate
+	completion := m.estimate(m.text.String())
+	prompt, newCompletion := m.prompt-m.countedPrompt, completion-m.countedCompletion
+	m.countedPrompt, m.countedCompletion = m.prompt, completion
+	m.checkpoints++
+	dailyTokens.AddTokens(m.tenantID, prompt+newCompletion)
+	if m.onCheckpoint != nil {
+		m.onCheckpoint(m.checkpoints, prompt, newCompletion)
+	}
+	if m.limit > 0 && dailyTokens.GetUsage(m.tenantID) > m.limit {
+		return &tokenLimitError{limit: m.limit}
+	}
+	return nil
+}
+
+// completionTokens estimates everything streamed so far, including text sent
+// before the stream was stopped
+func (m *streamMeter) completionTokens() int64 {
+	return m.estimate(m.text.String())
+}
+
+// countDailyTokens adds a finished request's tokens to its tenant's daily count,
+// less whatever its stream's checkpoints already added, and returns that remainder
+// for the request's final usage row
+func countDailyTokens(tenant *auth.Tenant, stream *sseStream, promptTokens, completionTokens int64) (int64, int64) {
+	if stream != nil && stream.meter != nil {
+		promptTokens -= stream.meter.countedPrompt
+		completionTokens -= stream.meter.countedCompletion
+	}
+	if tenant != nil {
+		dailyTokens.AddTokens(tenant.TenantID, promptTokens+completionTokens)
+	}
+	return promptTokens, completionTokens
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+)
+
+// templateVar matches a {{name}} placeholder, allowing spaces inside the braces
+var templateVar = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// PromptTemplates holds named prompts whose {{name}} placeholders are filled from an
+// infer request's variables. Every placeholder is required.
+type PromptTemplates map[string]string
+
+// LoadPromptTemplates reads a JSON object mapping template names to template text
+func LoadPromptTemplates(path string) (PromptTemplates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates PromptTemplates
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// BuildPromptTemplates loads PROMPT_TEMPLATES_JSON, leaving no templates when it is
+// unset or unreadable
+func BuildPromptTemplates(cfg config.Config) PromptTemplates {
+	if cfg.PromptTemplatesPath == "" {
+		return nil
+	}
+	templates, err := LoadPromptTemplates(cfg.PromptTemplatesPath)
+	if err != nil {
+		log.Warn().Err(err).Msg("prompt templates load failed; template requests will be rejected")
+		return nil
+	}
+	log.Info().Int("count", len(templates)).Str("path", cfg.PromptTemplatesPath).Msg("loaded prompt templates")
+	return templates
+}
+
+// Render fills the named template from vars, failing on an unknown template or when
+// any placeholder has no variable
+func (t PromptTemplates) Render(name string, vars map[string]string) (string, error) {
+	text, ok := t[name]
+	if !ok {
+		return "", fmt.Errorf("unknown template %q", name)
+	}
+	var missing []string
+	out := templateVar.ReplaceAllStringFunc(text, func(m string) string {
+		key := templateVar.FindStringSubmatch(m)[1]
+		v, ok := vars[key]
+		if !ok {
+			if !slices.Contains(missing, key) {
+				missing = append(missing, key)
+			}
+			return m
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %q is missing variables: %s", name, strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// renderTemplate replaces a template request with its rendered prompt, so routing,
+// limits and the provider only ever see the prompt
+func renderTemplate(templates PromptTemplates, req *InferRequest) error {
+	if req.Template == "" {
+		return nil
+	}
+	prompt, err := templates.Render(req.Template, req.Variables)
+	if err != nil {
+		return err
+	}
+	req.Prompt = prompt
+	return nil
+}
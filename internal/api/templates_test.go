@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// recordingProvider remembers the last request it was sent
+type recordingProvider struct {
+	mu  sync.Mutex
+	got providers.CompletionRequest
+}
+
+func (p *recordingProvider) Name() string                        { return "recorder" }
+func (p *recordingProvider) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (p *recordingProvider) Complete(_ context.Context, req providers.CompletionRequest) (providers.CompletionResponse, float64, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.got = req
+	return providers.CompletionResponse{Text: "ok"}, 0.0001, 1, nil
+}
+
+func TestPromptTemplatesRender(t *testing.T) {
+	templates := PromptTemplates{"summarize": "Summarize in {{ words }} words: {{text}} ({{words}})"}
+
+	got, err := templates.Render("summarize", map[string]string{"words": "ten", "text": "a {{words}} b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Summarize in ten words: a {{words}} b (ten)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	_, err = templates.Render("summarize", map[string]string{"text": "x"})
+	if err == nil || !strings.Contains(err.Error(), "missing variables: words") {
+		t.Errorf("expected a missing variable error naming words, got %v", err)
+	}
+	if _, err := templates.Render("nope", nil); err == nil {
+		t.Error("expected an unknown template to fail")
+	}
+}
+
+func TestInferRendersTemplateBeforeCallingProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(path, []byte(`{"greet": "Say hello to {{name}} in {{lang}}"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingProvider{}
+	handler := handleInfer(config.Config{DefaultPolicy: "cheapest", PromptTemplatesPath: path},
+		[]*providers.ResilientProvider{providers.WithResilience(rec, providers.ResilienceOptions{CBWindowSize: 10})})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "rendered", body: `{"model": "gpt-4o", "template": "greet", "variables": {"name": "Ada", "lang": "French"}}`, want: http.StatusOK},
+		{name: "missing variable", body: `{"model": "gpt-4o", "template": "greet", "variables": {"name": "Ada"}}`, want: http.StatusBadRequest},
+		{name: "unknown template", body: `{"model": "gpt-4o", "template": "nope"}`, want: http.StatusBadRequest},
+		{name: "template and prompt", body: `{"model": "gpt-4o", "template": "greet", "prompt": "hi"}`, want: http.StatusBadRequest},
+		{name: "variables without template", body: `{"model": "gpt-4o", "prompt": "hi", "variables": {"name": "Ada"}}`, want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(tt.body)))
+			if w.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.got.Prompt != "Say hello to Ada in French" {
+		t.Errorf("expected the rendered prompt sent to the provider, got %q", rec.got.Prompt)
+	}
+}
@@ -44,6 +44,12 @@ type RecentUsageResponse struct {
 	TokensOut int64     `json:"tokens_out"`
 }
 
+// TagUsageResponse is the cost per value of one cost tag, most expensive first
+type TagUsageResponse struct {
+	Tag    string               `json:"tag"`
+	Values []usage.TagAggregate `json:"values"`
+}
+
 // HandleDailyUsage returns daily usage aggregates
 func (h *UsageHandlers) HandleDailyUsage() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -142,6 +148,46 @@ func (h *UsageHandlers) HandleRecentUsage() http.HandlerFunc {
 	}
 }
 
+// HandleUsageByTag sums the tenant's cost per value of the tag named by ?tag= over
+// the last ?days= days (default 7, max 30)
+func (h *UsageHandlers) HandleUsageByTag() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := auth.GetTenantFromContext(r.Context())
+		if !ok {
+			h.writeError(w, r, http.StatusUnauthorized, "No tenant context")
+			return
+		}
+
+		tag := r.URL.Query().Get("tag")
+		if !usage.ValidTagKey(tag) {
+			h.writeError(w, r, http.StatusBadRequest, "tag must name a cost tag key")
+			return
+		}
+		days := 7
+		if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= 30 {
+			days = d
+		}
+		until := time.Now()
+		since := until.AddDate(0, 0, -days+1) // Include today
+
+		totals, err := h.store.GetTagUsage(r.Context(), tenant.TenantID, tag, since, until)
+		if err != nil {
+			log.Error().Err(err).Str("tenant", tenant.TenantID).Str("tag", tag).Msg("failed to get usage by tag")
+			h.writeError(w, r, http.StatusInternalServerError, "Failed to retrieve usage data")
+			return
+		}
+
+		response := TagUsageResponse{Tag: tag, Values: totals}
+		if response.Values == nil {
+			response.Values = []usage.TagAggregate{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error().Err(err).Msg("failed to encode usage by tag response")
+		}
+	}
+}
+
 func (h *UsageHandlers) writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
 	w.Header().Set("Content-Type", "application/problem+json")
 	if reqID := r.Header.Get("X-Request-ID"); reqID != "" {
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/buildinfo"
+)
+
+// HandleVersion reports the running build: {version, commit, build_date, go_version}
+func HandleVersion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(buildinfo.Get())
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/buildinfo"
+)
+
+func TestVersionReportsInjectedBuild(t *testing.T) {
+	prev := []string{buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate}
+	defer func() { buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = prev[0], prev[1], prev[2] }()
+	// what -ldflags "-X .../buildinfo.Version=..." sets at link time
+	buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = "v1.4.0", "3f2c1ab", "2025-06-01T12:00:00Z"
+
+	rr := httptest.NewRecorder()
+	HandleVersion().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/version", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var got buildinfo.Info
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := buildinfo.Info{Version: "v1.4.0", Commit: "3f2c1ab", BuildDate: "2025-06-01T12:00:00Z", GoVersion: runtime.Version()}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	rr = httptest.NewRecorder()
+	HandleAdminStatus().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/admin/status", nil))
+	var status AdminStatusResponse
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.BuildInfo != want {
+		t.Errorf("expected admin status to carry the same build info, got %+v", status.BuildInfo)
+	}
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxInMemory bounds how many recent entries are kept for queries; the file keeps everything
+const maxInMemory = 10000
+
+// Entry is one admin action with the state it changed
+type Entry struct {
+	TS        time.Time       `json:"ts"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+}
+
+// Log is an append-only audit trail, persisted as JSON lines when backed by a file
+type Log struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries []Entry
+}
+
+// Open loads an existing audit file and appends to it; an empty path keeps entries in memory only
+func Open(path string) (*Log, error) {
+	l := &Log{}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		l.keep(e)
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.f = f
+	return l, nil
+}
+
+// Record appends an entry for action, encoding before/after state as JSON
+func (l *Log) Record(actor, action, requestID string, before, after any) error {
+	e := Entry{TS: time.Now().UTC(), Actor: actor, Action: action, RequestID: requestID}
+	var err error
+	if e.Before, err = marshalState(before); err != nil {
+		return err
+	}
+	if e.After, err = marshalState(after); err != nil {
+		return err
+	}
+	return l.Append(e)
+}
+
+// Append writes e to the file (if any) before making it visible to queries
+func (l *Log) Append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := l.f.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	l.keep(e)
+	return nil
+}
+
+// Since returns up to limit entries at or after since, oldest first
+func (l *Log) Since(since time.Time, limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := []Entry{}
+	for _, e := range l.entries {
+		if e.TS.Before(since) {
+			continue
+		}
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Close closes the backing file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+func (l *Log) keep(e Entry) {
+	l.entries = append(l.entries, e)
+	if len(l.entries) > maxInMemory {
+		l.entries = append([]Entry(nil), l.entries[len(l.entries)-maxInMemory:]...)
+	}
+}
+
+func marshalState(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+type contextKey string
+
+const actorKey contextKey = "admin-actor"
+
+// WithActor attaches the authenticated admin identity to ctx
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFrom returns the admin identity from ctx, or "unknown"
+func ActorFrom(ctx context.Context) string {
+	if v, ok := ctx.Value(actorKey).(string); ok && v != "" {
+		return v
+	}
+	return "unknown"
+}
+
+var (
+	regMu  sync.RWMutex
+	regLog = &Log{}
+)
+
+// SetLog replaces the process-wide audit log
+func SetLog(l *Log) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	regLog = l
+}
+
+// GetLog returns the process-wide audit log (in-memory until SetLog is called)
+func GetLog() *Log {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	return regLog
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogPersistsAcrossOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := l.Record("ops", "set_policy", "req-1", map[string]string{"default_policy": "cheapest"}, map[string]string{"default_policy": "canary"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := l.Record("ops", "tenant_create", "req-2", nil, map[string]string{"tenant_id": "t1"}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	got := l.Since(time.Time{}, 0)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries after reopen, got %d", len(got))
+	}
+	if got[0].Action != "set_policy" || string(got[0].After) != `{"default_policy":"canary"}` {
+		t.Errorf("unexpected first entry %+v", got[0])
+	}
+	if got[1].Before != nil || got[1].RequestID != "req-2" {
+		t.Errorf("unexpected second entry %+v", got[1])
+	}
+
+	if got := l.Since(time.Time{}, 1); len(got) != 1 || got[0].Action != "set_policy" {
+		t.Errorf("limit should return the oldest entry first, got %+v", got)
+	}
+	if got := l.Since(time.Now().Add(time.Minute), 0); len(got) != 0 {
+		t.Errorf("expected nothing after now, got %d", len(got))
+	}
+}
+
+func TestActorFrom(t *testing.T) {
+	if got := ActorFrom(context.Background()); got != "unknown" {
+		t.Errorf("expected unknown actor, got %q", got)
+	}
+	if got := ActorFrom(WithActor(context.Background(), "ops")); got != "ops" {
+		t.Errorf("expected ops, got %q", got)
+	}
+}
@@ -7,16 +7,21 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	mrand "math/rand"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/awsconfig"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
 	"github.com/rs/zerolog/log"
 )
 
@@ -30,15 +35,33 @@ type Tenant struct {
 	RPSLimit        int       `json:"rps_limit" dynamodbav:"rps_limit"`
 	DailyTokenLimit int64     `json:"daily_token_limit" dynamodbav:"daily_token_limit"`
 	Enabled         bool      `json:"enabled" dynamodbav:"enabled"`
+	AllowedModels   []string  `json:"allowed_models,omitempty" dynamodbav:"allowed_models,omitempty"`     // empty allows every model
+	DeniedProviders []string  `json:"denied_providers,omitempty" dynamodbav:"denied_providers,omitempty"` // never routed to for this tenant
+	Priority        string    `json:"priority,omitempty" dynamodbav:"priority,omitempty"`                 // default QoS class (high, normal, low); empty derives it from the plan
 	CreatedAt       time.Time `json:"created_at" dynamodbav:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" dynamodbav:"updated_at"`
 }
 
+// ModelAllowed reports whether the tenant may request model
+func (t *Tenant) ModelAllowed(model string) bool {
+	return len(t.AllowedModels) == 0 || slices.Contains(t.AllowedModels, model)
+}
+
+// ProviderAllowed reports whether requests for the tenant may be routed to provider
+func (t *Tenant) ProviderAllowed(provider string) bool {
+	return !slices.Contains(t.DeniedProviders, provider)
+}
+
+// tenantCacheJitter spreads entry lifetimes by +/- this fraction of the TTL so
+// entries cached together don't all go back to DynamoDB at once
+const tenantCacheJitter = 0.1
+
 // TenantCache provides LRU caching for tenant lookups
 type TenantCache struct {
 	mu       sync.RWMutex
 	cache    map[string]*Tenant
 	accessed map[string]time.Time
+	expires  map[string]time.Time
 	ttl      time.Duration
 	maxSize  int
 }
@@ -47,12 +70,23 @@ func NewTenantCache(ttl time.Duration, maxSize int) *TenantCache {
 	return &TenantCache{
 		cache:    make(map[string]*Tenant),
 		accessed: make(map[string]time.Time),
+		expires:  make(map[string]time.Time),
 		ttl:      ttl,
 		maxSize:  maxSize,
 	}
 }
 
 func (tc *TenantCache) Get(keyHash string) (*Tenant, bool) {
+	return tc.get(keyHash, 0)
+}
+
+// GetStale is Get that also returns entries expired by at most grace, for when the
+// tenant can't be looked up again
+func (tc *TenantCache) GetStale(keyHash string, grace time.Duration) (*Tenant, bool) {
+	return tc.get(keyHash, grace)
+}
+
+func (tc *TenantCache) get(keyHash string, grace time.Duration) (*Tenant, bool) {
 	tc.mu.RLock()
 	defer tc.mu.RUnlock()
 
@@ -61,7 +95,7 @@ func (tc *TenantCache) Get(keyHash string) (*Tenant, bool) {
 		return nil, false
 	}
 
-	if time.Since(tc.accessed[keyHash]) > tc.ttl {
+	if time.Now().After(tc.expires[keyHash].Add(grace)) {
 		// Expired, but don't delete here - let cleanup handle it
 		return nil, false
 	}
@@ -88,11 +122,15 @@ func (tc *TenantCache) Put(keyHash string, tenant *Tenant) {
 		if oldestKey != "" {
 			delete(tc.cache, oldestKey)
 			delete(tc.accessed, oldestKey)
+			delete(tc.expires, oldestKey)
 		}
 	}
 
+	now := time.Now()
+	jitter := (mrand.Float64()*2 - 1) * tenantCacheJitter // +/- tenantCacheJitter
 	tc.cache[keyHash] = tenant
-	tc.accessed[keyHash] = time.Now()
+	tc.accessed[keyHash] = now
+	tc.expires[keyHash] = now.Add(time.Duration(float64(tc.ttl) * (1 + jitter)))
 }
 
 func (tc *TenantCache) Delete(keyHash string) {
@@ -101,27 +139,50 @@ func (tc *TenantCache) Delete(keyHash string) {
 
 	delete(tc.cache, keyHash)
 	delete(tc.accessed, keyHash)
+	delete(tc.expires, keyHash)
+}
+
+// DefaultStaleGrace is how long past its TTL a cached tenant keeps authenticating
+// while DynamoDB lookups fail
+const DefaultStaleGrace = config.DefaultTenantStaleGrace
+
+// dynamoAPI is the part of the DynamoDB client the key manager uses
+type dynamoAPI interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 }
 
 // APIKeyManager handles tenant authentication
 type APIKeyManager struct {
-	ddbClient   *dynamodb.Client
+	ddbClient   dynamoAPI
 	tableName   string
 	cache       *TenantCache
+	staleGrace  time.Duration
+	keySources  KeySources
 	fallbackMap map[string]*Tenant
 	mu          sync.RWMutex
+
+	// fileTenants holds the tenants file's keys by hash, replaced whole on each load
+	// so keys and tenants dropped from the file stop working
+	fileTenants map[string]*Tenant
+
+	// modification time and size of the tenants file as last loaded
+	fileMod  time.Time
+	fileSize int64
 }
 
 func NewAPIKeyManager(tableName, tenantsJSONPath string) (*APIKeyManager, error) {
 	mgr := &APIKeyManager{
 		tableName:   tableName,
 		cache:       NewTenantCache(60*time.Second, 1000),
+		staleGrace:  DefaultStaleGrace,
+		keySources:  DefaultKeySources,
 		fallbackMap: make(map[string]*Tenant),
 	}
 
 	// Initialize DDB client if table name is provided
 	if tableName != "" {
-		cfg, err := config.LoadDefaultConfig(context.TODO())
+		cfg, err := awsconfig.Load(context.TODO())
 		if err != nil {
 			log.Warn().Err(err).Msg("failed to load AWS config, using in-memory fallback")
 		} else {
@@ -139,32 +200,92 @@ func NewAPIKeyManager(tableName, tenantsJSONPath string) (*APIKeyManager, error)
 	return mgr, nil
 }
 
+// SetStaleGrace sets how long past its TTL a cached tenant is still served when
+// DynamoDB errors; 0 fails those requests instead
+func (mgr *APIKeyManager) SetStaleGrace(d time.Duration) {
+	mgr.staleGrace = d
+}
+
+// tenantFileEntry is a tenant as stored in the tenants JSON file. Tenant hides key
+// material from JSON, so the hash and salt are decoded through these fields instead.
+type tenantFileEntry struct {
+	Tenant
+	APIKeyHash string `json:"api_key_hash"`
+	Salt       string `json:"salt"`
+}
+
 func (mgr *APIKeyManager) loadTenantsFromJSON(path string) error {
+	// stat before reading so a write in between is picked up by the next check
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	var tenants []Tenant
-	if err := json.Unmarshal(data, &tenants); err != nil {
+	var entries []tenantFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
 		return err
 	}
 
-	mgr.mu.Lock()
-	defer mgr.mu.Unlock()
-
-	for _, tenant := range tenants {
-		// Assume the JSON contains plaintext API keys that need to be hashed
-		if tenant.APIKeyHash == "" && tenant.Salt == "" {
+	tenants := make(map[string]*Tenant, len(entries))
+	for _, e := range entries {
+		if e.APIKeyHash == "" {
 			continue // Skip invalid entries
 		}
-		mgr.fallbackMap[tenant.APIKeyHash] = &tenant
+		t := e.Tenant // copy so map entries never alias the loop variable
+		t.APIKeyHash = e.APIKeyHash
+		t.Salt = e.Salt
+		tenants[t.APIKeyHash] = &t
 	}
 
-	log.Info().Int("count", len(tenants)).Msg("loaded tenants from JSON")
+	mgr.mu.Lock()
+	mgr.fileTenants = tenants
+	mgr.fileMod, mgr.fileSize = fi.ModTime(), fi.Size()
+	mgr.mu.Unlock()
+
+	log.Info().Int("count", len(tenants)).Str("path", path).Msg("loaded tenants from JSON")
 	return nil
 }
 
+// WatchTenantsJSON re-reads the tenants file whenever its size or modification time
+// changes, replacing the file's tenants without a restart. It blocks until ctx is done.
+func (mgr *APIKeyManager) WatchTenantsJSON(ctx context.Context, path string, interval time.Duration) {
+	// start from the file as loaded, not as it is now, so a change made before the
+	// first tick is not mistaken for the baseline
+	mgr.mu.RLock()
+	lastMod, lastSize := mgr.fileMod, mgr.fileSize
+	mgr.mu.RUnlock()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("failed to stat tenants JSON")
+			continue
+		}
+		if fi.ModTime().Equal(lastMod) && fi.Size() == lastSize {
+			continue
+		}
+		if err := mgr.loadTenantsFromJSON(path); err != nil {
+			// keep the previous stamp so a half-written file is retried on the next tick
+			log.Warn().Err(err).Str("path", path).Msg("failed to reload tenants JSON")
+			continue
+		}
+		mgr.mu.RLock()
+		lastMod, lastSize = mgr.fileMod, mgr.fileSize
+		mgr.mu.RUnlock()
+	}
+}
+
 // HashAPIKey creates a hash of the API key with a random salt
 func HashAPIKey(apiKey, salt string) string {
 	h := sha256.New()
@@ -198,8 +319,12 @@ func (mgr *APIKeyManager) ValidateAPIKey(ctx context.Context, apiKey string) (*T
 	// For now, let's hash with empty salt and check fallback first
 	keyHash := HashAPIKey(apiKey, "")
 
-	// Check fallback map first
+	// Check the tenants file and fallback map first
 	mgr.mu.RLock()
+	if tenant, exists := mgr.fileTenants[keyHash]; exists {
+		mgr.mu.RUnlock()
+		return tenant, nil
+	}
 	if tenant, exists := mgr.fallbackMap[keyHash]; exists {
 		mgr.mu.RUnlock()
 		return tenant, nil
@@ -209,13 +334,19 @@ func (mgr *APIKeyManager) ValidateAPIKey(ctx context.Context, apiKey string) (*T
 	// If DDB is available, we need to scan or use a GSI
 	// For now, implement a simple scan (not efficient for production)
 	if mgr.ddbClient != nil {
-		return mgr.validateAPIKeyFromDDB(ctx, apiKey)
+		if tenant, ok := mgr.cache.Get(keyHash); ok {
+			return tenant, nil
+		}
+		return mgr.validateAPIKeyFromDDB(ctx, apiKey, keyHash)
 	}
 
 	return nil, fmt.Errorf("invalid API key")
 }
 
-func (mgr *APIKeyManager) validateAPIKeyFromDDB(ctx context.Context, apiKey string) (*Tenant, error) {
+// validateAPIKeyFromDDB looks the key up in DynamoDB, caching the tenant under
+// keyHash. When the lookup itself fails, a tenant cached within the stale grace
+// period is served instead so a DynamoDB blip doesn't reject every request.
+func (mgr *APIKeyManager) validateAPIKeyFromDDB(ctx context.Context, apiKey, keyHash string) (*Tenant, error) {
 	// This is a simplified implementation - in production you'd want a GSI on api_key_hash
 	input := &dynamodb.ScanInput{
 		TableName: aws.String(mgr.tableName),
@@ -223,6 +354,13 @@ func (mgr *APIKeyManager) validateAPIKeyFromDDB(ctx context.Context, apiKey stri
 
 	result, err := mgr.ddbClient.Scan(ctx, input)
 	if err != nil {
+		if mgr.staleGrace > 0 {
+			// only enabled tenants are ever cached; the check guards against that changing
+			if tenant, ok := mgr.cache.GetStale(keyHash, mgr.staleGrace); ok && tenant.Enabled {
+				log.Warn().Err(err).Str("tenant_id", tenant.TenantID).Msg("tenant lookup failed, serving cached tenant")
+				return tenant, nil
+			}
+		}
 		return nil, err
 	}
 
@@ -236,17 +374,39 @@ func (mgr *APIKeyManager) validateAPIKeyFromDDB(ctx context.Context, apiKey stri
 		expectedHash := HashAPIKey(apiKey, tenant.Salt)
 		if expectedHash == tenant.APIKeyHash && tenant.Enabled {
 			// Cache the result
-			mgr.cache.Put(tenant.APIKeyHash, &tenant)
+			mgr.cache.Put(keyHash, &tenant)
 			return &tenant, nil
 		}
 	}
 
+	// disabled or deleted; a later outage must not bring the key back
+	mgr.cache.Delete(keyHash)
 	return nil, fmt.Errorf("invalid API key")
 }
 
 // CreateTenant creates a new tenant with a generated API key
-func (mgr *APIKeyManager) CreateTenant(ctx context.Context, name, plan string, rpsLimit int, dailyTokenLimit int64) (*Tenant, string, error) {
-	tenantID := fmt.Sprintf("tenant_%d", time.Now().Unix())
+func (mgr *APIKeyManager) CreateTenant(ctx context.Context, name, plan string, rpsLimit int, dailyTokenLimit int64, allowedModels, deniedProviders []string) (*Tenant, string, error) {
+	return mgr.ImportTenant(ctx, Tenant{
+		Name:            name,
+		Plan:            plan,
+		RPSLimit:        rpsLimit,
+		DailyTokenLimit: dailyTokenLimit,
+		Enabled:         true,
+		AllowedModels:   allowedModels,
+		DeniedProviders: deniedProviders,
+	})
+}
+
+// ImportTenant creates a tenant with t's settings, Enabled and Priority included, and
+// a generated ID, API key and timestamps
+func (mgr *APIKeyManager) ImportTenant(ctx context.Context, t Tenant) (*Tenant, string, error) {
+	// the random suffix keeps tenants created in the same second, as in a bulk
+	// import, apart
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, "", err
+	}
+	tenantID := fmt.Sprintf("tenant_%d_%x", time.Now().Unix(), suffix)
 	apiKey, err := GenerateAPIKey()
 	if err != nil {
 		return nil, "", err
@@ -260,18 +420,10 @@ func (mgr *APIKeyManager) CreateTenant(ctx context.Context, name, plan string, r
 	keyHash := HashAPIKey(apiKey, salt)
 	now := time.Now()
 
-	tenant := &Tenant{
-		TenantID:        tenantID,
-		Name:            name,
-		APIKeyHash:      keyHash,
-		Salt:            salt,
-		Plan:            plan,
-		RPSLimit:        rpsLimit,
-		DailyTokenLimit: dailyTokenLimit,
-		Enabled:         true,
-		CreatedAt:       now,
-		UpdatedAt:       now,
-	}
+	tenant := &t
+	tenant.TenantID = tenantID
+	tenant.APIKeyHash, tenant.Salt = keyHash, salt
+	tenant.CreatedAt, tenant.UpdatedAt = now, now
 
 	// Store in DDB if available
 	if mgr.ddbClient != nil {
@@ -300,12 +452,111 @@ func (mgr *APIKeyManager) CreateTenant(ctx context.Context, name, plan string, r
 	return tenant, apiKey, nil
 }
 
+// ListTenants returns every tenant, those from the JSON file and, when configured,
+// DynamoDB, sorted by tenant ID. DynamoDB's copy wins for a tenant in both.
+func (mgr *APIKeyManager) ListTenants(ctx context.Context) ([]*Tenant, error) {
+	byID := make(map[string]*Tenant)
+	mgr.mu.RLock()
+	for _, m := range []map[string]*Tenant{mgr.fileTenants, mgr.fallbackMap} {
+		for _, t := range m {
+			c := *t
+			byID[c.TenantID] = &c
+		}
+	}
+	mgr.mu.RUnlock()
+
+	if mgr.ddbClient != nil {
+		var start map[string]types.AttributeValue
+		for {
+			page, err := mgr.ddbClient.Scan(ctx, &dynamodb.ScanInput{
+				TableName:         aws.String(mgr.tableName),
+				ExclusiveStartKey: start,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range page.Items {
+				var t Tenant
+				if err := attributevalue.UnmarshalMap(item, &t); err != nil || t.TenantID == "" {
+					continue
+				}
+				byID[t.TenantID] = &t
+			}
+			if len(page.LastEvaluatedKey) == 0 {
+				break
+			}
+			start = page.LastEvaluatedKey
+		}
+	}
+
+	out := make([]*Tenant, 0, len(byID))
+	for _, t := range byID {
+		out = append(out, t)
+	}
+	slices.SortFunc(out, func(a, b *Tenant) int { return strings.Compare(a.TenantID, b.TenantID) })
+	return out, nil
+}
+
+// KeySources says where a request's API key may come from. They are tried in order:
+// the header, then "Authorization: Bearer", then the query parameter; an empty
+// Header or QueryParam, or Bearer false, turns that source off
+type KeySources struct {
+	Header     string
+	Bearer     bool
+	QueryParam string
+}
+
+// DefaultKeySources accepts X-API-Key and Authorization: Bearer
+var DefaultKeySources = KeySources{Header: "X-API-Key", Bearer: true}
+
+// SetKeySources sets where APIKeyMiddleware looks for the key
+func (mgr *APIKeyManager) SetKeySources(s KeySources) {
+	mgr.keySources = s
+}
+
+// key returns the first key found in an enabled source, or ""
+func (s KeySources) key(r *http.Request) string {
+	if s.Header != "" {
+		if k := r.Header.Get(s.Header); k != "" {
+			return k
+		}
+	}
+	if s.Bearer {
+		const prefix = "Bearer "
+		if h := r.Header.Get("Authorization"); len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+			return strings.TrimSpace(h[len(prefix):])
+		}
+	}
+	if s.QueryParam != "" {
+		return r.URL.Query().Get(s.QueryParam)
+	}
+	return ""
+}
+
+// describe names the enabled sources for the missing-key error
+func (s KeySources) describe() string {
+	var names []string
+	if s.Header != "" {
+		names = append(names, s.Header+" header")
+	}
+	if s.Bearer {
+		names = append(names, "Authorization: Bearer header")
+	}
+	if s.QueryParam != "" {
+		names = append(names, s.QueryParam+" query parameter")
+	}
+	if len(names) == 0 {
+		return "no API key source is enabled"
+	}
+	return "an API key is required in the " + strings.Join(names, " or ")
+}
+
 // APIKeyMiddleware provides authentication for API requests
 func (mgr *APIKeyManager) APIKeyMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiKey := r.Header.Get("X-API-Key")
+		apiKey := mgr.keySources.key(r)
 		if apiKey == "" {
-			mgr.writeErrorResponse(w, r, http.StatusUnauthorized, "missing_api_key", "API key required", "X-API-Key header is required")
+			mgr.writeErrorResponse(w, r, http.StatusUnauthorized, "missing_api_key", "API key required", mgr.keySources.describe())
 			return
 		}
 
@@ -321,8 +572,8 @@ func (mgr *APIKeyManager) APIKeyMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Add tenant to request context
-		ctx := context.WithValue(r.Context(), "tenant", tenant)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		telemetry.AnnotateTenant(r.Context(), tenant.TenantID)
+		next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenant)))
 	})
 }
 
@@ -344,6 +595,11 @@ func (mgr *APIKeyManager) writeErrorResponse(w http.ResponseWriter, r *http.Requ
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// WithTenant attaches the authenticated tenant to ctx
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, "tenant", tenant)
+}
+
 // GetTenantFromContext extracts tenant from request context
 func GetTenantFromContext(ctx context.Context) (*Tenant, bool) {
 	tenant, ok := ctx.Value("tenant").(*Tenant)
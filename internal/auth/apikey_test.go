@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// writeTenantsFile writes one entry per API key, keys mapping each key to its tenant
+func writeTenantsFile(t *testing.T, path string, keys map[string]string) {
+	t.Helper()
+	var entries []map[string]interface{}
+	for key, id := range keys {
+		entries = append(entries, map[string]interface{}{
+			"tenant_id":    id,
+			"name":         id,
+			"api_key_hash": HashAPIKey(key, ""),
+			"plan":         "basic",
+			"rps_limit":    10,
+			"enabled":      true,
+		})
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantsJSONReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	writeTenantsFile(t, path, map[string]string{"key-a": "tenant-a", "key-c": "tenant-c"})
+
+	mgr, err := NewAPIKeyManager("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := mgr.ValidateAPIKey(ctx, "key-a"); err != nil {
+		t.Fatalf("expected key-a to be valid after startup load: %v", err)
+	}
+	if _, err := mgr.ValidateAPIKey(ctx, "key-b"); err == nil {
+		t.Fatal("expected key-b to be unknown before reload")
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go mgr.WatchTenantsJSON(watchCtx, path, 10*time.Millisecond)
+
+	// add a tenant with two active keys, rotate tenant-a's key and drop tenant-c
+	writeTenantsFile(t, path, map[string]string{"key-a2": "tenant-a", "key-b": "tenant-b", "key-b2": "tenant-b"})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := mgr.ValidateAPIKey(ctx, "key-b"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("tenant added to the file never became usable")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	a, err := mgr.ValidateAPIKey(ctx, "key-a2")
+	if err != nil {
+		t.Fatalf("expected rotated key to be valid: %v", err)
+	}
+	b, _ := mgr.ValidateAPIKey(ctx, "key-b")
+	if a == b || a.TenantID != "tenant-a" || b.TenantID != "tenant-b" {
+		t.Errorf("tenant entries alias each other: %s / %s", a.TenantID, b.TenantID)
+	}
+	if _, err := mgr.ValidateAPIKey(ctx, "key-a"); err == nil {
+		t.Error("expected the old key to stop working after rotation")
+	}
+	if b2, err := mgr.ValidateAPIKey(ctx, "key-b2"); err != nil || b2.TenantID != "tenant-b" {
+		t.Errorf("expected both of tenant-b's keys to work, got %v", err)
+	}
+	if _, err := mgr.ValidateAPIKey(ctx, "key-c"); err == nil {
+		t.Error("expected a tenant removed from the file to stop working")
+	}
+}
+
+// flakyDynamo serves tenants from Scan until failing is set, pageSize at a time when set
+type flakyDynamo struct {
+	tenants  []Tenant
+	failing  bool
+	pageSize int
+	scans    int
+}
+
+func (d *flakyDynamo) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if d.failing {
+		return nil, errors.New("service unavailable")
+	}
+	d.scans++
+	start, end := 0, len(d.tenants)
+	if v, ok := params.ExclusiveStartKey["next"].(*types.AttributeValueMemberN); ok {
+		start, _ = strconv.Atoi(v.Value)
+	}
+	out := &dynamodb.ScanOutput{}
+	if d.pageSize > 0 && start+d.pageSize < end {
+		end = start + d.pageSize
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"next": &types.AttributeValueMemberN{Value: strconv.Itoa(end)}}
+	}
+	for _, t := range d.tenants[start:end] {
+		item, err := attributevalue.MarshalMap(t)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
+
+func (d *flakyDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestStaleTenantServedWhileDynamoFails(t *testing.T) {
+	ddb := &flakyDynamo{tenants: []Tenant{
+		{TenantID: "live", APIKeyHash: HashAPIKey("key-live", "s1"), Salt: "s1", Enabled: true},
+		{TenantID: "off", APIKeyHash: HashAPIKey("key-off", "s2"), Salt: "s2", Enabled: true},
+	}}
+	mgr := &APIKeyManager{
+		ddbClient:   ddb,
+		tableName:   "tenants",
+		cache:       NewTenantCache(time.Millisecond, 10),
+		staleGrace:  time.Minute,
+		fallbackMap: make(map[string]*Tenant),
+	}
+	ctx := context.Background()
+	for _, key := range []string{"key-live", "key-off"} {
+		if _, err := mgr.ValidateAPIKey(ctx, key); err != nil {
+			t.Fatalf("expected %s to authenticate: %v", key, err)
+		}
+	}
+
+	// once both entries are past their TTL "off" is disabled, then the table goes away
+	time.Sleep(5 * time.Millisecond)
+	ddb.tenants[1].Enabled = false
+	if _, err := mgr.ValidateAPIKey(ctx, "key-off"); err == nil {
+		t.Fatal("expected the disabled tenant to be rejected")
+	}
+	ddb.failing = true
+
+	tenant, err := mgr.ValidateAPIKey(ctx, "key-live")
+	if err != nil {
+		t.Fatalf("expected the cached tenant within the grace period: %v", err)
+	}
+	if tenant.TenantID != "live" {
+		t.Errorf("expected tenant live, got %s", tenant.TenantID)
+	}
+	if _, err := mgr.ValidateAPIKey(ctx, "key-off"); err == nil {
+		t.Error("expected a disabled tenant never to be served stale")
+	}
+	if _, err := mgr.ValidateAPIKey(ctx, "key-unknown"); err == nil {
+		t.Error("expected an uncached key to fail while DynamoDB errors")
+	}
+
+	mgr.SetStaleGrace(0)
+	if _, err := mgr.ValidateAPIKey(ctx, "key-live"); err == nil {
+		t.Error("expected no stale serving with a zero grace period")
+	}
+}
+
+func TestListTenantsPagesAndMerges(t *testing.T) {
+	ddb := &flakyDynamo{pageSize: 2, tenants: []Tenant{
+		{TenantID: "c", Name: "from-ddb", APIKeyHash: "h-c"},
+		{TenantID: "a", APIKeyHash: "h-a"},
+		{TenantID: "b", APIKeyHash: "h-b"},
+	}}
+	mgr := &APIKeyManager{
+		ddbClient:   ddb,
+		tableName:   "tenants",
+		cache:       NewTenantCache(time.Minute, 10),
+		fallbackMap: map[string]*Tenant{"h-file": {TenantID: "c", Name: "from-file"}, "h-d": {TenantID: "d"}},
+	}
+
+	tenants, err := mgr.ListTenants(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, tn := range tenants {
+		ids = append(ids, tn.TenantID)
+	}
+	if got := fmt.Sprint(ids); got != "[a b c d]" {
+		t.Fatalf("expected every tenant once in ID order, got %s", got)
+	}
+	if ddb.scans != 2 {
+		t.Errorf("expected both scan pages read, got %d scans", ddb.scans)
+	}
+	if tenants[2].Name != "from-ddb" {
+		t.Errorf("expected DynamoDB's copy of a tenant in both, got %q", tenants[2].Name)
+	}
+}
+
+func TestAPIKeySources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	writeTenantsFile(t, path, map[string]string{"key-a": "tenant-a"})
+	mgr, err := NewAPIKeyManager("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := mgr.APIKeyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ := GetTenantFromContext(r.Context())
+		_, _ = w.Write([]byte(tenant.TenantID))
+	}))
+	send := func(target string, header http.Header) int {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	xKey := http.Header{"X-Api-Key": {"key-a"}}
+	bearer := http.Header{"Authorization": {"Bearer key-a"}}
+
+	if code := send("/v1/infer", xKey); code != http.StatusOK {
+		t.Errorf("expected X-API-Key accepted by default, got %d", code)
+	}
+	if code := send("/v1/infer", bearer); code != http.StatusOK {
+		t.Errorf("expected Authorization: Bearer accepted by default, got %d", code)
+	}
+	if code := send("/v1/infer?api_key=key-a", nil); code != http.StatusUnauthorized {
+		t.Errorf("expected the query parameter ignored until configured, got %d", code)
+	}
+	// X-API-Key comes first, so a bad one isn't rescued by a good bearer token
+	if code := send("/v1/infer", http.Header{"X-Api-Key": {"wrong"}, "Authorization": {"Bearer key-a"}}); code != http.StatusUnauthorized {
+		t.Errorf("expected X-API-Key to take precedence, got %d", code)
+	}
+
+	mgr.SetKeySources(KeySources{Header: "X-Gateway-Key", QueryParam: "api_key"})
+	if code := send("/v1/infer?api_key=key-a", nil); code != http.StatusOK {
+		t.Errorf("expected the configured query parameter accepted, got %d", code)
+	}
+	if code := send("/v1/infer", http.Header{"X-Gateway-Key": {"key-a"}}); code != http.StatusOK {
+		t.Errorf("expected the configured header accepted, got %d", code)
+	}
+	if code := send("/v1/infer", xKey); code != http.StatusUnauthorized {
+		t.Errorf("expected X-API-Key ignored once another header is configured, got %d", code)
+	}
+	if code := send("/v1/infer", bearer); code != http.StatusUnauthorized {
+		t.Errorf("expected a disabled bearer source ignored, got %d", code)
+	}
+}
@@ -0,0 +1,70 @@
+// Package awsconfig loads the AWS configuration shared by Bedrock and the DynamoDB
+// stores, so one region, profile and assumed role apply to every AWS client
+package awsconfig
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// SessionName identifies the router's sessions in the assumed role's CloudTrail
+const SessionName = "llm-router"
+
+// Options override the default credential chain; empty fields keep its behavior
+type Options struct {
+	// Region for clients that don't pick their own, like the DynamoDB stores
+	Region string
+	// Named profile from the shared config and credentials files
+	Profile string
+	// Role assumed with the base credentials, e.g. one in another account
+	RoleARN string
+}
+
+var (
+	mu      sync.RWMutex
+	current Options
+)
+
+// Set makes o apply to every later Load; call it once at startup
+func Set(o Options) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = o
+}
+
+// newSTS builds the client that assumes RoleARN; tests replace it
+var newSTS = func(cfg aws.Config) stscreds.AssumeRoleAPIClient {
+	return sts.NewFromConfig(cfg)
+}
+
+// Load is config.LoadDefaultConfig with the Set options applied first, so optFns
+// such as a per-client region still win. With a RoleARN the returned config's
+// credentials come from assuming that role, refreshed before they expire.
+func Load(ctx context.Context, optFns ...func(*config.LoadOptions) error) (aws.Config, error) {
+	mu.RLock()
+	o := current
+	mu.RUnlock()
+
+	var fns []func(*config.LoadOptions) error
+	if o.Region != "" {
+		fns = append(fns, config.WithRegion(o.Region))
+	}
+	if o.Profile != "" {
+		fns = append(fns, config.WithSharedConfigProfile(o.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, append(fns, optFns...)...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if o.RoleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(newSTS(cfg), o.RoleARN, func(ro *stscreds.AssumeRoleOptions) {
+			ro.RoleSessionName = SessionName
+		}))
+	}
+	return cfg, nil
+}
@@ -0,0 +1,74 @@
+package awsconfig
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+)
+
+// fakeSTS hands out fixed credentials and remembers the role it was asked for
+type fakeSTS struct {
+	got *sts.AssumeRoleInput
+}
+
+func (f *fakeSTS) AssumeRole(_ context.Context, in *sts.AssumeRoleInput, _ ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	f.got = in
+	return &sts.AssumeRoleOutput{Credentials: &types.Credentials{
+		AccessKeyId:     aws.String("ASSUMED"),
+		SecretAccessKey: aws.String("secret"),
+		SessionToken:    aws.String("token"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}}, nil
+}
+
+func TestLoadAssumesConfiguredRole(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "BASE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "base-secret")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/none")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/none")
+	fake := &fakeSTS{}
+	newSTS = func(aws.Config) stscreds.AssumeRoleAPIClient { return fake }
+	t.Cleanup(func() {
+		newSTS = func(cfg aws.Config) stscreds.AssumeRoleAPIClient { return sts.NewFromConfig(cfg) }
+		Set(Options{})
+	})
+
+	Set(Options{Region: "eu-west-1", RoleARN: "arn:aws:iam::123456789012:role/router"})
+	cfg, err := Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Region != "eu-west-1" {
+		t.Errorf("expected the configured region, got %q", cfg.Region)
+	}
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "ASSUMED" {
+		t.Errorf("expected assumed-role credentials, got %q", creds.AccessKeyID)
+	}
+	if fake.got == nil || aws.ToString(fake.got.RoleArn) != "arn:aws:iam::123456789012:role/router" || aws.ToString(fake.got.RoleSessionName) != SessionName {
+		t.Errorf("expected the role assumed as %s, got %+v", SessionName, fake.got)
+	}
+
+	// a client's own region still wins, and without a role the base credentials are used
+	Set(Options{Region: "eu-west-1"})
+	cfg, err = Load(context.Background(), config.WithRegion("us-west-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, err = cfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Region != "us-west-2" || creds.AccessKeyID != "BASE" {
+		t.Errorf("expected us-west-2 with base credentials, got %q %q", cfg.Region, creds.AccessKeyID)
+	}
+}
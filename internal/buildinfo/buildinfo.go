@@ -0,0 +1,49 @@
+// Package buildinfo reports which build of the router is running. The variables are
+// set at link time:
+//
+//	go build -ldflags "-X github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/buildinfo.Version=v1.2.3
+//	  -X github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/buildinfo.Commit=$(git rev-parse HEAD)
+//	  -X github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info is the build description served by /v1/version and /v1/admin/status
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the injected build details. Without ldflags, commit and date fall back to
+// the VCS stamp go build records for a git checkout, else "unknown".
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCallPanicked is returned to callers waiting on a call that panicked
+var ErrCallPanicked = errors.New("coalesced call panicked")
+
+type flight[T any] struct {
+	done     chan struct{}
+	val      T
+	panicked bool
+}
+
+// Coalescer runs one call per key at a time: identical calls arriving while it runs
+// wait for its result instead of making their own, and so do those arriving within
+// window after it succeeded
+type Coalescer[T any] struct {
+	mu     sync.Mutex
+	window time.Duration
+	calls  map[string]*flight[T]
+}
+
+// NewCoalescer keeps a successful result for window after its call returns
+func NewCoalescer[T any](window time.Duration) *Coalescer[T] {
+	return &Coalescer[T]{window: window, calls: make(map[string]*flight[T])}
+}
+
+// Do returns the running or recent result for key, or calls fn for it. fn reports
+// whether its result may be handed to calls arriving after it returns; callers
+// already waiting get it either way. shared is true when fn wasn't called, and err
+// is only ever ctx's error from giving up on waiting, or ErrCallPanicked when the
+// call being waited on panicked. A panic in fn reaches its own caller as usual.
+func (c *Coalescer[T]) Do(ctx context.Context, key string, fn func() (T, bool)) (v T, shared bool, err error) {
+	c.mu.Lock()
+	if f, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			if f.panicked {
+				return v, true, ErrCallPanicked
+			}
+			return f.val, true, nil
+		case <-ctx.Done():
+			return v, true, ctx.Err()
+		}
+	}
+	f := &flight[T]{done: make(chan struct{})}
+	c.calls[key] = f
+	c.mu.Unlock()
+
+	forget := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.calls[key] == f {
+			delete(c.calls, key)
+		}
+	}
+	keep, returned := false, false
+	defer func() {
+		// a panicking fn must still release its waiters and its key
+		if !returned {
+			f.panicked = true
+			close(f.done)
+			forget()
+		}
+	}()
+	f.val, keep = fn()
+	returned = true
+	close(f.done)
+	if keep && c.window > 0 {
+		time.AfterFunc(c.window, forget)
+	} else {
+		forget()
+	}
+	return f.val, false, nil
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalescerSharesRunningAndRecentResults(t *testing.T) {
+	c := NewCoalescer[string](50 * time.Millisecond)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (string, bool) {
+		calls.Add(1)
+		<-release
+		return "done", true
+	}
+
+	var wg sync.WaitGroup
+	results := make([]bool, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			v, shared, err := c.Do(context.Background(), "k", fn)
+			if err != nil || v != "done" {
+				t.Errorf("unexpected result %q %v", v, err)
+			}
+			results[i] = shared
+		}(i)
+	}
+	// let every caller reach Do before the call finishes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("expected one call for concurrent duplicates, got %d", calls.Load())
+	}
+
+	// within the window the result is replayed; after it the call runs again
+	if _, shared, _ := c.Do(context.Background(), "k", fn); !shared {
+		t.Error("expected a recent result to be shared")
+	}
+	time.Sleep(80 * time.Millisecond)
+	if _, shared, _ := c.Do(context.Background(), "k", fn); shared || calls.Load() != 2 {
+		t.Errorf("expected a fresh call once the window passed, shared=%v calls=%d", shared, calls.Load())
+	}
+}
+
+func TestCoalescerForgetsUnkeptResults(t *testing.T) {
+	c := NewCoalescer[int](time.Minute)
+	var calls int
+	fail := func() (int, bool) {
+		calls++
+		return calls, false
+	}
+	c.Do(context.Background(), "k", fail)
+	if v, shared, _ := c.Do(context.Background(), "k", fail); shared || v != 2 {
+		t.Errorf("expected an unkept result to be retried, got %d shared=%v", v, shared)
+	}
+}
+
+func TestCoalescerReleasesWaitersWhenTheCallPanics(t *testing.T) {
+	c := NewCoalescer[int](time.Minute)
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer func() { _ = recover() }()
+		c.Do(context.Background(), "k", func() (int, bool) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	waited := make(chan error, 1)
+	go func() {
+		_, _, err := c.Do(context.Background(), "k", func() (int, bool) { return 0, true })
+		waited <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	select {
+	case err := <-waited:
+		if !errors.Is(err, ErrCallPanicked) {
+			t.Errorf("expected ErrCallPanicked for the waiter, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter still blocked after the call panicked")
+	}
+	if v, shared, err := c.Do(context.Background(), "k", func() (int, bool) { return 7, true }); shared || err != nil || v != 7 {
+		t.Errorf("expected the key forgotten after the panic, got %d shared=%v err=%v", v, shared, err)
+	}
+}
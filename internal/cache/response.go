@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is what a cache hit replays instead of calling a provider
+type CachedResponse struct {
+	Provider string
+	Text     string
+}
+
+type entry struct {
+	key     string
+	resp    CachedResponse
+	expires time.Time
+}
+
+// ResponseCache is a TTL cache of completions, evicting least recently used entries past maxEntries
+type ResponseCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+// NewResponseCache creates a cache holding up to maxEntries responses for ttl each
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Key identifies a request by model, normalized prompt, max tokens and policy
+func Key(model, prompt string, maxTokens int, policy string) string {
+	h := sha256.New()
+	for _, part := range []string{model, NormalizePrompt(prompt), strconv.Itoa(maxTokens), policy} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NormalizePrompt trims and collapses whitespace so formatting-only differences share an entry
+func NormalizePrompt(prompt string) string {
+	return strings.Join(strings.Fields(prompt), " ")
+}
+
+// Get returns an unexpired response for key
+func (c *ResponseCache) Get(key string) (CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return CachedResponse{}, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return CachedResponse{}, false
+	}
+	c.ll.MoveToFront(el)
+	return e.resp, true
+}
+
+// Put stores resp under key, replacing any previous entry
+func (c *ResponseCache) Put(key string, resp CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.items[key]; ok {
+		el.Value = &entry{key: key, resp: resp, expires: expires}
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry{key: key, resp: resp, expires: expires})
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry).key)
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet evicted
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseCacheHitMiss(t *testing.T) {
+	c := NewResponseCache(time.Minute, 10)
+	k := Key("gpt-4o", "What is  2+2?\n", 0, "cheapest")
+
+	if _, ok := c.Get(k); ok {
+		t.Fatal("expected miss on empty cache")
+	}
+	c.Put(k, CachedResponse{Provider: "openai", Text: "4"})
+
+	// whitespace-only differences share the entry
+	if got, ok := c.Get(Key("gpt-4o", " What is 2+2? ", 0, "cheapest")); !ok || got.Text != "4" || got.Provider != "openai" {
+		t.Errorf("expected hit with normalized prompt, got %+v %v", got, ok)
+	}
+	// every other key component separates entries
+	for _, other := range []string{
+		Key("gpt-4o-mini", "What is 2+2?", 0, "cheapest"),
+		Key("gpt-4o", "What is 2+3?", 0, "cheapest"),
+		Key("gpt-4o", "What is 2+2?", 50, "cheapest"),
+		Key("gpt-4o", "What is 2+2?", 0, "fastest_p95"),
+	} {
+		if _, ok := c.Get(other); ok {
+			t.Errorf("unexpected hit for different request")
+		}
+	}
+}
+
+func TestResponseCacheTTLExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	c := NewResponseCache(30*time.Second, 10)
+	c.now = func() time.Time { return now }
+
+	c.Put("k", CachedResponse{Text: "cached"})
+	now = now.Add(29 * time.Second)
+	if _, ok := c.Get("k"); !ok {
+		t.Fatal("expected hit before TTL")
+	}
+	now = now.Add(time.Second)
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("expected miss at TTL")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expired entry should be evicted on read, len=%d", c.Len())
+	}
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewResponseCache(time.Minute, 2)
+	c.Put("a", CachedResponse{Text: "a"})
+	c.Put("b", CachedResponse{Text: "b"})
+	c.Get("a") // a is now most recent
+	c.Put("c", CachedResponse{Text: "c"})
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("expected a to survive")
+	}
+	if c.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", c.Len())
+	}
+}
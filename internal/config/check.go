@@ -0,0 +1,103 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// Problem is one issue found by Check. Fatal problems mean the server would run with
+// settings other than the ones configured, or fail outright.
+type Problem struct {
+	Fatal   bool
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Fatal {
+		return "error: " + p.Message
+	}
+	return "warning: " + p.Message
+}
+
+// Check runs ValidateConfig's warnings plus structural checks that would otherwise only
+// surface at runtime: the default policy, canary stage order, circuit breaker failure
+// kinds, and the tenants, prompt templates and OpenAI providers files
+func Check(cfg Config) []Problem {
+	var problems []Problem
+	for _, w := range ValidateConfig(cfg) {
+		problems = append(problems, Problem{Message: w})
+	}
+	fatal := func(format string, args ...any) {
+		problems = append(problems, Problem{Fatal: true, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !IsValidPolicy(cfg.DefaultPolicy) {
+		fatal("ROUTER_POLICY %q is not one of cheapest, fastest_p95, slo_burn_aware, canary, latency_slo", cfg.DefaultPolicy)
+	}
+	for i := 1; i < len(cfg.CanaryStages); i++ {
+		if cfg.CanaryStages[i] <= cfg.CanaryStages[i-1] {
+			fatal("CANARY_STAGES must increase, got %v", cfg.CanaryStages)
+			break
+		}
+	}
+	for _, k := range cfg.CBFailureKinds {
+		if !IsValidErrorKind(k) {
+			fatal("CB_FAILURE_KINDS %q is not one of %s", k, errorKindList())
+		}
+	}
+	if cfg.TenantsJSONPath != "" {
+		if err := checkJSONArray(cfg.TenantsJSONPath); err != nil {
+			fatal("TENANTS_JSON: %v", err)
+		}
+	}
+	if cfg.PromptTemplatesPath != "" {
+		if err := checkJSONObject(cfg.PromptTemplatesPath); err != nil {
+			fatal("PROMPT_TEMPLATES_JSON: %v", err)
+		}
+	}
+	if cfg.OpenAIProvidersPath != "" {
+		if err := checkJSONArray(cfg.OpenAIProvidersPath); err != nil {
+			fatal("OPENAI_PROVIDERS_JSON: %v", err)
+		}
+	}
+	return problems
+}
+
+// errorKindList is providers.ErrorKinds comma separated, for messages
+func errorKindList() string {
+	kinds := make([]string, len(providers.ErrorKinds))
+	for i, k := range providers.ErrorKinds {
+		kinds[i] = string(k)
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// checkJSONObject fails unless path can be read as a JSON object of strings
+func checkJSONObject(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// checkJSONArray fails unless path can be read as a JSON array of objects
+func checkJSONArray(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var entries []map[string]any
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
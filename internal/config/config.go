@@ -3,19 +3,53 @@ package config
 import (
 	"bufio"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
 )
 
+// DefaultSLOTarget is the availability objective when SLO_TARGET is unset (99%)
+const DefaultSLOTarget = 0.99
+
+// DefaultTenantStaleGrace is TENANT_STALE_GRACE when unset. It lives here rather than
+// in auth because telemetry imports config and auth imports telemetry.
+const DefaultTenantStaleGrace = 5 * time.Minute
+
 type Config struct {
-	Port           string
+	Port string
+	// HTTP server timeouts; ReadHeaderTimeout bounds slow-header (Slowloris) clients
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// Serve HTTP/2 without TLS (h2c) for proxies that speak it to the backend
+	H2C bool
+
 	DefaultPolicy  string
 	OpenAIKey      string
 	OpenAIModel    string
+	OpenAIBaseURL  string
+	OpenAIOrg      string
 	BedrockRegion  string
 	BedrockModelID string
-	OtelEndpoint   string
+	// Regions in failover order; defaults to BedrockRegion alone
+	BedrockRegions []string
+	// converse (default) or invoke; Converse falls back to InvokeModel per model as needed
+	BedrockAPI   string
+	OtelEndpoint string
+
+	// json or console; empty picks console on a terminal and json otherwise
+	LogFormat string
+	LogLevel  string
+	// Fraction of requests written to the access log; 5xx are always logged
+	AccessLogSample float64
+	// Whether prompts reach spans and error logs: none, hash (SHA-256) or full
+	PromptLogging string
 
 	EnableMockProvider bool
 	MockMeanLatencyMs  int
@@ -23,17 +57,201 @@ type Config struct {
 	MockErrorRate      float64
 	MockCostPer1kUSD   float64
 
+	// Optional self-hosted OpenAI-compatible endpoint (vLLM, Ollama)
+	LocalLLMBaseURL      string
+	LocalLLMName         string
+	LocalLLMAPIKey       string
+	LocalLLMModel        string
+	LocalLLMCostPer1kUSD float64
+
+	// Prime provider connection pools at startup and after reload
+	ProviderWarmup bool
+	// In-flight infer requests across all tenants before shedding with 503; 0 is unlimited
+	MaxGlobalConcurrency int
+	// How long a request may queue for a fair share of MaxGlobalConcurrency before 503
+	TenantQueueMaxWait time.Duration
+	// Upstream calls one infer request may make across retries and providers; 0 is unlimited
+	MaxTotalAttempts int
+	// Shed infer requests with 503 while the last minute's spend is over this; 0 is off
+	MaxCostUSDPerMinute float64
+	// Largest request body accepted after gzip decompression
+	MaxDecompressedBodyBytes int64
+	// Largest request body read off the wire, compressed or not
+	MaxRequestBodyBytes int64
+	// Connection pool shared by HTTP-based providers
+	ProviderMaxIdleConns        int
+	ProviderMaxIdleConnsPerHost int
+	ProviderIdleConnTimeout     time.Duration
+	// Outbound requests and tokens per minute per provider, mirroring upstream account
+	// limits; the default applies to providers not listed and 0 is unlimited
+	ProviderRPM  int
+	ProviderRPMs map[string]int
+	ProviderTPM  int
+	ProviderTPMs map[string]int
+	// How long a call may queue for provider quota before failing over
+	ProviderQuotaMaxWait time.Duration
+	// Error kinds that count toward a provider's circuit breaker; empty uses the providers default
+	CBFailureKinds []string
+
+	// Keep a conversation_id's turns on one provider for this long after its latest turn; 0 is off
+	StickyConversationTTL  time.Duration
+	StickyConversationsMax int
+
+	// Optional TTL cache for identical prompts
+	EnableResponseCache     bool
+	ResponseCacheTTL        time.Duration
+	ResponseCacheMaxEntries int
+
+	// Optional coalescing of identical requests without an Idempotency-Key
+	EnableContentDedup bool
+	ContentDedupWindow time.Duration
+
+	// Optional tiktoken rank file for exact token counts on matching models
+	TokenizerBPEPath   string
+	TokenizerBPEModels []string
+	// JSON object of named prompt templates with {{name}} placeholders
+	PromptTemplatesPath string
+	// JSON array of additional named OpenAI-style providers
+	OpenAIProvidersPath string
+	// Bill at list price per token plus this fraction instead of the provider-reported cost; 0 is off
+	CostMarkup float64
+
 	AdminToken string
+	// JSON file of named admin tokens with roles (viewer, operator, admin)
+	AdminTokensJSONPath string
+	// Liveness fails when infer requests are in flight but none completes for this long
+	LivenessStallWindow time.Duration
+	// On SIGTERM readiness fails for this long before the server stops accepting requests
+	ShutdownDrainDelay time.Duration
+	// Availability objective for burn rates and the SLO report, e.g. 0.99
+	SLOTarget float64
+	// Append-only JSON-lines file for admin actions; empty keeps them in memory
+	AuditLogPath string
+	// Receives canary and circuit-breaker events as JSON POSTs; empty disables
+	EventWebhookURL string
+
+	// AWS credentials for Bedrock and the DynamoDB tables; empty keeps the default chain
+	AWSRegion  string
+	AWSProfile string
+	AWSRoleARN string
 
 	// Multi-tenant configuration
 	DDBTenantsTable     string
 	DDBUsageTable       string
 	TenantsJSONPath     string
+	TenantsJSONRefresh  time.Duration
+	TenantStaleGrace    time.Duration // how long past its cache TTL a tenant still authenticates while DynamoDB errors
 	EnableUsageTracking bool
+	// How long an Idempotency-Key replays its stored response
+	IdempotencyTTL time.Duration
+	// Largest response body stored for Idempotency-Key replay; larger ones re-run on repeat
+	IdempotencyMaxResponseBytes int
+	// Streamed tokens between updates of the tenant's daily count and limit checks; 0 counts a stream only when it ends
+	StreamUsageCheckpointTokens int64
+
+	// Percent of a tenant's daily token limit past which responses carry X-Usage-Warning; 0 is off
+	UsageWarningPct float64
+
+	// Embedding model for /v1/embeddings requests that name none; "" leaves it to each provider
+	EmbeddingModel string
+
+	// Where tenants' API keys are read from, in this order; "" or false turns a source off
+	APIKeyHeader     string
+	APIKeyBearer     bool
+	APIKeyQueryParam string
 
 	CanaryStages         []float64
 	CanaryWindow         int
 	CanaryBurnMultiplier float64
+	CanaryMaxP95Ratio    float64
+	CanaryMinSavingsPct  float64 // cost per request savings over the primary auto-advance needs; 0 disables
+	CanaryMode           string  // requests (default) or cost
+	CanaryTenants        []string
+	// minimum time a canary evaluation window stays open on top of its CanaryWindow
+	// calls; either may be 0 for a purely call- or time-based window
+	CanaryMinDwell time.Duration
+
+	// fastest_p95 sample threshold and recency half-life
+	FastestP95MinSamples int
+	FastestP95HalfLife   time.Duration
+	// p95 latency target for latency_slo, which shares fastest_p95's sampling
+	LatencySLOP95 time.Duration
+
+	// Request bounds, with per-model overrides keyed by model name prefix
+	MaxPromptChars       int
+	ModelMaxPromptChars  map[string]int
+	MaxOutputTokens      int
+	ModelMaxOutputTokens map[string]int
+	// Context window in tokens for models missing from the built-in table (0 skips
+	// the check) and per-model windows replacing the table's
+	ContextWindow       int
+	ModelContextWindows map[string]int
+
+	// Logical model names clients may request instead of provider model IDs. A bare
+	// name that isn't one passes through as a model ID; only alias:<name> must match
+	ModelAliases map[string]string
+	// Per-attempt provider timeout by routing policy, replacing the 30s default
+	PolicyTimeouts map[string]time.Duration
+}
+
+// parseModelLimits reads "100000,gpt-4o=512000,claude-3=800000": a bare number
+// replaces def and model=n entries override it for models with that prefix
+func parseModelLimits(s string, def int) (int, map[string]int) {
+	var perModel map[string]int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		model, n, ok := strings.Cut(part, "=")
+		if !ok {
+			if v, err := strconv.Atoi(part); err == nil && v > 0 {
+				def = v
+			}
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSpace(n))
+		if model = strings.TrimSpace(model); err != nil || v <= 0 || model == "" {
+			continue
+		}
+		if perModel == nil {
+			perModel = make(map[string]int)
+		}
+		perModel[model] = v
+	}
+	return def, perModel
+}
+
+// parseAliases reads "fast=gpt-4o-mini,smart=gpt-4o", skipping malformed entries
+func parseAliases(s string) map[string]string {
+	var aliases map[string]string
+	for _, part := range strings.Split(s, ",") {
+		name, model, ok := strings.Cut(part, "=")
+		name, model = strings.TrimSpace(name), strings.TrimSpace(model)
+		if !ok || name == "" || model == "" {
+			continue
+		}
+		if aliases == nil {
+			aliases = make(map[string]string)
+		}
+		aliases[name] = model
+	}
+	return aliases
+}
+
+// parsePolicyTimeouts reads "fastest_p95=5s,cheapest=60s", skipping malformed entries
+func parsePolicyTimeouts(s string) map[string]time.Duration {
+	var timeouts map[string]time.Duration
+	for _, part := range strings.Split(s, ",") {
+		policy, v, ok := strings.Cut(part, "=")
+		policy = strings.TrimSpace(policy)
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if !ok || policy == "" || err != nil || d <= 0 {
+			continue
+		}
+		if timeouts == nil {
+			timeouts = make(map[string]time.Duration)
+		}
+		timeouts[policy] = d
+	}
+	return timeouts
 }
 
 func getenv(k, def string) string {
@@ -87,6 +305,9 @@ func ValidateConfig(cfg Config) []string {
 		if cfg.EnableMockProvider {
 			providerCount++
 		}
+		if cfg.LocalLLMBaseURL != "" {
+			providerCount++
+		}
 		if providerCount < 2 {
 			warnings = append(warnings, "canary policy requires at least 2 providers, falling back to cheapest")
 		}
@@ -101,6 +322,9 @@ func (c Config) MaskSecrets() Config {
 	if masked.OpenAIKey != "" {
 		masked.OpenAIKey = "***masked***"
 	}
+	if masked.LocalLLMAPIKey != "" {
+		masked.LocalLLMAPIKey = "***masked***"
+	}
 	if masked.AdminToken != "" {
 		masked.AdminToken = "***masked***"
 	}
@@ -114,10 +338,17 @@ func IsValidPolicy(policy string) bool {
 		"fastest_p95":    true,
 		"slo_burn_aware": true,
 		"canary":         true,
+		"latency_slo":    true,
 	}
 	return validPolicies[policy]
 }
 
+// IsValidErrorKind checks if kind is one of the providers' error kinds, as reported in
+// error_kind
+func IsValidErrorKind(kind string) bool {
+	return slices.Contains(providers.ErrorKinds, providers.ErrorKind(kind))
+}
+
 func Load() Config {
 	loadDotEnv()
 	cfg := Config{
@@ -125,12 +356,38 @@ func Load() Config {
 		DefaultPolicy:      getenv("ROUTER_POLICY", "cheapest"),
 		OpenAIKey:          getenv("OPENAI_API_KEY", ""),
 		OpenAIModel:        getenv("OPENAI_MODEL", "gpt-4o"),
+		EmbeddingModel:     getenv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		OpenAIBaseURL:      getenv("OPENAI_BASE_URL", ""),
+		OpenAIOrg:          getenv("OPENAI_ORG", ""),
 		BedrockRegion:      getenv("BEDROCK_REGION", "us-east-1"),
 		BedrockModelID:     getenv("BEDROCK_MODEL_ID", "anthropic.claude-3-haiku"),
+		BedrockAPI:         getenv("BEDROCK_API", "converse"),
 		OtelEndpoint:       getenv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
 		EnableMockProvider: getenv("ENABLE_MOCK_PROVIDER", "") != "" && getenv("ENABLE_MOCK_PROVIDER", "") != "0",
 		AdminToken:         getenv("ADMIN_TOKEN", ""),
 	}
+	cfg.ReadTimeout = 10 * time.Second
+	if v, err := time.ParseDuration(getenv("HTTP_READ_TIMEOUT", "")); err == nil && v >= 0 {
+		cfg.ReadTimeout = v
+	}
+	cfg.ReadHeaderTimeout = 5 * time.Second
+	if v, err := time.ParseDuration(getenv("HTTP_READ_HEADER_TIMEOUT", "")); err == nil && v >= 0 {
+		cfg.ReadHeaderTimeout = v
+	}
+	cfg.WriteTimeout = 60 * time.Second
+	if v, err := time.ParseDuration(getenv("HTTP_WRITE_TIMEOUT", "")); err == nil && v >= 0 {
+		cfg.WriteTimeout = v
+	}
+	cfg.IdleTimeout = 90 * time.Second
+	if v, err := time.ParseDuration(getenv("HTTP_IDLE_TIMEOUT", "")); err == nil && v >= 0 {
+		cfg.IdleTimeout = v
+	}
+	cfg.MaxHeaderBytes = 1 << 20
+	if v, err := strconv.Atoi(getenv("HTTP_MAX_HEADER_BYTES", "")); err == nil && v > 0 {
+		cfg.MaxHeaderBytes = v
+	}
+	cfg.H2C = getenv("HTTP_H2C", "") != "" && getenv("HTTP_H2C", "") != "0"
+
 	// defaults
 	cfg.MockMeanLatencyMs = 40
 	cfg.MockP95LatencyMs = 120
@@ -149,13 +406,124 @@ func Load() Config {
 	if v, err := strconv.ParseFloat(getenv("MOCK_COST_PER_1K_TOKENS_USD", ""), 64); err == nil && v >= 0 {
 		cfg.MockCostPer1kUSD = v
 	}
+	cfg.LocalLLMBaseURL = getenv("LOCAL_LLM_BASE_URL", "")
+	cfg.LocalLLMName = getenv("LOCAL_LLM_NAME", "local")
+	cfg.LocalLLMAPIKey = getenv("LOCAL_LLM_API_KEY", "")
+	cfg.LocalLLMModel = getenv("LOCAL_LLM_MODEL", "")
+	if v, err := strconv.ParseFloat(getenv("LOCAL_LLM_COST_PER_1K_TOKENS_USD", ""), 64); err == nil && v >= 0 {
+		cfg.LocalLLMCostPer1kUSD = v
+	}
+	if v := getenv("LOG_FORMAT", ""); v == "json" || v == "console" {
+		cfg.LogFormat = v
+	}
+	cfg.LogLevel = getenv("LOG_LEVEL", "info")
+	cfg.PromptLogging = "none"
+	if v := getenv("PROMPT_LOGGING", ""); v == "hash" || v == "full" {
+		cfg.PromptLogging = v
+	}
+	cfg.AccessLogSample = 1
+	if v, err := strconv.ParseFloat(getenv("ACCESS_LOG_SAMPLE", ""), 64); err == nil && v >= 0 && v <= 1 {
+		cfg.AccessLogSample = v
+	}
+	cfg.ProviderWarmup = getenv("PROVIDER_WARMUP", "") == "on"
+	if v, err := strconv.Atoi(getenv("MAX_GLOBAL_CONCURRENCY", "")); err == nil && v > 0 {
+		cfg.MaxGlobalConcurrency = v
+	}
+	cfg.TenantQueueMaxWait = 100 * time.Millisecond
+	if d, err := time.ParseDuration(getenv("TENANT_QUEUE_MAX_WAIT", "")); err == nil && d >= 0 {
+		cfg.TenantQueueMaxWait = d
+	}
+	cfg.MaxTotalAttempts = 3
+	if v, err := strconv.Atoi(getenv("MAX_TOTAL_ATTEMPTS", "")); err == nil && v >= 0 {
+		cfg.MaxTotalAttempts = v
+	}
+	if v, err := strconv.ParseFloat(getenv("MAX_COST_USD_PER_MINUTE", ""), 64); err == nil && v > 0 {
+		cfg.MaxCostUSDPerMinute = v
+	}
+	cfg.MaxDecompressedBodyBytes = 10 << 20
+	if v, err := strconv.ParseInt(getenv("MAX_DECOMPRESSED_BODY_BYTES", ""), 10, 64); err == nil && v > 0 {
+		cfg.MaxDecompressedBodyBytes = v
+	}
+	cfg.MaxRequestBodyBytes = 10 << 20
+	if v, err := strconv.ParseInt(getenv("MAX_REQUEST_BODY_BYTES", ""), 10, 64); err == nil && v > 0 {
+		cfg.MaxRequestBodyBytes = v
+	}
+	cfg.ProviderMaxIdleConns = 256
+	if v, err := strconv.Atoi(getenv("PROVIDER_MAX_IDLE_CONNS", "")); err == nil && v > 0 {
+		cfg.ProviderMaxIdleConns = v
+	}
+	cfg.ProviderMaxIdleConnsPerHost = 64
+	if v, err := strconv.Atoi(getenv("PROVIDER_MAX_IDLE_CONNS_PER_HOST", "")); err == nil && v > 0 {
+		cfg.ProviderMaxIdleConnsPerHost = v
+	}
+	cfg.ProviderIdleConnTimeout = 90 * time.Second
+	if v, err := time.ParseDuration(getenv("PROVIDER_IDLE_CONN_TIMEOUT", "")); err == nil && v > 0 {
+		cfg.ProviderIdleConnTimeout = v
+	}
+	cfg.ProviderRPM, cfg.ProviderRPMs = parseModelLimits(getenv("PROVIDER_RPM", ""), 0)
+	cfg.ProviderTPM, cfg.ProviderTPMs = parseModelLimits(getenv("PROVIDER_TPM", ""), 0)
+	cfg.ProviderQuotaMaxWait = 250 * time.Millisecond
+	if v, err := time.ParseDuration(getenv("PROVIDER_QUOTA_MAX_WAIT", "")); err == nil && v >= 0 {
+		cfg.ProviderQuotaMaxWait = v
+	}
+	for _, k := range strings.Split(getenv("CB_FAILURE_KINDS", ""), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			cfg.CBFailureKinds = append(cfg.CBFailureKinds, k)
+		}
+	}
+	if v, err := time.ParseDuration(getenv("STICKY_CONVERSATION_TTL", "")); err == nil && v > 0 {
+		cfg.StickyConversationTTL = v
+	}
+	cfg.StickyConversationsMax = 10000
+	if v, err := strconv.Atoi(getenv("STICKY_CONVERSATIONS_MAX", "")); err == nil && v > 0 {
+		cfg.StickyConversationsMax = v
+	}
+	cfg.EnableResponseCache = getenv("ENABLE_RESPONSE_CACHE", "") != "" && getenv("ENABLE_RESPONSE_CACHE", "") != "0"
+	cfg.ResponseCacheTTL = 5 * time.Minute
+	if v, err := time.ParseDuration(getenv("RESPONSE_CACHE_TTL", "")); err == nil && v > 0 {
+		cfg.ResponseCacheTTL = v
+	}
+	cfg.ResponseCacheMaxEntries = 1000
+	if v, err := strconv.Atoi(getenv("RESPONSE_CACHE_MAX_ENTRIES", "")); err == nil && v > 0 {
+		cfg.ResponseCacheMaxEntries = v
+	}
+	cfg.EnableContentDedup = getenv("ENABLE_CONTENT_DEDUP", "") != "" && getenv("ENABLE_CONTENT_DEDUP", "") != "0"
+	cfg.ContentDedupWindow = 5 * time.Second
+	if v, err := time.ParseDuration(getenv("CONTENT_DEDUP_WINDOW", "")); err == nil && v >= 0 {
+		cfg.ContentDedupWindow = v
+	}
+	cfg.TokenizerBPEPath = getenv("TOKENIZER_BPE_PATH", "")
+	cfg.PromptTemplatesPath = getenv("PROMPT_TEMPLATES_JSON", "")
+	cfg.OpenAIProvidersPath = getenv("OPENAI_PROVIDERS_JSON", "")
+	for _, m := range strings.Split(getenv("TOKENIZER_BPE_MODELS", "gpt-4,gpt-3.5"), ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			cfg.TokenizerBPEModels = append(cfg.TokenizerBPEModels, m)
+		}
+	}
+	for _, r := range strings.Split(getenv("BEDROCK_REGIONS", ""), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			cfg.BedrockRegions = append(cfg.BedrockRegions, r)
+		}
+	}
+	if len(cfg.BedrockRegions) == 0 {
+		cfg.BedrockRegions = []string{cfg.BedrockRegion}
+	}
+	cfg.MaxPromptChars, cfg.ModelMaxPromptChars = parseModelLimits(getenv("MAX_PROMPT_CHARS", ""), 100000)
+	cfg.MaxOutputTokens, cfg.ModelMaxOutputTokens = parseModelLimits(getenv("MAX_OUTPUT_TOKENS", ""), 8192)
+	cfg.ContextWindow, cfg.ModelContextWindows = parseModelLimits(getenv("CONTEXT_WINDOWS", ""), 0)
+	cfg.ModelAliases = parseAliases(getenv("ALIASES", ""))
+	cfg.PolicyTimeouts = parsePolicyTimeouts(getenv("POLICY_TIMEOUTS", ""))
+	if v, err := strconv.ParseFloat(getenv("COST_MARKUP", ""), 64); err == nil && v > 0 {
+		cfg.CostMarkup = v
+	}
 	// Canary config with defaults
 	cfg.CanaryStages = []float64{1, 5, 25}
 	if s := getenv("CANARY_STAGES", ""); s != "" {
 		parts := strings.Split(s, ",")
 		var st []float64
 		for _, p := range parts {
-			if f, err := strconv.ParseFloat(strings.TrimSpace(p), 64); err == nil && f >= 0 {
+			// stages are percentages; anything outside (0,100] is ignored
+			if f, err := strconv.ParseFloat(strings.TrimSpace(p), 64); err == nil && f > 0 && f <= 100 {
 				st = append(st, f)
 			}
 		}
@@ -164,18 +532,106 @@ func Load() Config {
 		}
 	}
 	cfg.CanaryWindow = 200
-	if v, err := strconv.Atoi(getenv("CANARY_WINDOW", "")); err == nil && v > 0 {
+	if v, err := strconv.Atoi(getenv("CANARY_WINDOW", "")); err == nil && v >= 0 {
 		cfg.CanaryWindow = v
 	}
+	cfg.CanaryMinDwell = 5 * time.Minute
+	if v, err := time.ParseDuration(getenv("CANARY_MIN_DWELL", "")); err == nil && v >= 0 {
+		cfg.CanaryMinDwell = v
+	}
 	cfg.CanaryBurnMultiplier = 2.0
 	if v, err := strconv.ParseFloat(getenv("CANARY_BURN_MULTIPLIER", ""), 64); err == nil && v > 0 {
 		cfg.CanaryBurnMultiplier = v
 	}
+	cfg.CanaryMaxP95Ratio = 2.0
+	if v, err := strconv.ParseFloat(getenv("CANARY_MAX_P95_RATIO", ""), 64); err == nil && v > 0 {
+		cfg.CanaryMaxP95Ratio = v
+	}
+	if v, err := strconv.ParseFloat(getenv("CANARY_MIN_SAVINGS_PCT", ""), 64); err == nil && v > 0 && v < 100 {
+		cfg.CanaryMinSavingsPct = v
+	}
+	for _, t := range strings.Split(getenv("CANARY_TENANTS", ""), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			cfg.CanaryTenants = append(cfg.CanaryTenants, t)
+		}
+	}
+	cfg.CanaryMode = "requests"
+	if v := getenv("CANARY_MODE", ""); v == "requests" || v == "cost" {
+		cfg.CanaryMode = v
+	}
+	cfg.FastestP95MinSamples = 20
+	if v, err := strconv.Atoi(getenv("FASTEST_P95_MIN_SAMPLES", "")); err == nil && v > 0 {
+		cfg.FastestP95MinSamples = v
+	}
+	cfg.FastestP95HalfLife = 5 * time.Minute
+	if v, err := time.ParseDuration(getenv("FASTEST_P95_HALF_LIFE", "")); err == nil && v >= 0 {
+		cfg.FastestP95HalfLife = v
+	}
+	cfg.LatencySLOP95 = 2 * time.Second
+	if v, err := time.ParseDuration(getenv("LATENCY_SLO_P95", "")); err == nil && v > 0 {
+		cfg.LatencySLOP95 = v
+	}
+
+	cfg.AdminTokensJSONPath = getenv("ADMIN_TOKENS_JSON", "")
+	// longer than a remote call with retries (3 x 30s timeouts plus backoff)
+	cfg.LivenessStallWindow = 3 * time.Minute
+	if v, err := time.ParseDuration(getenv("LIVENESS_STALL_WINDOW", "")); err == nil && v > 0 {
+		cfg.LivenessStallWindow = v
+	}
+	cfg.ShutdownDrainDelay = 5 * time.Second
+	if v, err := time.ParseDuration(getenv("SHUTDOWN_DRAIN_DELAY", "")); err == nil && v >= 0 {
+		cfg.ShutdownDrainDelay = v
+	}
+	cfg.SLOTarget = DefaultSLOTarget
+	if v, err := strconv.ParseFloat(getenv("SLO_TARGET", ""), 64); err == nil && v > 0 && v < 1 {
+		cfg.SLOTarget = v
+	}
+	cfg.AuditLogPath = getenv("AUDIT_LOG_PATH", "")
+	cfg.EventWebhookURL = getenv("EVENT_WEBHOOK_URL", "")
+
+	cfg.AWSRegion = getenv("AWS_REGION", "")
+	cfg.AWSProfile = getenv("AWS_PROFILE", "")
+	// with a web identity token file the SDK already assumes AWS_ROLE_ARN itself
+	if getenv("AWS_WEB_IDENTITY_TOKEN_FILE", "") == "" {
+		cfg.AWSRoleARN = getenv("AWS_ROLE_ARN", "")
+	}
 
 	// Multi-tenant config
 	cfg.DDBTenantsTable = getenv("DDB_TENANTS_TABLE", "")
 	cfg.DDBUsageTable = getenv("DDB_USAGE_TABLE", "")
 	cfg.TenantsJSONPath = getenv("TENANTS_JSON", "")
+	cfg.TenantsJSONRefresh = 10 * time.Second
+	if v, err := time.ParseDuration(getenv("TENANTS_JSON_REFRESH", "")); err == nil && v > 0 {
+		cfg.TenantsJSONRefresh = v
+	}
+	cfg.TenantStaleGrace = DefaultTenantStaleGrace
+	if v, err := time.ParseDuration(getenv("TENANT_STALE_GRACE", "")); err == nil && v >= 0 {
+		cfg.TenantStaleGrace = v
+	}
+	cfg.UsageWarningPct = 80
+	if v, err := strconv.ParseFloat(getenv("USAGE_WARNING_PCT", ""), 64); err == nil && v >= 0 && v < 100 {
+		cfg.UsageWarningPct = v
+	}
+	cfg.APIKeyHeader = strings.TrimSpace(getenv("API_KEY_HEADER", "X-API-Key"))
+	if cfg.APIKeyHeader == "off" {
+		cfg.APIKeyHeader = ""
+	}
+	cfg.APIKeyBearer = getenv("API_KEY_BEARER", "") != "0"
+	cfg.APIKeyQueryParam = strings.TrimSpace(getenv("API_KEY_QUERY_PARAM", ""))
+
+	cfg.IdempotencyTTL = 24 * time.Hour
+	if v, err := time.ParseDuration(getenv("IDEMPOTENCY_TTL", "")); err == nil && v > 0 {
+		cfg.IdempotencyTTL = v
+	}
+	cfg.IdempotencyMaxResponseBytes = 32 * 1024
+	if v, err := strconv.Atoi(getenv("IDEMPOTENCY_MAX_RESPONSE_BYTES", "")); err == nil && v > 0 {
+		cfg.IdempotencyMaxResponseBytes = v
+	}
+
+	cfg.StreamUsageCheckpointTokens = 256
+	if v, err := strconv.ParseInt(getenv("STREAM_USAGE_CHECKPOINT_TOKENS", ""), 10, 64); err == nil && v >= 0 {
+		cfg.StreamUsageCheckpointTokens = v
+	}
 
 	// Enable usage tracking if DDB tables are set or if explicitly enabled (for JSON fallback)
 	cfg.EnableUsageTracking = (cfg.DDBTenantsTable != "" && cfg.DDBUsageTable != "") ||
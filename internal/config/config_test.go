@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateConfig(t *testing.T) {
@@ -45,6 +48,16 @@ func TestValidateConfig(t *testing.T) {
 			},
 			expectedWarnings: 0,
 		},
+		{
+			name: "canary policy with local endpoint",
+			config: Config{
+				DefaultPolicy:   "canary",
+				OpenAIKey:       "test-key",
+				LocalLLMBaseURL: "http://localhost:11434/v1",
+			},
+			envVars:          map[string]string{},
+			expectedWarnings: 0,
+		},
 		{
 			name: "non-canary policy",
 			config: Config{
@@ -176,3 +189,89 @@ func TestMaskSecretsEmptyValues(t *testing.T) {
 		t.Errorf("expected empty AdminToken to remain empty, got %q", masked.AdminToken)
 	}
 }
+
+func TestLoadCanaryStagesArePercentages(t *testing.T) {
+	t.Setenv("CANARY_STAGES", "1, 150, 5, -1, 25")
+	cfg := Load()
+	want := []float64{1, 5, 25}
+	if len(cfg.CanaryStages) != len(want) {
+		t.Fatalf("expected stages %v, got %v", want, cfg.CanaryStages)
+	}
+	for i := range want {
+		if cfg.CanaryStages[i] != want[i] {
+			t.Fatalf("expected stages %v, got %v", want, cfg.CanaryStages)
+		}
+	}
+}
+
+func TestLoadModelLimits(t *testing.T) {
+	t.Setenv("MAX_PROMPT_CHARS", "200000, gpt-4o=512000, bad=x, =5")
+	t.Setenv("MAX_OUTPUT_TOKENS", "")
+	cfg := Load()
+	if cfg.MaxPromptChars != 200000 {
+		t.Errorf("expected default prompt cap 200000, got %d", cfg.MaxPromptChars)
+	}
+	if len(cfg.ModelMaxPromptChars) != 1 || cfg.ModelMaxPromptChars["gpt-4o"] != 512000 {
+		t.Errorf("expected only the gpt-4o override, got %v", cfg.ModelMaxPromptChars)
+	}
+	if cfg.MaxOutputTokens != 8192 || cfg.ModelMaxOutputTokens != nil {
+		t.Errorf("expected output cap to stay 8192 with no overrides, got %d %v", cfg.MaxOutputTokens, cfg.ModelMaxOutputTokens)
+	}
+}
+
+func TestLoadAliases(t *testing.T) {
+	t.Setenv("ALIASES", "fast=gpt-4o-mini, smart = gpt-4o,broken,=x,y=")
+	cfg := Load()
+	if len(cfg.ModelAliases) != 2 || cfg.ModelAliases["fast"] != "gpt-4o-mini" || cfg.ModelAliases["smart"] != "gpt-4o" {
+		t.Errorf("expected fast and smart aliases only, got %v", cfg.ModelAliases)
+	}
+}
+
+func TestLoadPolicyTimeouts(t *testing.T) {
+	t.Setenv("POLICY_TIMEOUTS", "fastest_p95=5s, cheapest = 1m,canary=soon,=3s,slo_burn_aware=0s")
+	cfg := Load()
+	if len(cfg.PolicyTimeouts) != 2 || cfg.PolicyTimeouts["fastest_p95"] != 5*time.Second || cfg.PolicyTimeouts["cheapest"] != time.Minute {
+		t.Errorf("expected fastest_p95 and cheapest timeouts only, got %v", cfg.PolicyTimeouts)
+	}
+}
+
+func TestCheckCatchesStructuralProblems(t *testing.T) {
+	base := Config{DefaultPolicy: "cheapest", CanaryStages: []float64{1, 5, 25}}
+	if problems := Check(base); len(problems) != 0 {
+		t.Fatalf("expected a valid config to pass, got %v", problems)
+	}
+
+	badTenants := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(badTenants, []byte(`{"tenant_id": "t1"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"unknown policy", func(c *Config) { c.DefaultPolicy = "cheapset" }, "ROUTER_POLICY"},
+		{"stages out of order", func(c *Config) { c.CanaryStages = []float64{5, 1, 25} }, "CANARY_STAGES"},
+		{"repeated stage", func(c *Config) { c.CanaryStages = []float64{5, 5} }, "CANARY_STAGES"},
+		{"unknown failure kind", func(c *Config) { c.CBFailureKinds = []string{"timeout", "upstream_500"} }, "CB_FAILURE_KINDS"},
+		{"unparseable tenants", func(c *Config) { c.TenantsJSONPath = badTenants }, "TENANTS_JSON"},
+		{"missing tenants", func(c *Config) { c.TenantsJSONPath = badTenants + ".missing" }, "TENANTS_JSON"},
+	} {
+		cfg := base
+		tc.mutate(&cfg)
+		problems := Check(cfg)
+		if len(problems) != 1 || !problems[0].Fatal || !strings.Contains(problems[0].Message, tc.want) {
+			t.Errorf("%s: expected one fatal %s problem, got %v", tc.name, tc.want, problems)
+		}
+	}
+
+	// ValidateConfig's fallbacks are reported but don't fail the check
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_PROFILE", "")
+	cfg := base
+	cfg.DefaultPolicy = "canary"
+	problems := Check(cfg)
+	if len(problems) != 1 || problems[0].Fatal {
+		t.Errorf("expected a single non-fatal canary warning, got %v", problems)
+	}
+}
@@ -127,6 +127,8 @@ func serveSwaggerHTML(w http.ResponseWriter, r *http.Request) {
 							'/v1/admin/policy',
 							'/v1/admin/providers/reload',
 							'/v1/admin/tenants',
+							'/v1/admin/tenants/import',
+							'/v1/admin/tenants/export',
 							'/v1/admin/tenants/{tenant_id}/usage'
 						];
 						
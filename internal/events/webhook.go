@@ -0,0 +1,151 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Event types pushed to the webhook
+const (
+	CanaryAdvance    = "canary_advance"
+	CanaryRollback   = "canary_rollback"
+	CanaryTransition = "canary_transition"
+	CircuitOpen      = "circuit_open"
+	CircuitClosed    = "circuit_closed"
+	SpendTripped     = "spend_guardrail_tripped"
+	SpendCleared     = "spend_guardrail_cleared"
+	UsageWarning     = "usage_soft_limit"
+)
+
+// queueSize bounds events waiting for delivery; beyond it new events are dead-lettered
+const queueSize = 256
+
+// Event is the JSON body POSTed to EVENT_WEBHOOK_URL
+type Event struct {
+	Type string         `json:"type"`
+	TS   time.Time      `json:"ts"`
+	Data map[string]any `json:"data,omitempty"`
+}
+
+// Webhook delivers events in the background with retries, so a slow or failing
+// receiver never blocks the request path. Events that can't be delivered are
+// written to the log as dead letters.
+type Webhook struct {
+	url      string
+	client   *http.Client
+	queue    chan Event
+	attempts int
+	backoff  time.Duration
+}
+
+// NewWebhook posts to url; call Run to start delivery
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:      url,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		queue:    make(chan Event, queueSize),
+		attempts: 3,
+		backoff:  time.Second,
+	}
+}
+
+// Publish queues e without blocking
+func (w *Webhook) Publish(e Event) {
+	select {
+	case w.queue <- e:
+	default:
+		deadLetter(e, errors.New("delivery queue full"))
+	}
+}
+
+// Run delivers queued events one at a time until ctx is cancelled
+func (w *Webhook) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-w.queue:
+			w.deliver(ctx, e)
+		}
+	}
+}
+
+// deliver tries e up to w.attempts times with doubling backoff
+func (w *Webhook) deliver(ctx context.Context, e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		deadLetter(e, err)
+		return
+	}
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil {
+			return
+		}
+		if attempt >= w.attempts {
+			break
+		}
+		t := time.NewTimer(w.backoff << (attempt - 1))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			deadLetter(e, ctx.Err())
+			return
+		case <-t.C:
+		}
+	}
+	deadLetter(e, err)
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deadLetter logs an undeliverable event with its full payload so it can be replayed
+func deadLetter(e Event, err error) {
+	log.Error().Err(err).Str("event_type", e.Type).Interface("event", e).Msg("webhook event dead-lettered")
+}
+
+var (
+	regMu   sync.RWMutex
+	regHook *Webhook
+)
+
+// SetWebhook installs the process-wide webhook; nil turns publishing off
+func SetWebhook(w *Webhook) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	regHook = w
+}
+
+// Publish sends an event of type typ through the installed webhook, if any
+func Publish(typ string, data map[string]any) {
+	regMu.RLock()
+	w := regHook
+	regMu.RUnlock()
+	if w == nil {
+		return
+	}
+	w.Publish(Event{Type: typ, TS: time.Now().UTC(), Data: data})
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetriesUntilDelivered(t *testing.T) {
+	var calls atomic.Int32
+	got := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		got <- e
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL)
+	w.backoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	w.Publish(Event{Type: CircuitOpen, Data: map[string]any{"provider": "openai"}})
+	select {
+	case e := <-got:
+		if e.Type != CircuitOpen || e.Data["provider"] != "openai" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected delivery on the third attempt, got %d calls", n)
+	}
+}
+
+func TestWebhookPublishNeverBlocks(t *testing.T) {
+	// nothing drains the queue, so overflow must be dead-lettered rather than block
+	w := NewWebhook("http://127.0.0.1:0")
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < queueSize+10; i++ {
+			w.Publish(Event{Type: CanaryTransition})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full queue")
+	}
+	if len(w.queue) != queueSize {
+		t.Errorf("expected a full queue of %d, got %d", queueSize, len(w.queue))
+	}
+}
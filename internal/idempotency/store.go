@@ -1,23 +1,39 @@
 package idempotency
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/awsconfig"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
 	"github.com/rs/zerolog/log"
 )
 
 const MaxResponseSize = 32 * 1024 // 32KB max response size
 
+// MaxResponseSizeLimit keeps a raised cap under DynamoDB's 400KB item size
+const MaxResponseSizeLimit = 350 * 1024
+
+// MaxRequestSize is the largest request body read for fingerprinting by default
+const MaxRequestSize = 10 << 20
+
+// MaxKeyLength bounds Idempotency-Key; DynamoDB sort keys allow more but nothing legitimate needs it
+const MaxKeyLength = 255
+
 // IdempotencyRecord represents a stored idempotency record
 type IdempotencyRecord struct {
 	TenantID       string    `json:"tenant_id" dynamodbav:"tenant_id"`
@@ -25,25 +41,51 @@ type IdempotencyRecord struct {
 	Status         int       `json:"status" dynamodbav:"status"`
 	ResponseHash   string    `json:"response_hash" dynamodbav:"response_hash"`
 	ResponseBody   string    `json:"response_body" dynamodbav:"response_body"`
+	RequestHash    string    `json:"request_hash,omitempty" dynamodbav:"request_hash,omitempty"`
 	CreatedAt      time.Time `json:"created_at" dynamodbav:"created_at"`
 	TTL            int64     `json:"ttl" dynamodbav:"ttl"`
+
+	// NotReplayable marks a response too large to store; the key still guards its
+	// payload, but a repeat runs the request again
+	NotReplayable bool `json:"not_replayable,omitempty" dynamodbav:"not_replayable,omitempty"`
+}
+
+// DefaultTTL is how long a stored response can be replayed
+const DefaultTTL = 24 * time.Hour
+
+// dynamoAPI is the part of the DynamoDB client the store uses
+type dynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
 }
 
 // Store handles idempotency key storage and retrieval
 type Store struct {
-	ddbClient *dynamodb.Client
+	ddbClient dynamoAPI
 	tableName string
 	enabled   bool
+	ttl       time.Duration
+	// largest response body kept for replay
+	maxResponse int
+	// largest request body read for fingerprinting
+	maxRequest int64
 }
 
-func NewStore(tableName string) (*Store, error) {
+// NewStore keeps records in tableName for ttl (DefaultTTL when zero); an empty table disables idempotency
+func NewStore(tableName string, ttl time.Duration) (*Store, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
 	store := &Store{
-		tableName: tableName,
-		enabled:   tableName != "",
+		tableName:   tableName,
+		enabled:     tableName != "",
+		ttl:         ttl,
+		maxResponse: MaxResponseSize,
+		maxRequest:  MaxRequestSize,
 	}
 
 	if store.enabled {
-		cfg, err := config.LoadDefaultConfig(context.TODO())
+		cfg, err := awsconfig.Load(context.TODO())
 		if err != nil {
 			log.Warn().Err(err).Msg("failed to load AWS config, disabling idempotency")
 			store.enabled = false
@@ -55,6 +97,30 @@ func NewStore(tableName string) (*Store, error) {
 	return store, nil
 }
 
+// SetMaxResponseSize sets the largest response body stored for replay, clamped to
+// MaxResponseSizeLimit; zero or less keeps the current size
+func (s *Store) SetMaxResponseSize(n int) {
+	if n <= 0 {
+		return
+	}
+	s.maxResponse = min(n, MaxResponseSizeLimit)
+}
+
+// SetMaxRequestSize sets the largest request body read for fingerprinting; larger
+// requests are rejected with 413. Zero or less keeps the current size.
+func (s *Store) SetMaxRequestSize(n int64) {
+	if n <= 0 {
+		return
+	}
+	s.maxRequest = n
+}
+
+// expired reports whether record is past its TTL. DynamoDB's TTL sweep can lag by up
+// to 48h, so reads can't rely on expired items being gone.
+func expired(record IdempotencyRecord, now time.Time) bool {
+	return record.TTL < now.Unix()
+}
+
 // GetRecord retrieves an existing idempotency record
 func (s *Store) GetRecord(ctx context.Context, tenantID, idempotencyKey string) (*IdempotencyRecord, error) {
 	if !s.enabled {
@@ -82,6 +148,9 @@ func (s *Store) GetRecord(ctx context.Context, tenantID, idempotencyKey string)
 	if err := attributevalue.UnmarshalMap(result.Item, &record); err != nil {
 		return nil, err
 	}
+	if expired(record, time.Now()) {
+		return nil, nil // not swept yet; the next StoreRecord overwrites it
+	}
 
 	return &record, nil
 }
@@ -115,17 +184,22 @@ func (s *Store) StoreRecord(ctx context.Context, record IdempotencyRecord) error
 	return err
 }
 
-// ResponseRecorder captures HTTP responses for idempotency
+// ResponseRecorder captures HTTP responses for idempotency. A body past its limit
+// (MaxResponseSize unless the store's is set) is dropped rather than kept truncated,
+// since replaying part of a response would hand the client a corrupt body
 type ResponseRecorder struct {
 	http.ResponseWriter
 	statusCode int
 	body       []byte
+	limit      int
+	oversized  bool
 }
 
 func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
 	return &ResponseRecorder{
 		ResponseWriter: w,
 		statusCode:     http.StatusOK,
+		limit:          MaxResponseSize,
 	}
 }
 
@@ -135,12 +209,29 @@ func (r *ResponseRecorder) WriteHeader(code int) {
 }
 
 func (r *ResponseRecorder) Write(data []byte) (int, error) {
-	if len(r.body)+len(data) <= MaxResponseSize {
-		r.body = append(r.body, data...)
+	if !r.oversized {
+		if len(r.body)+len(data) <= r.limit {
+			r.body = append(r.body, data...)
+		} else {
+			r.oversized = true
+			r.body = nil
+		}
 	}
 	return r.ResponseWriter.Write(data)
 }
 
+// Oversized reports whether the body outgrew the limit and wasn't kept
+func (r *ResponseRecorder) Oversized() bool {
+	return r.oversized
+}
+
+// Flush keeps streamed responses flowing through the recorder
+func (r *ResponseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (r *ResponseRecorder) Status() int {
 	return r.statusCode
 }
@@ -164,11 +255,15 @@ func (s *Store) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		idempotencyKey := r.Header.Get("Idempotency-Key")
+		idempotencyKey := NormalizeKey(r.Header.Get("Idempotency-Key"))
 		if idempotencyKey == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
+		if err := ValidateKey(idempotencyKey); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_idempotency_key", "Invalid Idempotency-Key", err.Error())
+			return
+		}
 
 		tenant, ok := auth.GetTenantFromContext(r.Context())
 		if !ok {
@@ -176,6 +271,18 @@ func (s *Store) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		fingerprint, err := RequestFingerprint(w, r, s.maxRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, "request_too_large", "Request Body Too Large",
+				fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "invalid_request", "Invalid request", "failed to read request body")
+			return
+		}
+
 		// Check for existing record
 		existing, err := s.GetRecord(r.Context(), tenant.TenantID, idempotencyKey)
 		if err != nil {
@@ -185,13 +292,29 @@ func (s *Store) Middleware(next http.Handler) http.Handler {
 		}
 
 		if existing != nil {
-			// Return cached response
-			s.replayResponse(w, r, existing)
-			return
+			// records stored before fingerprinting have no hash and replay as before
+			if existing.RequestHash != "" && existing.RequestHash != fingerprint {
+				telemetry.IdempotencyTotal.WithLabelValues("conflict").Inc()
+				writeProblem(w, r, http.StatusUnprocessableEntity, "idempotency_key_reuse",
+					"Idempotency key reuse", "idempotency key reuse with different payload")
+				return
+			}
+			if existing.NotReplayable {
+				// the original response was too large to keep, so run the request again
+				telemetry.IdempotencyTotal.WithLabelValues("not_replayable").Inc()
+			} else {
+				// Return cached response
+				telemetry.IdempotencyTotal.WithLabelValues("hit").Inc()
+				s.replayResponse(w, r, existing)
+				return
+			}
+		} else {
+			telemetry.IdempotencyTotal.WithLabelValues("miss").Inc()
 		}
 
 		// Record new request
 		recorder := NewResponseRecorder(w)
+		recorder.limit = s.maxResponse
 		next.ServeHTTP(recorder, r)
 
 		// Store the response
@@ -202,12 +325,22 @@ func (s *Store) Middleware(next http.Handler) http.Handler {
 			Status:         recorder.Status(),
 			ResponseHash:   recorder.Hash(),
 			ResponseBody:   string(recorder.Body()),
+			RequestHash:    fingerprint,
 			CreatedAt:      now,
-			TTL:            now.Add(24 * time.Hour).Unix(), // 24 hour TTL
+			TTL:            now.Add(s.ttl).Unix(),
+		}
+		if recorder.Oversized() {
+			// keep the key and payload hash without a partial body
+			telemetry.IdempotencyTotal.WithLabelValues("too_large").Inc()
+			log.Warn().Str("tenant_id", tenant.TenantID).Int("max_bytes", s.maxResponse).Msg("response too large to replay; Idempotency-Key stored as not replayable")
+			record.NotReplayable = true
+			record.ResponseHash = ""
 		}
 
 		if err := s.StoreRecord(r.Context(), record); err != nil {
 			log.Error().Err(err).Msg("failed to store idempotency record")
+		} else {
+			telemetry.IdempotencyTotal.WithLabelValues("stored").Inc()
 		}
 	})
 }
@@ -221,6 +354,12 @@ func (s *Store) replayResponse(w http.ResponseWriter, r *http.Request, record *I
 	}
 
 	w.Header().Set("X-Idempotency-Replay", "true")
+	// whole seconds since the original response, like the HTTP Age header
+	age := int64(time.Since(record.CreatedAt).Seconds())
+	if age < 0 {
+		age = 0
+	}
+	w.Header().Set("X-Idempotency-Key-Age", strconv.FormatInt(age, 10))
 
 	w.WriteHeader(record.Status)
 
@@ -228,3 +367,70 @@ func (s *Store) replayResponse(w http.ResponseWriter, r *http.Request, record *I
 		_, _ = w.Write([]byte(record.ResponseBody))
 	}
 }
+
+// NormalizeKey trims surrounding whitespace from an Idempotency-Key header value
+func NormalizeKey(key string) string {
+	return strings.TrimSpace(key)
+}
+
+// ValidateKey accepts 1-255 characters of letters, digits, '-', '_', '.' and ':',
+// which covers UUIDs and ULIDs while keeping keys safe as DynamoDB sort keys
+func ValidateKey(key string) error {
+	if key == "" || len(key) > MaxKeyLength {
+		return fmt.Errorf("idempotency key must be 1-%d characters", MaxKeyLength)
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return fmt.Errorf("idempotency key may only contain letters, digits, '-', '_', '.' and ':'")
+		}
+	}
+	return nil
+}
+
+// RequestFingerprint hashes the method, path and body so a key can't replay a response
+// for a different request. JSON bodies are canonicalized first, so formatting and key
+// order don't matter. The body is restored for the next handler; one over maxBytes
+// fails with *http.MaxBytesError.
+func RequestFingerprint(w http.ResponseWriter, r *http.Request, maxBytes int64) (string, error) {
+	var body []byte
+	if r.Body != nil {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			return "", err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		body = b
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err == nil && !dec.More() {
+		if canonical, err := json.Marshal(v); err == nil {
+			body = canonical
+		}
+	}
+
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, errorType, title, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	if reqID := r.Header.Get("X-Request-ID"); reqID != "" {
+		w.Header().Set("X-Request-ID", reqID)
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":   fmt.Sprintf("https://example.com/errors/%s", errorType),
+		"title":  title,
+		"detail": detail,
+		"status": status,
+	})
+}
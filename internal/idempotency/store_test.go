@@ -0,0 +1,312 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+func TestValidateKey(t *testing.T) {
+	valid := []string{"a", "3f2b8c1e-9d4a-4e7b-8a1c-2b3d4e5f6a7b", "01HZX3K9Q4:retry.2_b", strings.Repeat("k", MaxKeyLength)}
+	for _, k := range valid {
+		if err := ValidateKey(k); err != nil {
+			t.Errorf("ValidateKey(%q) = %v, want nil", k, err)
+		}
+	}
+	invalid := []string{"", strings.Repeat("k", MaxKeyLength+1), "has space", "slash/key", "quote\"", "nul\x00", "ünïcode"}
+	for _, k := range invalid {
+		if err := ValidateKey(k); err == nil {
+			t.Errorf("ValidateKey(%q) = nil, want error", k)
+		}
+	}
+}
+
+func TestRequestFingerprintIgnoresJSONFormatting(t *testing.T) {
+	fp := func(body string) string {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body))
+		got, err := RequestFingerprint(httptest.NewRecorder(), r, MaxRequestSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	a := fp(`{"prompt": "hi", "max_tokens": 10}`)
+	if b := fp("{\n  \"max_tokens\": 10,\n  \"prompt\": \"hi\"\n}"); a != b {
+		t.Error("expected reordered, reformatted JSON to share a fingerprint")
+	}
+	if c := fp(`{"prompt": "hi", "max_tokens": 11}`); a == c {
+		t.Error("expected a different payload to change the fingerprint")
+	}
+	if d := fp(`{"prompt": "hi", "max_tokens": 10}{"extra": true}`); a == d {
+		t.Error("expected trailing data to change the fingerprint")
+	}
+}
+
+func TestMiddlewareKeyHandling(t *testing.T) {
+	store := newMemoryStore(0)
+	calls := 0
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text": "ok"}`))
+	}))
+	tenant := &auth.Tenant{TenantID: "t1", Enabled: true}
+
+	do := func(key, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		r = r.WithContext(auth.WithTenant(r.Context(), tenant))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := do("bad key!", `{"prompt": "hi"}`); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid key, got %d", rec.Code)
+	}
+	if calls != 0 {
+		t.Fatalf("expected handler not called for invalid key, got %d calls", calls)
+	}
+
+	if rec := do("order-42", `{"prompt": "hi"}`); rec.Code != http.StatusOK {
+		t.Fatalf("expected first request to succeed, got %d", rec.Code)
+	}
+
+	// same payload, reformatted and with padding around the key: replayed
+	rec := do("  order-42 ", `{ "prompt":"hi" }`)
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Idempotency-Replay") != "true" {
+		t.Fatalf("expected replay, got %d replay=%q", rec.Code, rec.Header().Get("X-Idempotency-Replay"))
+	}
+
+	rec = do("order-42", `{"prompt": "something else"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for key reuse with a different payload, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "idempotency key reuse with different payload") {
+		t.Errorf("unexpected body: %s", rec.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("expected the handler to run once, got %d", calls)
+	}
+
+	store.SetMaxRequestSize(32)
+	if rec := do("order-43", `{"prompt": "`+strings.Repeat("x", 64)+`"}`); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a body over the fingerprint limit, got %d", rec.Code)
+	}
+	if calls != 1 {
+		t.Errorf("expected the handler not to run for an oversized body, got %d calls", calls)
+	}
+}
+
+func TestReplayCountsHitAndReportsAge(t *testing.T) {
+	store := newMemoryStore(0)
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"text": "ok"}`))
+	}))
+	tenant := &auth.Tenant{TenantID: "t-metrics", Enabled: true}
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{"prompt": "hi"}`))
+		r.Header.Set("Idempotency-Key", "metrics-1")
+		r = r.WithContext(auth.WithTenant(r.Context(), tenant))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	hits := testutil.ToFloat64(telemetry.IdempotencyTotal.WithLabelValues("hit"))
+	stored := testutil.ToFloat64(telemetry.IdempotencyTotal.WithLabelValues("stored"))
+
+	if rec := do(); rec.Header().Get("X-Idempotency-Replay") != "" || rec.Header().Get("X-Idempotency-Key-Age") != "" {
+		t.Fatal("expected replay headers only on a replay")
+	}
+	if got := testutil.ToFloat64(telemetry.IdempotencyTotal.WithLabelValues("stored")); got != stored+1 {
+		t.Errorf("expected stored to increment, went from %v to %v", stored, got)
+	}
+
+	// backdate the record so the age header has something to report
+	rec, _ := store.GetRecord(t.Context(), tenant.TenantID, "metrics-1")
+	rec.CreatedAt = time.Now().Add(-90 * time.Second)
+	store.StoreRecord(t.Context(), *rec)
+
+	replay := do()
+	if replay.Header().Get("X-Idempotency-Replay") != "true" {
+		t.Fatal("expected a replay")
+	}
+	if age := replay.Header().Get("X-Idempotency-Key-Age"); age != "90" {
+		t.Errorf("expected key age 90, got %q", age)
+	}
+	if got := testutil.ToFloat64(telemetry.IdempotencyTotal.WithLabelValues("hit")); got != hits+1 {
+		t.Errorf("expected hit to increment, went from %v to %v", hits, got)
+	}
+}
+
+func TestOversizedResponseIsNotReplayed(t *testing.T) {
+	store := newMemoryStore(0)
+	calls := 0
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// written in pieces, like an encoder streaming a large completion
+		w.Write([]byte(`{"text": "`))
+		for i := 0; i < 4; i++ {
+			w.Write([]byte(strings.Repeat("x", MaxResponseSize/3)))
+		}
+		w.Write([]byte(`"}`))
+	}))
+	tenant := &auth.Tenant{TenantID: "t-large", Enabled: true}
+	send := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		r = r.WithContext(auth.WithTenant(r.Context(), tenant))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+	do := func() *httptest.ResponseRecorder { return send("large-1", `{"prompt": "hi"}`) }
+	tooLarge := testutil.ToFloat64(telemetry.IdempotencyTotal.WithLabelValues("too_large"))
+
+	first := do()
+	if first.Body.Len() <= MaxResponseSize {
+		t.Fatalf("expected the full response sent to the client, got %d bytes", first.Body.Len())
+	}
+	rec, _ := store.GetRecord(t.Context(), tenant.TenantID, "large-1")
+	if rec == nil || !rec.NotReplayable || rec.ResponseBody != "" {
+		t.Fatalf("expected the key recorded as not replayable without a body, got %+v", rec)
+	}
+	if got := testutil.ToFloat64(telemetry.IdempotencyTotal.WithLabelValues("too_large")); got != tooLarge+1 {
+		t.Errorf("expected too_large to increment, went from %v to %v", tooLarge, got)
+	}
+
+	retry := do()
+	if retry.Header().Get("X-Idempotency-Replay") != "" || calls != 2 {
+		t.Fatalf("expected the retry to run the request again, got %d calls", calls)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Error("expected the retry to get the whole response")
+	}
+
+	// the key still belongs to its original payload
+	if conflict := send("large-1", `{"prompt": "something else"}`); conflict.Code != http.StatusUnprocessableEntity || calls != 2 {
+		t.Errorf("expected 422 for a different payload under the key, got %d after %d calls", conflict.Code, calls)
+	}
+
+	// with the cap raised the same response is replayed whole
+	store.SetMaxResponseSize(2 * MaxResponseSize)
+	send("large-2", `{"prompt": "hi"}`)
+	if replay := send("large-2", `{"prompt": "hi"}`); replay.Header().Get("X-Idempotency-Replay") != "true" || replay.Body.String() != first.Body.String() {
+		t.Errorf("expected a full replay under the raised cap, got %d bytes (replay %q)", replay.Body.Len(), replay.Header().Get("X-Idempotency-Replay"))
+	}
+}
+
+// memoryDynamo is a DynamoDB table held in a map, keyed by pk and sk
+type memoryDynamo struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func itemKey(key map[string]types.AttributeValue) string {
+	pk, _ := key["pk"].(*types.AttributeValueMemberS)
+	sk, _ := key["sk"].(*types.AttributeValueMemberS)
+	return pk.Value + "\x00" + sk.Value
+}
+
+func (d *memoryDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: d.items[itemKey(params.Key)]}, nil
+}
+
+func (d *memoryDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[itemKey(params.Item)] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// newMemoryStore is an enabled store backed by memoryDynamo
+func newMemoryStore(ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{
+		ddbClient:   &memoryDynamo{items: make(map[string]map[string]types.AttributeValue)},
+		tableName:   "idem",
+		enabled:     true,
+		ttl:         ttl,
+		maxResponse: MaxResponseSize,
+		maxRequest:  MaxRequestSize,
+	}
+}
+
+// staleDynamo returns item for every read, like a table whose TTL sweep hasn't run
+type staleDynamo struct {
+	item IdempotencyRecord
+}
+
+func (d *staleDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	item, err := attributevalue.MarshalMap(d.item)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{Item: item}, nil
+}
+
+func (d *staleDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestGetRecordIgnoresExpiredItems(t *testing.T) {
+	created := time.Now().Add(-25 * time.Hour)
+	ddb := &staleDynamo{item: IdempotencyRecord{
+		TenantID:       "t1",
+		IdempotencyKey: "old",
+		Status:         http.StatusOK,
+		CreatedAt:      created,
+		TTL:            created.Add(DefaultTTL).Unix(),
+	}}
+	store := &Store{ddbClient: ddb, tableName: "idem", enabled: true, ttl: DefaultTTL}
+
+	rec, err := store.GetRecord(context.Background(), "t1", "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != nil {
+		t.Fatalf("expected expired record to be treated as missing, got %+v", rec)
+	}
+
+	ddb.item.TTL = time.Now().Add(time.Hour).Unix()
+	if rec, err := store.GetRecord(context.Background(), "t1", "old"); err != nil || rec == nil {
+		t.Fatalf("expected unexpired record, got %v, %v", rec, err)
+	}
+}
+
+func TestStoreRecordUsesConfiguredTTL(t *testing.T) {
+	store := newMemoryStore(time.Minute)
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/v1/infer", strings.NewReader(`{}`))
+	r.Header.Set("Idempotency-Key", "ttl-1")
+	r = r.WithContext(auth.WithTenant(r.Context(), &auth.Tenant{TenantID: "t1"}))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	rec, _ := store.GetRecord(context.Background(), "t1", "ttl-1")
+	if rec == nil {
+		t.Fatal("expected stored record")
+	}
+	if want := rec.CreatedAt.Add(time.Minute).Unix(); rec.TTL != want {
+		t.Errorf("expected TTL %d from the configured duration, got %d", want, rec.TTL)
+	}
+}
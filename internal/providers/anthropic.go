@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// anthropicMaxTokens is used when the request leaves max_tokens unset; the Messages API requires it
+const anthropicMaxTokens = 1024
+
+type anthropicReq struct {
+	AnthropicVersion string               `json:"anthropic_version"`
+	System           string               `json:"system,omitempty"`
+	Messages         []anthropicMessage   `json:"messages"`
+	MaxTokens        int                  `json:"max_tokens"`
+	Tools            []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice       *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	// tool_use blocks in responses
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"` // auto, any or tool
+	Name string `json:"name,omitempty"`
+}
+
+// anthropicTools maps tools and the tool choice onto Anthropic's. ToolChoiceNone sends
+// no tools at all, which every Anthropic model version understands.
+func anthropicTools(req CompletionRequest) ([]anthropicTool, *anthropicToolChoice) {
+	if len(req.Tools) == 0 || req.ToolChoice == ToolChoiceNone {
+		return nil, nil
+	}
+	tools := make([]anthropicTool, 0, len(req.Tools))
+	for _, t := range req.Tools {
+		tools = append(tools, anthropicTool{Name: t.Name, Description: t.Description, InputSchema: toolSchema(t)})
+	}
+	switch req.ToolChoice {
+	case "", ToolChoiceAuto:
+		return tools, nil
+	case ToolChoiceRequired:
+		return tools, &anthropicToolChoice{Type: "any"}
+	}
+	return tools, &anthropicToolChoice{Type: "tool", Name: req.ToolChoice}
+}
+
+// anthropicBody builds an Anthropic Messages body. System and developer turns move to the
+// top-level system prompt since Anthropic only accepts user/assistant in messages.
+func anthropicBody(req CompletionRequest) ([]byte, error) {
+	body := anthropicReq{AnthropicVersion: "bedrock-2023-05-31", MaxTokens: req.MaxTok}
+	body.Tools, body.ToolChoice = anthropicTools(req)
+	if body.MaxTokens <= 0 {
+		body.MaxTokens = anthropicMaxTokens
+	}
+	for _, m := range req.ChatMessages() {
+		switch m.Role {
+		case "system", "developer":
+			if body.System != "" {
+				body.System += "\n\n"
+			}
+			body.System += m.Content
+		default:
+			body.Messages = append(body.Messages, anthropicMessage{
+				Role:    m.Role,
+				Content: []anthropicContentBlock{{Type: "text", Text: m.Content}},
+			})
+		}
+	}
+	return json.Marshal(body)
+}
+
+type anthropicResp struct {
+	Content []anthropicContentBlock `json:"content"`
+	Usage   *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// parseAnthropicResp joins the text blocks of an Anthropic Messages response and
+// collects its tool_use blocks. tokens is the reported input+output usage, or zero
+// when the body carries none.
+func parseAnthropicResp(b []byte) (resp CompletionResponse, tokens int, err error) {
+	var r anthropicResp
+	if err := json.Unmarshal(b, &r); err != nil {
+		return CompletionResponse{}, 0, err
+	}
+	var sb strings.Builder
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			sb.WriteString(c.Text)
+		case "tool_use":
+			args := c.Input
+			if len(args) == 0 {
+				args = json.RawMessage(`{}`)
+			}
+			resp.ToolCalls = append(resp.ToolCalls, ToolCall{ID: c.ID, Name: c.Name, Arguments: args})
+		}
+	}
+	resp.Text = sb.String()
+	if r.Usage != nil {
+		tokens = r.Usage.InputTokens + r.Usage.OutputTokens
+	}
+	return resp, tokens, nil
+}
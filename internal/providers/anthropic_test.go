@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAnthropicBodyPreservesRoles(t *testing.T) {
+	b, err := anthropicBody(CompletionRequest{
+		Messages: []Message{
+			{Role: "system", Content: "be terse"},
+			{Role: "developer", Content: "answer in French"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "bonjour"},
+		},
+		Prompt: `say "bye"`,
+	})
+	if err != nil {
+		t.Fatalf("anthropicBody: %v", err)
+	}
+	var body anthropicReq
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatalf("body is not valid JSON: %v", err)
+	}
+
+	if body.System != "be terse\n\nanswer in French" {
+		t.Errorf("expected system and developer turns in system prompt, got %q", body.System)
+	}
+	if body.MaxTokens != anthropicMaxTokens {
+		t.Errorf("expected default max_tokens %d, got %d", anthropicMaxTokens, body.MaxTokens)
+	}
+	wantRoles := []string{"user", "assistant", "user"}
+	wantText := []string{"hi", "bonjour", `say "bye"`}
+	if len(body.Messages) != len(wantRoles) {
+		t.Fatalf("expected %d messages, got %+v", len(wantRoles), body.Messages)
+	}
+	for i, m := range body.Messages {
+		if m.Role != wantRoles[i] || len(m.Content) != 1 || m.Content[0].Type != "text" || m.Content[0].Text != wantText[i] {
+			t.Errorf("message %d: expected %s %q, got %+v", i, wantRoles[i], wantText[i], m)
+		}
+	}
+}
+
+func TestAnthropicToolsRoundTrip(t *testing.T) {
+	req := CompletionRequest{
+		Prompt: "weather in Paris?",
+		Tools: []Tool{
+			{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)},
+			{Name: "get_time"},
+		},
+		ToolChoice: ToolChoiceRequired,
+	}
+	b, err := anthropicBody(req)
+	if err != nil {
+		t.Fatalf("anthropicBody: %v", err)
+	}
+	var body anthropicReq
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatalf("body is not valid JSON: %v", err)
+	}
+	if len(body.Tools) != 2 || body.Tools[0].Name != "get_weather" || body.Tools[1].Name != "get_time" {
+		t.Fatalf("expected both tools sent, got %+v", body.Tools)
+	}
+	if string(body.Tools[1].InputSchema) != string(emptySchema) {
+		t.Errorf("expected an empty schema for a tool without parameters, got %s", body.Tools[1].InputSchema)
+	}
+	if body.ToolChoice == nil || body.ToolChoice.Type != "any" {
+		t.Errorf("expected required to map to any, got %+v", body.ToolChoice)
+	}
+
+	req.ToolChoice = ToolChoiceNone
+	b, _ = anthropicBody(req)
+	var none anthropicReq
+	if err := json.Unmarshal(b, &none); err != nil || len(none.Tools) != 0 || none.ToolChoice != nil {
+		t.Errorf("expected tool choice none to send no tools, got %s", b)
+	}
+
+	resp, tokens, err := parseAnthropicResp([]byte(`{"content":[{"type":"text","text":"checking"},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "checking" || tokens != 15 {
+		t.Errorf("expected text and usage alongside the tool call, got %q and %d tokens", resp.Text, tokens)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" || string(resp.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+		t.Errorf("expected the tool_use block as a tool call, got %+v", resp.ToolCalls)
+	}
+}
@@ -2,56 +2,382 @@ package providers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	bedrockruntime "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/awsconfig"
+)
+
+// Bedrock APIs selectable with BEDROCK_API
+const (
+	// BedrockConverse normalizes messages, roles and usage across model families
+	BedrockConverse = "converse"
+	// BedrockInvokeModel sends Anthropic models a hand-built Anthropic body; others use Converse
+	BedrockInvokeModel = "invoke"
 )
 
+// bedrockAPI is the part of the Bedrock runtime client we call, so tests can stub it
+type bedrockAPI interface {
+	Converse(ctx context.Context, in *bedrockruntime.ConverseInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error)
+	InvokeModel(ctx context.Context, in *bedrockruntime.InvokeModelInput, optFns ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error)
+}
+
+// BedrockOptions configures NewBedrockProvider
+type BedrockOptions struct {
+	ModelID string
+	// Regions in failover order; empty uses AWS_REGION
+	Regions []string
+	// API is BedrockConverse (default) or BedrockInvokeModel
+	API string
+	// OnRegion, when set, hears the outcome of every regional call: ok, failover or error
+	OnRegion func(region, outcome string)
+}
+
 type BedrockProvider struct {
-	client  *bedrockruntime.Client
 	modelID string
+	regions []string
+	// newClient builds the client for a region on first use
+	newClient func(region string) (bedrockAPI, error)
+	mu        sync.Mutex
+	clients   map[string]bedrockAPI
+	onRegion  func(region, outcome string)
+	// useInvoke skips Converse for every Anthropic model
+	useInvoke bool
+	// models Converse rejected; they go straight to InvokeModel from then on
+	invokeOnly sync.Map
 	// simplistic pricing table per 1k tokens
 	pricePer1k map[string]float64
 }
 
-func NewBedrockProvider(modelID string, region string) (*BedrockProvider, error) {
-	if region == "" {
-		region = os.Getenv("AWS_REGION")
+// NewBedrockProvider calls Bedrock in opts.Regions through opts.API, BedrockConverse
+// or BedrockInvokeModel; an empty API means Converse
+func NewBedrockProvider(opts BedrockOptions) (*BedrockProvider, error) {
+	if len(opts.Regions) == 0 {
+		opts.Regions = []string{os.Getenv("AWS_REGION")}
 	}
-	awsCfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	// route SDK calls through the tracing transport so Bedrock calls show up as child spans
+	httpClient := &http.Client{Transport: newTracingTransport("bedrock", sharedTransport())}
+	newClient := func(region string) (bedrockAPI, error) {
+		awsCfg, err := awsconfig.Load(context.Background(), config.WithRegion(region), config.WithHTTPClient(httpClient))
+		if err != nil {
+			return nil, err
+		}
+		return bedrockruntime.NewFromConfig(awsCfg), nil
+	}
+	// build the primary region's client now so bad config surfaces at startup
+	primary, err := newClient(opts.Regions[0])
 	if err != nil {
 		return nil, err
 	}
-	client := bedrockruntime.NewFromConfig(awsCfg)
+	p := newBedrockProvider(newClient, opts)
+	p.clients[opts.Regions[0]] = primary
+	return p, nil
+}
+
+func newBedrockProvider(newClient func(region string) (bedrockAPI, error), opts BedrockOptions) *BedrockProvider {
 	return &BedrockProvider{
-		client:  client,
-		modelID: modelID,
+		modelID:   opts.ModelID,
+		regions:   opts.Regions,
+		newClient: newClient,
+		clients:   make(map[string]bedrockAPI),
+		onRegion:  opts.OnRegion,
+		useInvoke: opts.API == BedrockInvokeModel,
 		pricePer1k: map[string]float64{
 			"anthropic.claude-3-sonnet": 3.00,
 			"anthropic.claude-3-haiku":  0.25,
 		},
-	}, nil
+	}
 }
 
 func (p *BedrockProvider) Name() string { return "bedrock" }
 
+// SupportsTools is true: Converse takes a tool config and InvokeModel sends Anthropic tools
+func (p *BedrockProvider) SupportsTools() bool { return true }
+
+// DefaultModel is the configured BEDROCK_MODEL_ID
+func (p *BedrockProvider) DefaultModel() string { return p.modelID }
+
+// bedrockFallbackPrice stands in for models missing from pricePer1k
+const bedrockFallbackPrice = 3.0
+
+// CostPer1kTokensUSD is the model's list price, the configured model's for "", or
+// bedrockFallbackPrice for models without one
 func (p *BedrockProvider) CostPer1kTokensUSD(model string) float64 {
+	if model == "" {
+		model = p.modelID
+	}
 	if v, ok := p.pricePer1k[model]; ok {
 		return v
 	}
-	return 3.0
+	return bedrockFallbackPrice
+}
+
+// KnowsPrice is false when CostPer1kTokensUSD(model) would be bedrockFallbackPrice
+func (p *BedrockProvider) KnowsPrice(model string) bool {
+	if model == "" {
+		model = p.modelID
+	}
+	_, ok := p.pricePer1k[model]
+	return ok
 }
 
+// Complete tries each region in order, moving on only when a region is throttling or
+// unavailable; other errors are about the request and would fail anywhere
 func (p *BedrockProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, float64, int64, error) {
-	// Using Bedrock InvokeModel with a minimal JSON body in Anthropic-compatible format
-	// Note: Different model providers may require different bodies; this is a simplified example.
-	payload := []byte(fmt.Sprintf(`{"anthropic_version":"bedrock-2023-05-31","messages":[{"role":"user","content":[{"type":"text","text":"%s"}]}]}`, req.Prompt))
+	var resp CompletionResponse
+	var cost float64
+	var lat int64
+	err := p.failover(ctx, func(region string) (err error) {
+		resp, cost, lat, err = p.completeIn(ctx, region, req)
+		return err
+	})
+	if err != nil {
+		return CompletionResponse{}, 0, 0, err
+	}
+	return resp, cost, lat, nil
+}
+
+// failover calls fn with each region in order until one succeeds, moving on only when
+// a region is throttling or unavailable
+func (p *BedrockProvider) failover(ctx context.Context, fn func(region string) error) error {
+	var lastErr error
+	for i, region := range p.regions {
+		err := fn(region)
+		if err == nil {
+			p.observeRegion(region, "ok")
+			return nil
+		}
+		lastErr = err
+		if ctx.Err() != nil || !regionLevel(err) || i == len(p.regions)-1 {
+			p.observeRegion(region, "error")
+			break
+		}
+		p.observeRegion(region, "failover")
+	}
+	return lastErr
+}
+
+// completeIn calls one region, through Converse unless the model needs InvokeModel.
+// Only Anthropic models go through InvokeModel, the one body shape it's sent.
+func (p *BedrockProvider) completeIn(ctx context.Context, region string, req CompletionRequest) (CompletionResponse, float64, int64, error) {
+	client, err := p.client(region)
+	if err != nil {
+		return CompletionResponse{}, 0, 0, &regionError{region: region, err: err}
+	}
+	invokable := anthropicModel(req.Model)
+	if _, skip := p.invokeOnly.Load(req.Model); invokable && (p.useInvoke || skip) {
+		return p.invokeModel(ctx, client, req)
+	}
+	resp, cost, lat, err := p.converse(ctx, client, req)
+	if invokable && converseUnsupported(err) {
+		p.invokeOnly.Store(req.Model, struct{}{})
+		return p.invokeModel(ctx, client, req)
+	}
+	return resp, cost, lat, err
+}
+
+// client returns the region's client, building it on first use
+func (p *BedrockProvider) client(region string) (bedrockAPI, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.clients[region]; ok {
+		return c, nil
+	}
+	c, err := p.newClient(region)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[region] = c
+	return c, nil
+}
+
+func (p *BedrockProvider) observeRegion(region, outcome string) {
+	if p.onRegion != nil {
+		p.onRegion(region, outcome)
+	}
+}
+
+// regionError is a failure to build a region's client
+type regionError struct {
+	region string
+	err    error
+}
+
+func (e *regionError) Error() string { return "bedrock " + e.region + ": " + e.err.Error() }
+func (e *regionError) Unwrap() error { return e.err }
+
+// regionLevel reports whether err is the region's fault (throttling, 5xx, unreachable
+// endpoint) rather than the request's, so another region may succeed
+func regionLevel(err error) bool {
+	var re *regionError
+	if errors.As(err, &re) {
+		return true
+	}
+	switch KindOf(err) {
+	case KindRateLimited, KindUpstream5xx, KindNetwork:
+		return true
+	}
+	return false
+}
+
+// converse calls the Converse API and prices the call from the reported token usage
+func (p *BedrockProvider) converse(ctx context.Context, client bedrockAPI, req CompletionRequest) (CompletionResponse, float64, int64, error) {
+	in, err := converseInput(req)
+	if err != nil {
+		return CompletionResponse{}, 0, 0, err
+	}
+	t0 := time.Now()
+	out, err := client.Converse(ctx, in)
+	if err != nil {
+		return CompletionResponse{}, 0, 0, err
+	}
+	lat := time.Since(t0).Milliseconds()
+
+	var resp CompletionResponse
+	var text strings.Builder
+	if msg, ok := out.Output.(*types.ConverseOutputMemberMessage); ok {
+		for _, block := range msg.Value.Content {
+			switch b := block.(type) {
+			case *types.ContentBlockMemberText:
+				text.WriteString(b.Value)
+			case *types.ContentBlockMemberToolUse:
+				call, err := converseToolCall(b.Value)
+				if err != nil {
+					return CompletionResponse{}, 0, 0, &MalformedResponseError{Provider: p.Name(), Reason: err.Error()}
+				}
+				resp.ToolCalls = append(resp.ToolCalls, call)
+			}
+		}
+	}
+	resp.Text = text.String()
+	if err := checkCompletion(p.Name(), resp); err != nil {
+		return CompletionResponse{}, 0, 0, err
+	}
+	tokens := max(req.MaxTok, 50)
+	if u := out.Usage; u != nil && u.InputTokens != nil && u.OutputTokens != nil {
+		tokens = int(*u.InputTokens + *u.OutputTokens)
+	}
+	return resp, p.CostPer1kTokensUSD(req.Model) / 1000.0 * float64(tokens), lat, nil
+}
+
+// converseToolCall reads a tool_use block, re-encoding its input document as JSON
+func converseToolCall(b types.ToolUseBlock) (ToolCall, error) {
+	call := ToolCall{Arguments: json.RawMessage(`{}`)}
+	if b.ToolUseId != nil {
+		call.ID = *b.ToolUseId
+	}
+	if b.Name != nil {
+		call.Name = *b.Name
+	}
+	if b.Input != nil {
+		args, err := b.Input.MarshalSmithyDocument()
+		if err != nil {
+			return ToolCall{}, err
+		}
+		call.Arguments = args
+	}
+	return call, nil
+}
+
+// converseToolConfig maps tools and the tool choice onto Converse. Converse has no
+// "none" choice, so ToolChoiceNone sends no tools.
+func converseToolConfig(req CompletionRequest) (*types.ToolConfiguration, error) {
+	if len(req.Tools) == 0 || req.ToolChoice == ToolChoiceNone {
+		return nil, nil
+	}
+	cfg := &types.ToolConfiguration{}
+	for _, t := range req.Tools {
+		var schema map[string]any
+		if err := json.Unmarshal(toolSchema(t), &schema); err != nil {
+			return nil, fmt.Errorf("tool %s parameters: %w", t.Name, err)
+		}
+		spec := types.ToolSpecification{
+			Name:        strPtr(t.Name),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(schema)},
+		}
+		if t.Description != "" {
+			spec.Description = strPtr(t.Description)
+		}
+		cfg.Tools = append(cfg.Tools, &types.ToolMemberToolSpec{Value: spec})
+	}
+	switch req.ToolChoice {
+	case "", ToolChoiceAuto:
+	case ToolChoiceRequired:
+		cfg.ToolChoice = &types.ToolChoiceMemberAny{}
+	default:
+		cfg.ToolChoice = &types.ToolChoiceMemberTool{Value: types.SpecificToolChoice{Name: strPtr(req.ToolChoice)}}
+	}
+	return cfg, nil
+}
+
+// converseInput maps the conversation onto Converse. System and developer turns become
+// system blocks, and consecutive turns from the same role are merged since Converse
+// requires user and assistant to alternate.
+func converseInput(req CompletionRequest) (*bedrockruntime.ConverseInput, error) {
+	in := &bedrockruntime.ConverseInput{ModelId: &req.Model}
+	toolConfig, err := converseToolConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	in.ToolConfig = toolConfig
+	if req.MaxTok > 0 {
+		maxTok := int32(req.MaxTok)
+		in.InferenceConfig = &types.InferenceConfiguration{MaxTokens: &maxTok}
+	}
+	for _, m := range req.ChatMessages() {
+		switch m.Role {
+		case "system", "developer":
+			in.System = append(in.System, &types.SystemContentBlockMemberText{Value: m.Content})
+			continue
+		}
+		role := types.ConversationRoleUser
+		if m.Role == "assistant" {
+			role = types.ConversationRoleAssistant
+		}
+		block := &types.ContentBlockMemberText{Value: m.Content}
+		if n := len(in.Messages); n > 0 && in.Messages[n-1].Role == role {
+			in.Messages[n-1].Content = append(in.Messages[n-1].Content, block)
+			continue
+		}
+		in.Messages = append(in.Messages, types.Message{Role: role, Content: []types.ContentBlock{block}})
+	}
+	return in, nil
+}
+
+// converseUnsupported reports whether Converse rejected the model itself (e.g. older
+// Titan or Cohere text models), as opposed to the request
+func converseUnsupported(err error) bool {
+	var ve *types.ValidationException
+	return errors.As(err, &ve) && strings.Contains(strings.ToLower(ve.ErrorMessage()), "support the model")
+}
+
+// anthropicModel reports whether a Bedrock model ID, or a cross-region inference
+// profile such as us.anthropic.claude-3-haiku, names an Anthropic model
+func anthropicModel(model string) bool {
+	return strings.HasPrefix(model, "anthropic.") || strings.Contains(model, ".anthropic.")
+}
+
+// invokeModel is the fallback for Anthropic models Converse doesn't support
+func (p *BedrockProvider) invokeModel(ctx context.Context, client bedrockAPI, req CompletionRequest) (CompletionResponse, float64, int64, error) {
+	// Using Bedrock InvokeModel with an Anthropic JSON body
+	payload, err := anthropicBody(req)
+	if err != nil {
+		return CompletionResponse{}, 0, 0, err
+	}
 
 	t0 := time.Now()
-	out, err := p.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+	out, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
 		ModelId:     &req.Model,
 		ContentType: strPtr("application/json"),
 		Body:        payload,
@@ -59,10 +385,96 @@ func (p *BedrockProvider) Complete(ctx context.Context, req CompletionRequest) (
 	if err != nil {
 		return CompletionResponse{}, 0, 0, err
 	}
-	// For brevity, avoid parsing the entire provider-specific response; assume text is in body string
-	text := string(out.Body)
 	lat := time.Since(t0).Milliseconds()
-	return CompletionResponse{Text: text}, p.CostPer1kTokensUSD(req.Model) / 1000.0 * float64(max(req.MaxTok, 50)), lat, nil
+	resp, tokens, err := parseAnthropicResp(out.Body)
+	if err != nil {
+		return CompletionResponse{}, 0, 0, &MalformedResponseError{Provider: p.Name(), Reason: err.Error()}
+	}
+	if err := checkCompletion(p.Name(), resp); err != nil {
+		return CompletionResponse{}, 0, 0, err
+	}
+	if tokens == 0 {
+		tokens = max(req.MaxTok, 50)
+	}
+	return resp, p.CostPer1kTokensUSD(req.Model) / 1000.0 * float64(tokens), lat, nil
 }
 
 func strPtr(s string) *string { return &s }
+
+// Titan text embedding models; v2 is embedded with when a request names no model
+const (
+	titanEmbedV2 = "amazon.titan-embed-text-v2:0"
+	titanEmbedV1 = "amazon.titan-embed-text-v1"
+)
+
+// bedrockEmbedPricePer1k is USD per 1k input tokens of the Titan models Embed can parse
+var bedrockEmbedPricePer1k = map[string]float64{
+	titanEmbedV2: 0.00002,
+	titanEmbedV1: 0.0001,
+}
+
+// ServesEmbeddingModel is true for the Titan text embedding models, which go through
+// InvokeModel
+func (p *BedrockProvider) ServesEmbeddingModel(model string) bool {
+	if model == "" {
+		return true
+	}
+	_, ok := bedrockEmbedPricePer1k[model]
+	return ok
+}
+
+// EmbeddingCostPer1kTokensUSD is the Titan model's list price, 0 for other models
+func (p *BedrockProvider) EmbeddingCostPer1kTokensUSD(model string) float64 {
+	if model == "" {
+		model = titanEmbedV2
+	}
+	return bedrockEmbedPricePer1k[model]
+}
+
+type titanEmbedResp struct {
+	Embedding           []float64 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+// Embed calls a Titan embedding model once per text, since Titan takes a single
+// inputText, failing over between regions like Complete
+func (p *BedrockProvider) Embed(ctx context.Context, texts []string, model string) (EmbeddingResponse, float64, int64, error) {
+	if model == "" {
+		model = titanEmbedV2
+	}
+	var out EmbeddingResponse
+	t0 := time.Now()
+	err := p.failover(ctx, func(region string) error {
+		client, err := p.client(region)
+		if err != nil {
+			return &regionError{region: region, err: err}
+		}
+		// a region that fails part way is retried from the start elsewhere
+		out = EmbeddingResponse{Vectors: make([][]float64, 0, len(texts)), Model: model}
+		for _, text := range texts {
+			body, _ := json.Marshal(map[string]string{"inputText": text})
+			res, err := client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+				ModelId:     &model,
+				ContentType: strPtr("application/json"),
+				Body:        body,
+			})
+			if err != nil {
+				return err
+			}
+			var tr titanEmbedResp
+			if err := json.Unmarshal(res.Body, &tr); err != nil {
+				return &MalformedResponseError{Provider: p.Name(), Reason: err.Error()}
+			}
+			if len(tr.Embedding) == 0 {
+				return &MalformedResponseError{Provider: p.Name(), Reason: "no embedding"}
+			}
+			out.Vectors = append(out.Vectors, tr.Embedding)
+			out.Tokens += tr.InputTextTokenCount
+		}
+		return nil
+	})
+	if err != nil {
+		return EmbeddingResponse{}, 0, 0, err
+	}
+	return out, embeddingCost(p.EmbeddingCostPer1kTokensUSD(model), out.Tokens, texts), time.Since(t0).Milliseconds(), nil
+}
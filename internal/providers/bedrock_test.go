@@ -0,0 +1,300 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	bedrockruntime "github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// stubBedrock answers Converse with reply, or a canned one, unless the model is in
+// unsupported or err is set; InvokeModel returns invokeBody, or an Anthropic reply when unset
+type stubBedrock struct {
+	unsupported map[string]bool
+	err         error
+	reply       []types.ContentBlock
+	invokeBody  []byte
+	converse    []*bedrockruntime.ConverseInput
+	invoked     int
+}
+
+func (s *stubBedrock) Converse(_ context.Context, in *bedrockruntime.ConverseInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.ConverseOutput, error) {
+	s.converse = append(s.converse, in)
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.unsupported[*in.ModelId] {
+		msg := "This action doesn't support the model that you provided. Try again with a supported text or chat model."
+		return nil, &types.ValidationException{Message: &msg}
+	}
+	in32 := func(v int32) *int32 { return &v }
+	content := s.reply
+	if content == nil {
+		content = []types.ContentBlock{&types.ContentBlockMemberText{Value: "Bonjour"}, &types.ContentBlockMemberText{Value: "!"}}
+	}
+	return &bedrockruntime.ConverseOutput{
+		Output: &types.ConverseOutputMemberMessage{Value: types.Message{
+			Role:    types.ConversationRoleAssistant,
+			Content: content,
+		}},
+		Usage: &types.TokenUsage{InputTokens: in32(300), OutputTokens: in32(100)},
+	}, nil
+}
+
+func (s *stubBedrock) InvokeModel(_ context.Context, in *bedrockruntime.InvokeModelInput, _ ...func(*bedrockruntime.Options)) (*bedrockruntime.InvokeModelOutput, error) {
+	s.invoked++
+	if s.invokeBody != nil {
+		return &bedrockruntime.InvokeModelOutput{Body: s.invokeBody}, nil
+	}
+	return &bedrockruntime.InvokeModelOutput{Body: []byte(`{"content":[{"type":"text","text":"invoked"}]}`)}, nil
+}
+
+// stubProvider serves every region from stub
+func stubProvider(stub *stubBedrock, opts BedrockOptions) *BedrockProvider {
+	opts.Regions = []string{"us-east-1"}
+	return newBedrockProvider(func(string) (bedrockAPI, error) { return stub, nil }, opts)
+}
+
+func TestBedrockConverseMapsMessagesAndUsage(t *testing.T) {
+	stub := &stubBedrock{}
+	p := stubProvider(stub, BedrockOptions{})
+	resp, cost, _, err := p.Complete(context.Background(), CompletionRequest{
+		Model: "meta.llama3-8b-instruct-v1:0",
+		Messages: []Message{
+			{Role: "system", Content: "be terse"},
+			{Role: "developer", Content: "answer in French"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "salut"},
+			{Role: "user", Content: "context"},
+		},
+		Prompt: "say hello",
+		MaxTok: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Bonjour!" {
+		t.Errorf("expected text blocks joined, got %q", resp.Text)
+	}
+	// 400 reported tokens at the 3.00 default price
+	if want := 3.0 / 1000 * 400; cost != want {
+		t.Errorf("expected cost %v from reported usage, got %v", want, cost)
+	}
+
+	in := stub.converse[0]
+	if len(in.System) != 2 {
+		t.Fatalf("expected system and developer turns as system blocks, got %d", len(in.System))
+	}
+	if in.InferenceConfig == nil || *in.InferenceConfig.MaxTokens != 64 {
+		t.Errorf("expected max tokens 64, got %+v", in.InferenceConfig)
+	}
+	// the trailing user turn and Prompt merge so roles alternate
+	wantRoles := []types.ConversationRole{types.ConversationRoleUser, types.ConversationRoleAssistant, types.ConversationRoleUser}
+	wantBlocks := []int{1, 1, 2}
+	if len(in.Messages) != len(wantRoles) {
+		t.Fatalf("expected %d messages, got %d", len(wantRoles), len(in.Messages))
+	}
+	for i, m := range in.Messages {
+		if m.Role != wantRoles[i] || len(m.Content) != wantBlocks[i] {
+			t.Errorf("message %d: expected %s with %d blocks, got %s with %d", i, wantRoles[i], wantBlocks[i], m.Role, len(m.Content))
+		}
+	}
+	if stub.invoked != 0 {
+		t.Errorf("expected no InvokeModel calls, got %d", stub.invoked)
+	}
+}
+
+func TestBedrockFallsBackToInvokeModel(t *testing.T) {
+	stub := &stubBedrock{unsupported: map[string]bool{"anthropic.claude-v2": true, "amazon.titan-text-lite-v1": true}}
+	p := stubProvider(stub, BedrockOptions{})
+	req := CompletionRequest{Model: "anthropic.claude-v2", Prompt: "hi"}
+
+	for i := 0; i < 2; i++ {
+		resp, _, _, err := p.Complete(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Text != "invoked" {
+			t.Errorf("call %d: expected InvokeModel response, got %q", i, resp.Text)
+		}
+	}
+	if len(stub.converse) != 1 || stub.invoked != 2 {
+		t.Errorf("expected one Converse attempt then InvokeModel only, got %d Converse and %d InvokeModel", len(stub.converse), stub.invoked)
+	}
+
+	invokeOnly := stubProvider(stub, BedrockOptions{API: BedrockInvokeModel})
+	if _, _, _, err := invokeOnly.Complete(context.Background(), CompletionRequest{Model: "anthropic.claude-3-haiku", Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.converse) != 1 || stub.invoked != 3 {
+		t.Errorf("expected BEDROCK_API=invoke to skip Converse, got %d Converse and %d InvokeModel", len(stub.converse), stub.invoked)
+	}
+
+	// other families would be sent a body they can't read, so Converse's error stands
+	if _, _, _, err := p.Complete(context.Background(), CompletionRequest{Model: "amazon.titan-text-lite-v1", Prompt: "hi"}); err == nil {
+		t.Error("expected Converse's error for a non-Anthropic model it doesn't support")
+	}
+	if _, _, _, err := invokeOnly.Complete(context.Background(), CompletionRequest{Model: "meta.llama3-8b-instruct-v1:0", Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.converse) != 3 || stub.invoked != 3 {
+		t.Errorf("expected non-Anthropic models to stay on Converse, got %d Converse and %d InvokeModel", len(stub.converse), stub.invoked)
+	}
+}
+
+func TestBedrockPricesEmptyModelAsConfiguredModel(t *testing.T) {
+	p := stubProvider(&stubBedrock{}, BedrockOptions{ModelID: "anthropic.claude-3-haiku"})
+	if got := p.CostPer1kTokensUSD(""); got != 0.25 || !p.KnowsPrice("") {
+		t.Errorf("expected \"\" priced as BEDROCK_MODEL_ID, got %v", got)
+	}
+	if got := p.CostPer1kTokensUSD("meta.llama3-8b-instruct-v1:0"); got != bedrockFallbackPrice || p.KnowsPrice("meta.llama3-8b-instruct-v1:0") {
+		t.Errorf("expected the fallback price for an unlisted model, got %v", got)
+	}
+}
+
+func TestBedrockFailsOverOnRegionErrors(t *testing.T) {
+	throttled := "Too many requests, please wait before trying again."
+	stubs := map[string]*stubBedrock{
+		"us-east-1": {err: &types.ThrottlingException{Message: &throttled}},
+		"us-west-2": {},
+	}
+	var built []string
+	outcomes := map[string]string{}
+	p := newBedrockProvider(func(region string) (bedrockAPI, error) {
+		built = append(built, region)
+		return stubs[region], nil
+	}, BedrockOptions{
+		Regions:  []string{"us-east-1", "us-west-2"},
+		OnRegion: func(region, outcome string) { outcomes[region] = outcome },
+	})
+
+	resp, _, _, err := p.Complete(context.Background(), CompletionRequest{Model: "anthropic.claude-3-haiku", Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("expected us-west-2 to serve the request, got %v", err)
+	}
+	if resp.Text != "Bonjour!" {
+		t.Errorf("unexpected text %q", resp.Text)
+	}
+	if len(stubs["us-east-1"].converse) != 1 || len(stubs["us-west-2"].converse) != 1 {
+		t.Errorf("expected one call per region, got %d and %d", len(stubs["us-east-1"].converse), len(stubs["us-west-2"].converse))
+	}
+	if outcomes["us-east-1"] != "failover" || outcomes["us-west-2"] != "ok" {
+		t.Errorf("expected failover then ok, got %v", outcomes)
+	}
+
+	// a bad request fails the same everywhere, so it stays in the first region
+	invalid := "malformed input request"
+	stubs["us-east-1"].err = &types.ValidationException{Message: &invalid}
+	if _, _, _, err := p.Complete(context.Background(), CompletionRequest{Model: "anthropic.claude-3-haiku", Prompt: "hi"}); err == nil {
+		t.Fatal("expected the validation error")
+	}
+	if len(stubs["us-west-2"].converse) != 1 {
+		t.Errorf("expected no failover for a request error, us-west-2 got %d calls", len(stubs["us-west-2"].converse))
+	}
+	if len(built) != 2 {
+		t.Errorf("expected each region's client built once, got %v", built)
+	}
+
+	// an endpoint that no longer resolves is as dead as one refusing connections
+	stubs["us-east-1"].err = &net.DNSError{Err: "no such host", Name: "bedrock-runtime.us-east-1.amazonaws.com"}
+	if _, _, _, err := p.Complete(context.Background(), CompletionRequest{Model: "anthropic.claude-3-haiku", Prompt: "hi"}); err != nil {
+		t.Fatalf("expected failover past a DNS failure, got %v", err)
+	}
+	if len(stubs["us-west-2"].converse) != 2 {
+		t.Errorf("expected us-west-2 to serve after a DNS failure, got %d calls", len(stubs["us-west-2"].converse))
+	}
+}
+
+func TestBedrockMalformedInvokeResponseFails(t *testing.T) {
+	for name, body := range map[string]string{
+		"raw text":      "invoked",
+		"empty content": `{"content":[],"usage":{"input_tokens":10,"output_tokens":0}}`,
+	} {
+		stub := &stubBedrock{invokeBody: []byte(body)}
+		rp := WithResilience(stubProvider(stub, BedrockOptions{API: BedrockInvokeModel}), ResilienceOptions{MaxRetries: 1, CBWindowSize: 20})
+		_, cost, _, err := rp.Complete(context.Background(), CompletionRequest{Model: "anthropic.claude-3-haiku", Prompt: "hi"})
+		var me *MalformedResponseError
+		if !errors.As(err, &me) || KindOf(err) != KindUpstream5xx {
+			t.Errorf("%s: expected upstream_5xx malformed response, got %v", name, err)
+		}
+		if cost != 0 {
+			t.Errorf("%s: expected no cost for a failed call, got %v", name, cost)
+		}
+		if stub.invoked != 2 {
+			t.Errorf("%s: expected the retry to engage, got %d calls", name, stub.invoked)
+		}
+	}
+}
+
+func TestBedrockToolsRoundTrip(t *testing.T) {
+	weather := Tool{Name: "get_weather", Description: "current weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}
+	stub := &stubBedrock{reply: []types.ContentBlock{
+		&types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+			ToolUseId: strPtr("tooluse_1"),
+			Name:      strPtr("get_weather"),
+			Input:     document.NewLazyDocument(map[string]any{"city": "Paris"}),
+		}},
+	}}
+	p := stubProvider(stub, BedrockOptions{})
+	resp, _, _, err := p.Complete(context.Background(), CompletionRequest{
+		Model: "anthropic.claude-3-haiku", Prompt: "weather in Paris?", Tools: []Tool{weather}, ToolChoice: "get_weather",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "tooluse_1" || resp.ToolCalls[0].Name != "get_weather" {
+		t.Fatalf("expected the tool_use block as a tool call, got %+v", resp.ToolCalls)
+	}
+	if string(resp.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+		t.Errorf("expected arguments as JSON, got %s", resp.ToolCalls[0].Arguments)
+	}
+
+	cfg := stub.converse[0].ToolConfig
+	if cfg == nil || len(cfg.Tools) != 1 {
+		t.Fatalf("expected one tool sent to Converse, got %+v", cfg)
+	}
+	if spec := cfg.Tools[0].(*types.ToolMemberToolSpec).Value; *spec.Name != "get_weather" || *spec.Description != "current weather" {
+		t.Errorf("expected the tool spec passed through, got %+v", spec)
+	}
+	if choice, ok := cfg.ToolChoice.(*types.ToolChoiceMemberTool); !ok || *choice.Value.Name != "get_weather" {
+		t.Errorf("expected a named tool choice, got %+v", cfg.ToolChoice)
+	}
+
+	invoke := &stubBedrock{invokeBody: []byte(`{"content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],"usage":{"input_tokens":10,"output_tokens":5}}`)}
+	resp, _, _, err = stubProvider(invoke, BedrockOptions{API: BedrockInvokeModel}).Complete(context.Background(), CompletionRequest{
+		Model: "anthropic.claude-3-haiku", Prompt: "weather in Paris?", Tools: []Tool{weather},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" || string(resp.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+		t.Errorf("expected the InvokeModel tool_use block as a tool call, got %+v", resp.ToolCalls)
+	}
+}
+
+func TestBedrockTitanEmbed(t *testing.T) {
+	stub := &stubBedrock{invokeBody: []byte(`{"embedding":[0.1,0.2,0.3],"inputTextTokenCount":500}`)}
+	p := stubProvider(stub, BedrockOptions{})
+	resp, cost, _, err := p.Embed(context.Background(), []string{"a", "b"}, "")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if stub.invoked != 2 || len(resp.Vectors) != 2 || len(resp.Vectors[0]) != 3 {
+		t.Fatalf("expected one Titan call and vector per text, got %d calls, %v", stub.invoked, resp.Vectors)
+	}
+	if resp.Tokens != 1000 || resp.Model != titanEmbedV2 {
+		t.Errorf("expected the reported token counts summed by %s, got %d by %q", titanEmbedV2, resp.Tokens, resp.Model)
+	}
+	// 1000 tokens at the Titan v2 price
+	if want := 0.00002; cost < want*0.999 || cost > want*1.001 {
+		t.Errorf("expected cost %v, got %v", want, cost)
+	}
+	if !p.ServesEmbeddingModel(titanEmbedV1) || p.ServesEmbeddingModel("cohere.embed-english-v3") {
+		t.Error("expected only the Titan models to be served")
+	}
+}
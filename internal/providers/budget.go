@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrAttemptBudgetExhausted is returned when a request's attempt budget ran out before
+// this provider was called at all
+var ErrAttemptBudgetExhausted = errors.New("attempt budget exhausted")
+
+// AttemptBudget caps upstream calls for one request across every provider it tries, so
+// retries and failover together can't multiply latency and cost
+type AttemptBudget struct {
+	max  int32
+	used atomic.Int32
+
+	mu     sync.Mutex
+	failed []string
+}
+
+// NewAttemptBudget allows up to max upstream calls; max <= 0 is unlimited
+func NewAttemptBudget(max int) *AttemptBudget {
+	return &AttemptBudget{max: int32(max)}
+}
+
+// take claims one attempt, reporting false once the budget is spent
+func (b *AttemptBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	for {
+		n := b.used.Load()
+		if b.max > 0 && n >= b.max {
+			return false
+		}
+		if b.used.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+// Used returns how many upstream calls have been made against the budget
+func (b *AttemptBudget) Used() int {
+	if b == nil {
+		return 0
+	}
+	return int(b.used.Load())
+}
+
+// recordFailure notes that an attempt on provider failed
+func (b *AttemptBudget) recordFailure(provider string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !slices.Contains(b.failed, provider) {
+		b.failed = append(b.failed, provider)
+	}
+}
+
+// FailedProviders returns the providers that failed at least one attempt, in the
+// order they first failed
+func (b *AttemptBudget) FailedProviders() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.failed)
+}
+
+type attemptBudgetKey struct{}
+
+// WithAttemptBudget attaches b to ctx; ResilientProvider calls made with the returned
+// context draw every attempt, retries included, from it
+func WithAttemptBudget(ctx context.Context, b *AttemptBudget) context.Context {
+	return context.WithValue(ctx, attemptBudgetKey{}, b)
+}
+
+func attemptBudgetFrom(ctx context.Context) *AttemptBudget {
+	b, _ := ctx.Value(attemptBudgetKey{}).(*AttemptBudget)
+	return b
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// EmbeddingResponse holds one vector per input text, in input order
+type EmbeddingResponse struct {
+	Vectors [][]float64
+	// Model is the embedding model that produced the vectors
+	Model string
+	// Tokens is the input the provider reports billing, 0 when it doesn't say
+	Tokens int
+}
+
+// Embedder is optionally implemented by providers with an embeddings API. Embeddings
+// are priced per input token apart from completions, usually far below them, so they
+// carry their own list price.
+type Embedder interface {
+	// ServesEmbeddingModel reports whether the provider embeds with model, "" being
+	// its default embedding model
+	ServesEmbeddingModel(model string) bool
+	// EmbeddingCostPer1kTokensUSD is the list price of embedding 1k input tokens with
+	// model, the provider's default embedding model for ""
+	EmbeddingCostPer1kTokensUSD(model string) float64
+	Embed(ctx context.Context, texts []string, model string) (resp EmbeddingResponse, costUSD float64, latencyMs int64, err error)
+}
+
+// ErrEmbeddingsUnsupported is returned without calling a provider that doesn't serve
+// the embedding model
+var ErrEmbeddingsUnsupported = errors.New("provider does not serve the embedding model")
+
+// embeddingsUnsupported wraps ErrEmbeddingsUnsupported with the provider and model
+func embeddingsUnsupported(provider, model string) error {
+	return fmt.Errorf("%s %q: %w", provider, model, ErrEmbeddingsUnsupported)
+}
+
+// embeddingTokens estimates the input tokens of texts at about four characters per
+// token, as quotaTokens does for prompts
+func embeddingTokens(texts []string) int {
+	chars := 0
+	for _, t := range texts {
+		chars += len(t)
+	}
+	return chars / 4
+}
+
+// embeddingCost prices tokens at per1k, estimating them from texts when the provider
+// didn't report any
+func embeddingCost(per1k float64, tokens int, texts []string) float64 {
+	if tokens == 0 {
+		tokens = embeddingTokens(texts)
+	}
+	return per1k / 1000.0 * float64(tokens)
+}
+
+// ServesEmbeddingModel reports whether the wrapped provider has an embeddings API that
+// serves model, "" being its default
+func (rp *ResilientProvider) ServesEmbeddingModel(model string) bool {
+	e, ok := rp.inner.(Embedder)
+	return ok && e.ServesEmbeddingModel(model)
+}
+
+// EmbeddingCostPer1kTokensUSD is the wrapped provider's embedding list price, 0 when
+// it doesn't serve model
+func (rp *ResilientProvider) EmbeddingCostPer1kTokensUSD(model string) float64 {
+	if !rp.ServesEmbeddingModel(model) {
+		return 0
+	}
+	return rp.inner.(Embedder).EmbeddingCostPer1kTokensUSD(model)
+}
+
+// Embed embeds texts with the same circuit breaker, quota, timeouts and retries as
+// Complete. Outcomes stay out of Stats, whose latencies steer completion routing and
+// would read fast from quick embedding calls.
+func (rp *ResilientProvider) Embed(ctx context.Context, texts []string, model string) (EmbeddingResponse, float64, int64, error) {
+	if !rp.ServesEmbeddingModel(model) {
+		return EmbeddingResponse{}, 0, 0, embeddingsUnsupported(rp.Name(), model)
+	}
+	e := rp.inner.(Embedder)
+	var resp EmbeddingResponse
+	cost, lat, err := rp.call(ctx, embeddingTokens(texts), nil, func(ctx context.Context) (cost float64, err error) {
+		resp, cost, _, err = e.Embed(ctx, texts, model)
+		return cost, err
+	})
+	if err != nil {
+		return EmbeddingResponse{}, 0, lat, err
+	}
+	return resp, cost, lat, nil
+}
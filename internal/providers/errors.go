@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorKind classifies a provider failure for metrics and logs
+type ErrorKind string
+
+const (
+	KindTimeout     ErrorKind = "timeout"
+	KindCancelled   ErrorKind = "cancelled"
+	KindRateLimited ErrorKind = "rate_limited"
+	KindUpstream5xx ErrorKind = "upstream_5xx"
+	KindUpstream4xx ErrorKind = "upstream_4xx"
+	KindCircuitOpen ErrorKind = "circuit_open"
+	KindQuota       ErrorKind = "quota_exhausted"
+	KindNetwork     ErrorKind = "network"
+	KindUnknown     ErrorKind = "unknown"
+)
+
+// ErrorKinds lists every ErrorKind, as accepted in CB_FAILURE_KINDS
+var ErrorKinds = []ErrorKind{KindTimeout, KindCancelled, KindRateLimited, KindUpstream5xx, KindUpstream4xx, KindCircuitOpen, KindQuota, KindNetwork, KindUnknown}
+
+// ErrCircuitOpen is returned without calling the provider while its breaker is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// StatusError is a non-2xx HTTP response from a provider
+type StatusError struct {
+	Provider   string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s status %d", e.Provider, e.StatusCode)
+}
+
+// MalformedResponseError is a 2xx response whose body is unparseable or carries no
+// completion. It counts as an upstream failure so retries and the breaker engage.
+type MalformedResponseError struct {
+	Provider string
+	Reason   string
+}
+
+func (e *MalformedResponseError) Error() string {
+	return e.Provider + " malformed response: " + e.Reason
+}
+
+// checkCompletion rejects a parsed completion with neither visible text nor tool calls
+func checkCompletion(provider string, resp CompletionResponse) error {
+	if strings.TrimSpace(resp.Text) == "" && len(resp.ToolCalls) == 0 {
+		return &MalformedResponseError{Provider: provider, Reason: "empty completion"}
+	}
+	return nil
+}
+
+// KindOf classifies err; nil yields ""
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return KindCircuitOpen
+	case errors.Is(err, ErrQuotaExhausted):
+		return KindQuota
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	case errors.Is(err, context.Canceled):
+		return KindCancelled
+	}
+
+	var me *MalformedResponseError
+	if errors.As(err, &me) {
+		return KindUpstream5xx
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch {
+		case se.StatusCode == http.StatusTooManyRequests:
+			return KindRateLimited
+		case se.StatusCode >= 500:
+			return KindUpstream5xx
+		case se.StatusCode >= 400:
+			return KindUpstream4xx
+		}
+	}
+
+	// AWS SDK API errors expose their code without importing smithy here
+	var coded interface{ ErrorCode() string }
+	if errors.As(err, &coded) {
+		code := coded.ErrorCode()
+		switch {
+		case strings.Contains(code, "Throttling") || strings.Contains(code, "TooManyRequests"):
+			return KindRateLimited
+		case strings.Contains(code, "Timeout"):
+			return KindTimeout
+		case strings.Contains(code, "ServiceUnavailable") || strings.Contains(code, "InternalServer"):
+			return KindUpstream5xx
+		case strings.Contains(code, "Validation") || strings.Contains(code, "AccessDenied"):
+			return KindUpstream4xx
+		}
+	}
+
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return KindTimeout
+	}
+	// refused or reset connections and failed lookups never reached the model
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return KindNetwork
+	}
+	return KindUnknown
+}
@@ -3,8 +3,11 @@ package providers
 import (
 	"context"
 	"errors"
+	"hash/fnv"
 	"math"
 	"math/rand"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -14,6 +17,33 @@ type MockProvider struct {
 	p95Ms     float64
 	errorRate float64
 	costPer1k float64
+	// embedCostPer1k prices embeddings, which the mock always supports
+	embedCostPer1k float64
+
+	mu       sync.Mutex
+	rng      *rand.Rand // nil uses the global source
+	failNext int
+	hang     bool
+	warmups  int
+
+	defaultModel string
+}
+
+// MockOptions configures NewMockProviderWithOptions
+type MockOptions struct {
+	Name      string // defaults to "mock"
+	MeanMs    float64
+	P95Ms     float64
+	ErrorRate float64
+	CostPer1k float64
+	// Seed makes latency and error sampling reproducible when non-zero
+	Seed int64
+	// Hang blocks every call until its context is cancelled
+	Hang bool
+	// DefaultModel is reported for requests without a model
+	DefaultModel string
+	// EmbeddingCostPer1k is the price of 1k embedded input tokens; 0 keeps CostPer1k
+	EmbeddingCostPer1k float64
 }
 
 func NewMockProvider(meanMs, p95Ms float64, errorRate float64, costPer1k float64) *MockProvider {
@@ -23,11 +53,78 @@ func NewMockProvider(meanMs, p95Ms float64, errorRate float64, costPer1k float64
 		p95Ms:     p95Ms,
 		errorRate: errorRate,
 		costPer1k: costPer1k,
+		// as dear as its completions, so real embedding providers win
+		embedCostPer1k: costPer1k,
+	}
+}
+
+// NewMockProviderWithOptions creates a mock with deterministic controls for resilience tests
+func NewMockProviderWithOptions(opts MockOptions) *MockProvider {
+	m := NewMockProvider(opts.MeanMs, opts.P95Ms, opts.ErrorRate, opts.CostPer1k)
+	if opts.Name != "" {
+		m.name = opts.Name
 	}
+	if opts.Seed != 0 {
+		m.rng = rand.New(rand.NewSource(opts.Seed))
+	}
+	m.hang = opts.Hang
+	m.defaultModel = opts.DefaultModel
+	if opts.EmbeddingCostPer1k > 0 {
+		m.embedCostPer1k = opts.EmbeddingCostPer1k
+	}
+	return m
 }
 
 func (m *MockProvider) Name() string                            { return m.name }
 func (m *MockProvider) CostPer1kTokensUSD(model string) float64 { return m.costPer1k }
+func (m *MockProvider) DefaultModel() string                    { return m.defaultModel }
+
+// FailNext makes the next n calls fail regardless of the configured error rate
+func (m *MockProvider) FailNext(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failNext = n
+}
+
+// SetHang toggles hang mode, where calls block until their context is cancelled
+func (m *MockProvider) SetHang(hang bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hang = hang
+}
+
+// Warmup only counts calls; the mock has no connections to prime
+func (m *MockProvider) Warmup(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.warmups++
+	return nil
+}
+
+// Warmups returns how many times Warmup was called
+func (m *MockProvider) Warmups() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.warmups
+}
+
+func (m *MockProvider) normFloat64() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rng != nil {
+		return m.rng.NormFloat64()
+	}
+	return rand.NormFloat64()
+}
+
+func (m *MockProvider) float64() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rng != nil {
+		return m.rng.Float64()
+	}
+	return rand.Float64()
+}
 
 // sampleLatency samples from a lognormal distribution configured to approximate given mean and p95
 func (m *MockProvider) sampleLatency() time.Duration {
@@ -54,7 +151,7 @@ func (m *MockProvider) sampleLatency() time.Duration {
 	sigma := (lo + hi) / 2
 	mu := math.Log(mean) - sigma*sigma/2
 	// sample
-	n := rand.NormFloat64()
+	n := m.normFloat64()
 	x := math.Exp(mu + sigma*n)
 	// clamp to [0, 3*p95] to avoid extreme outliers
 	if x < 0 {
@@ -67,6 +164,36 @@ func (m *MockProvider) sampleLatency() time.Duration {
 }
 
 func (m *MockProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, float64, int64, error) {
+	lat, err := m.simulate(ctx)
+	if err != nil {
+		return CompletionResponse{}, 0, lat, err
+	}
+	// cost estimation using request MaxTok or default 50
+	toks := req.MaxTok
+	if toks <= 0 {
+		toks = 50
+	}
+	cost := m.costPer1k * float64(toks) / 1000.0
+	return CompletionResponse{Text: "(mock) hello"}, cost, lat, nil
+}
+
+// simulate waits out a sampled latency, or until ctx is done in hang mode, and then
+// fails at the configured error rate or when a failure was queued with FailNext
+func (m *MockProvider) simulate(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	hang := m.hang
+	forceFail := m.failNext > 0
+	if forceFail {
+		m.failNext--
+	}
+	m.mu.Unlock()
+
+	if hang {
+		t0 := time.Now()
+		<-ctx.Done()
+		return time.Since(t0).Milliseconds(), ctx.Err()
+	}
+
 	d := m.sampleLatency()
 	t := time.NewTimer(d)
 	select {
@@ -74,18 +201,61 @@ func (m *MockProvider) Complete(ctx context.Context, req CompletionRequest) (Com
 		if !t.Stop() {
 			<-t.C
 		}
-		return CompletionResponse{}, 0, 0, ctx.Err()
+		return 0, ctx.Err()
 	case <-t.C:
 	}
 	// decide error
-	if rand.Float64() < m.errorRate {
-		return CompletionResponse{}, 0, int64(d / time.Millisecond), errors.New("mock error")
+	if forceFail || m.float64() < m.errorRate {
+		return int64(d / time.Millisecond), errors.New("mock error")
 	}
-	// cost estimation using request MaxTok or default 50
-	toks := req.MaxTok
-	if toks <= 0 {
-		toks = 50
+	return int64(d / time.Millisecond), nil
+}
+
+// mockEmbeddingDims is the length of the mock's vectors
+const mockEmbeddingDims = 8
+
+// mockEmbeddingModel is reported for requests without an embedding model
+const mockEmbeddingModel = "mock-embedding"
+
+// ServesEmbeddingModel is true for any model, which the mock stands in for
+func (m *MockProvider) ServesEmbeddingModel(model string) bool { return true }
+
+func (m *MockProvider) EmbeddingCostPer1kTokensUSD(model string) float64 { return m.embedCostPer1k }
+
+// Embed returns a vector per text seeded from the text, so equal texts embed equally,
+// reporting and pricing estimated input tokens
+func (m *MockProvider) Embed(ctx context.Context, texts []string, model string) (EmbeddingResponse, float64, int64, error) {
+	lat, err := m.simulate(ctx)
+	if err != nil {
+		return EmbeddingResponse{}, 0, lat, err
 	}
-	cost := m.costPer1k * float64(toks) / 1000.0
-	return CompletionResponse{Text: "(mock) hello"}, cost, int64(d / time.Millisecond), nil
+	if model == "" {
+		model = mockEmbeddingModel
+	}
+	out := EmbeddingResponse{Vectors: make([][]float64, len(texts)), Model: model, Tokens: embeddingTokens(texts)}
+	for i, text := range texts {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(text))
+		rng := rand.New(rand.NewSource(int64(h.Sum64())))
+		v := make([]float64, mockEmbeddingDims)
+		for j := range v {
+			v[j] = rng.Float64()*2 - 1
+		}
+		out.Vectors[i] = v
+	}
+	return out, embeddingCost(m.embedCostPer1k, out.Tokens, texts), lat, nil
+}
+
+// CompleteStream completes like Complete and then hands the text out word by word
+func (m *MockProvider) CompleteStream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (CompletionResponse, float64, int64, error) {
+	resp, cost, lat, err := m.Complete(ctx, req)
+	if err != nil {
+		return resp, cost, lat, err
+	}
+	for _, chunk := range strings.SplitAfter(resp.Text, " ") {
+		if err := onDelta(chunk); err != nil {
+			return CompletionResponse{}, 0, lat, err
+		}
+	}
+	return resp, cost, lat, nil
 }
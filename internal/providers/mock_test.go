@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -35,3 +37,91 @@ func TestMockLatencyDistribution(t *testing.T) {
 		t.Fatalf("p95 out of expected range: %.2f", p95)
 	}
 }
+
+func TestMockSeedIsReproducible(t *testing.T) {
+	outcomes := func() []bool {
+		mp := NewMockProviderWithOptions(MockOptions{MeanMs: 1, P95Ms: 1, ErrorRate: 0.5, Seed: 42})
+		var errs []bool
+		for i := 0; i < 20; i++ {
+			_, _, _, err := mp.Complete(context.Background(), CompletionRequest{})
+			errs = append(errs, err != nil)
+		}
+		return errs
+	}
+	a, b := outcomes(), outcomes()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("call %d differs between runs with the same seed", i)
+		}
+	}
+}
+
+func TestMockHangHonoursContext(t *testing.T) {
+	mp := NewMockProviderWithOptions(MockOptions{MeanMs: 1, P95Ms: 1, Hang: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err := mp.Complete(ctx, CompletionRequest{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("hang returned before the deadline")
+	}
+}
+
+func TestCircuitBreakerLifecycleWithMock(t *testing.T) {
+	mp := NewMockProviderWithOptions(MockOptions{MeanMs: 1, P95Ms: 1, Seed: 1})
+	rp := WithResilience(mp, ResilienceOptions{
+		Timeout:      50 * time.Millisecond,
+		CBWindowSize: 4,
+		CBCooldown:   30 * time.Millisecond,
+	})
+	call := func() error {
+		_, _, _, err := rp.Complete(context.Background(), CompletionRequest{})
+		return err
+	}
+
+	// closed -> open after a burst of correlated failures
+	mp.FailNext(4)
+	for i := 0; i < 4; i++ {
+		if err := call(); err == nil {
+			t.Fatalf("call %d: expected scheduled failure", i)
+		}
+	}
+	if got := rp.CBStateValue(); got != 0 {
+		t.Fatalf("expected open breaker, got state %v", got)
+	}
+	if err := call(); err == nil || err.Error() != "circuit open" {
+		t.Fatalf("expected circuit open rejection, got %v", err)
+	}
+
+	// open -> half-open: the probe hangs past its timeout and the breaker reopens
+	time.Sleep(40 * time.Millisecond)
+	mp.SetHang(true)
+	done := make(chan error, 1)
+	go func() { done <- call() }()
+	deadline := time.Now().Add(time.Second)
+	for rp.CBStateValue() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("breaker never entered half-open")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected hung probe to time out, got %v", err)
+	}
+	if got := rp.CBStateValue(); got != 0 {
+		t.Fatalf("expected breaker to reopen after failed probe, got state %v", got)
+	}
+
+	// half-open -> closed once a probe succeeds
+	mp.SetHang(false)
+	time.Sleep(40 * time.Millisecond)
+	if err := call(); err != nil {
+		t.Fatalf("expected successful probe, got %v", err)
+	}
+	if got := rp.CBStateValue(); got != 2 {
+		t.Fatalf("expected closed breaker, got state %v", got)
+	}
+}
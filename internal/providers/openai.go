@@ -5,75 +5,222 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
 type OpenAIProvider struct {
+	name    string
 	apiKey  string
-	baseURL string
+	org     string
+	baseURL string // API base, e.g. https://api.openai.com/v1
 	client  *http.Client
 	// simple pricing map USD per 1k tokens, can be extended per model
-	pricePer1k map[string]float64
+	pricePer1k    map[string]float64
+	fallbackPrice float64
+	// fallbackConfigured is set when fallbackPrice was given as pricing["default"]
+	fallbackConfigured bool
+	defaultModel       string
+
+	// USD per 1k input tokens by embedding model; nil when the endpoint has no embeddings
+	embedPricePer1k    map[string]float64
+	embedFallbackPrice float64
+	// embedAnyModel is set by an embedding_pricing["default"], serving unlisted models
+	embedAnyModel bool
 }
 
-func NewOpenAIProvider(apiKey string) *OpenAIProvider {
-	return &OpenAIProvider{
-		apiKey:  apiKey,
-		baseURL: "https://api.openai.com/v1/chat/completions",
-		client:  &http.Client{Timeout: 60 * time.Second},
-		pricePer1k: map[string]float64{
+// OpenAIOptions configures an OpenAI-style provider
+type OpenAIOptions struct {
+	Name    string // defaults to "openai"
+	APIKey  string // may be empty for self-hosted endpoints
+	BaseURL string // API base, e.g. https://api.openai.com/v1; empty uses api.openai.com
+	Org     string // sent as the OpenAI-Organization header when set
+	// Pricing is USD per 1k tokens by model, with Pricing["default"] for models not
+	// listed. Nil uses OpenAI's list prices.
+	Pricing map[string]float64
+	// EmbeddingPricing is USD per 1k input tokens by embedding model; only listed models
+	// are routed to the endpoint unless EmbeddingPricing["default"] prices the rest. Nil
+	// uses OpenAI's list prices when Pricing is nil too; otherwise the endpoint isn't
+	// routed embeddings.
+	EmbeddingPricing map[string]float64
+}
+
+// NewOpenAIProviderWithOptions creates an OpenAI-style provider registered under
+// opts.Name, so several endpoints or keys can be routed between as distinct providers
+func NewOpenAIProviderWithOptions(opts OpenAIOptions) *OpenAIProvider {
+	if opts.Name == "" {
+		opts.Name = "openai"
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = defaultOpenAIBaseURL
+	}
+	p := &OpenAIProvider{
+		name:    opts.Name,
+		apiKey:  opts.APIKey,
+		org:     opts.Org,
+		baseURL: strings.TrimSuffix(opts.BaseURL, "/"),
+		client:  &http.Client{Timeout: 60 * time.Second, Transport: newTracingTransport(opts.Name, sharedTransport())},
+	}
+	switch {
+	case opts.EmbeddingPricing != nil:
+		p.embedPricePer1k = make(map[string]float64, len(opts.EmbeddingPricing))
+		for k, v := range opts.EmbeddingPricing {
+			p.embedPricePer1k[k] = v
+		}
+		p.embedFallbackPrice, p.embedAnyModel = p.embedPricePer1k["default"]
+	case opts.Pricing == nil:
+		p.embedPricePer1k = map[string]float64{
+			"text-embedding-3-small": 0.00002,
+			"text-embedding-3-large": 0.00013,
+			"text-embedding-ada-002": 0.0001,
+		}
+	}
+	if opts.Pricing == nil {
+		p.pricePer1k = map[string]float64{
 			"gpt-4o":      5.00,
 			"gpt-4o-mini": 0.60,
 			"gpt-4.1":     10.00,
-		},
+		}
+		p.fallbackPrice = 10.0
+		return p
+	}
+	p.pricePer1k = make(map[string]float64, len(opts.Pricing))
+	for k, v := range opts.Pricing {
+		p.pricePer1k[k] = v
+	}
+	p.fallbackPrice, p.fallbackConfigured = p.pricePer1k["default"]
+	return p
+}
+
+// NewOpenAIProvider creates the OpenAI provider. baseURL overrides the API base for
+// proxied deployments (empty uses api.openai.com); org is sent as the
+// OpenAI-Organization header when set. The key always goes out as a Bearer token.
+func NewOpenAIProvider(apiKey, baseURL, org string) *OpenAIProvider {
+	return NewOpenAIProviderWithOptions(OpenAIOptions{APIKey: apiKey, BaseURL: baseURL, Org: org})
+}
+
+// NewOpenAICompatibleProvider registers a self-hosted endpoint speaking the OpenAI chat
+// schema (vLLM, Ollama, LM Studio) as its own named provider. baseURL is the API base
+// (e.g. http://localhost:11434/v1); apiKey may be empty. Models missing from pricing
+// use pricing["default"], or zero when unset.
+func NewOpenAICompatibleProvider(name, baseURL, apiKey string, pricing map[string]float64) *OpenAIProvider {
+	if pricing == nil {
+		pricing = map[string]float64{}
 	}
+	return NewOpenAIProviderWithOptions(OpenAIOptions{Name: name, APIKey: apiKey, BaseURL: baseURL, Pricing: pricing})
 }
 
-func (p *OpenAIProvider) Name() string { return "openai" }
+func (p *OpenAIProvider) Name() string { return p.name }
+
+// SetDefaultModel sets the model used for requests that don't name one
+func (p *OpenAIProvider) SetDefaultModel(model string) { p.defaultModel = model }
 
+func (p *OpenAIProvider) DefaultModel() string { return p.defaultModel }
+
+// CostPer1kTokensUSD is the model's list price, the default model's for "", or the
+// fallback price for models without one
 func (p *OpenAIProvider) CostPer1kTokensUSD(model string) float64 {
+	if model == "" {
+		model = p.defaultModel
+	}
 	if v, ok := p.pricePer1k[model]; ok {
 		return v
 	}
-	return 10.0 // fallback
+	return p.fallbackPrice
+}
+
+// KnowsPrice is false when CostPer1kTokensUSD(model) would be the fallback price,
+// unless the fallback was configured as pricing["default"]
+func (p *OpenAIProvider) KnowsPrice(model string) bool {
+	if model == "" {
+		model = p.defaultModel
+	}
+	_, ok := p.pricePer1k[model]
+	return ok || p.fallbackConfigured
 }
 
 type openaiReq struct {
-	Model    string      `json:"model"`
-	Messages []oaMessage `json:"messages"`
-	MaxTok   int         `json:"max_tokens,omitempty"`
-	Stream   bool        `json:"stream,omitempty"`
+	Model      string      `json:"model"`
+	Messages   []oaMessage `json:"messages"`
+	MaxTok     int         `json:"max_tokens,omitempty"`
+	Stream     bool        `json:"stream,omitempty"`
+	Tools      []oaTool    `json:"tools,omitempty"`
+	ToolChoice any         `json:"tool_choice,omitempty"`
 }
 type oaMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
 }
+type oaTool struct {
+	Type     string     `json:"type"`
+	Function oaFunction `json:"function"`
+}
+type oaFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+type oaToolCall struct {
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON encoded as a string
+	} `json:"function"`
+}
 type openaiResp struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string       `json:"content"`
+			ToolCalls []oaToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
 }
 
+// SupportsTools is true: tools are sent as OpenAI functions
+func (p *OpenAIProvider) SupportsTools() bool { return true }
+
+// openaiToolChoice maps a tool choice onto tool_choice, naming a function for anything
+// other than auto, none or required
+func openaiToolChoice(choice string) any {
+	switch choice {
+	case "":
+		return nil
+	case ToolChoiceAuto, ToolChoiceNone, ToolChoiceRequired:
+		return choice
+	}
+	return map[string]any{"type": "function", "function": map[string]string{"name": choice}}
+}
+
 func (p *OpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, float64, int64, error) {
-	body := openaiReq{
-		Model: req.Model,
-		Messages: []oaMessage{
-			{Role: "user", Content: req.Prompt},
-		},
+	body := openaiReq{Model: req.Model}
+	for _, m := range req.ChatMessages() {
+		body.Messages = append(body.Messages, oaMessage{Role: m.Role, Content: m.Content})
 	}
 	if req.MaxTok > 0 {
 		body.MaxTok = req.MaxTok
 	}
+	for _, t := range req.Tools {
+		body.Tools = append(body.Tools, oaTool{Type: "function", Function: oaFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters}})
+	}
+	if len(body.Tools) > 0 {
+		body.ToolChoice = openaiToolChoice(req.ToolChoice)
+	}
 
 	b, _ := json.Marshal(body)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL, bytes.NewReader(b))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(b))
 	if err != nil {
 		return CompletionResponse{}, 0, 0, err
 	}
-	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if p.org != "" {
+		httpReq.Header.Set("OpenAI-Organization", p.org)
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	t0 := time.Now()
@@ -83,19 +230,49 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req CompletionRequest) (C
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return CompletionResponse{}, 0, 0, fmt.Errorf("openai status %d", resp.StatusCode)
+		return CompletionResponse{}, 0, 0, &StatusError{Provider: p.name, StatusCode: resp.StatusCode}
 	}
 	var or openaiResp
 	if err := json.NewDecoder(resp.Body).Decode(&or); err != nil {
-		return CompletionResponse{}, 0, 0, err
+		return CompletionResponse{}, 0, 0, &MalformedResponseError{Provider: p.name, Reason: err.Error()}
+	}
+	if len(or.Choices) == 0 {
+		return CompletionResponse{}, 0, 0, &MalformedResponseError{Provider: p.name, Reason: "no choices"}
 	}
-	text := ""
-	if len(or.Choices) > 0 {
-		text = or.Choices[0].Message.Content
+	msg := or.Choices[0].Message
+	out := CompletionResponse{Text: msg.Content}
+	for _, tc := range msg.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, ToolCall{ID: tc.ID, Name: tc.Function.Name, Arguments: toolArguments(tc.Function.Arguments)})
+	}
+	if err := checkCompletion(p.name, out); err != nil {
+		return CompletionResponse{}, 0, 0, err
 	}
 	lat := time.Since(t0).Milliseconds()
 	// We don't precisely know token count here; use list price per 1k as rough estimate for policy purposes
-	return CompletionResponse{Text: text}, p.CostPer1kTokensUSD(req.Model) / 1000.0 * float64(max(req.MaxTok, 50)), lat, nil
+	return out, p.CostPer1kTokensUSD(req.Model) / 1000.0 * float64(max(req.MaxTok, 50)), lat, nil
+}
+
+// Warmup lists models to open a keep-alive connection (DNS, TCP, TLS) ahead of the first
+// completion. Any HTTP response counts as primed; only transport errors are returned.
+func (p *OpenAIProvider) Warmup(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if p.org != "" {
+		httpReq.Header.Set("OpenAI-Organization", p.org)
+	}
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain so the connection goes back to the pool
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
 }
 
 func max(a, b int) int {
@@ -104,3 +281,90 @@ func max(a, b int) int {
 	}
 	return b
 }
+
+// defaultOpenAIEmbeddingModel is embedded with when a request names no model
+const defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+
+// ServesEmbeddingModel is true for models with an embedding price, and for any model
+// when embedding pricing has a "default"
+func (p *OpenAIProvider) ServesEmbeddingModel(model string) bool {
+	if p.embedPricePer1k == nil {
+		return false
+	}
+	if model == "" {
+		model = defaultOpenAIEmbeddingModel
+	}
+	_, listed := p.embedPricePer1k[model]
+	return listed || p.embedAnyModel
+}
+
+// EmbeddingCostPer1kTokensUSD is the embedding model's price, or the "default" one for
+// models without one
+func (p *OpenAIProvider) EmbeddingCostPer1kTokensUSD(model string) float64 {
+	if model == "" {
+		model = defaultOpenAIEmbeddingModel
+	}
+	if v, ok := p.embedPricePer1k[model]; ok {
+		return v
+	}
+	return p.embedFallbackPrice
+}
+
+type openaiEmbedReq struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+type openaiEmbedResp struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// Embed calls /embeddings and prices the call from the reported prompt tokens
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string, model string) (EmbeddingResponse, float64, int64, error) {
+	if model == "" {
+		model = defaultOpenAIEmbeddingModel
+	}
+	b, _ := json.Marshal(openaiEmbedReq{Model: model, Input: texts})
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/embeddings", bytes.NewReader(b))
+	if err != nil {
+		return EmbeddingResponse{}, 0, 0, err
+	}
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	if p.org != "" {
+		httpReq.Header.Set("OpenAI-Organization", p.org)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	t0 := time.Now()
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return EmbeddingResponse{}, 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return EmbeddingResponse{}, 0, 0, &StatusError{Provider: p.name, StatusCode: resp.StatusCode}
+	}
+	var or openaiEmbedResp
+	if err := json.NewDecoder(resp.Body).Decode(&or); err != nil {
+		return EmbeddingResponse{}, 0, 0, &MalformedResponseError{Provider: p.name, Reason: err.Error()}
+	}
+	if len(or.Data) != len(texts) {
+		return EmbeddingResponse{}, 0, 0, &MalformedResponseError{Provider: p.name, Reason: fmt.Sprintf("%d embeddings for %d inputs", len(or.Data), len(texts))}
+	}
+	out := EmbeddingResponse{Vectors: make([][]float64, len(texts)), Model: model, Tokens: or.Usage.PromptTokens}
+	for _, d := range or.Data {
+		if d.Index < 0 || d.Index >= len(texts) || out.Vectors[d.Index] != nil {
+			return EmbeddingResponse{}, 0, 0, &MalformedResponseError{Provider: p.name, Reason: fmt.Sprintf("bad embedding index %d", d.Index)}
+		}
+		out.Vectors[d.Index] = d.Embedding
+	}
+	lat := time.Since(t0).Milliseconds()
+	return out, embeddingCost(p.EmbeddingCostPer1kTokensUSD(model), out.Tokens, texts), lat, nil
+}
@@ -0,0 +1,211 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var gotPath, gotAuth, gotModel string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"hello from llama"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAICompatibleProvider("ollama", srv.URL+"/v1/", "", map[string]float64{"llama3": 0.05})
+	if p.Name() != "ollama" {
+		t.Errorf("expected name ollama, got %s", p.Name())
+	}
+	if got := p.CostPer1kTokensUSD("llama3"); got != 0.05 {
+		t.Errorf("expected llama3 price 0.05, got %v", got)
+	}
+	if got := p.CostPer1kTokensUSD("unknown"); got != 0 {
+		t.Errorf("expected zero fallback price without default, got %v", got)
+	}
+	if !p.KnowsPrice("llama3") || p.KnowsPrice("unknown") {
+		t.Error("expected only llama3's price to be known without a default")
+	}
+	// no model means the provider's default model, priced as if it were named
+	if got := p.CostPer1kTokensUSD(""); got != 0 || p.KnowsPrice("") {
+		t.Errorf("expected no price for \"\" without a default model, got %v", got)
+	}
+	p.SetDefaultModel("llama3")
+	if got := p.CostPer1kTokensUSD(""); got != 0.05 || !p.KnowsPrice("") {
+		t.Errorf("expected \"\" priced as the default model llama3, got %v", got)
+	}
+
+	out, _, _, err := p.Complete(context.Background(), CompletionRequest{Model: "llama3", Prompt: "ping"})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if out.Text != "hello from llama" {
+		t.Errorf("unexpected text %q", out.Text)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("expected /v1/chat/completions, got %s", gotPath)
+	}
+	if gotAuth != "" {
+		t.Errorf("expected no Authorization header without api key, got %q", gotAuth)
+	}
+	if gotModel != "llama3" {
+		t.Errorf("expected model llama3 forwarded, got %s", gotModel)
+	}
+
+	priced := NewOpenAICompatibleProvider("vllm", srv.URL, "k", map[string]float64{"default": 0.1})
+	if got := priced.CostPer1kTokensUSD("anything"); got != 0.1 {
+		t.Errorf("expected default price 0.1, got %v", got)
+	}
+	if !priced.KnowsPrice("anything") {
+		t.Error("expected a configured default to count as a known price")
+	}
+}
+
+func TestOpenAIProviderBaseURLAndOrg(t *testing.T) {
+	var gotPath, gotOrg, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotOrg = r.Header.Get("OpenAI-Organization")
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("sk-test", srv.URL+"/proxy/v1", "org-123")
+	if _, _, _, err := p.Complete(context.Background(), CompletionRequest{Model: "gpt-4o", Prompt: "ping"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if gotPath != "/proxy/v1/chat/completions" {
+		t.Errorf("expected configured base URL to be used, got path %s", gotPath)
+	}
+	if gotOrg != "org-123" {
+		t.Errorf("expected OpenAI-Organization org-123, got %q", gotOrg)
+	}
+	if gotAuth != "Bearer sk-test" {
+		t.Errorf("expected bearer auth, got %q", gotAuth)
+	}
+
+	// an empty base URL keeps the public API
+	def := NewOpenAIProvider("sk-test", "", "")
+	if def.baseURL != defaultOpenAIBaseURL {
+		t.Errorf("expected default base URL, got %s", def.baseURL)
+	}
+}
+
+func TestOpenAIProviderPreservesMessageRoles(t *testing.T) {
+	var got []oaMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openaiReq
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got = body.Messages
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("sk-test", srv.URL, "")
+	req := CompletionRequest{
+		Model: "gpt-4o",
+		Messages: []Message{
+			{Role: "system", Content: "be terse"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+		Prompt: "how are you?",
+	}
+	if _, _, _, err := p.Complete(context.Background(), req); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	want := []oaMessage{
+		{Role: "system", Content: "be terse"},
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: "hello"},
+		{Role: "user", Content: "how are you?"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestOpenAIProviderToolsRoundTrip(t *testing.T) {
+	var got struct {
+		Tools      []oaTool        `json:"tools"`
+		ToolChoice json.RawMessage `json:"tool_choice"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("sk-test", srv.URL, "")
+	out, _, _, err := p.Complete(context.Background(), CompletionRequest{
+		Model:      "gpt-4o",
+		Prompt:     "weather in Paris?",
+		Tools:      []Tool{{Name: "get_weather", Description: "current weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}},
+		ToolChoice: "get_weather",
+	})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Type != "function" || got.Tools[0].Function.Name != "get_weather" || got.Tools[0].Function.Description != "current weather" {
+		t.Errorf("expected the tool sent as a function, got %+v", got.Tools)
+	}
+	if string(got.ToolChoice) != `{"function":{"name":"get_weather"},"type":"function"}` {
+		t.Errorf("expected a named function tool_choice, got %s", got.ToolChoice)
+	}
+	if len(out.ToolCalls) != 1 || out.ToolCalls[0].ID != "call_1" || out.ToolCalls[0].Name != "get_weather" {
+		t.Fatalf("expected the tool call parsed, got %+v", out.ToolCalls)
+	}
+	if string(out.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+		t.Errorf("expected arguments as a JSON object, got %s", out.ToolCalls[0].Arguments)
+	}
+}
+
+func TestOpenAIMalformedResponsesFail(t *testing.T) {
+	for name, body := range map[string]string{
+		"empty choices":  `{"choices":[]}`,
+		"malformed body": `{"choices":[{"message":`,
+		"blank content":  `{"choices":[{"message":{"content":"  "}}]}`,
+	} {
+		calls := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}))
+		rp := WithResilience(NewOpenAICompatibleProvider("vllm", srv.URL, "", map[string]float64{"default": 0.1}), ResilienceOptions{MaxRetries: 1, CBWindowSize: 20})
+		_, cost, _, err := rp.Complete(context.Background(), CompletionRequest{Model: "llama3", Prompt: "ping"})
+		srv.Close()
+		if KindOf(err) != KindUpstream5xx {
+			t.Errorf("%s: expected upstream_5xx, got %v (%v)", name, KindOf(err), err)
+		}
+		if cost != 0 {
+			t.Errorf("%s: expected no cost for a failed call, got %v", name, cost)
+		}
+		if calls != 2 {
+			t.Errorf("%s: expected the retry to engage, got %d calls", name, calls)
+		}
+		if rp.Stats().ErrorRate() != 1 {
+			t.Errorf("%s: expected both attempts recorded as errors, got error rate %v", name, rp.Stats().ErrorRate())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOpenAIProviderPropagatesTraceContext(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	}()
+
+	var gotTraceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"hi"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("test-key", srv.URL, "")
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "infer")
+	if _, _, _, err := p.Complete(ctx, CompletionRequest{Model: "gpt-4o", Prompt: "ping"}); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	parent.End()
+
+	if gotTraceparent == "" {
+		t.Fatal("expected traceparent header on outbound request")
+	}
+
+	var child sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		if s.Parent().SpanID() == parent.SpanContext().SpanID() {
+			child = s
+		}
+	}
+	if child == nil {
+		t.Fatal("expected a child span for the provider call")
+	}
+	if child.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("child span not in parent trace")
+	}
+	var status int64
+	for _, kv := range child.Attributes() {
+		if kv.Key == "http.status_code" {
+			status = kv.Value.AsInt64()
+		}
+	}
+	if status != http.StatusOK {
+		t.Errorf("expected http.status_code 200 on span, got %d", status)
+	}
+}
+
+func TestOpenAIEmbed(t *testing.T) {
+	var gotPath, gotModel string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+		w.Header().Set("Content-Type", "application/json")
+		// out of order, as the API allows
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0.3,0.4]},{"index":0,"embedding":[0.1,0.2]}],"usage":{"prompt_tokens":2000}}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider("test-key", srv.URL, "")
+	resp, cost, _, err := p.Embed(context.Background(), []string{"first", "second"}, "")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if gotPath != "/embeddings" || gotModel != defaultOpenAIEmbeddingModel {
+		t.Errorf("expected %s on /embeddings, got %q on %q", defaultOpenAIEmbeddingModel, gotModel, gotPath)
+	}
+	if len(resp.Vectors) != 2 || resp.Vectors[0][0] != 0.1 || resp.Vectors[1][0] != 0.3 {
+		t.Errorf("expected vectors in input order, got %v", resp.Vectors)
+	}
+	// 2000 tokens of text-embedding-3-small
+	if want := 0.00004; cost < want*0.999 || cost > want*1.001 {
+		t.Errorf("expected cost %v, got %v", want, cost)
+	}
+
+	priced := NewOpenAIProviderWithOptions(OpenAIOptions{Name: "compat", BaseURL: srv.URL, Pricing: map[string]float64{"default": 1}})
+	if priced.ServesEmbeddingModel("") {
+		t.Error("expected an endpoint with its own pricing but no embedding pricing to have no embeddings")
+	}
+	if resp.Model != defaultOpenAIEmbeddingModel || p.ServesEmbeddingModel("amazon.titan-embed-text-v2:0") {
+		t.Errorf("expected only listed models served and the default reported, got %q", resp.Model)
+	}
+	compat := NewOpenAIProviderWithOptions(OpenAIOptions{Name: "compat", BaseURL: srv.URL, EmbeddingPricing: map[string]float64{"default": 0.00001}})
+	if !compat.ServesEmbeddingModel("nomic-embed-text") {
+		t.Error("expected a default embedding price to serve any model")
+	}
+}
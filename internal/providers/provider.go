@@ -2,35 +2,85 @@ package providers
 
 import (
 	"context"
-	"errors"
 	"math"
 	"math/rand"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Message is a single chat turn; Role is system, developer, user or assistant
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
 // CompletionRequest represents a text completion request
 type CompletionRequest struct {
-	Model  string
-	Prompt string
-	MaxTok int
-	Stream bool
+	Model string
+	// Prompt is shorthand for a trailing user message
+	Prompt   string
+	Messages []Message
+	MaxTok   int
+	Stream   bool
+	// Tools the model may call, for providers implementing ToolCaller
+	Tools      []Tool
+	ToolChoice string // ToolChoiceAuto (default), ToolChoiceNone, ToolChoiceRequired or a tool name
+}
+
+// ChatMessages returns the conversation to send: Messages followed by Prompt as a user turn
+func (r CompletionRequest) ChatMessages() []Message {
+	msgs := make([]Message, 0, len(r.Messages)+1)
+	msgs = append(msgs, r.Messages...)
+	if r.Prompt != "" {
+		msgs = append(msgs, Message{Role: "user", Content: r.Prompt})
+	}
+	return msgs
 }
 
 // CompletionResponse represents a text completion response
 type CompletionResponse struct {
 	Text string
+	// ToolCalls the model made instead of, or alongside, Text
+	ToolCalls []ToolCall
 }
 
 // Provider is the interface implemented by all LLM providers
 type Provider interface {
 	Name() string
-	// CostPer1kTokensUSD returns the nominal list price used by policies like Cheapest
+	// CostPer1kTokensUSD returns the nominal list price used by policies like Cheapest;
+	// "" prices the provider's default model, as a request without a model is sent it
 	CostPer1kTokensUSD(model string) float64
 	Complete(ctx context.Context, req CompletionRequest) (resp CompletionResponse, costUSD float64, latencyMs int64, err error)
 }
 
+// Warmer is optionally implemented by providers that can prime their connection pool
+// with a cheap call before serving traffic
+type Warmer interface {
+	Warmup(ctx context.Context) error
+}
+
+// Streamer is optionally implemented by providers that can return text incrementally.
+// onDelta is called with each chunk in order; an error from it aborts the call
+type Streamer interface {
+	CompleteStream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (resp CompletionResponse, costUSD float64, latencyMs int64, err error)
+}
+
+// DefaultModeler is optionally implemented by providers that know which model to use
+// when a request doesn't name one
+type DefaultModeler interface {
+	DefaultModel() string
+}
+
+// PriceKnower is optionally implemented by providers whose CostPer1kTokensUSD falls
+// back to a placeholder price for models missing from their pricing. KnowsPrice is
+// false when the price for model is that placeholder.
+type PriceKnower interface {
+	KnowsPrice(model string) bool
+}
+
 // ---- Resilience and Metrics Wrappers ----
 
 // Outcome holds a single call result
@@ -101,15 +151,86 @@ func (s *Stats) P95LatencyMs() int64 {
 	return int64(vals[idx])
 }
 
+// SuccessCount returns how many successful calls are in the window, i.e. how many
+// samples back the p95
+func (s *Stats) SuccessCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, o := range s.outcomes {
+		if !o.Err {
+			n++
+		}
+	}
+	return n
+}
+
+// RecentP95LatencyMs is P95LatencyMs with each sample weighted by 0.5^(age/halfLife),
+// so a past latency spike fades instead of pinning the p95 until it leaves the window.
+// halfLife <= 0 weights all samples equally.
+func (s *Stats) RecentP95LatencyMs(halfLife time.Duration) int64 {
+	if halfLife <= 0 {
+		return s.P95LatencyMs()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	type sample struct {
+		ms     int64
+		weight float64
+	}
+	now := time.Now()
+	var vals []sample
+	var total float64
+	for _, o := range s.outcomes {
+		if o.Err {
+			continue
+		}
+		w := math.Exp2(-float64(now.Sub(o.At)) / float64(halfLife))
+		vals = append(vals, sample{o.LatencyMs, w})
+		total += w
+	}
+	if len(vals) == 0 {
+		return 0
+	}
+	sort.Slice(vals, func(i, j int) bool { return vals[i].ms < vals[j].ms })
+	var cum float64
+	for _, v := range vals {
+		cum += v.weight
+		if cum >= 0.95*total {
+			return v.ms
+		}
+	}
+	return vals[len(vals)-1].ms
+}
+
 // ErrorRateSince computes error rate over outcomes within the last d duration
 func (s *Stats) ErrorRateSince(d time.Duration) float64 {
+	total, errs := s.CountsSince(d)
+	if total == 0 {
+		return 0
+	}
+	return float64(errs) / float64(total)
+}
+
+// LatenciesSince returns the latencies of successful calls within the last d duration
+func (s *Stats) LatenciesSince(d time.Duration) []int64 {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if len(s.outcomes) == 0 {
-		return 0
+	cutoff := time.Now().Add(-d)
+	var vals []int64
+	for _, o := range s.outcomes {
+		if !o.Err && o.At.After(cutoff) {
+			vals = append(vals, o.LatencyMs)
+		}
 	}
+	return vals
+}
+
+// CountsSince returns the number of outcomes and errors within the last d duration
+func (s *Stats) CountsSince(d time.Duration) (total, errs int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	cutoff := time.Now().Add(-d)
-	var total, errs int
 	for _, o := range s.outcomes {
 		if o.At.After(cutoff) {
 			total++
@@ -118,10 +239,7 @@ func (s *Stats) ErrorRateSince(d time.Duration) float64 {
 			}
 		}
 	}
-	if total == 0 {
-		return 0
-	}
-	return float64(errs) / float64(total)
+	return total, errs
 }
 
 // CBStateValue exposes 0=open,1=half,2=closed
@@ -146,6 +264,10 @@ type CircuitBreaker struct {
 	openedAt      time.Time
 	cooldown      time.Duration
 	halfOpenProbe bool
+	// forced holds the breaker open, with no half-open probes, until Reset
+	forced bool
+	// onChange hears the breaker opening (true) or closing (false), outside the lock
+	onChange func(open bool)
 }
 
 func NewCircuitBreaker(windowSize int, cooldown time.Duration) *CircuitBreaker {
@@ -158,6 +280,9 @@ func (cb *CircuitBreaker) Allow() bool {
 	if !cb.open {
 		return true
 	}
+	if cb.forced {
+		return false
+	}
 	// if open and cooldown passed, allow a half-open probe
 	if time.Since(cb.openedAt) >= cb.cooldown {
 		if !cb.halfOpenProbe {
@@ -168,9 +293,82 @@ func (cb *CircuitBreaker) Allow() bool {
 	return false
 }
 
-func (cb *CircuitBreaker) OnResult(err bool) {
+// ForceOpen opens the breaker regardless of the error rate and keeps it open,
+// skipping half-open probes, until Reset
+func (cb *CircuitBreaker) ForceOpen() {
+	cb.mu.Lock()
+	wasOpen := cb.open
+	cb.open, cb.forced = true, true
+	cb.halfOpenProbe = false
+	cb.openedAt = time.Now()
+	onChange := cb.onChange
+	cb.mu.Unlock()
+	if onChange != nil && !wasOpen {
+		onChange(true)
+	}
+}
+
+// Reset closes the breaker and forgets recent results, ending a forced open
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	wasOpen := cb.open
+	cb.open, cb.forced = false, false
+	cb.halfOpenProbe = false
+	cb.window = nil
+	onChange := cb.onChange
+	cb.mu.Unlock()
+	if onChange != nil && wasOpen {
+		onChange(false)
+	}
+}
+
+// RetryIn is how long until the breaker lets a call through again: 0 when closed or
+// when its cooldown has ended and a half-open probe is due, and -1 while forced open,
+// which only Reset ends
+func (cb *CircuitBreaker) RetryIn() time.Duration {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
+	switch {
+	case !cb.open:
+		return 0
+	case cb.forced:
+		return -1
+	}
+	if left := cb.cooldown - time.Since(cb.openedAt); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// ForcedOpen reports whether the breaker is held open by ForceOpen
+func (cb *CircuitBreaker) ForcedOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.forced
+}
+
+// OnCancel frees a half-open probe whose caller gave up before a result, so the
+// next caller can probe instead of the breaker staying open indefinitely
+func (cb *CircuitBreaker) OnCancel() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.open {
+		cb.halfOpenProbe = false
+	}
+}
+
+func (cb *CircuitBreaker) OnResult(err bool) {
+	cb.mu.Lock()
+	wasOpen := cb.open
+	cb.recordLocked(err)
+	open, onChange := cb.open, cb.onChange
+	cb.mu.Unlock()
+	if onChange != nil && open != wasOpen {
+		onChange(open)
+	}
+}
+
+func (cb *CircuitBreaker) recordLocked(err bool) {
 	cb.window = append(cb.window, err)
 	if len(cb.window) > cb.windowSize {
 		cb.window = cb.window[len(cb.window)-cb.windowSize:]
@@ -214,8 +412,33 @@ type ResilienceOptions struct {
 	JitterFrac   float64 // 0..1 of backoff
 	CBWindowSize int
 	CBCooldown   time.Duration
+	// OnCircuitChange, when set, is called as the provider's breaker opens or closes
+	OnCircuitChange func(provider string, open bool)
+	// OnRetry, when set, is called as Complete starts each attempt after the first
+	OnRetry func(provider string)
+	// RPM and TPM cap outbound requests and tokens per minute, mirroring the account's
+	// upstream limits so calls are paced instead of answered with 429s; 0 is unlimited
+	RPM int
+	TPM int
+	// QuotaMaxWait is how long a call may queue for RPM/TPM room before failing with
+	// ErrQuotaExhausted; 0 fails at once
+	QuotaMaxWait time.Duration
+	// OnQuota, when set, hears the share of the RPM and TPM buckets in use after each
+	// call takes or is refused room
+	OnQuota func(provider string, requests, tokens float64)
+	// CBFailureKinds are the errors that count toward opening the breaker; others,
+	// such as a 400 for a bad prompt, leave it alone. nil uses DefaultCBFailureKinds
+	CBFailureKinds []ErrorKind
 }
 
+// DefaultCBFailureKinds trip the breaker on the provider misbehaving: 5xx and
+// malformed responses, timeouts, unreachable endpoints, and errors nothing classifies.
+// 4xx answers, 429s and client cancellations don't count. Unknown stays in because
+// it's what a provider failing in a way KindOf doesn't recognise (an unmapped AWS
+// error code, the mock's injected failures) looks like; leaving it out would keep
+// such a provider in rotation, while tripping only diverts traffic for CBCooldown.
+var DefaultCBFailureKinds = []ErrorKind{KindUpstream5xx, KindTimeout, KindNetwork, KindUnknown}
+
 // ResilientProvider wraps a provider with timeout, retry, and circuit breaker, while recording stats
 type ResilientProvider struct {
 	inner Provider
@@ -223,80 +446,370 @@ type ResilientProvider struct {
 
 	stats *Stats
 	cb    *CircuitBreaker
+	// quota is nil without RPM or TPM limits
+	quota *quota
+
+	// disabled pulls the provider out of rotation without touching the breaker
+	disabled atomic.Bool
 }
 
 func WithResilience(p Provider, opts ResilienceOptions) *ResilientProvider {
 	stats := NewStats(100)
 	cb := NewCircuitBreaker(opts.CBWindowSize, opts.CBCooldown)
-	return &ResilientProvider{inner: p, opts: opts, stats: stats, cb: cb}
+	if opts.OnCircuitChange != nil {
+		cb.onChange = func(open bool) { opts.OnCircuitChange(p.Name(), open) }
+	}
+	return &ResilientProvider{inner: p, opts: opts, stats: stats, cb: cb, quota: newQuota(opts.RPM, opts.TPM, opts.QuotaMaxWait)}
 }
 
+// AdoptState takes over old's stats and circuit breaker, so a rebuilt provider keeps
+// its latency history and any open breaker. The breaker keeps old's window and
+// cooldown. Call it before rp serves traffic.
+func (rp *ResilientProvider) AdoptState(old *ResilientProvider) {
+	rp.stats = old.stats
+	rp.cb = old.cb
+}
+
+// SetEnabled adds or removes the provider from routing rotation
+func (rp *ResilientProvider) SetEnabled(enabled bool) { rp.disabled.Store(!enabled) }
+
+// Enabled reports whether the provider is eligible for routing
+func (rp *ResilientProvider) Enabled() bool { return !rp.disabled.Load() }
+
 func (rp *ResilientProvider) Name() string { return rp.inner.Name() }
 func (rp *ResilientProvider) CostPer1kTokensUSD(model string) float64 {
 	return rp.inner.CostPer1kTokensUSD(model)
 }
 
+// PriceKnown reports whether CostPer1kTokensUSD(model) is a real list price rather
+// than a placeholder; providers that don't say are taken at their word
+func (rp *ResilientProvider) PriceKnown(model string) bool {
+	if k, ok := rp.inner.(PriceKnower); ok {
+		return k.KnowsPrice(model)
+	}
+	return true
+}
+
+// Warmup primes the wrapped provider's connections if it supports it; the result is not
+// recorded in stats or the circuit breaker
+func (rp *ResilientProvider) Warmup(ctx context.Context) error {
+	if w, ok := rp.inner.(Warmer); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// Probe makes one call to the wrapped provider under the configured timeout, ignoring
+// the circuit breaker. Like Warmup, the result is not recorded in stats or the breaker.
+func (rp *ResilientProvider) Probe(ctx context.Context, req CompletionRequest) (int64, error) {
+	if rp.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rp.opts.Timeout)
+		defer cancel()
+	}
+	t0 := time.Now()
+	_, _, _, err := rp.inner.Complete(ctx, req)
+	return time.Since(t0).Milliseconds(), err
+}
+
+// SupportsTools reports whether the wrapped provider passes tools through
+func (rp *ResilientProvider) SupportsTools() bool {
+	t, ok := rp.inner.(ToolCaller)
+	return ok && t.SupportsTools()
+}
+
+// DefaultModel returns the wrapped provider's default model, or "" if it has none
+func (rp *ResilientProvider) DefaultModel() string {
+	if d, ok := rp.inner.(DefaultModeler); ok {
+		return d.DefaultModel()
+	}
+	return ""
+}
+
 func (rp *ResilientProvider) Stats() *Stats { return rp.stats }
 
 // CBStateValue returns 0=open,1=half,2=closed for the inner circuit breaker
 func (rp *ResilientProvider) CBStateValue() float64 { return rp.cb.StateValue() }
 
+// ForceOpenCB trips the provider's breaker until ResetCB, taking it out of routing
+func (rp *ResilientProvider) ForceOpenCB() { rp.cb.ForceOpen() }
+
+// ResetCB closes the provider's breaker, ending a forced open
+func (rp *ResilientProvider) ResetCB() { rp.cb.Reset() }
+
+// CBForcedOpen reports whether an operator holds the provider's breaker open
+func (rp *ResilientProvider) CBForcedOpen() bool { return rp.cb.ForcedOpen() }
+
+// Available reports whether a call routed to the provider could reach it now: it is
+// enabled and its breaker is closed or due a half-open probe
+func (rp *ResilientProvider) Available() bool { return rp.Enabled() && rp.cb.RetryIn() == 0 }
+
+// RetryIn is how long until the provider's breaker admits a call, -1 while forced open
+func (rp *ResilientProvider) RetryIn() time.Duration { return rp.cb.RetryIn() }
+
+// QuotaSaturated reports whether the provider's RPM/TPM quota has no room for another
+// call right now, so routing can prefer a provider that does
+func (rp *ResilientProvider) QuotaSaturated() bool { return rp.quota.saturated() }
+
+// QuotaSaturation returns the share of the RPM and TPM quotas in use, 0..1
+func (rp *ResilientProvider) QuotaSaturation() (requests, tokens float64) {
+	return rp.quota.saturation()
+}
+
+// acquireQuota waits for room under the provider's RPM/TPM quota, if it has one
+func (rp *ResilientProvider) acquireQuota(ctx context.Context, tokens int) error {
+	if rp.quota == nil {
+		return nil
+	}
+	err := rp.quota.acquire(ctx, tokens)
+	if rp.opts.OnQuota != nil {
+		requests, tokens := rp.quota.saturation()
+		rp.opts.OnQuota(rp.inner.Name(), requests, tokens)
+	}
+	return err
+}
+
+// randomJitter spreads d by +/- frac, clamping frac to 1 so the sleep never goes negative
 func randomJitter(d time.Duration, frac float64) time.Duration {
-	if frac <= 0 {
+	if frac <= 0 || d <= 0 {
 		return d
 	}
+	if frac > 1 {
+		frac = 1
+	}
 	f := (rand.Float64()*2 - 1) * frac // +/- frac
-	return time.Duration(float64(d) * (1 + f))
+	j := time.Duration(float64(d) * (1 + f))
+	if j <= 0 {
+		return d
+	}
+	return j
+}
+
+// maxBackoffShift keeps BaseBackoff << shift from overflowing for any sane base
+const maxBackoffShift = 30
+
+// backoff returns the sleep before the retry following attempt (1-based):
+// exponential from BaseBackoff, capped at MaxBackoff, then jittered
+func (rp *ResilientProvider) backoff(attempt int) time.Duration {
+	shift := attempt - 1
+	if shift < 0 {
+		shift = 0
+	}
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	d := rp.opts.BaseBackoff << uint(shift)
+	if d < 0 || d>>uint(shift) != rp.opts.BaseBackoff {
+		d = time.Duration(math.MaxInt64) // overflowed; the cap below brings it back
+	}
+	if rp.opts.MaxBackoff > 0 && d > rp.opts.MaxBackoff {
+		d = rp.opts.MaxBackoff
+	}
+	return randomJitter(d, rp.opts.JitterFrac)
 }
 
+// retryFits reports whether ctx's deadline leaves room for a retry after sleeping
+// wait: at least the provider's p95 latency. Without a deadline or a p95 to go on
+// there is always room.
+func (rp *ResilientProvider) retryFits(ctx context.Context, wait time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	p95 := rp.stats.P95LatencyMs()
+	if p95 <= 0 {
+		return true
+	}
+	return time.Until(deadline)-wait >= time.Duration(p95)*time.Millisecond
+}
+
+// Complete calls the inner provider with retries. The returned latency is time
+// spent in provider calls only (summed across attempts), never backoff sleeps,
+// so latency metrics reflect the upstream rather than our retry policy. Each attempt
+// draws on the context's AttemptBudget, if any; once it is spent the last error is
+// returned without further retries. Nor is a retry made when the context's deadline
+// is closer than the backoff plus the provider's p95. Each attempt also waits for
+// room under the RPM/TPM quota, failing with ErrQuotaExhausted when there is none.
 func (rp *ResilientProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, float64, int64, error) {
+	// the request is wrong for this provider, not the provider unhealthy
+	if len(req.Tools) > 0 && !rp.SupportsTools() {
+		return CompletionResponse{}, 0, 0, toolsUnsupported(rp.Name())
+	}
+	var resp CompletionResponse
+	cost, lat, err := rp.call(ctx, quotaTokens(req), rp.stats, func(ctx context.Context) (cost float64, err error) {
+		resp, cost, _, err = rp.inner.Complete(ctx, req)
+		return cost, err
+	})
+	if err != nil {
+		return CompletionResponse{}, 0, lat, err
+	}
+	return resp, cost, lat, nil
+}
+
+// call runs attempt under the circuit breaker, quota, attempt budget, per-call timeout
+// and retries. Outcomes are recorded in stats unless it is nil. The latency is the
+// successful attempt's, or all the upstream time spent when every attempt failed.
+func (rp *ResilientProvider) call(ctx context.Context, tokens int, stats *Stats, attempt func(ctx context.Context) (float64, error)) (float64, int64, error) {
 	// circuit breaker gate
 	if !rp.cb.Allow() {
-		return CompletionResponse{}, 0, 0, errors.New("circuit open")
+		return 0, 0, ErrCircuitOpen
 	}
 
-	var attempt int
+	var n int
 	var lastErr error
-	start := time.Now()
-	defer func() {
-		// No-op here; actual latency recorded on success or final failure below
-		_ = start
-	}()
+	var upstream time.Duration
+	budget := attemptBudgetFrom(ctx)
 
 	for {
-		attempt++
+		n++
+		// the caller went away; don't spend another attempt on a response nobody reads
+		if err := ctx.Err(); err != nil {
+			rp.cb.OnCancel()
+			return 0, upstream.Milliseconds(), err
+		}
+		// a full quota says nothing about the provider's health: no stats, no breaker
+		if err := rp.acquireQuota(ctx, tokens); err != nil {
+			rp.cb.OnCancel()
+			if lastErr == nil || ctx.Err() != nil {
+				lastErr = err
+			}
+			return 0, upstream.Milliseconds(), lastErr
+		}
+		if !budget.take() {
+			rp.cb.OnCancel()
+			if lastErr == nil {
+				lastErr = ErrAttemptBudgetExhausted
+			}
+			return 0, upstream.Milliseconds(), lastErr
+		}
+		if n > 1 && rp.opts.OnRetry != nil {
+			rp.opts.OnRetry(rp.inner.Name())
+		}
 		callCtx := ctx
 		cancel := func() {}
-		if rp.opts.Timeout > 0 {
-			callCtx, cancel = context.WithTimeout(ctx, rp.opts.Timeout)
+		if timeout := rp.callTimeout(ctx); timeout > 0 {
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
 		}
 		t0 := time.Now()
-		resp, cost, _, err := rp.inner.Complete(callCtx, req)
+		cost, err := attempt(callCtx)
 		cancel()
-		lat := time.Since(t0).Milliseconds()
+		callTime := time.Since(t0)
+		upstream += callTime
+		lat := callTime.Milliseconds()
 
 		if err == nil {
-			rp.stats.Record(lat, false)
+			if stats != nil {
+				stats.Record(lat, false)
+			}
 			rp.cb.OnResult(false)
-			return resp, cost, lat, nil
+			return cost, lat, nil
 		}
 
 		lastErr = err
-		rp.stats.Record(lat, true)
-		rp.cb.OnResult(true)
+		if ctx.Err() != nil {
+			// cancelled by the caller, not a provider failure: keep it out of stats and the breaker
+			rp.cb.OnCancel()
+			return 0, upstream.Milliseconds(), ctx.Err()
+		}
+		if stats != nil {
+			stats.Record(lat, true)
+		}
+		rp.breakerFailure(err)
+		budget.recordFailure(rp.Name())
 
-		if attempt > rp.opts.MaxRetries {
+		if n > rp.opts.MaxRetries {
 			break
 		}
 		// exponential backoff with jitter
-		backoff := rp.opts.BaseBackoff * time.Duration(1<<uint(attempt-1))
-		if rp.opts.MaxBackoff > 0 && backoff > rp.opts.MaxBackoff {
-			backoff = rp.opts.MaxBackoff
+		wait := rp.backoff(n)
+		if !rp.retryFits(ctx, wait) {
+			// a retry would only run into the caller's deadline; fail over now instead
+			break
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return 0, upstream.Milliseconds(), ctx.Err()
+		case <-t.C:
 		}
-		time.Sleep(randomJitter(backoff, rp.opts.JitterFrac))
 	}
 
-	// failed after retries; estimate latency as elapsed
-	lat := time.Since(start).Milliseconds()
-	return CompletionResponse{}, 0, lat, lastErr
+	return 0, upstream.Milliseconds(), lastErr
+}
+
+// breakerFailure records a failed call with the breaker when err is one of the kinds
+// it counts; any other error only frees a half-open probe, as a cancellation does
+func (rp *ResilientProvider) breakerFailure(err error) {
+	kinds := rp.opts.CBFailureKinds
+	if kinds == nil {
+		kinds = DefaultCBFailureKinds
+	}
+	if slices.Contains(kinds, KindOf(err)) {
+		rp.cb.OnResult(true)
+		return
+	}
+	rp.cb.OnCancel()
+}
+
+// CompleteStream delivers the completion through onDelta. A streaming provider gets a
+// single attempt, since retrying after text went out would repeat it; other providers,
+// and requests with tools, go through Complete and the whole text is sent as one delta.
+func (rp *ResilientProvider) CompleteStream(ctx context.Context, req CompletionRequest, onDelta func(string) error) (CompletionResponse, float64, int64, error) {
+	s, ok := rp.inner.(Streamer)
+	if !ok || len(req.Tools) > 0 {
+		req.Stream = false
+		resp, cost, lat, err := rp.Complete(ctx, req)
+		if err == nil && resp.Text != "" {
+			err = onDelta(resp.Text)
+		}
+		return resp, cost, lat, err
+	}
+
+	if !rp.cb.Allow() {
+		return CompletionResponse{}, 0, 0, ErrCircuitOpen
+	}
+	if err := rp.acquireQuota(ctx, quotaTokens(req)); err != nil {
+		rp.cb.OnCancel()
+		return CompletionResponse{}, 0, 0, err
+	}
+	budget := attemptBudgetFrom(ctx)
+	if !budget.take() {
+		rp.cb.OnCancel()
+		return CompletionResponse{}, 0, 0, ErrAttemptBudgetExhausted
+	}
+	callCtx := ctx
+	cancel := func() {}
+	if timeout := rp.callTimeout(ctx); timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+	t0 := time.Now()
+	// the caller stopping the stream, e.g. at a usage limit, isn't the provider failing
+	var stopped error
+	resp, cost, _, err := s.CompleteStream(callCtx, req, func(delta string) error {
+		if err := onDelta(delta); err != nil {
+			stopped = err
+			return err
+		}
+		return nil
+	})
+	lat := time.Since(t0).Milliseconds()
+
+	switch {
+	case err == nil:
+		rp.stats.Record(lat, false)
+		rp.cb.OnResult(false)
+	case ctx.Err() != nil:
+		rp.cb.OnCancel()
+		return CompletionResponse{}, 0, lat, ctx.Err()
+	case stopped != nil:
+		rp.cb.OnCancel()
+		return resp, cost, lat, stopped
+	default:
+		rp.stats.Record(lat, true)
+		rp.breakerFailure(err)
+		budget.recordFailure(rp.Name())
+	}
+	return resp, cost, lat, err
 }
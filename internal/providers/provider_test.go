@@ -0,0 +1,444 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCompleteStopsRetryingOnCancel(t *testing.T) {
+	mock := NewMockProviderWithOptions(MockOptions{ErrorRate: 1, Seed: 1})
+	rp := WithResilience(mock, ResilienceOptions{
+		MaxRetries:   5,
+		BaseBackoff:  10 * time.Second, // would sleep for minutes if cancellation were ignored
+		MaxBackoff:   time.Minute,
+		CBWindowSize: 100,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, _, _, err := rp.Complete(ctx, CompletionRequest{Prompt: "ping"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected prompt return after cancel, took %v", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// only the first attempt ran before cancellation
+	if total, _ := rp.Stats().CountsSince(time.Hour); total != 1 {
+		t.Errorf("expected 1 recorded attempt, got %d", total)
+	}
+}
+
+func TestCancelledCallIsNotAProviderFailure(t *testing.T) {
+	mock := NewMockProviderWithOptions(MockOptions{Hang: true})
+	rp := WithResilience(mock, ResilienceOptions{MaxRetries: 2, CBWindowSize: 1, CBCooldown: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := rp.Complete(ctx, CompletionRequest{Prompt: "ping"}); err == nil {
+		t.Fatal("expected error from hung call")
+	}
+	if total, _ := rp.Stats().CountsSince(time.Hour); total != 0 {
+		t.Errorf("caller cancellation should not be recorded, got %d outcomes", total)
+	}
+	if rp.CBStateValue() != 2 {
+		t.Errorf("caller cancellation should not trip the breaker, state %v", rp.CBStateValue())
+	}
+}
+
+func TestCancelledHalfOpenProbeFreesSlot(t *testing.T) {
+	cb := NewCircuitBreaker(1, 0)
+	cb.OnResult(true) // open with zero cooldown
+	if !cb.Allow() {
+		t.Fatal("expected half-open probe to be allowed")
+	}
+	if cb.Allow() {
+		t.Fatal("only one probe at a time")
+	}
+	cb.OnCancel()
+	if !cb.Allow() {
+		t.Error("expected a new probe after the previous one was cancelled")
+	}
+}
+
+func TestForcedOpenSkipsHalfOpenProbe(t *testing.T) {
+	var got []bool
+	cb := NewCircuitBreaker(10, 0)
+	cb.onChange = func(open bool) { got = append(got, open) }
+
+	cb.ForceOpen()
+	if cb.Allow() || cb.StateValue() != 0 || !cb.ForcedOpen() {
+		t.Fatalf("expected a forced breaker to stay open past its cooldown, state %v", cb.StateValue())
+	}
+	cb.OnResult(false) // a call already in flight succeeding must not close it
+	if cb.Allow() {
+		t.Fatal("expected the breaker to stay forced open")
+	}
+
+	cb.Reset()
+	if !cb.Allow() || cb.StateValue() != 2 || cb.ForcedOpen() {
+		t.Fatalf("expected reset to close the breaker, state %v", cb.StateValue())
+	}
+	if fmt.Sprint(got) != "[true false]" {
+		t.Errorf("expected open then close callbacks, got %v", got)
+	}
+}
+
+func TestCircuitChangeCallback(t *testing.T) {
+	var got []string
+	rp := WithResilience(NewMockProviderWithOptions(MockOptions{Name: "flaky"}), ResilienceOptions{
+		CBWindowSize: 2,
+		OnCircuitChange: func(provider string, open bool) {
+			got = append(got, fmt.Sprintf("%s:%v", provider, open))
+		},
+	})
+	rp.cb.OnResult(true)
+	rp.cb.OnResult(true) // trips
+	rp.cb.Allow()
+	rp.cb.OnResult(true) // failed probe keeps it open, no second event
+	rp.cb.Allow()
+	rp.cb.OnResult(false) // recovers
+	if want := []string{"flaky:true", "flaky:false"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// statusProvider answers every call with an HTTP status error
+type statusProvider struct{ code int }
+
+func (p statusProvider) Name() string                        { return "status" }
+func (p statusProvider) CostPer1kTokensUSD(_ string) float64 { return 0.001 }
+func (p statusProvider) Complete(context.Context, CompletionRequest) (CompletionResponse, float64, int64, error) {
+	return CompletionResponse{}, 0, 1, &StatusError{Provider: "status", StatusCode: p.code}
+}
+
+func TestBreakerCountsOnlyProviderFailures(t *testing.T) {
+	ctx := context.Background()
+	badRequest := WithResilience(statusProvider{code: 400}, ResilienceOptions{CBWindowSize: 4, CBCooldown: time.Minute})
+	for i := 0; i < 10; i++ {
+		badRequest.Complete(ctx, CompletionRequest{Prompt: "ping"})
+	}
+	if badRequest.CBStateValue() != 2 {
+		t.Fatal("expected 400s to leave the breaker closed")
+	}
+	if _, _, _, err := badRequest.Complete(ctx, CompletionRequest{Prompt: "ping"}); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("expected calls to keep reaching a provider answering 400")
+	}
+
+	unavailable := WithResilience(statusProvider{code: 503}, ResilienceOptions{CBWindowSize: 4, CBCooldown: time.Minute})
+	for i := 0; i < 4; i++ {
+		unavailable.Complete(ctx, CompletionRequest{Prompt: "ping"})
+	}
+	if _, _, _, err := unavailable.Complete(ctx, CompletionRequest{Prompt: "ping"}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected 503s to trip the breaker, got %v", err)
+	}
+
+	// the set is configurable: counting 4xx trips on them too
+	strict := WithResilience(statusProvider{code: 400}, ResilienceOptions{CBWindowSize: 4, CBCooldown: time.Minute, CBFailureKinds: []ErrorKind{KindUpstream4xx}})
+	for i := 0; i < 4; i++ {
+		strict.Complete(ctx, CompletionRequest{Prompt: "ping"})
+	}
+	if strict.CBStateValue() == 2 {
+		t.Error("expected configured 4xx failures to trip the breaker")
+	}
+}
+
+func TestBackoffOverflowSafe(t *testing.T) {
+	rp := WithResilience(NewMockProvider(0, 0, 0, 0), ResilienceOptions{BaseBackoff: time.Second})
+	for _, attempt := range []int{0, 1, 31, 40, 64, 100, 1 << 20} {
+		if d := rp.backoff(attempt); d < time.Second {
+			t.Errorf("attempt %d: expected a growing positive backoff, got %v", attempt, d)
+		}
+	}
+	if d := rp.backoff(2); d != 2*time.Second {
+		t.Errorf("expected 2s on the second attempt, got %v", d)
+	}
+}
+
+func TestBackoffCappedBeforeJitter(t *testing.T) {
+	rp := WithResilience(NewMockProvider(0, 0, 0, 0), ResilienceOptions{
+		BaseBackoff: 100 * time.Millisecond,
+		MaxBackoff:  time.Second,
+	})
+	if d := rp.backoff(10); d != time.Second {
+		t.Errorf("expected cap of 1s, got %v", d)
+	}
+	if d := rp.backoff(1000); d != time.Second {
+		t.Errorf("expected cap of 1s for huge attempt, got %v", d)
+	}
+
+	rp.opts.JitterFrac = 0.2
+	for i := 0; i < 1000; i++ {
+		if d := rp.backoff(50); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+			t.Fatalf("jitter should spread the capped value by 20%%, got %v", d)
+		}
+	}
+}
+
+func TestJitterNeverNegative(t *testing.T) {
+	for _, frac := range []float64{0.5, 1, 5, 100} {
+		for i := 0; i < 1000; i++ {
+			if d := randomJitter(time.Second, frac); d <= 0 || d > 2*time.Second {
+				t.Fatalf("frac %v: jittered sleep out of range: %v", frac, d)
+			}
+		}
+	}
+	if d := randomJitter(0, 0.5); d != 0 {
+		t.Errorf("zero backoff should stay zero, got %v", d)
+	}
+}
+
+func TestFailedLatencyExcludesBackoff(t *testing.T) {
+	mock := NewMockProviderWithOptions(MockOptions{ErrorRate: 1, Seed: 1})
+	rp := WithResilience(mock, ResilienceOptions{
+		MaxRetries:   2,
+		BaseBackoff:  100 * time.Millisecond,
+		CBWindowSize: 100,
+	})
+
+	start := time.Now()
+	_, _, latency, err := rp.Complete(context.Background(), CompletionRequest{Prompt: "ping"})
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Fatal("expected failure")
+	}
+	// two backoffs of 100ms and 200ms happened, but none of it was upstream time
+	if elapsed < 300*time.Millisecond {
+		t.Fatalf("expected retries to sleep, elapsed %v", elapsed)
+	}
+	if latency >= 100 {
+		t.Errorf("expected latency to exclude backoff sleeps, got %dms of %v elapsed", latency, elapsed)
+	}
+}
+
+func TestNoRetryPastDeadline(t *testing.T) {
+	mock := NewMockProviderWithOptions(MockOptions{ErrorRate: 1, Seed: 1})
+	rp := WithResilience(mock, ResilienceOptions{
+		MaxRetries:   3,
+		BaseBackoff:  10 * time.Millisecond,
+		CBWindowSize: 100,
+	})
+	// the provider usually takes 500ms, more than the caller has left
+	for i := 0; i < 20; i++ {
+		rp.Stats().Record(500, false)
+	}
+	before, _ := rp.Stats().CountsSince(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err := rp.Complete(ctx, CompletionRequest{Prompt: "ping"})
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the provider's own error straight away, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected an immediate return, took %v", elapsed)
+	}
+	attempts := func() int {
+		n, _ := rp.Stats().CountsSince(time.Hour)
+		return n - before
+	}
+	if got := attempts(); got != 1 {
+		t.Errorf("expected a single attempt, got %d", got)
+	}
+
+	// with time to spare the retries happen as usual
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	before, _ = rp.Stats().CountsSince(time.Hour)
+	rp.Complete(ctx, CompletionRequest{Prompt: "ping"})
+	if got := attempts(); got != 4 {
+		t.Errorf("expected all 4 attempts with a generous deadline, got %d", got)
+	}
+}
+
+func TestAttemptBudgetSharedAcrossProviders(t *testing.T) {
+	budget := NewAttemptBudget(4)
+	ctx := WithAttemptBudget(context.Background(), budget)
+
+	// each provider would make 3 attempts on its own; the chain may only make 4
+	var chain []*ResilientProvider
+	var errs []error
+	for i := 0; i < 3; i++ {
+		rp := WithResilience(NewMockProviderWithOptions(MockOptions{ErrorRate: 1, Seed: 1}), ResilienceOptions{MaxRetries: 2, CBWindowSize: 100})
+		_, _, _, err := rp.Complete(ctx, CompletionRequest{Prompt: "ping"})
+		chain = append(chain, rp)
+		errs = append(errs, err)
+	}
+
+	attempts := 0
+	for _, rp := range chain {
+		total, _ := rp.Stats().CountsSince(time.Hour)
+		attempts += total
+	}
+	if attempts != 4 || budget.Used() != 4 {
+		t.Errorf("expected 4 attempts across the chain, got %d (budget used %d)", attempts, budget.Used())
+	}
+	// the second provider keeps its own error; the third never gets a call
+	if errs[1] == nil || errors.Is(errs[1], ErrAttemptBudgetExhausted) {
+		t.Errorf("expected the last upstream error once the budget ran out mid-retry, got %v", errs[1])
+	}
+	if !errors.Is(errs[2], ErrAttemptBudgetExhausted) {
+		t.Errorf("expected ErrAttemptBudgetExhausted for a provider reached after the budget, got %v", errs[2])
+	}
+
+	if !NewAttemptBudget(0).take() || (*AttemptBudget)(nil).Used() != 0 {
+		t.Error("expected a zero budget to be unlimited and a nil budget to be usable")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorKind
+	}{
+		{nil, ""},
+		{ErrCircuitOpen, KindCircuitOpen},
+		{context.DeadlineExceeded, KindTimeout},
+		{fmt.Errorf("wrapped: %w", context.Canceled), KindCancelled},
+		{&StatusError{Provider: "openai", StatusCode: 429}, KindRateLimited},
+		{&StatusError{Provider: "openai", StatusCode: 503}, KindUpstream5xx},
+		{&StatusError{Provider: "openai", StatusCode: 400}, KindUpstream4xx},
+		{codedErr("ThrottlingException"), KindRateLimited},
+		{&url.Error{Op: "Post", URL: "http://x", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, KindNetwork},
+		{&net.DNSError{Err: "no such host", Name: "x"}, KindNetwork},
+		{errors.New("mock error"), KindUnknown},
+	}
+	for _, c := range cases {
+		if got := KindOf(c.err); got != c.want {
+			t.Errorf("KindOf(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+type codedErr string
+
+func (e codedErr) Error() string     { return string(e) }
+func (e codedErr) ErrorCode() string { return string(e) }
+
+func TestWarmupDelegatesWithoutRecording(t *testing.T) {
+	mock := NewMockProviderWithOptions(MockOptions{Seed: 1})
+	rp := WithResilience(mock, ResilienceOptions{CBWindowSize: 10})
+
+	if err := rp.Warmup(context.Background()); err != nil {
+		t.Fatalf("warmup: %v", err)
+	}
+	if mock.Warmups() != 1 {
+		t.Errorf("expected warmup to reach the provider once, got %d", mock.Warmups())
+	}
+	if n, _ := rp.Stats().CountsSince(time.Hour); n != 0 {
+		t.Errorf("expected warmup not to be recorded in stats, got %d outcomes", n)
+	}
+}
+
+func TestDefaultModelDelegates(t *testing.T) {
+	op := NewOpenAIProvider("sk-test", "", "")
+	op.SetDefaultModel("gpt-4o-mini")
+	if got := WithResilience(op, ResilienceOptions{CBWindowSize: 10}).DefaultModel(); got != "gpt-4o-mini" {
+		t.Errorf("expected openai default gpt-4o-mini, got %q", got)
+	}
+	if got := WithResilience(NewMockProvider(1, 1, 0, 0), ResilienceOptions{CBWindowSize: 10}).DefaultModel(); got != "" {
+		t.Errorf("expected no default for a plain mock, got %q", got)
+	}
+}
+
+func TestToolsRejectedByUnsupportedProvider(t *testing.T) {
+	mock := NewMockProviderWithOptions(MockOptions{Seed: 1})
+	rp := WithResilience(mock, ResilienceOptions{CBWindowSize: 10, MaxRetries: 2})
+	if rp.SupportsTools() {
+		t.Fatal("expected the mock not to support tools")
+	}
+
+	_, _, _, err := rp.Complete(context.Background(), CompletionRequest{Prompt: "hi", Tools: []Tool{{Name: "get_weather"}}})
+	if !errors.Is(err, ErrToolsUnsupported) {
+		t.Fatalf("expected ErrToolsUnsupported, got %v", err)
+	}
+	if want := "mock: provider does not support tools"; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	// a request the provider can't take says nothing about its health
+	if n, _ := rp.Stats().CountsSince(time.Hour); n != 0 {
+		t.Errorf("expected the rejection not to be recorded in stats, got %d outcomes", n)
+	}
+	if !WithResilience(NewOpenAIProvider("sk-test", "", ""), ResilienceOptions{CBWindowSize: 10}).SupportsTools() {
+		t.Error("expected openai to support tools")
+	}
+}
+
+func TestRecentP95FadesOldSpike(t *testing.T) {
+	s := NewStats(200)
+	old := time.Now().Add(-time.Hour)
+	for i := 0; i < 50; i++ {
+		s.outcomes = append(s.outcomes, Outcome{LatencyMs: 2000, At: old})
+	}
+	for i := 0; i < 50; i++ {
+		s.Record(100, false)
+	}
+	s.Record(9999, true) // errors don't count towards latency
+
+	if got := s.P95LatencyMs(); got != 2000 {
+		t.Fatalf("unweighted p95 should still see the spike, got %d", got)
+	}
+	if got := s.RecentP95LatencyMs(5 * time.Minute); got != 100 {
+		t.Fatalf("recency-weighted p95 should have recovered, got %d", got)
+	}
+	if got := s.RecentP95LatencyMs(0); got != 2000 {
+		t.Fatalf("zero half-life should match the plain p95, got %d", got)
+	}
+	if got := s.SuccessCount(); got != 100 {
+		t.Fatalf("want 100 successes, got %d", got)
+	}
+}
+
+func TestQuotaPacesOutboundCalls(t *testing.T) {
+	// 6000 RPM is 100 a second, with a one-second bucket
+	rp := WithResilience(NewMockProviderWithOptions(MockOptions{Seed: 1}), ResilienceOptions{CBWindowSize: 10, RPM: 6000, QuotaMaxWait: time.Second})
+	start := time.Now()
+	for i := 0; i < 150; i++ {
+		if _, _, _, err := rp.Complete(context.Background(), CompletionRequest{Prompt: "ping"}); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	// the 50 calls past the bucket go out at 100/s
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected 150 calls paced over about 0.5s, took %v", elapsed)
+	}
+}
+
+func TestQuotaExhaustedFailsFast(t *testing.T) {
+	var requests, tokens float64
+	rp := WithResilience(NewMockProviderWithOptions(MockOptions{Seed: 1}), ResilienceOptions{
+		CBWindowSize: 1,
+		RPM:          60,
+		TPM:          600,
+		OnQuota:      func(_ string, r, tk float64) { requests, tokens = r, tk },
+	})
+	if _, _, _, err := rp.Complete(context.Background(), CompletionRequest{Prompt: "ping", MaxTok: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if !rp.QuotaSaturated() || requests < 0.99 || tokens < 0.99 {
+		t.Fatalf("expected the quota full after one call, saturation %v/%v", requests, tokens)
+	}
+
+	start := time.Now()
+	_, _, _, err := rp.Complete(context.Background(), CompletionRequest{Prompt: "ping"})
+	if !errors.Is(err, ErrQuotaExhausted) || KindOf(err) != KindQuota {
+		t.Fatalf("expected ErrQuotaExhausted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("expected an immediate failure without QuotaMaxWait, took %v", elapsed)
+	}
+	if total, _ := rp.Stats().CountsSince(time.Hour); total != 1 {
+		t.Errorf("expected only the admitted call recorded, got %d", total)
+	}
+	if rp.CBStateValue() != 2 {
+		t.Errorf("a full quota should not trip the breaker, state %v", rp.CBStateValue())
+	}
+}
@@ -0,0 +1,166 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExhausted is returned without calling the provider when its configured
+// RPM/TPM quota has no room within QuotaMaxWait. The provider is healthy, so this
+// stays out of its stats and breaker; routing elsewhere is the remedy.
+var ErrQuotaExhausted = errors.New("provider quota exhausted")
+
+// quotaBucket is a token bucket refilled continuously at a per-minute limit. It holds
+// one second's worth so calls are paced the way providers enforce minute limits, in
+// short intervals, rather than let through as a burst at the top of the minute. A
+// request larger than the bucket is admitted once the bucket is full and leaves it in
+// debt, so big requests still count in full.
+type quotaBucket struct {
+	capacity float64
+	level    float64
+	perSec   float64
+	last     time.Time
+}
+
+func newQuotaBucket(perMinute int, now time.Time) *quotaBucket {
+	perSec := float64(perMinute) / 60
+	capacity := math.Max(perSec, 1)
+	return &quotaBucket{capacity: capacity, level: capacity, perSec: perSec, last: now}
+}
+
+func (b *quotaBucket) refill(now time.Time) {
+	b.level = math.Min(b.capacity, b.level+now.Sub(b.last).Seconds()*b.perSec)
+	b.last = now
+}
+
+// wait is how long until n can be taken; 0 means now
+func (b *quotaBucket) wait(n float64) time.Duration {
+	need := math.Min(n, b.capacity)
+	if b.level >= need {
+		return 0
+	}
+	return time.Duration((need - b.level) / b.perSec * float64(time.Second))
+}
+
+// used is the share of the bucket spent, 0..1
+func (b *quotaBucket) used() float64 {
+	return math.Min(math.Max(1-b.level/b.capacity, 0), 1)
+}
+
+// quota paces a provider's outbound calls under its requests-per-minute and
+// tokens-per-minute limits; either bucket is nil when unlimited
+type quota struct {
+	mu       sync.Mutex
+	requests *quotaBucket
+	tokens   *quotaBucket
+	maxWait  time.Duration
+}
+
+// newQuota returns nil when neither limit is set
+func newQuota(rpm, tpm int, maxWait time.Duration) *quota {
+	if rpm <= 0 && tpm <= 0 {
+		return nil
+	}
+	now := time.Now()
+	q := &quota{maxWait: maxWait}
+	if rpm > 0 {
+		q.requests = newQuotaBucket(rpm, now)
+	}
+	if tpm > 0 {
+		q.tokens = newQuotaBucket(tpm, now)
+	}
+	return q
+}
+
+// acquire takes one request and tokens from the quota, queueing up to maxWait for
+// room. It fails fast with ErrQuotaExhausted when the wait would be longer.
+func (q *quota) acquire(ctx context.Context, tokens int) error {
+	if q == nil {
+		return nil
+	}
+	deadline := time.Now().Add(q.maxWait)
+	for {
+		q.mu.Lock()
+		now := time.Now()
+		wait := q.waitLocked(now, float64(tokens))
+		if wait == 0 {
+			if q.requests != nil {
+				q.requests.level--
+			}
+			if q.tokens != nil {
+				q.tokens.level -= float64(tokens)
+			}
+			q.mu.Unlock()
+			return nil
+		}
+		q.mu.Unlock()
+		if now.Add(wait).After(deadline) {
+			return ErrQuotaExhausted
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (q *quota) waitLocked(now time.Time, tokens float64) time.Duration {
+	var wait time.Duration
+	if q.requests != nil {
+		q.requests.refill(now)
+		wait = q.requests.wait(1)
+	}
+	if q.tokens != nil {
+		q.tokens.refill(now)
+		if w := q.tokens.wait(tokens); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// saturation is the share of each bucket spent, 0..1; unlimited reads as 0
+func (q *quota) saturation() (requests, tokens float64) {
+	if q == nil {
+		return 0, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	if q.requests != nil {
+		q.requests.refill(now)
+		requests = q.requests.used()
+	}
+	if q.tokens != nil {
+		q.tokens.refill(now)
+		tokens = q.tokens.used()
+	}
+	return requests, tokens
+}
+
+// saturated reports whether a request couldn't start right now
+func (q *quota) saturated() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.waitLocked(time.Now(), 1) > 0
+}
+
+// quotaTokens estimates what a request counts against a tokens-per-minute limit:
+// about four characters per prompt token plus the completion it may produce, which
+// is how providers reserve TPM before generating
+func quotaTokens(req CompletionRequest) int {
+	chars := 0
+	for _, m := range req.ChatMessages() {
+		chars += len(m.Content)
+	}
+	return chars/4 + req.MaxTok
+}
@@ -0,0 +1,22 @@
+package providers
+
+import (
+	"context"
+	"time"
+)
+
+type callTimeoutKey struct{}
+
+// WithCallTimeout replaces ResilienceOptions.Timeout for ResilientProvider calls made
+// with the returned context; each attempt, retries included, gets d
+func WithCallTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, callTimeoutKey{}, d)
+}
+
+// callTimeout is the per-attempt timeout for a call made with ctx
+func (rp *ResilientProvider) callTimeout(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(callTimeoutKey{}).(time.Duration); ok && d > 0 {
+		return d
+	}
+	return rp.opts.Timeout
+}
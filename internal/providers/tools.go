@@ -0,0 +1,68 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Tool is a function the model may ask to call. Parameters is a JSON Schema object
+// describing its arguments; empty means it takes none.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is the model asking for a tool to be called; Arguments is a JSON object
+type ToolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Tool choices understood by every tool-capable provider. Any other non-empty value
+// names the one tool the model must call.
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// ToolCaller is optionally implemented by providers that pass Tools through to the
+// model and return its ToolCalls
+type ToolCaller interface {
+	SupportsTools() bool
+}
+
+// ErrToolsUnsupported is returned without calling a provider that can't take tools
+var ErrToolsUnsupported = errors.New("provider does not support tools")
+
+// toolsUnsupported wraps ErrToolsUnsupported with the provider's name
+func toolsUnsupported(provider string) error {
+	return fmt.Errorf("%s: %w", provider, ErrToolsUnsupported)
+}
+
+// emptySchema stands in for a tool without parameters where a schema is required
+var emptySchema = json.RawMessage(`{"type":"object","properties":{}}`)
+
+// toolSchema is the tool's parameters, or an empty object schema
+func toolSchema(t Tool) json.RawMessage {
+	if len(t.Parameters) == 0 {
+		return emptySchema
+	}
+	return t.Parameters
+}
+
+// toolArguments keeps arguments that are valid JSON as they are and encodes anything
+// else as a JSON string, so a sloppy model never breaks the response
+func toolArguments(s string) json.RawMessage {
+	if s == "" {
+		return json.RawMessage(`{}`)
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	b, _ := json.Marshal(s)
+	return b
+}
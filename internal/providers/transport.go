@@ -0,0 +1,61 @@
+package providers
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportOptions tunes the connection pool shared by HTTP-based providers
+type TransportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+}
+
+// DefaultTransportOptions keeps enough idle connections per host for high QPS to a
+// single API endpoint; net/http's own default of 2 per host forces constant redials
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        256,
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// NewTransport builds a pooled transport with opts on top of the standard dial and TLS settings
+func NewTransport(opts TransportOptions) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          opts.MaxIdleConns,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+var (
+	transportMu sync.RWMutex
+	transport   = NewTransport(DefaultTransportOptions())
+)
+
+// SetTransportOptions replaces the shared transport used by providers created afterwards
+func SetTransportOptions(opts TransportOptions) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	transport = NewTransport(opts)
+}
+
+// sharedTransport returns the pooled transport new providers should send through
+func sharedTransport() *http.Transport {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+	return transport
+}
@@ -0,0 +1,54 @@
+package providers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProviderReusesConnections(t *testing.T) {
+	var newConns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"content": "pong"}}]}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	SetTransportOptions(TransportOptions{MaxIdleConns: 8, MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Minute})
+	defer SetTransportOptions(DefaultTransportOptions())
+
+	p := NewOpenAICompatibleProvider("local", srv.URL, "", nil)
+	for i := 0; i < 20; i++ {
+		if _, _, _, err := p.Complete(context.Background(), CompletionRequest{Prompt: "ping"}); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if n := newConns.Load(); n != 1 {
+		t.Errorf("expected sequential requests to share one connection, got %d", n)
+	}
+}
+
+func BenchmarkProviderSequentialRequests(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices": [{"message": {"content": "pong"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAICompatibleProvider("local", srv.URL, "", nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, _, err := p.Complete(context.Background(), CompletionRequest{Prompt: "ping"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
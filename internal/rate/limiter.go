@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/events"
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+	"github.com/rs/zerolog/log"
 )
 
 // Bucket represents a token bucket for rate limiting
@@ -76,12 +78,14 @@ type DailyUsage struct {
 	mu         sync.RWMutex
 	usage      map[string]int64     // tenantID -> tokens used today
 	resetTimes map[string]time.Time // tenantID -> next reset time
+	warned     map[string]time.Time // tenantID -> reset time of the day last warned about
 }
 
 func NewDailyUsage() *DailyUsage {
 	return &DailyUsage{
 		usage:      make(map[string]int64),
 		resetTimes: make(map[string]time.Time),
+		warned:     make(map[string]time.Time),
 	}
 }
 
@@ -122,6 +126,24 @@ func (du *DailyUsage) GetUsage(tenantID string) int64 {
 	return du.usage[tenantID]
 }
 
+// MarkWarned records that tenantID was warned about its usage today, reporting false
+// when it already had been, so a warning fires once per day
+func (du *DailyUsage) MarkWarned(tenantID string) bool {
+	du.mu.Lock()
+	defer du.mu.Unlock()
+
+	reset, exists := du.resetTimes[tenantID]
+	if !exists || time.Now().After(reset) {
+		// no usage today to warn about
+		return false
+	}
+	if du.warned[tenantID].Equal(reset) {
+		return false
+	}
+	du.warned[tenantID] = reset
+	return true
+}
+
 func (du *DailyUsage) GetReset(tenantID string) time.Time {
 	du.mu.RLock()
 	defer du.mu.RUnlock()
@@ -135,20 +157,47 @@ func (du *DailyUsage) GetReset(tenantID string) time.Time {
 	return time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, tomorrow.Location())
 }
 
+// DefaultSoftLimitPct is the share of a daily limit at which tenants are warned
+const DefaultSoftLimitPct = 80
+
 // Limiter manages rate limiting for tenants
 type Limiter struct {
 	rpsBuckets map[string]*Bucket
 	dailyUsage *DailyUsage
 	mu         sync.RWMutex
+	// percent of the daily token limit past which responses carry X-Usage-Warning; 0 is off
+	softLimitPct float64
+	// add each request's estimate to dailyUsage; off once it is shared with the handlers
+	chargeEstimate bool
 }
 
 func NewLimiter() *Limiter {
 	return &Limiter{
-		rpsBuckets: make(map[string]*Bucket),
-		dailyUsage: NewDailyUsage(),
+		rpsBuckets:     make(map[string]*Bucket),
+		dailyUsage:     NewDailyUsage(),
+		softLimitPct:   DefaultSoftLimitPct,
+		chargeEstimate: true,
 	}
 }
 
+// SetDailyUsage checks daily token limits against du instead of the limiter's own
+// count. du is the one the infer handlers charge with each request's actual tokens,
+// so the limiter stops adding its per-request estimate to it.
+func (l *Limiter) SetDailyUsage(du *DailyUsage) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.dailyUsage = du
+	l.chargeEstimate = false
+}
+
+// SetSoftLimitPct sets the percentage of a tenant's daily token limit past which
+// requests are still served but warned about; 0 turns warnings off
+func (l *Limiter) SetSoftLimitPct(pct float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.softLimitPct = pct
+}
+
 func (l *Limiter) getRPSBucket(tenantID string, rpsLimit int) *Bucket {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -230,12 +279,50 @@ func (l *Limiter) RateLimitMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("X-TokenLimit-Reset", strconv.FormatInt(tokenReset.Unix(), 10))
 
 		// Record pre-request token usage estimate
-		l.RecordTokenUsage(tenant.TenantID, estimatedTokens)
+		if l.chargeEstimate {
+			l.RecordTokenUsage(tenant.TenantID, estimatedTokens)
+		}
+		l.warnNearLimit(w, tenant)
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// warnNearLimit sets X-Usage-Warning once the tenant's daily tokens pass the soft limit
+func (l *Limiter) warnNearLimit(w http.ResponseWriter, tenant *auth.Tenant) {
+	l.mu.RLock()
+	pct := l.softLimitPct
+	l.mu.RUnlock()
+	l.dailyUsage.WarnNearLimit(w, tenant, pct)
+}
+
+// WarnNearLimit sets X-Usage-Warning once the tenant's daily tokens pass pct percent
+// of its limit; the first crossing each day is also counted and published as an
+// event. pct of 0 or less is off.
+func (du *DailyUsage) WarnNearLimit(w http.ResponseWriter, tenant *auth.Tenant, pct float64) {
+	if pct <= 0 || tenant.DailyTokenLimit <= 0 {
+		return
+	}
+	used := du.GetUsage(tenant.TenantID)
+	usedPct := float64(used) / float64(tenant.DailyTokenLimit) * 100
+	if usedPct < pct {
+		return
+	}
+	w.Header().Set("X-Usage-Warning", fmt.Sprintf("daily token limit %.0f%% used (%d of %d)", usedPct, used, tenant.DailyTokenLimit))
+	if du.MarkWarned(tenant.TenantID) {
+		ev := map[string]any{
+			"tenant_id":         tenant.TenantID,
+			"limit":             "daily_tokens",
+			"used_tokens":       used,
+			"daily_token_limit": tenant.DailyTokenLimit,
+			"reset":             du.GetReset(tenant.TenantID).UTC(),
+		}
+		telemetry.UsageWarningsTotal.WithLabelValues("daily_tokens").Inc()
+		log.Warn().Str("event", events.UsageWarning).Fields(ev).Msg("tenant passed its soft usage limit")
+		events.Publish(events.UsageWarning, ev)
+	}
+}
+
 func (l *Limiter) estimateTokensFromRequest(r *http.Request) int64 {
 	// Simple estimation - in practice, you'd parse the request body and use model-specific rates
 	// For now, assume average request uses 1000 tokens
@@ -0,0 +1,80 @@
+package rate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/auth"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/telemetry"
+)
+
+func TestSoftLimitWarnsWithoutBlocking(t *testing.T) {
+	// each request is estimated at 1000 tokens, so the fourth reaches 80%
+	tenant := &auth.Tenant{TenantID: "t-soft", RPSLimit: 100, DailyTokenLimit: 5000, Enabled: true}
+	l := NewLimiter()
+	handler := l.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/infer", nil)
+		r = r.WithContext(auth.WithTenant(r.Context(), tenant))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	warnings := func() float64 {
+		return testutil.ToFloat64(telemetry.UsageWarningsTotal.WithLabelValues("daily_tokens"))
+	}
+	before := warnings()
+
+	for i := 1; i <= 3; i++ {
+		if w := send(); w.Code != http.StatusOK || w.Header().Get("X-Usage-Warning") != "" {
+			t.Fatalf("request %d at %d%%: expected 200 without a warning, got %d %q", i, i*20, w.Code, w.Header().Get("X-Usage-Warning"))
+		}
+	}
+	for i := 4; i <= 5; i++ {
+		w := send()
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d under the hard limit: expected 200, got %d", i, w.Code)
+		}
+		if w.Header().Get("X-Usage-Warning") == "" {
+			t.Errorf("request %d at %d%%: expected X-Usage-Warning", i, i*20)
+		}
+	}
+	if got := warnings() - before; got != 1 {
+		t.Errorf("expected the crossing counted once for the day, got %v", got)
+	}
+
+	if w := send(); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the hard limit still enforced, got %d", w.Code)
+	}
+}
+
+func TestSharedDailyUsageIsChargedByItsOwner(t *testing.T) {
+	tenant := &auth.Tenant{TenantID: "t-shared", RPSLimit: 100, DailyTokenLimit: 5000, Enabled: true}
+	du := NewDailyUsage()
+	l := NewLimiter()
+	l.SetDailyUsage(du)
+	handler := l.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		du.AddTokens(tenant.TenantID, 4500)
+	}))
+	send := func() int {
+		r := httptest.NewRequest(http.MethodPost, "/v1/infer", nil)
+		r = r.WithContext(auth.WithTenant(r.Context(), tenant))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := send(); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := du.GetUsage(tenant.TenantID); got != 4500 {
+		t.Errorf("expected only the handler's tokens counted, got %d", got)
+	}
+	if code := send(); code != http.StatusTooManyRequests {
+		t.Errorf("expected the handler's tokens to count toward the limit, got %d", code)
+	}
+}
@@ -0,0 +1,100 @@
+package router
+
+// Reasons the canary held its stage at a window boundary instead of auto-advancing
+const (
+	CanaryBlockedSavingsUnknown      = "savings_unknown"
+	CanaryBlockedInsufficientSavings = "insufficient_savings"
+)
+
+// costTally sums the billed cost of one provider's successful canary-era requests
+type costTally struct {
+	sum float64
+	n   int
+}
+
+// SetCanaryMinSavingsPct requires the candidate's observed cost per request to be at
+// least pct percent below the primary's before the canary auto-advances; zero or less
+// disables the check
+func (e *Engine) SetCanaryMinSavingsPct(pct float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.canary.minSavingsPct = pct
+}
+
+// CanaryMinSavingsPct returns the savings auto-advance requires, 0 when off
+func (e *Engine) CanaryMinSavingsPct() float64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.canary.minSavingsPct
+}
+
+// RecordCanaryCost adds the billed cost of a successful request to provider's tally
+// for the current canary stage
+func (e *Engine) RecordCanaryCost(provider string, costUSD float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.canary.costs == nil {
+		e.canary.costs = make(map[string]*costTally)
+	}
+	t := e.canary.costs[provider]
+	if t == nil {
+		t = &costTally{}
+		e.canary.costs[provider] = t
+	}
+	t.sum += costUSD
+	t.n++
+}
+
+// CanarySavingsPct returns how much cheaper per request the candidate has been than
+// the primary during the current stage, as a percentage of the primary's cost; ok is
+// false until both have billed requests
+func (e *Engine) CanarySavingsPct() (pct float64, ok bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.canarySavingsLocked()
+}
+
+// CanaryAdvanceBlocked returns why the last window boundary held the stage, or "" when
+// it didn't
+func (e *Engine) CanaryAdvanceBlocked() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.canary.blocked
+}
+
+func (e *Engine) canarySavingsLocked() (float64, bool) {
+	primary := e.canaryPrimaryLocked()
+	if primary == nil {
+		return 0, false
+	}
+	cand, prim := e.canary.costs[e.canary.candidate], e.canary.costs[primary.Name()]
+	if cand == nil || prim == nil || cand.n == 0 || prim.n == 0 || prim.sum <= 0 {
+		return 0, false
+	}
+	primAvg := prim.sum / float64(prim.n)
+	candAvg := cand.sum / float64(cand.n)
+	return (primAvg - candAvg) / primAvg * 100, true
+}
+
+// savingsBlockLocked returns why auto-advance must wait for better savings, or ""
+func (e *Engine) savingsBlockLocked() string {
+	if e.canary.minSavingsPct <= 0 {
+		return ""
+	}
+	pct, ok := e.canarySavingsLocked()
+	if !ok {
+		return CanaryBlockedSavingsUnknown
+	}
+	if pct < e.canary.minSavingsPct {
+		return CanaryBlockedInsufficientSavings
+	}
+	return ""
+}
+
+// resetCanaryStageLocked starts a new stage's observation: the evaluation window,
+// cost tallies and any advance block are per stage
+func (e *Engine) resetCanaryStageLocked() {
+	e.startCanaryWindowLocked()
+	e.canary.costs = nil
+	e.canary.blocked = ""
+}
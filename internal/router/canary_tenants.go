@@ -0,0 +1,53 @@
+package router
+
+import (
+	"sort"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// SetCanaryTenants targets the canary at tenants: under the canary policy their
+// requests always go to the candidate, whatever the stage, while everyone else keeps
+// following the stage percentage. Nil or empty clears the list.
+func (e *Engine) SetCanaryTenants(tenants []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.canary.tenants = nil
+	for _, t := range tenants {
+		if t == "" {
+			continue
+		}
+		if e.canary.tenants == nil {
+			e.canary.tenants = make(map[string]bool)
+		}
+		e.canary.tenants[t] = true
+	}
+}
+
+// CanaryTenants returns the tenants the canary targets, sorted
+func (e *Engine) CanaryTenants() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]string, 0, len(e.canary.tenants))
+	for t := range e.canary.tenants {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// decideFor is decide for a request from tenant: a canary-targeted tenant skips the
+// roll and goes straight to the candidate
+func (e *Engine) decideFor(tenant string, policy string, model string, ps []*providers.ResilientProvider, roll func() float64) (*providers.ResilientProvider, string, *CanaryRoll) {
+	if Strategy(policy) == Canary && tenant != "" {
+		e.mu.RLock()
+		targeted := e.canary.tenants[tenant]
+		mode := e.canary.mode
+		e.mu.RUnlock()
+		if primary, candidate := cheapestPair(available(ps), model); targeted && candidate != nil {
+			cr := &CanaryRoll{Primary: primary.Name(), Candidate: candidate.Name(), Percent: 100, Mode: mode, Targeted: true}
+			return candidate, "tenant targeted by canary, routed to candidate", cr
+		}
+	}
+	return e.decide(policy, model, ps, roll)
+}
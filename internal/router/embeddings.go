@@ -0,0 +1,23 @@
+package router
+
+import "github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+
+// ChooseEmbedder picks the provider with the lowest embedding list price for model
+// among those serving it that allow accepts (nil allows all); "" is each provider's
+// default embedding model. Like decide it skips providers with an open breaker, and
+// those whose quota is full while any has room. It returns nil when none qualify.
+func (e *Engine) ChooseEmbedder(model string, allow func(name string) bool) *providers.ResilientProvider {
+	var ps []*providers.ResilientProvider
+	for _, p := range e.providers() {
+		if p.ServesEmbeddingModel(model) && p.Available() && (allow == nil || allow(p.Name())) {
+			ps = append(ps, p)
+		}
+	}
+	var best *providers.ResilientProvider
+	for _, p := range unsaturated(ps) {
+		if best == nil || p.EmbeddingCostPer1kTokensUSD(model) < best.EmbeddingCostPer1kTokensUSD(model) {
+			best = p
+		}
+	}
+	return best
+}
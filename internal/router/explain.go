@@ -0,0 +1,99 @@
+package router
+
+import (
+	"math/rand"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// ProviderSignals are the per-provider inputs a policy considers
+type ProviderSignals struct {
+	Name         string  `json:"name"`
+	CostPer1kUSD float64 `json:"cost_per_1k_tokens_usd"`
+	// PriceKnown is false when CostPer1kUSD is a placeholder for a model without pricing
+	PriceKnown   bool    `json:"price_known"`
+	P95LatencyMs int64   `json:"p95_latency_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+	BurnRate     float64 `json:"burn_rate"`
+	CBState      float64 `json:"cb_state"`
+	Enabled      bool    `json:"enabled"`
+	// SupportsModel is always true today: providers accept any model id
+	SupportsModel bool `json:"supports_model"`
+}
+
+// CanaryRoll records the traffic split decision for the canary policy
+type CanaryRoll struct {
+	Primary   string     `json:"primary"`
+	Candidate string     `json:"candidate"`
+	Percent   float64    `json:"percent"`
+	Roll      float64    `json:"roll"`
+	Mode      CanaryMode `json:"mode"`
+	// SelectPercent is the request share that yields Percent of spend in cost mode
+	SelectPercent float64 `json:"select_percent,omitempty"`
+	// Targeted means the tenant is on the canary's allowlist, so no roll was drawn
+	Targeted bool `json:"targeted,omitempty"`
+}
+
+// ChoiceExplanation describes why a policy picks a provider
+type ChoiceExplanation struct {
+	Policy    string            `json:"policy"`
+	Model     string            `json:"model"`
+	Chosen    string            `json:"chosen"`
+	Reason    string            `json:"reason"`
+	Providers []ProviderSignals `json:"providers"`
+	Canary    *CanaryRoll       `json:"canary,omitempty"`
+}
+
+// Explain runs the policy like Choose and reports the signals behind the decision.
+// It has no side effects: the canary roll is drawn from a throwaway source so the
+// engine's RNG sequence seen by real traffic is unchanged.
+func (e *Engine) Explain(policy string, model string) ChoiceExplanation {
+	return e.explain("", policy, model, nil, rand.Float64)
+}
+
+// ExplainFor is Explain for a request from tenant that may only be routed to the
+// providers allow accepts (nil allows all), as ChooseForTenant would route it
+func (e *Engine) ExplainFor(tenant, policy, model string, allow func(name string) bool) ChoiceExplanation {
+	return e.explain(tenant, policy, model, allow, rand.Float64)
+}
+
+func (e *Engine) explain(tenant, policy, model string, allow func(name string) bool, roll func() float64) ChoiceExplanation {
+	e.mu.RLock()
+	var all []*providers.ResilientProvider
+	for _, p := range e.provs {
+		if allow == nil || allow(p.Name()) {
+			all = append(all, p)
+		}
+	}
+	e.mu.RUnlock()
+
+	ex := ChoiceExplanation{Policy: policy, Model: model}
+	for _, p := range all {
+		er := p.Stats().ErrorRate()
+		ex.Providers = append(ex.Providers, ProviderSignals{
+			Name:          p.Name(),
+			CostPer1kUSD:  p.CostPer1kTokensUSD(model),
+			PriceKnown:    p.PriceKnown(model),
+			P95LatencyMs:  p.Stats().P95LatencyMs(),
+			ErrorRate:     er,
+			BurnRate:      er / e.sloTarget,
+			CBState:       p.CBStateValue(),
+			Enabled:       p.Enabled(),
+			SupportsModel: true,
+		})
+	}
+
+	var ps []*providers.ResilientProvider
+	for _, p := range e.providers() {
+		if allow == nil || allow(p.Name()) {
+			ps = append(ps, p)
+		}
+	}
+	chosen, reason, cr := e.decideFor(tenant, policy, model, ps, roll)
+	if chosen != nil {
+		ex.Chosen = chosen.Name()
+	}
+	ex.Reason = reason
+	ex.Canary = cr
+	return ex
+}
@@ -1,6 +1,8 @@
 package router
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"sort"
 	"sync"
@@ -16,6 +18,44 @@ const (
 	FastestP95   Strategy = "fastest_p95"
 	SLOBurnAware Strategy = "slo_burn_aware"
 	Canary       Strategy = "canary"
+	LatencySLO   Strategy = "latency_slo"
+
+	// Forced labels metrics for requests that named their provider and skipped the policy
+	Forced Strategy = "forced"
+)
+
+// CanaryMode says what a canary stage percentage is a share of
+type CanaryMode string
+
+const (
+	// CanaryByRequests sends the stage percent of requests to the candidate
+	CanaryByRequests CanaryMode = "requests"
+	// CanaryByCost sends the candidate the share of requests that makes its expected
+	// share of spend equal the stage percent
+	CanaryByCost CanaryMode = "cost"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown provider")
+	ErrProviderDisabled = errors.New("provider is disabled")
+	// ErrNoProviders means the engine has no providers to route between at all
+	ErrNoProviders = errors.New("no providers configured")
+)
+
+// UnavailableError means providers are configured but each is disabled or has its
+// breaker open, so none can serve a request now
+type UnavailableError struct {
+	// RetryAfter is when the first breaker is due a half-open probe; 0 when no
+	// provider will come back by itself, being disabled or forced open
+	RetryAfter time.Duration
+}
+
+func (e *UnavailableError) Error() string { return "all providers tripped or disabled" }
+
+const (
+	DefaultMinP95Samples = 20
+	DefaultP95HalfLife   = 5 * time.Minute
+	DefaultLatencySLO    = 2 * time.Second
 )
 
 type Engine struct {
@@ -24,15 +64,35 @@ type Engine struct {
 	sloTarget float64
 	rng       *rand.Rand
 
+	// fastest_p95 only trusts a p95 backed by minP95Samples successes; samples are
+	// weighted by recency with p95HalfLife
+	minP95Samples int
+	p95HalfLife   time.Duration
+	// latency_slo picks the cheapest provider whose p95 is within latencySLO
+	latencySLO time.Duration
+
+	// sticky pins conversations to a provider; nil when stickiness is off
+	sticky *stickyRoutes
+
 	canary struct {
 		candidate      string
 		stages         []float64
 		stageIdx       int
 		calls          int
-		window         int
+		window         CanaryWindow
+		windowStart    time.Time
 		burnMult       float64
+		maxP95Ratio    float64
+		mode           CanaryMode
 		lastTransition time.Time
 		lastReason     string
+		// minSavingsPct gates auto-advance on the candidate being cheaper per request
+		minSavingsPct float64
+		costs         map[string]*costTally
+		blocked       string
+		// tenants always routed to the candidate, bypassing the roll
+		tenants map[string]bool
+		now     func() time.Time
 	}
 }
 
@@ -41,46 +101,200 @@ func NewEngine(providersList []*providers.ResilientProvider) *Engine {
 		provs:     providersList,
 		sloTarget: 0.01, // 99% success target
 		rng:       rand.New(rand.NewSource(42)),
+
+		minP95Samples: DefaultMinP95Samples,
+		p95HalfLife:   DefaultP95HalfLife,
+		latencySLO:    DefaultLatencySLO,
 	}
-	e.canary.stages = []float64{0.01, 0.05, 0.25}
-	e.canary.window = 200
+	e.canary.stages = []float64{percentToFraction(1), percentToFraction(5), percentToFraction(25)}
+	e.canary.window = DefaultCanaryWindow
+	e.canary.now = time.Now
+	e.canary.windowStart = e.canary.now()
 	e.canary.burnMult = 2.0
-	if len(providersList) > 1 {
-		// default candidate = second cheapest
-		primary, candidate := e.cheapestPair("")
-		_ = primary
+	e.canary.maxP95Ratio = 2.0
+	e.canary.mode = CanaryByRequests
+	// default candidate = second cheapest; none while fewer than two are enabled
+	if _, candidate := cheapestPair(e.providers(), ""); candidate != nil {
 		e.canary.candidate = candidate.Name()
 	}
 	return e
 }
 
+// SetProviders replaces the providers the engine routes between, e.g. after a reload.
+// The canary keeps its candidate while a provider of that name remains; otherwise
+// it falls back to the second cheapest, as in NewEngine.
+func (e *Engine) SetProviders(ps []*providers.ResilientProvider) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.provs = ps
+	for _, p := range ps {
+		if p.Name() == e.canary.candidate {
+			return
+		}
+	}
+	e.canary.candidate = ""
+	if _, candidate := cheapestPair(ps, ""); candidate != nil {
+		e.canary.candidate = candidate.Name()
+	}
+}
+
+// Canary stages are stored as fractions (0..1) because Choose compares them against
+// the RNG; the API, config and admin surface speak percentages (0..100). These are
+// the only conversion points between the two.
+func percentToFraction(p float64) float64 { return p / 100.0 }
+func fractionToPercent(f float64) float64 { return f * 100.0 }
+
+// CanaryWindow is how much of a stage the canary observes before judging the
+// candidate: at least Calls candidate calls and at least Dwell since the window
+// opened. Either may be zero for a purely time- or call-based window.
+type CanaryWindow struct {
+	Calls int
+	Dwell time.Duration
+}
+
+// DefaultCanaryWindow needs both, so a quiet stage isn't judged on a handful of calls
+// and a busy one isn't advanced within seconds
+var DefaultCanaryWindow = CanaryWindow{Calls: 200, Dwell: 5 * time.Minute}
+
 // ConfigureCanary allows runtime tuning of canary stages (as percentages 0..100),
-// evaluation window (#calls), and burn rate multiplier threshold for rollback.
-func (e *Engine) ConfigureCanary(stagesPercent []float64, window int, burnMultiplier float64) {
+// evaluation window, and burn rate multiplier threshold for rollback. Stages
+// outside (0,100] are dropped rather than misread as fractions; a window with
+// neither calls nor dwell keeps the current one.
+func (e *Engine) ConfigureCanary(stagesPercent []float64, window CanaryWindow, burnMultiplier float64) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	var st []float64
 	for _, p := range stagesPercent {
-		if p < 0 {
+		if p <= 0 || p > 100 {
 			continue
 		}
-		// convert percent to fraction
-		st = append(st, p/100.0)
+		st = append(st, percentToFraction(p))
 	}
 	if len(st) > 0 {
 		e.canary.stages = st
 		if e.canary.stageIdx >= len(st) {
 			e.canary.stageIdx = len(st) - 1
+			e.resetCanaryStageLocked()
+			e.canary.lastTransition = e.canary.now()
+			e.canary.lastReason = "config_clamp"
 		}
 	}
-	if window > 0 {
-		e.canary.window = window
+	if window.Calls > 0 || window.Dwell > 0 {
+		e.canary.window = CanaryWindow{Calls: max(window.Calls, 0), Dwell: max(window.Dwell, 0)}
 	}
 	if burnMultiplier > 0 {
 		e.canary.burnMult = burnMultiplier
 	}
 }
 
+// ValidateCanaryStages checks that stages are percentages in (0,100] and strictly increasing
+func ValidateCanaryStages(stagesPercent []float64) error {
+	if len(stagesPercent) == 0 {
+		return fmt.Errorf("at least one stage is required")
+	}
+	for i, p := range stagesPercent {
+		if p <= 0 || p > 100 {
+			return fmt.Errorf("stage %d must be in (0,100], got %v", i, p)
+		}
+		if i > 0 && p <= stagesPercent[i-1] {
+			return fmt.Errorf("stages must be strictly increasing, got %v after %v", p, stagesPercent[i-1])
+		}
+	}
+	return nil
+}
+
+// CanaryStages returns the configured stages as percentages (0..100).
+func (e *Engine) CanaryStages() []float64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]float64, len(e.canary.stages))
+	for i, f := range e.canary.stages {
+		out[i] = fractionToPercent(f)
+	}
+	return out
+}
+
+// SetCanaryMaxP95Ratio sets how much slower (candidate p95 / primary p95) the candidate
+// may be before auto-rollback; zero or less disables the latency guardrail
+func (e *Engine) SetCanaryMaxP95Ratio(ratio float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.canary.maxP95Ratio = ratio
+}
+
+// SetCanaryMode switches between request-count and cost-weighted stages; unknown modes
+// are ignored
+func (e *Engine) SetCanaryMode(mode CanaryMode) {
+	if mode != CanaryByRequests && mode != CanaryByCost {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.canary.mode = mode
+}
+
+// CanaryMode returns what the stage percentage is a share of
+func (e *Engine) CanaryMode() CanaryMode {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.canary.mode
+}
+
+// CanaryMaxP95Ratio returns the candidate/primary p95 ratio that triggers auto-rollback
+func (e *Engine) CanaryMaxP95Ratio() float64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.canary.maxP95Ratio
+}
+
+// SetFastestP95Options sets how many successful samples a provider needs before
+// fastest_p95 ranks it by latency, and the half-life used to fade old samples
+// (zero or less weights the whole window equally)
+func (e *Engine) SetFastestP95Options(minSamples int, halfLife time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if minSamples > 0 {
+		e.minP95Samples = minSamples
+	}
+	e.p95HalfLife = halfLife
+}
+
+// FastestP95Options returns the sample minimum and half-life fastest_p95 ranks by
+func (e *Engine) FastestP95Options() (minSamples int, halfLife time.Duration) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.minP95Samples, e.p95HalfLife
+}
+
+// SetLatencySLO sets the p95 latency target latency_slo holds providers to; zero or
+// less keeps the current target
+func (e *Engine) SetLatencySLO(target time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if target > 0 {
+		e.latencySLO = target
+	}
+}
+
+// LatencySLO returns the p95 latency target latency_slo holds providers to
+func (e *Engine) LatencySLO() time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.latencySLO
+}
+
+// ErrorBudget is the error rate slo_burn_aware and canary rollback treat as a burn rate of 1
+func (e *Engine) ErrorBudget() float64 {
+	return e.sloTarget
+}
+
+// CanaryBurnMultiplier returns the burn rate multiple that triggers auto-rollback
+func (e *Engine) CanaryBurnMultiplier() float64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.canary.burnMult
+}
+
 // CanaryPercent returns current canary stage percentage (0..100).
 func (e *Engine) CanaryPercent() float64 {
 	e.mu.RLock()
@@ -88,7 +302,7 @@ func (e *Engine) CanaryPercent() float64 {
 	if len(e.canary.stages) == 0 {
 		return 0
 	}
-	return e.canary.stages[e.canary.stageIdx] * 100.0
+	return fractionToPercent(e.canary.stages[e.canary.stageIdx])
 }
 
 func (e *Engine) CanaryStageIndex() int {
@@ -102,8 +316,8 @@ func (e *Engine) CanaryAdvance() {
 	defer e.mu.Unlock()
 	if e.canary.stageIdx+1 < len(e.canary.stages) {
 		e.canary.stageIdx++
-		e.canary.calls = 0
-		e.canary.lastTransition = time.Now()
+		e.resetCanaryStageLocked()
+		e.canary.lastTransition = e.canary.now()
 		e.canary.lastReason = "manual_advance"
 	}
 }
@@ -112,8 +326,8 @@ func (e *Engine) CanaryRollback() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.canary.stageIdx = 0
-	e.canary.calls = 0
-	e.canary.lastTransition = time.Now()
+	e.resetCanaryStageLocked()
+	e.canary.lastTransition = e.canary.now()
 	e.canary.lastReason = "manual_rollback"
 }
 
@@ -124,8 +338,8 @@ func (e *Engine) CanaryCandidateProvider() string {
 	return e.canary.candidate
 }
 
-// CanaryWindowSize returns the current canary evaluation window
-func (e *Engine) CanaryWindowSize() int {
+// CanaryWindow returns the current canary evaluation window
+func (e *Engine) CanaryWindow() CanaryWindow {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.canary.window
@@ -145,60 +359,174 @@ func (e *Engine) CanaryLastReason() string {
 	return e.canary.lastReason
 }
 
+// providers returns a copy of the providers currently enabled for routing. A breaker
+// an operator forced open takes its provider out too; one opened by errors does not,
+// since it needs traffic for its half-open probe, and decide skips it only until then.
 func (e *Engine) providers() []*providers.ResilientProvider {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	return e.provs
+	out := make([]*providers.ResilientProvider, 0, len(e.provs))
+	for _, p := range e.provs {
+		if p.Enabled() && !p.CBForcedOpen() {
+			out = append(out, p)
+		}
+	}
+	return out
 }
 
-func (e *Engine) cheapest(model string) *providers.ResilientProvider {
-	ps := e.providers()
+// available drops providers whose breaker is open and not yet due a probe: a request
+// routed to one fails without a call, wasting the attempt
+func available(ps []*providers.ResilientProvider) []*providers.ResilientProvider {
+	var out []*providers.ResilientProvider
+	for _, p := range ps {
+		if p.Available() {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Unavailable explains a nil choice that is down to the providers rather than the
+// request: ErrNoProviders when none are configured, an *UnavailableError when every
+// one is disabled or tripped, and nil while any could serve
+func (e *Engine) Unavailable() error {
+	e.mu.RLock()
+	ps := append([]*providers.ResilientProvider(nil), e.provs...)
+	e.mu.RUnlock()
+	if len(ps) == 0 {
+		return ErrNoProviders
+	}
+	ue := &UnavailableError{}
+	for _, p := range ps {
+		if p.Available() {
+			return nil
+		}
+		if !p.Enabled() {
+			continue
+		}
+		if in := p.RetryIn(); in > 0 && (ue.RetryAfter == 0 || in < ue.RetryAfter) {
+			ue.RetryAfter = in
+		}
+	}
+	return ue
+}
+
+// unsaturated drops providers whose RPM/TPM quota is full, so requests fail over to
+// one with room instead of queueing or failing; when every provider is full they all
+// stay and their quotas decide
+func unsaturated(ps []*providers.ResilientProvider) []*providers.ResilientProvider {
+	var out []*providers.ResilientProvider
+	for _, p := range ps {
+		if !p.QuotaSaturated() {
+			out = append(out, p)
+		}
+	}
+	if len(out) == 0 || len(out) == len(ps) {
+		return ps
+	}
+	return out
+}
+
+// cheaper orders providers by list price for model, except that a real price always
+// beats a provider's placeholder for a model it has no price for: comparing against
+// a made-up number says nothing about which is actually cheaper
+func cheaper(a, b *providers.ResilientProvider, model string) bool {
+	if ak, bk := a.PriceKnown(model), b.PriceKnown(model); ak != bk {
+		return ak
+	}
+	return a.CostPer1kTokensUSD(model) < b.CostPer1kTokensUSD(model)
+}
+
+func cheapest(ps []*providers.ResilientProvider, model string) *providers.ResilientProvider {
 	if len(ps) == 0 {
 		return nil
 	}
 	best := ps[0]
-	bestCost := best.CostPer1kTokensUSD(model)
 	for _, p := range ps[1:] {
-		if c := p.CostPer1kTokensUSD(model); c < bestCost {
+		if cheaper(p, best, model) {
 			best = p
-			bestCost = c
 		}
 	}
 	return best
 }
 
-func (e *Engine) cheapestPair(model string) (*providers.ResilientProvider, *providers.ResilientProvider) {
-	ps := e.providers()
+func cheapestPair(ps []*providers.ResilientProvider, model string) (*providers.ResilientProvider, *providers.ResilientProvider) {
 	if len(ps) < 2 {
 		return nil, nil
 	}
-	sort.Slice(ps, func(i, j int) bool {
-		return ps[i].CostPer1kTokensUSD(model) < ps[j].CostPer1kTokensUSD(model)
+	ps = append([]*providers.ResilientProvider(nil), ps...)
+	sort.SliceStable(ps, func(i, j int) bool {
+		return cheaper(ps[i], ps[j], model)
 	})
 	return ps[0], ps[1]
 }
 
-func (e *Engine) fastestP95() *providers.ResilientProvider {
-	ps := e.providers()
-	if len(ps) == 0 {
-		return nil
-	}
-	best := ps[0]
-	bestP := best.Stats().P95LatencyMs()
-	for _, p := range ps[1:] {
-		if v := p.Stats().P95LatencyMs(); v > 0 && (bestP == 0 || v < bestP) {
+// fastestP95 picks the lowest recency-weighted p95 among providers with at least
+// minSamples successes. measured is false when no provider has enough samples yet and
+// the choice fell back to the cheapest.
+func fastestP95(ps []*providers.ResilientProvider, model string, minSamples int, halfLife time.Duration) (best *providers.ResilientProvider, measured bool) {
+	var bestP int64
+	for _, p := range ps {
+		if p.Stats().SuccessCount() < minSamples {
+			continue
+		}
+		if v := p.Stats().RecentP95LatencyMs(halfLife); v > 0 && (best == nil || v < bestP) {
 			best = p
 			bestP = v
 		}
 	}
-	if bestP == 0 { // no data, fallback to cheapest
-		return e.cheapest("")
+	if best == nil {
+		return cheapest(ps, model), false
 	}
-	return best
+	return best, true
 }
 
-func (e *Engine) healthyAlternative(model string) *providers.ResilientProvider {
-	ps := e.providers()
+// withinLatencySLO picks the cheapest provider whose recency-weighted p95 meets target,
+// judging only providers with at least minSamples successes. When none meets it the
+// fastest measured provider is returned with met false; with nothing measured yet the
+// choice falls back to the cheapest, as fastest_p95 does.
+func withinLatencySLO(ps []*providers.ResilientProvider, model string, target time.Duration, minSamples int, halfLife time.Duration) (best *providers.ResilientProvider, met, measured bool) {
+	targetMs := target.Milliseconds()
+	var meeting []*providers.ResilientProvider
+	var fastest *providers.ResilientProvider
+	var fastestP int64
+	for _, p := range ps {
+		if p.Stats().SuccessCount() < minSamples {
+			continue
+		}
+		v := p.Stats().RecentP95LatencyMs(halfLife)
+		if v <= 0 {
+			continue
+		}
+		if v <= targetMs {
+			meeting = append(meeting, p)
+		}
+		if fastest == nil || v < fastestP {
+			fastest = p
+			fastestP = v
+		}
+	}
+	if len(meeting) > 0 {
+		return cheapest(meeting, model), true, true
+	}
+	if fastest != nil {
+		return fastest, false, true
+	}
+	return cheapest(ps, model), false, false
+}
+
+// costWeightedFraction is the probability of picking the candidate so that its expected
+// share of spend is target. A request of n tokens costs n*price/1000 on either side, so
+// solving q*cc / (q*cc + (1-q)*cp) = target leaves only the price ratio; the request
+// size cancels. Free providers make spend meaningless, so the target is used as is.
+func costWeightedFraction(target, candidatePer1k, primaryPer1k float64) float64 {
+	if candidatePer1k <= 0 || primaryPer1k <= 0 {
+		return target
+	}
+	return target * primaryPer1k / (target*primaryPer1k + (1-target)*candidatePer1k)
+}
+
+func healthyAlternative(ps []*providers.ResilientProvider, model string) *providers.ResilientProvider {
 	if len(ps) == 0 {
 		return nil
 	}
@@ -207,7 +535,7 @@ func (e *Engine) healthyAlternative(model string) *providers.ResilientProvider {
 	bestErr := best.Stats().ErrorRate()
 	for _, p := range ps[1:] {
 		er := p.Stats().ErrorRate()
-		if er < bestErr || (er == bestErr && p.CostPer1kTokensUSD(model) < best.CostPer1kTokensUSD(model)) {
+		if er < bestErr || (er == bestErr && cheaper(p, best, model)) {
 			best = p
 			bestErr = er
 		}
@@ -217,35 +545,109 @@ func (e *Engine) healthyAlternative(model string) *providers.ResilientProvider {
 
 // Choose selects a provider based on the policy and current stats
 func (e *Engine) Choose(policy string, model string) *providers.ResilientProvider {
+	chosen, _, _ := e.decide(policy, model, e.providers(), e.rng.Float64)
+	return chosen
+}
+
+// ChooseAllowed is Choose restricted to providers allow accepts, for callers such as
+// tenants barred from some providers; it returns nil when none qualify
+func (e *Engine) ChooseAllowed(policy string, model string, allow func(name string) bool) *providers.ResilientProvider {
+	var ps []*providers.ResilientProvider
+	for _, p := range e.providers() {
+		if allow(p.Name()) {
+			ps = append(ps, p)
+		}
+	}
+	chosen, _, _ := e.decide(policy, model, ps, e.rng.Float64)
+	return chosen
+}
+
+// Force returns the named provider, bypassing policy selection. Providers accept any
+// model id today, so only existence and the enabled flag are checked.
+func (e *Engine) Force(name string) (*providers.ResilientProvider, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, p := range e.provs {
+		if p.Name() != name {
+			continue
+		}
+		if !p.Enabled() {
+			return nil, ErrProviderDisabled
+		}
+		return p, nil
+	}
+	return nil, ErrUnknownProvider
+}
+
+// decide holds the policy logic shared by Choose and Explain, picking among ps. roll is
+// only drawn for the canary split, so callers control which RNG is consumed.
+func (e *Engine) decide(policy string, model string, ps []*providers.ResilientProvider, roll func() float64) (*providers.ResilientProvider, string, *CanaryRoll) {
+	ps = unsaturated(available(ps))
+	if len(ps) == 0 {
+		return nil, "no providers available", nil
+	}
 	switch Strategy(policy) {
 	case Cheapest:
-		return e.cheapest(model)
+		return cheapest(ps, model), "lowest list price", nil
 	case FastestP95:
-		return e.fastestP95()
+		e.mu.RLock()
+		minSamples, halfLife := e.minP95Samples, e.p95HalfLife
+		e.mu.RUnlock()
+		if fp, measured := fastestP95(ps, model, minSamples, halfLife); fp != nil {
+			if !measured {
+				return fp, fmt.Sprintf("fewer than %d latency samples, fell back to cheapest", minSamples), nil
+			}
+			return fp, "lowest observed p95 latency", nil
+		}
+		return nil, "no providers available", nil
+	case LatencySLO:
+		e.mu.RLock()
+		target, minSamples, halfLife := e.latencySLO, e.minP95Samples, e.p95HalfLife
+		e.mu.RUnlock()
+		p, met, measured := withinLatencySLO(ps, model, target, minSamples, halfLife)
+		switch {
+		case p == nil:
+			return nil, "no providers available", nil
+		case !measured:
+			return p, fmt.Sprintf("fewer than %d latency samples, fell back to cheapest", minSamples), nil
+		case !met:
+			return p, fmt.Sprintf("no provider within the %v p95 target, chose lowest observed p95", target), nil
+		}
+		return p, fmt.Sprintf("cheapest provider within the %v p95 target", target), nil
 	case SLOBurnAware:
-		alt := e.healthyAlternative(model)
+		alt := healthyAlternative(ps, model)
 		// if cheapest is burning error budget, pick healthier alt
-		cheapest := e.cheapest(model)
-		if cheapest == nil {
-			return alt
+		cheap := cheapest(ps, model)
+		if cheap == nil {
+			return alt, "no cheapest provider, chose lowest error rate", nil
 		}
-		burn := cheapest.Stats().ErrorRate() / e.sloTarget
+		burn := cheap.Stats().ErrorRate() / e.sloTarget
 		if burn > 1.0 {
-			return alt
+			return alt, fmt.Sprintf("cheapest %s burning error budget (%.2fx), chose lowest error rate", cheap.Name(), burn), nil
 		}
-		return cheapest
+		return cheap, "cheapest provider within error budget", nil
 	case Canary:
-		primary, candidate := e.cheapestPair(model)
+		primary, candidate := cheapestPair(ps, model)
 		if primary == nil || candidate == nil {
-			return primary
+			// fewer than two providers in rotation; nothing to split traffic across
+			return cheapest(ps, model), "fewer than two providers in rotation, fell back to cheapest", nil
 		}
+		e.mu.RLock()
 		p := e.canary.stages[e.canary.stageIdx]
-		if e.rng.Float64() < p {
-			return candidate
+		mode := e.canary.mode
+		e.mu.RUnlock()
+		cr := &CanaryRoll{Primary: primary.Name(), Candidate: candidate.Name(), Percent: fractionToPercent(p), Mode: mode}
+		if mode == CanaryByCost {
+			p = costWeightedFraction(p, candidate.CostPer1kTokensUSD(model), primary.CostPer1kTokensUSD(model))
+			cr.SelectPercent = fractionToPercent(p)
 		}
-		return primary
+		cr.Roll = roll()
+		if cr.Roll < p {
+			return candidate, "canary roll below stage percent, routed to candidate", cr
+		}
+		return primary, "canary roll above stage percent, routed to primary", cr
 	default:
-		return e.cheapest(model)
+		return cheapest(ps, model), "unknown policy, fell back to cheapest", nil
 	}
 }
 
@@ -256,10 +658,10 @@ func (e *Engine) RecordResult(providerName string, failed bool) {
 		defer e.mu.Unlock()
 		if providerName == e.canary.candidate {
 			e.canary.calls++
-			// simple stage progression: every 200 calls without excessive failures, advance
-			// rollback on failure rate > burnMult x SLO
-			calls := e.canary.calls
-			if calls%e.canary.window == 0 {
+			// simple stage progression: at the end of each window without excessive
+			// failures, advance; rollback on failure rate > burnMult x SLO
+			if e.canaryWindowDoneLocked() {
+				e.startCanaryWindowLocked()
 				// check failure rate of candidate
 				var cand *providers.ResilientProvider
 				for _, p := range e.provs {
@@ -271,19 +673,66 @@ func (e *Engine) RecordResult(providerName string, failed bool) {
 				if cand != nil {
 					burn := cand.Stats().ErrorRate() / e.sloTarget
 					if burn > e.canary.burnMult {
-						// rollback to first stage
-						e.canary.stageIdx = 0
-						e.canary.lastTransition = time.Now()
-						e.canary.lastReason = "auto_rollback_burn_rate"
+						e.autoRollbackLocked("auto_rollback_burn_rate")
 						return
 					}
+					// a healthy but much slower candidate is also a regression
+					if primary := e.canaryPrimaryLocked(); primary != nil && e.canary.maxP95Ratio > 0 {
+						candP95, primP95 := cand.Stats().P95LatencyMs(), primary.Stats().P95LatencyMs()
+						if candP95 > 0 && primP95 > 0 && float64(candP95) > e.canary.maxP95Ratio*float64(primP95) {
+							e.autoRollbackLocked("auto_rollback_latency_regression")
+							return
+						}
+					}
 				}
 				if e.canary.stageIdx+1 < len(e.canary.stages) {
+					// healthy isn't enough when the canary has to pay for itself; hold
+					// the stage and look again at the next window
+					if blocked := e.savingsBlockLocked(); blocked != "" {
+						e.canary.blocked = blocked
+						return
+					}
 					e.canary.stageIdx++
-					e.canary.lastTransition = time.Now()
+					e.resetCanaryStageLocked()
+					e.canary.lastTransition = e.canary.now()
 					e.canary.lastReason = "auto_advance"
 				}
 			}
 		}
 	}
 }
+
+// autoRollbackLocked resets the canary to its first stage, recording why
+func (e *Engine) autoRollbackLocked(reason string) {
+	e.canary.stageIdx = 0
+	e.resetCanaryStageLocked()
+	e.canary.lastTransition = e.canary.now()
+	e.canary.lastReason = reason
+}
+
+// canaryWindowDoneLocked reports whether the open window has both its calls and its dwell
+func (e *Engine) canaryWindowDoneLocked() bool {
+	w := e.canary.window
+	return e.canary.calls >= w.Calls && e.canary.now().Sub(e.canary.windowStart) >= w.Dwell
+}
+
+// startCanaryWindowLocked opens a new evaluation window, e.g. after a stage change or
+// a window that ended without one
+func (e *Engine) startCanaryWindowLocked() {
+	e.canary.calls = 0
+	e.canary.windowStart = e.canary.now()
+}
+
+// canaryPrimaryLocked returns the cheapest enabled provider other than the candidate
+func (e *Engine) canaryPrimaryLocked() *providers.ResilientProvider {
+	var best *providers.ResilientProvider
+	for _, p := range e.provs {
+		if !p.Enabled() || p.Name() == e.canary.candidate {
+			continue
+		}
+		if best == nil || cheaper(p, best, "") {
+			best = p
+		}
+	}
+	return best
+}
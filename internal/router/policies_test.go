@@ -2,7 +2,10 @@ package router
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
 )
@@ -35,6 +38,30 @@ func TestCheapest(t *testing.T) {
 	}
 }
 
+// unpricedProv only has a placeholder price, whatever the model
+type unpricedProv struct{ mockProv }
+
+func (u *unpricedProv) KnowsPrice(model string) bool { return false }
+
+func TestCheapestIgnoresPlaceholderPrices(t *testing.T) {
+	guess := rp(&unpricedProv{mockProv{name: "guess", cost: 0.5}})
+	known := rp(&mockProv{name: "known", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{guess, known})
+	if got := e.Choose("cheapest", "new-model"); got.Name() != "known" {
+		t.Fatalf("expected the provider with a real price, got %s", got.Name())
+	}
+	if ex := e.Explain("cheapest", "new-model"); ex.Providers[0].PriceKnown || !ex.Providers[1].PriceKnown {
+		t.Errorf("expected explain to flag the placeholder price, got %+v", ex.Providers)
+	}
+
+	// with nothing priced the placeholders are all there is to go on
+	other := rp(&unpricedProv{mockProv{name: "other", cost: 1}})
+	e = NewEngine([]*providers.ResilientProvider{other, guess})
+	if got := e.Choose("cheapest", "new-model"); got.Name() != "guess" {
+		t.Fatalf("expected the lowest placeholder when no price is known, got %s", got.Name())
+	}
+}
+
 func TestFastestP95(t *testing.T) {
 	a := rp(&mockProv{name: "a", cost: 2})
 	b := rp(&mockProv{name: "b", cost: 1})
@@ -50,6 +77,68 @@ func TestFastestP95(t *testing.T) {
 	}
 }
 
+func TestFastestP95NeedsMinSamples(t *testing.T) {
+	measured := rp(&mockProv{name: "measured", cost: 2})
+	lucky := rp(&mockProv{name: "lucky", cost: 3})
+	for i := 0; i < 100; i++ {
+		measured.Stats().Record(100, false)
+	}
+	e := NewEngine([]*providers.ResilientProvider{measured, lucky})
+	e.SetFastestP95Options(10, 0)
+
+	// a couple of fast samples aren't enough to beat a well-measured provider
+	for i := 0; i < 9; i++ {
+		lucky.Stats().Record(10, false)
+		if got := e.Choose("fastest_p95", ""); got.Name() != "measured" {
+			t.Fatalf("after %d samples want measured, got %s", i+1, got.Name())
+		}
+	}
+	lucky.Stats().Record(10, false)
+	if got := e.Choose("fastest_p95", ""); got.Name() != "lucky" {
+		t.Fatalf("with enough samples want lucky, got %s", got.Name())
+	}
+
+	// with nobody over the threshold the policy falls back to cost
+	cheap := rp(&mockProv{name: "cheap", cost: 1})
+	slow := rp(&mockProv{name: "slow", cost: 5})
+	for i := 0; i < 5; i++ {
+		cheap.Stats().Record(500, false)
+		slow.Stats().Record(50, false)
+	}
+	e = NewEngine([]*providers.ResilientProvider{cheap, slow})
+	got, reason, _ := e.decide("fastest_p95", "", e.providers(), e.rng.Float64)
+	if got.Name() != "cheap" {
+		t.Fatalf("below threshold want cheapest, got %s (%s)", got.Name(), reason)
+	}
+}
+
+func TestLatencySLO(t *testing.T) {
+	fast := rp(&mockProv{name: "fast", cost: 3})
+	ok := rp(&mockProv{name: "ok", cost: 1})
+	slow := rp(&mockProv{name: "slow", cost: 0.5})
+	for i := 0; i < 50; i++ {
+		fast.Stats().Record(50, false)
+		ok.Stats().Record(400, false)
+		slow.Stats().Record(3000, false)
+	}
+	e := NewEngine([]*providers.ResilientProvider{fast, ok, slow})
+	e.SetLatencySLO(time.Second)
+
+	// fast and ok both meet the target, so the cheaper of them wins; slow is cheapest
+	// overall but misses it
+	got, reason, _ := e.decide("latency_slo", "", e.providers(), e.rng.Float64)
+	if got.Name() != "ok" {
+		t.Fatalf("with two providers within the target want the cheaper ok, got %s (%s)", got.Name(), reason)
+	}
+
+	// nobody meets a 10ms target, so the fastest wins
+	e.SetLatencySLO(10 * time.Millisecond)
+	got, reason, _ = e.decide("latency_slo", "", e.providers(), e.rng.Float64)
+	if got.Name() != "fast" {
+		t.Fatalf("with none within the target want the fastest, got %s (%s)", got.Name(), reason)
+	}
+}
+
 func TestSLOBurnAware(t *testing.T) {
 	a := rp(&mockProv{name: "a", cost: 1})
 	b := rp(&mockProv{name: "b", cost: 2})
@@ -89,3 +178,540 @@ func TestCanaryRolloutAndRollback(t *testing.T) {
 		t.Fatalf("expected rollback keeping majority on primary, primaryCount=%d", primaryCount)
 	}
 }
+
+func TestCanaryStagePercentRoutesThatShare(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.ConfigureCanary([]float64{25}, CanaryWindow{Calls: 1_000_000}, 2.0) // window large enough that the stage never moves
+
+	if got := e.CanaryPercent(); got != 25 {
+		t.Fatalf("expected CanaryPercent 25, got %v", got)
+	}
+
+	const n = 20000
+	candidate := 0
+	for i := 0; i < n; i++ {
+		if e.Choose("canary", "").Name() == "b" {
+			candidate++
+		}
+	}
+	if share := float64(candidate) / n; share < 0.23 || share > 0.27 {
+		t.Fatalf("expected ~25%% of traffic on the candidate, got %.2f%%", share*100)
+	}
+}
+
+func TestCanaryTenantsAlwaysGetCandidate(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.ConfigureCanary([]float64{25}, CanaryWindow{Calls: 1_000_000}, 2.0)
+	e.SetCanaryTenants([]string{"internal"})
+
+	const n = 20000
+	candidate := 0
+	for i := 0; i < n; i++ {
+		if got := e.ChooseForTenant("internal", "", "canary", "", nil); got.Name() != "b" {
+			t.Fatalf("expected the targeted tenant on the candidate, got %s", got.Name())
+		}
+		if e.ChooseForTenant("customer", "", "canary", "", nil).Name() == "b" {
+			candidate++
+		}
+	}
+	if share := float64(candidate) / n; share < 0.23 || share > 0.27 {
+		t.Fatalf("expected other tenants to follow the 25%% stage, got %.2f%%", share*100)
+	}
+
+	// targeting only applies to the canary policy
+	if got := e.ChooseForTenant("internal", "", "cheapest", "", nil); got.Name() != "a" {
+		t.Errorf("expected cheapest to ignore canary targeting, got %s", got.Name())
+	}
+	e.SetCanaryTenants(nil)
+	if got := e.CanaryTenants(); len(got) != 0 {
+		t.Errorf("expected targeting cleared, got %v", got)
+	}
+}
+
+func TestCostWeightedCanaryMatchesSpendShare(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 4})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.ConfigureCanary([]float64{25}, CanaryWindow{Calls: 1_000_000}, 2.0)
+	e.SetCanaryMode(CanaryByCost)
+
+	// prompts from 10 to ~4000 tokens; spend is what each request costs where it lands
+	sizes := rand.New(rand.NewSource(7))
+	const n = 40000
+	var candidateSpend, totalSpend float64
+	for i := 0; i < n; i++ {
+		tokens := float64(10 + sizes.Intn(4000))
+		p := e.Choose("canary", "")
+		spend := tokens * p.CostPer1kTokensUSD("") / 1000
+		totalSpend += spend
+		if p.Name() == "b" {
+			candidateSpend += spend
+		}
+	}
+	if share := candidateSpend / totalSpend; share < 0.23 || share > 0.27 {
+		t.Fatalf("expected ~25%% of spend on the candidate, got %.2f%%", share*100)
+	}
+
+	// the pricier candidate gets well under a quarter of the requests
+	ex := e.Explain("canary", "")
+	if ex.Canary.Mode != CanaryByCost || ex.Canary.SelectPercent >= 25 {
+		t.Errorf("expected a reduced request share in cost mode, got %+v", ex.Canary)
+	}
+}
+
+func TestConfigureCanaryIgnoresOutOfRangeStages(t *testing.T) {
+	e := NewEngine([]*providers.ResilientProvider{rp(&mockProv{name: "a", cost: 1}), rp(&mockProv{name: "b", cost: 2})})
+	if got := e.CanaryPercent(); got != 1 {
+		t.Fatalf("expected default first stage of 1%%, got %v", got)
+	}
+
+	e.ConfigureCanary([]float64{-5, 0, 10, 150}, CanaryWindow{}, 0)
+	stages := e.CanaryStages()
+	if len(stages) != 1 || stages[0] != 10 {
+		t.Fatalf("expected only the 10%% stage to be kept, got %v", stages)
+	}
+}
+
+func TestCanaryTransitionsRecordReasonAndTime(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.ConfigureCanary([]float64{1, 5, 25}, CanaryWindow{Calls: 2}, 3.0)
+
+	if e.CanaryCandidateProvider() != "b" {
+		t.Fatalf("expected second cheapest as candidate, got %q", e.CanaryCandidateProvider())
+	}
+	if e.CanaryWindow().Calls != 2 || e.CanaryBurnMultiplier() != 3.0 {
+		t.Fatalf("expected window 2 and burn multiplier 3, got %d/%v", e.CanaryWindow().Calls, e.CanaryBurnMultiplier())
+	}
+	if !e.CanaryLastTransition().IsZero() || e.CanaryLastReason() != "" {
+		t.Fatalf("expected no transition recorded yet")
+	}
+
+	e.CanaryAdvance()
+	if e.CanaryStageIndex() != 1 || e.CanaryPercent() != 5 || e.CanaryLastReason() != "manual_advance" {
+		t.Fatalf("advance: got stage %d (%v%%) reason %q", e.CanaryStageIndex(), e.CanaryPercent(), e.CanaryLastReason())
+	}
+	advancedAt := e.CanaryLastTransition()
+	if advancedAt.IsZero() {
+		t.Fatal("advance: expected transition time to be set")
+	}
+
+	// two clean candidate calls fill the window and auto-advance to the last stage
+	e.RecordResult("b", false)
+	e.RecordResult("b", false)
+	if e.CanaryStageIndex() != 2 || e.CanaryLastReason() != "auto_advance" {
+		t.Fatalf("auto advance: got stage %d reason %q", e.CanaryStageIndex(), e.CanaryLastReason())
+	}
+
+	// advancing past the last stage is a no-op
+	lastAt := e.CanaryLastTransition()
+	e.CanaryAdvance()
+	if e.CanaryStageIndex() != 2 || e.CanaryLastReason() != "auto_advance" || !e.CanaryLastTransition().Equal(lastAt) {
+		t.Fatalf("advance at last stage should not transition")
+	}
+
+	e.CanaryRollback()
+	if e.CanaryStageIndex() != 0 || e.CanaryPercent() != 1 || e.CanaryLastReason() != "manual_rollback" {
+		t.Fatalf("rollback: got stage %d reason %q", e.CanaryStageIndex(), e.CanaryLastReason())
+	}
+	if e.CanaryLastTransition().Before(lastAt) {
+		t.Fatal("rollback: expected transition time to move forward")
+	}
+
+	// failing candidate calls burn past the multiplier and trigger an automatic rollback
+	e.CanaryAdvance()
+	for i := 0; i < 2; i++ {
+		b.Stats().Record(10, true)
+		e.RecordResult("b", true)
+	}
+	if e.CanaryStageIndex() != 0 || e.CanaryLastReason() != "auto_rollback_burn_rate" {
+		t.Fatalf("auto rollback: got stage %d reason %q", e.CanaryStageIndex(), e.CanaryLastReason())
+	}
+}
+
+func TestCanaryWindowTypes(t *testing.T) {
+	// newCanary returns an engine at stage 0 with a fake clock and a way to step it
+	newCanary := func(w CanaryWindow) (*Engine, func(time.Duration)) {
+		e := NewEngine([]*providers.ResilientProvider{rp(&mockProv{name: "a", cost: 1}), rp(&mockProv{name: "b", cost: 2})})
+		now := time.Now()
+		e.canary.now = func() time.Time { return now }
+		e.ConfigureCanary([]float64{1, 5, 25}, w, 2.0)
+		e.CanaryRollback()
+		return e, func(d time.Duration) { now = now.Add(d) }
+	}
+	calls := func(e *Engine, n int) {
+		for i := 0; i < n; i++ {
+			e.RecordResult("b", false)
+		}
+	}
+
+	if NewEngine(nil).CanaryWindow() != DefaultCanaryWindow || DefaultCanaryWindow.Calls == 0 || DefaultCanaryWindow.Dwell == 0 {
+		t.Fatalf("expected a hybrid default window, got %+v", DefaultCanaryWindow)
+	}
+
+	t.Run("calls", func(t *testing.T) {
+		e, _ := newCanary(CanaryWindow{Calls: 5})
+		calls(e, 4)
+		if e.CanaryStageIndex() != 0 {
+			t.Fatalf("expected no advance before 5 calls, got stage %d", e.CanaryStageIndex())
+		}
+		calls(e, 1)
+		if e.CanaryStageIndex() != 1 {
+			t.Fatalf("expected advance on the 5th call with no time passing, got stage %d", e.CanaryStageIndex())
+		}
+	})
+
+	t.Run("dwell", func(t *testing.T) {
+		e, advance := newCanary(CanaryWindow{Dwell: time.Minute})
+		calls(e, 1000)
+		if e.CanaryStageIndex() != 0 {
+			t.Fatalf("expected heavy traffic not to advance within the dwell, got stage %d", e.CanaryStageIndex())
+		}
+		advance(time.Minute)
+		calls(e, 1)
+		if e.CanaryStageIndex() != 1 {
+			t.Fatalf("expected advance on the first call after a minute, got stage %d", e.CanaryStageIndex())
+		}
+		calls(e, 1)
+		if e.CanaryStageIndex() != 1 {
+			t.Fatalf("expected the new stage to start its own dwell, got stage %d", e.CanaryStageIndex())
+		}
+	})
+
+	t.Run("hybrid", func(t *testing.T) {
+		e, advance := newCanary(CanaryWindow{Calls: 5, Dwell: time.Minute})
+		calls(e, 10)
+		if e.CanaryStageIndex() != 0 {
+			t.Fatalf("expected enough calls alone not to advance, got stage %d", e.CanaryStageIndex())
+		}
+		advance(time.Minute)
+		calls(e, 1)
+		if e.CanaryStageIndex() != 1 {
+			t.Fatalf("expected advance once calls and dwell are both met, got stage %d", e.CanaryStageIndex())
+		}
+		advance(time.Hour)
+		calls(e, 4)
+		if e.CanaryStageIndex() != 1 {
+			t.Fatalf("expected dwell alone not to advance a quiet stage, got stage %d", e.CanaryStageIndex())
+		}
+		calls(e, 1)
+		if e.CanaryStageIndex() != 2 {
+			t.Fatalf("expected advance on the 5th call of the stage, got stage %d", e.CanaryStageIndex())
+		}
+	})
+}
+
+func TestCanaryRollsBackOnLatencyRegression(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.ConfigureCanary([]float64{1, 5, 25}, CanaryWindow{Calls: 10}, 2.0)
+	e.CanaryAdvance()
+
+	// candidate is error free but 3x slower than the primary
+	for i := 0; i < 50; i++ {
+		a.Stats().Record(100, false)
+		b.Stats().Record(300, false)
+	}
+	for i := 0; i < 10; i++ {
+		e.RecordResult("b", false)
+	}
+	if e.CanaryStageIndex() != 0 || e.CanaryLastReason() != "auto_rollback_latency_regression" {
+		t.Fatalf("expected latency rollback, got stage %d reason %q", e.CanaryStageIndex(), e.CanaryLastReason())
+	}
+
+	// within the allowed ratio the canary keeps advancing
+	e.SetCanaryMaxP95Ratio(4.0)
+	for i := 0; i < 10; i++ {
+		e.RecordResult("b", false)
+	}
+	if e.CanaryStageIndex() != 1 || e.CanaryLastReason() != "auto_advance" {
+		t.Fatalf("expected advance under a 4x ratio, got stage %d reason %q", e.CanaryStageIndex(), e.CanaryLastReason())
+	}
+}
+
+func TestCanaryAdvanceNeedsMinSavings(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.ConfigureCanary([]float64{1, 5, 25}, CanaryWindow{Calls: 4}, 2.0)
+	e.SetCanaryMinSavingsPct(10)
+
+	// healthy, but nothing billed yet to compare
+	for i := 0; i < 4; i++ {
+		e.RecordResult("b", false)
+	}
+	if e.CanaryStageIndex() != 0 || e.CanaryAdvanceBlocked() != CanaryBlockedSavingsUnknown {
+		t.Fatalf("expected the stage held for unknown savings, got stage %d blocked %q", e.CanaryStageIndex(), e.CanaryAdvanceBlocked())
+	}
+
+	// healthy and only 5% cheaper than the primary
+	for i := 0; i < 4; i++ {
+		e.RecordCanaryCost("a", 0.010)
+		e.RecordCanaryCost("b", 0.0095)
+		e.RecordResult("b", false)
+	}
+	if pct, ok := e.CanarySavingsPct(); !ok || pct < 4.99 || pct > 5.01 {
+		t.Fatalf("expected 5%% savings, got %v (ok=%v)", pct, ok)
+	}
+	if e.CanaryStageIndex() != 0 || e.CanaryAdvanceBlocked() != CanaryBlockedInsufficientSavings {
+		t.Fatalf("expected the stage held for insufficient savings, got stage %d blocked %q", e.CanaryStageIndex(), e.CanaryAdvanceBlocked())
+	}
+
+	// cheaper candidate requests pull the average past the minimum
+	for i := 0; i < 4; i++ {
+		e.RecordCanaryCost("b", 0.005)
+		e.RecordResult("b", false)
+	}
+	if e.CanaryStageIndex() != 1 || e.CanaryLastReason() != "auto_advance" {
+		t.Fatalf("expected advance once savings clear the minimum, got stage %d reason %q", e.CanaryStageIndex(), e.CanaryLastReason())
+	}
+	// a new stage starts its own comparison
+	if _, ok := e.CanarySavingsPct(); ok || e.CanaryAdvanceBlocked() != "" {
+		t.Errorf("expected savings and block reset on advance")
+	}
+}
+
+func TestDisabledProviderNeverChosen(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+
+	a.SetEnabled(false)
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "canary"} {
+		for i := 0; i < 50; i++ {
+			if got := e.Choose(policy, ""); got == nil || got.Name() != "b" {
+				t.Fatalf("%s: want b while a is disabled, got %v", policy, got)
+			}
+		}
+	}
+
+	a.SetEnabled(true)
+	if got := e.Choose("cheapest", ""); got == nil || got.Name() != "a" {
+		t.Fatalf("want a after re-enable, got %v", got)
+	}
+}
+
+func TestSaturatedProviderFailsOver(t *testing.T) {
+	cheap := providers.WithResilience(&mockProv{name: "cheap", cost: 1}, providers.ResilienceOptions{CBWindowSize: 20, RPM: 60})
+	pricey := rp(&mockProv{name: "pricey", cost: 5})
+	e := NewEngine([]*providers.ResilientProvider{cheap, pricey})
+
+	if got := e.Choose("cheapest", ""); got != cheap {
+		t.Fatalf("expected cheap with quota to spare, got %s", got.Name())
+	}
+	// one call fills a 60 RPM quota's one-second bucket
+	if _, _, _, err := cheap.Complete(context.Background(), providers.CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Choose("cheapest", ""); got != pricey {
+		t.Errorf("expected failover from the saturated provider, got %s", got.Name())
+	}
+
+	// with nowhere else to go the saturated provider is still chosen
+	pricey.SetEnabled(false)
+	if got := e.Choose("cheapest", ""); got != cheap {
+		t.Errorf("expected the only provider despite its quota, got %v", got)
+	}
+}
+
+func TestForcedOpenProviderNeverChosen(t *testing.T) {
+	a := rp(&mockProv{name: "a", cost: 1})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+
+	a.ForceOpenCB()
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "canary"} {
+		for i := 0; i < 50; i++ {
+			if got := e.Choose(policy, ""); got == nil || got.Name() != "b" {
+				t.Fatalf("%s: want b while a's breaker is forced open, got %v", policy, got)
+			}
+		}
+	}
+
+	a.ResetCB()
+	if got := e.Choose("cheapest", ""); got == nil || got.Name() != "a" {
+		t.Fatalf("want a after breaker reset, got %v", got)
+	}
+}
+
+func TestTrippedProvidersNotChosen(t *testing.T) {
+	a := providers.WithResilience(&mockProv{name: "a", cost: 1, fail: true}, providers.ResilienceOptions{CBWindowSize: 2, CBCooldown: time.Minute})
+	b := rp(&mockProv{name: "b", cost: 2})
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	if err := e.Unavailable(); err != nil {
+		t.Fatalf("expected providers available, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, _, _ = a.Complete(context.Background(), providers.CompletionRequest{Prompt: "hi"})
+	}
+	// a's breaker is open until its cooldown ends, so b serves rather than a failing fast
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "canary", "latency_slo"} {
+		if got := e.Choose(policy, ""); got != b {
+			t.Fatalf("%s: want b while a is tripped, got %v", policy, got)
+		}
+	}
+
+	b.SetEnabled(false)
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "canary", "latency_slo"} {
+		if got := e.Choose(policy, ""); got != nil {
+			t.Fatalf("%s: want no provider with all tripped or disabled, got %s", policy, got.Name())
+		}
+	}
+	var ue *UnavailableError
+	if err := e.Unavailable(); !errors.As(err, &ue) || ue.RetryAfter <= 0 || ue.RetryAfter > time.Minute {
+		t.Fatalf("expected unavailable until a's cooldown ends, got %v", err)
+	}
+
+	if err := NewEngine(nil).Unavailable(); !errors.Is(err, ErrNoProviders) {
+		t.Errorf("expected ErrNoProviders with none configured, got %v", err)
+	}
+}
+
+func TestExplainMatchesChoice(t *testing.T) {
+	build := func() *Engine {
+		a := rp(&mockProv{name: "a", cost: 1})
+		b := rp(&mockProv{name: "b", cost: 2})
+		c := rp(&mockProv{name: "c", cost: 3})
+		for i := 0; i < 50; i++ {
+			a.Stats().Record(120, i%10 == 0) // cheapest but burning budget
+			b.Stats().Record(40, false)
+			c.Stats().Record(80, false)
+		}
+		c.SetEnabled(false)
+		e := NewEngine([]*providers.ResilientProvider{a, b, c})
+		e.ConfigureCanary([]float64{50}, CanaryWindow{Calls: 200}, 2.0)
+		return e
+	}
+
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "bogus"} {
+		e := build()
+		ex := e.Explain(policy, "m")
+		got := e.Choose(policy, "m")
+		if got == nil || ex.Chosen != got.Name() {
+			t.Errorf("%s: explanation chose %q, Choose returned %v", policy, ex.Chosen, got)
+		}
+		if ex.Reason == "" || len(ex.Providers) != 3 {
+			t.Errorf("%s: expected reason and signals for all providers, got %+v", policy, ex)
+		}
+	}
+
+	// canary: driving the explanation from an identically seeded engine RNG must reproduce Choose
+	live, shadow := build(), build()
+	for i := 0; i < 50; i++ {
+		got := live.Choose("canary", "m")
+		ex := shadow.explain("", "canary", "m", nil, shadow.rng.Float64)
+		if ex.Canary == nil || ex.Chosen != got.Name() {
+			t.Fatalf("call %d: explanation chose %q, Choose returned %s", i, ex.Chosen, got.Name())
+		}
+	}
+
+	ex := build().Explain("canary", "m")
+	if ex.Canary.Primary != "a" || ex.Canary.Candidate != "b" || ex.Canary.Percent != 50 {
+		t.Errorf("unexpected canary split %+v", ex.Canary)
+	}
+	for _, s := range ex.Providers {
+		if s.Name == "c" && s.Enabled {
+			t.Errorf("expected disabled provider to be reported as such")
+		}
+	}
+}
+
+func TestExplainDoesNotConsumeCanaryRNG(t *testing.T) {
+	e := NewEngine([]*providers.ResilientProvider{rp(&mockProv{name: "a", cost: 1}), rp(&mockProv{name: "b", cost: 2})})
+	ref := NewEngine([]*providers.ResilientProvider{rp(&mockProv{name: "a", cost: 1}), rp(&mockProv{name: "b", cost: 2})})
+	for i := 0; i < 10; i++ {
+		e.Explain("canary", "")
+	}
+	if got, want := e.rng.Float64(), ref.rng.Float64(); got != want {
+		t.Fatalf("Explain advanced the engine RNG: %v != %v", got, want)
+	}
+}
+
+func TestChooseAllowedSkipsDeniedProvider(t *testing.T) {
+	e := NewEngine([]*providers.ResilientProvider{rp(&mockProv{name: "a", cost: 1}), rp(&mockProv{name: "b", cost: 2})})
+	notA := func(name string) bool { return name != "a" }
+
+	for _, policy := range []string{"cheapest", "fastest_p95", "slo_burn_aware", "canary"} {
+		for i := 0; i < 50; i++ {
+			if got := e.ChooseAllowed(policy, "", notA); got == nil || got.Name() != "b" {
+				t.Fatalf("%s: want b with a denied, got %v", policy, got)
+			}
+		}
+	}
+	if got := e.ChooseAllowed("cheapest", "", func(string) bool { return false }); got != nil {
+		t.Fatalf("want nil with every provider denied, got %v", got.Name())
+	}
+	if got := e.Choose("cheapest", ""); got == nil || got.Name() != "a" {
+		t.Fatalf("want a for unrestricted callers, got %v", got)
+	}
+}
+
+func TestChooseStickyKeepsConversationOnProvider(t *testing.T) {
+	ma, mb := &mockProv{name: "a", cost: 1}, &mockProv{name: "b", cost: 2}
+	a, b := rp(ma), rp(mb)
+	e := NewEngine([]*providers.ResilientProvider{a, b})
+	e.SetStickiness(time.Minute, 10)
+
+	if got := e.ChooseSticky("c1", "cheapest", "", nil); got == nil || got.Name() != "a" {
+		t.Fatalf("want a for the first turn, got %v", got)
+	}
+	// a is no longer cheapest, but c1 already started there
+	ma.cost = 3
+	if got := e.ChooseSticky("c1", "cheapest", "", nil); got == nil || got.Name() != "a" {
+		t.Fatalf("want c1 kept on a, got %v", got)
+	}
+	if got := e.ChooseSticky("c2", "cheapest", "", nil); got == nil || got.Name() != "b" {
+		t.Fatalf("want a new conversation routed by policy to b, got %v", got)
+	}
+	if got := e.ChooseSticky("", "cheapest", "", nil); got == nil || got.Name() != "b" {
+		t.Fatalf("want requests without a conversation routed by policy, got %v", got)
+	}
+
+	// a tripped breaker moves the conversation for good
+	ma.fail = true
+	for a.CBStateValue() != 0 {
+		_, _, _, _ = a.Complete(context.Background(), providers.CompletionRequest{Prompt: "hi"})
+	}
+	if got := e.ChooseSticky("c1", "cheapest", "", nil); got == nil || got.Name() != "b" {
+		t.Fatalf("want c1 moved off unhealthy a, got %v", got)
+	}
+	a.ResetCB()
+	if got := e.ChooseSticky("c1", "cheapest", "", nil); got == nil || got.Name() != "b" {
+		t.Fatalf("want c1 to stay on b once moved, got %v", got)
+	}
+
+	// pins only last ttl past the latest turn
+	now := time.Now()
+	e.sticky.now = func() time.Time { return now.Add(2 * time.Minute) }
+	ma.cost = 1
+	if got := e.ChooseSticky("c1", "cheapest", "", nil); got == nil || got.Name() != "a" {
+		t.Fatalf("want an expired conversation routed by policy, got %v", got)
+	}
+
+	e.SetStickiness(0, 0)
+	ma.cost = 3
+	if got := e.ChooseSticky("c1", "cheapest", "", nil); got == nil || got.Name() != "b" {
+		t.Fatalf("want stickiness off to route by policy, got %v", got)
+	}
+}
+
+func TestStickyRoutesEvictLeastRecent(t *testing.T) {
+	s := newStickyRoutes(time.Minute, 2)
+	s.put("c1", "a")
+	s.put("c2", "b")
+	s.put("c1", "a")
+	s.put("c3", "a")
+	if _, ok := s.get("c2"); ok {
+		t.Error("want the least recently routed conversation evicted")
+	}
+	if p, ok := s.get("c1"); !ok || p != "a" {
+		t.Errorf("want c1 kept on a, got %q %v", p, ok)
+	}
+}
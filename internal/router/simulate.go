@@ -0,0 +1,54 @@
+package router
+
+import (
+	"math/rand"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+// Simulation is the spread of providers a policy picked over repeated choices
+type Simulation struct {
+	Policy string `json:"policy"`
+	Model  string `json:"model"`
+	Runs   int    `json:"runs"`
+	// Selected counts how many runs picked each provider
+	Selected map[string]int `json:"selected"`
+	// Unrouted counts runs where no provider was available
+	Unrouted        int     `json:"unrouted"`
+	AvgCostPer1kUSD float64 `json:"avg_cost_per_1k_tokens_usd"`
+	AvgP95LatencyMs float64 `json:"avg_p95_latency_ms"`
+}
+
+// Simulate runs the policy n times against the current provider stats and reports
+// which providers it picked. Like Explain it has no side effects: canary rolls come
+// from a throwaway source and nothing is recorded against the canary window.
+func (e *Engine) Simulate(policy string, model string, n int) Simulation {
+	return e.simulate(policy, model, n, rand.Float64)
+}
+
+func (e *Engine) simulate(policy string, model string, n int, roll func() float64) Simulation {
+	sim := Simulation{Policy: policy, Model: model, Runs: n, Selected: map[string]int{}}
+	ps := e.providers()
+	byName := map[string]*providers.ResilientProvider{}
+	for i := 0; i < n; i++ {
+		chosen, _, _ := e.decide(policy, model, ps, roll)
+		if chosen == nil {
+			sim.Unrouted++
+			continue
+		}
+		sim.Selected[chosen.Name()]++
+		byName[chosen.Name()] = chosen
+	}
+	routed := n - sim.Unrouted
+	if routed == 0 {
+		return sim
+	}
+	// weight each provider's price and p95 by its share rather than re-reading them per run
+	for name, count := range sim.Selected {
+		p := byName[name]
+		share := float64(count) / float64(routed)
+		sim.AvgCostPer1kUSD += share * p.CostPer1kTokensUSD(model)
+		sim.AvgP95LatencyMs += share * float64(p.Stats().P95LatencyMs())
+	}
+	return sim
+}
@@ -0,0 +1,135 @@
+package router
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+type stickyEntry struct {
+	conversation string
+	provider     string
+	expires      time.Time
+}
+
+// stickyRoutes remembers the provider each conversation was routed to, for ttl after
+// its last turn, evicting least recently used conversations past maxEntries
+type stickyRoutes struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+func newStickyRoutes(ttl time.Duration, maxEntries int) *stickyRoutes {
+	return &stickyRoutes{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// get returns the provider conversation is pinned to, if the pin hasn't expired
+func (s *stickyRoutes) get(conversation string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[conversation]
+	if !ok {
+		return "", false
+	}
+	e := el.Value.(*stickyEntry)
+	if !s.now().Before(e.expires) {
+		s.ll.Remove(el)
+		delete(s.items, conversation)
+		return "", false
+	}
+	return e.provider, true
+}
+
+// put pins conversation to provider, restarting its ttl
+func (s *stickyRoutes) put(conversation, provider string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires := s.now().Add(s.ttl)
+	if el, ok := s.items[conversation]; ok {
+		el.Value = &stickyEntry{conversation: conversation, provider: provider, expires: expires}
+		s.ll.MoveToFront(el)
+		return
+	}
+	s.items[conversation] = s.ll.PushFront(&stickyEntry{conversation: conversation, provider: provider, expires: expires})
+	for s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		oldest := s.ll.Back()
+		s.ll.Remove(oldest)
+		delete(s.items, oldest.Value.(*stickyEntry).conversation)
+	}
+}
+
+// SetStickiness keeps each conversation on the provider its first turn was routed to
+// for ttl after its latest turn, remembering at most maxConversations. A ttl of zero
+// or less turns stickiness off.
+func (e *Engine) SetStickiness(ttl time.Duration, maxConversations int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if ttl <= 0 {
+		e.sticky = nil
+		return
+	}
+	e.sticky = newStickyRoutes(ttl, maxConversations)
+}
+
+// Stickiness returns the conversation pin ttl and capacity; a zero ttl means off
+func (e *Engine) Stickiness() (ttl time.Duration, maxConversations int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.sticky == nil {
+		return 0, 0
+	}
+	return e.sticky.ttl, e.sticky.maxEntries
+}
+
+// ChooseSticky is ChooseAllowed for one turn of a conversation. With stickiness on,
+// a conversation stays on its provider while that provider is still allowed, in
+// rotation and its breaker isn't open; otherwise the policy picks again and the
+// conversation moves. An empty conversation or a nil allow behaves like Choose.
+func (e *Engine) ChooseSticky(conversation string, policy string, model string, allow func(name string) bool) *providers.ResilientProvider {
+	return e.ChooseForTenant("", conversation, policy, model, allow)
+}
+
+// ChooseForTenant is ChooseSticky for a request from tenant, which the canary may
+// target (see SetCanaryTenants)
+func (e *Engine) ChooseForTenant(tenant string, conversation string, policy string, model string, allow func(name string) bool) *providers.ResilientProvider {
+	var ps []*providers.ResilientProvider
+	for _, p := range e.providers() {
+		if allow == nil || allow(p.Name()) {
+			ps = append(ps, p)
+		}
+	}
+
+	e.mu.RLock()
+	sticky := e.sticky
+	e.mu.RUnlock()
+	if sticky == nil || conversation == "" {
+		chosen, _, _ := e.decideFor(tenant, policy, model, ps, e.rng.Float64)
+		return chosen
+	}
+
+	if name, ok := sticky.get(conversation); ok {
+		for _, p := range ps {
+			if p.Name() == name && p.CBStateValue() > 0 {
+				sticky.put(conversation, name)
+				return p
+			}
+		}
+	}
+	chosen, _, _ := e.decideFor(tenant, policy, model, ps, e.rng.Float64)
+	if chosen != nil {
+		sticky.put(conversation, chosen.Name())
+	}
+	return chosen
+}
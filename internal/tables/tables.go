@@ -0,0 +1,194 @@
+// Package tables creates the DynamoDB tables the router's stores expect, with their
+// key schemas, indexes and TTL, so a new deployment doesn't have to set them up by hand
+package tables
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// APIKeyIndex is the tenants table's global secondary index on api_key_hash
+const APIKeyIndex = "api_key_hash-index"
+
+// TTLAttribute holds the expiry of idempotency records, in Unix seconds
+const TTLAttribute = "ttl"
+
+// activeTimeout bounds the wait for a newly created table to become usable
+const activeTimeout = 2 * time.Minute
+
+// dynamoAPI is the part of the DynamoDB client Ensure uses
+type dynamoAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// Names are the configured table names; an empty name is skipped
+type Names struct {
+	// Tenants is DDB_TENANTS_TABLE, keyed by tenant_id with sk "meta"
+	Tenants string
+	// Usage is DDB_USAGE_TABLE, keyed by pk/sk; idempotency records share it and
+	// expire through its TTL
+	Usage string
+}
+
+// Result says what Ensure did to one table
+type Result struct {
+	Table      string
+	Created    bool
+	TTLEnabled bool
+}
+
+// tenantsTable is the tenants table definition for name
+func tenantsTable(name string) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName:   aws.String(name),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("tenant_id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("api_key_hash"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("tenant_id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{{
+			IndexName:  aws.String(APIKeyIndex),
+			KeySchema:  []types.KeySchemaElement{{AttributeName: aws.String("api_key_hash"), KeyType: types.KeyTypeHash}},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		}},
+	}
+}
+
+// usageTable is the usage and idempotency table definition for name
+func usageTable(name string) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName:   aws.String(name),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		},
+	}
+}
+
+// Ensure creates each named table that doesn't exist yet and turns on TTL for the
+// usage table. Tables that already exist are left alone apart from TTL, but one
+// whose key schema differs from what the stores expect is an error. Running it
+// again is a no-op.
+func Ensure(ctx context.Context, client dynamoAPI, names Names) ([]Result, error) {
+	var results []Result
+	if names.Tenants != "" {
+		created, err := ensureTable(ctx, client, tenantsTable(names.Tenants))
+		if err != nil {
+			return results, err
+		}
+		results = append(results, Result{Table: names.Tenants, Created: created})
+	}
+	if names.Usage != "" {
+		created, err := ensureTable(ctx, client, usageTable(names.Usage))
+		if err != nil {
+			return results, err
+		}
+		enabled, err := ensureTTL(ctx, client, names.Usage)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, Result{Table: names.Usage, Created: created, TTLEnabled: enabled})
+	}
+	return results, nil
+}
+
+// ensureTable creates def's table unless it exists, waiting until it is active, and
+// reports whether it was created
+func ensureTable(ctx context.Context, client dynamoAPI, def *dynamodb.CreateTableInput) (bool, error) {
+	name := aws.ToString(def.TableName)
+	out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: def.TableName})
+	if err == nil {
+		return false, checkKeySchema(name, out.Table.KeySchema, def.KeySchema)
+	}
+	if !errors.As(err, new(*types.ResourceNotFoundException)) {
+		return false, fmt.Errorf("describe table %s: %w", name, err)
+	}
+
+	created := true
+	if _, err := client.CreateTable(ctx, def); err != nil {
+		// someone else is creating it; wait for theirs like ours
+		if !errors.As(err, new(*types.ResourceInUseException)) {
+			return false, fmt.Errorf("create table %s: %w", name, err)
+		}
+		created = false
+	}
+	waiter := dynamodb.NewTableExistsWaiter(client, func(o *dynamodb.TableExistsWaiterOptions) {
+		o.MinDelay = time.Second
+	})
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: def.TableName}, activeTimeout); err != nil {
+		return created, fmt.Errorf("wait for table %s: %w", name, err)
+	}
+	return created, nil
+}
+
+// checkKeySchema reports an existing table whose keys differ from want
+func checkKeySchema(table string, have, want []types.KeySchemaElement) error {
+	mismatch := len(have) != len(want)
+	for i := 0; !mismatch && i < len(want); i++ {
+		mismatch = aws.ToString(have[i].AttributeName) != aws.ToString(want[i].AttributeName) || have[i].KeyType != want[i].KeyType
+	}
+	if mismatch {
+		return fmt.Errorf("table %s exists with key schema %s, want %s", table, formatKeys(have), formatKeys(want))
+	}
+	return nil
+}
+
+// formatKeys renders a key schema like "pk HASH, sk RANGE"
+func formatKeys(keys []types.KeySchemaElement) string {
+	s := ""
+	for i, k := range keys {
+		if i > 0 {
+			s += ", "
+		}
+		s += aws.ToString(k.AttributeName) + " " + string(k.KeyType)
+	}
+	return s
+}
+
+// ensureTTL enables TTL on TTLAttribute unless it is already on, reporting whether it
+// had to be turned on
+func ensureTTL(ctx context.Context, client dynamoAPI, table string) (bool, error) {
+	out, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(table)})
+	if err != nil {
+		return false, fmt.Errorf("describe TTL of %s: %w", table, err)
+	}
+	if d := out.TimeToLiveDescription; d != nil {
+		switch d.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			if aws.ToString(d.AttributeName) != TTLAttribute {
+				return false, fmt.Errorf("table %s has TTL on %s, want %s", table, aws.ToString(d.AttributeName), TTLAttribute)
+			}
+			return false, nil
+		}
+	}
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("enable TTL on %s: %w", table, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,130 @@
+package tables
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamo keeps table definitions and TTL settings in memory; created tables are
+// active straight away
+type fakeDynamo struct {
+	tables  map[string]*dynamodb.CreateTableInput
+	ttl     map[string]string
+	creates int
+	ttlSets int
+}
+
+func newFakeDynamo() *fakeDynamo {
+	return &fakeDynamo{tables: map[string]*dynamodb.CreateTableInput{}, ttl: map[string]string{}}
+}
+
+func (f *fakeDynamo) DescribeTable(_ context.Context, in *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	def, ok := f.tables[aws.ToString(in.TableName)]
+	if !ok {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("table not found")}
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName:   def.TableName,
+		TableStatus: types.TableStatusActive,
+		KeySchema:   def.KeySchema,
+	}}, nil
+}
+
+func (f *fakeDynamo) CreateTable(_ context.Context, in *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	name := aws.ToString(in.TableName)
+	if _, ok := f.tables[name]; ok {
+		return nil, &types.ResourceInUseException{Message: aws.String("table exists")}
+	}
+	f.tables[name] = in
+	f.creates++
+	return &dynamodb.CreateTableOutput{}, nil
+}
+
+func (f *fakeDynamo) DescribeTimeToLive(_ context.Context, in *dynamodb.DescribeTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	d := &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	if attr, ok := f.ttl[aws.ToString(in.TableName)]; ok {
+		d = &types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusEnabled, AttributeName: aws.String(attr)}
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: d}, nil
+}
+
+func (f *fakeDynamo) UpdateTimeToLive(_ context.Context, in *dynamodb.UpdateTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	f.ttl[aws.ToString(in.TableName)] = aws.ToString(in.TimeToLiveSpecification.AttributeName)
+	f.ttlSets++
+	return &dynamodb.UpdateTimeToLiveOutput{}, nil
+}
+
+func TestEnsureCreatesTables(t *testing.T) {
+	ddb := newFakeDynamo()
+	names := Names{Tenants: "router-tenants", Usage: "router-usage"}
+	results, err := Ensure(context.Background(), ddb, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Created || !results[1].Created || !results[1].TTLEnabled {
+		t.Fatalf("expected both tables created and TTL enabled, got %+v", results)
+	}
+
+	tenants := ddb.tables["router-tenants"]
+	if got := formatKeys(tenants.KeySchema); got != "tenant_id HASH, sk RANGE" {
+		t.Errorf("tenants key schema = %s", got)
+	}
+	if len(tenants.GlobalSecondaryIndexes) != 1 || aws.ToString(tenants.GlobalSecondaryIndexes[0].IndexName) != APIKeyIndex {
+		t.Fatalf("expected the %s index, got %+v", APIKeyIndex, tenants.GlobalSecondaryIndexes)
+	}
+	if got := formatKeys(tenants.GlobalSecondaryIndexes[0].KeySchema); got != "api_key_hash HASH" {
+		t.Errorf("api key index schema = %s", got)
+	}
+	if got := formatKeys(ddb.tables["router-usage"].KeySchema); got != "pk HASH, sk RANGE" {
+		t.Errorf("usage key schema = %s", got)
+	}
+	if ddb.ttl["router-usage"] != TTLAttribute {
+		t.Errorf("expected TTL on %s for the usage table, got %q", TTLAttribute, ddb.ttl["router-usage"])
+	}
+	if _, ok := ddb.ttl["router-tenants"]; ok {
+		t.Error("expected no TTL on the tenants table")
+	}
+
+	// a second run finds everything in place
+	results, err = Ensure(context.Background(), ddb, names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ddb.creates != 2 || ddb.ttlSets != 1 {
+		t.Errorf("expected a rerun to change nothing, got %d creates and %d TTL updates", ddb.creates, ddb.ttlSets)
+	}
+	if results[0].Created || results[1].Created || results[1].TTLEnabled {
+		t.Errorf("expected a rerun to report nothing done, got %+v", results)
+	}
+}
+
+func TestEnsureSkipsUnnamedTables(t *testing.T) {
+	ddb := newFakeDynamo()
+	results, err := Ensure(context.Background(), ddb, Names{Usage: "router-usage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(ddb.tables) != 1 {
+		t.Errorf("expected only the usage table, got %+v", results)
+	}
+}
+
+func TestEnsureRejectsMismatchedKeySchema(t *testing.T) {
+	ddb := newFakeDynamo()
+	ddb.tables["router-usage"] = &dynamodb.CreateTableInput{
+		TableName: aws.String("router-usage"),
+		KeySchema: []types.KeySchemaElement{{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash}},
+	}
+	_, err := Ensure(context.Background(), ddb, Names{Usage: "router-usage"})
+	if err == nil || !strings.Contains(err.Error(), "want pk HASH, sk RANGE") {
+		t.Fatalf("expected a key schema mismatch, got %v", err)
+	}
+	if ddb.ttlSets != 0 {
+		t.Error("expected TTL untouched on a mismatched table")
+	}
+}
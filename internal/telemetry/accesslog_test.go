@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = prev })
+	return &buf
+}
+
+func TestAccessLogLine(t *testing.T) {
+	buf := captureLog(t)
+	h := RequestIDMiddleware(AccessLog(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AnnotateTenant(r.Context(), "t-1")
+		AnnotateProvider(r.Context(), "mock")
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/v1/estimate?prompt=secret+prompt", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{"method": "GET", "path": "/v1/estimate", "status": float64(201), "tenant": "t-1", "provider": "mock", "request_id": "req-1"}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s: want %v, got %v", k, v, line[k])
+		}
+	}
+	if ms, ok := line["latency_ms"].(float64); !ok || ms < 5 {
+		t.Errorf("expected latency_ms >= 5, got %v", line["latency_ms"])
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Error("query string leaked into the access log")
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	buf := captureLog(t)
+	status := http.StatusOK
+	h := AccessLog(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/healthz", nil))
+	if buf.Len() != 0 {
+		t.Fatalf("expected sample 0 to drop successful requests, got %q", buf.String())
+	}
+	status = http.StatusBadGateway
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/infer", nil))
+	if !strings.Contains(buf.String(), `"status":502`) {
+		t.Fatalf("expected server errors to be logged regardless of sampling, got %q", buf.String())
+	}
+}
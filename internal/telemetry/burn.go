@@ -0,0 +1,80 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+	"github.com/rs/zerolog/log"
+)
+
+// BurnWindows are the rolling windows exported on router_burn_rate. Pairs of
+// short/long windows (5m/1h, 30m/6h) back multi-window multi-burn-rate alerts.
+// Note each window is still bounded by the provider's stats sample size.
+var BurnWindows = []struct {
+	Label    string
+	Duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+}
+
+// BurnSampler periodically exports per-provider and fleet-wide burn rates so the
+// gauges reflect all providers rather than whichever one served the last request
+type BurnSampler struct {
+	sloTarget float64
+	providers func() []*providers.ResilientProvider
+	burning   map[string]bool
+}
+
+// NewBurnSampler creates a sampler for the given error-rate SLO target (e.g. 0.01 for 99%)
+func NewBurnSampler(sloTarget float64, provs func() []*providers.ResilientProvider) *BurnSampler {
+	return &BurnSampler{sloTarget: sloTarget, providers: provs, burning: make(map[string]bool)}
+}
+
+// Run samples every interval until ctx is cancelled
+func (s *BurnSampler) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	s.Sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Sample()
+		}
+	}
+}
+
+// Sample computes and exports burn rates for every window once
+func (s *BurnSampler) Sample() {
+	ps := s.providers()
+	for _, w := range BurnWindows {
+		var fleetTotal, fleetErrs int
+		for _, p := range ps {
+			total, errs := p.Stats().CountsSince(w.Duration)
+			fleetTotal += total
+			fleetErrs += errs
+			BurnRate.WithLabelValues(p.Name(), w.Label).Set(s.burn(total, errs))
+		}
+		BurnRate.WithLabelValues("all", w.Label).Set(s.burn(fleetTotal, fleetErrs))
+	}
+
+	// warn once when a provider starts burning budget on the fast window, not on every sample
+	for _, p := range ps {
+		total, errs := p.Stats().CountsSince(time.Minute)
+		burning := s.burn(total, errs) > 1.0
+		if burning && !s.burning[p.Name()] {
+			log.Warn().Str("provider", p.Name()).Float64("burn_1m", s.burn(total, errs)).Msg("error budget burning")
+		}
+		s.burning[p.Name()] = burning
+	}
+}
+
+func (s *BurnSampler) burn(total, errs int) float64 {
+	return burnRate(total, errs, s.sloTarget)
+}
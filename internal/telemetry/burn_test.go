@@ -0,0 +1,40 @@
+package telemetry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+func TestBurnSamplerSeededStats(t *testing.T) {
+	a := providers.WithResilience(providers.NewMockProvider(10, 20, 0, 1), providers.ResilienceOptions{CBWindowSize: 20})
+	b := providers.WithResilience(providers.NewMockProvider(10, 20, 0, 2), providers.ResilienceOptions{CBWindowSize: 20})
+	// a: 2% errors, b: no errors
+	for i := 0; i < 50; i++ {
+		a.Stats().Record(10, i < 1)
+		b.Stats().Record(10, false)
+	}
+	provs := []*providers.ResilientProvider{a}
+
+	if got := ProviderBurnRate(a, time.Hour, 0.99); math.Abs(got-2.0) > 1e-9 {
+		t.Errorf("expected ProviderBurnRate 2.0 at a 99%% target, got %v", got)
+	}
+
+	NewBurnSampler(0.01, func() []*providers.ResilientProvider { return provs }).Sample()
+
+	for _, w := range BurnWindows {
+		if got := testutil.ToFloat64(BurnRate.WithLabelValues("mock", w.Label)); math.Abs(got-2.0) > 1e-9 {
+			t.Errorf("window %s: expected provider burn 2.0, got %v", w.Label, got)
+		}
+	}
+
+	// fleet burn blends both providers' outcomes
+	provs = []*providers.ResilientProvider{a, b}
+	NewBurnSampler(0.01, func() []*providers.ResilientProvider { return provs }).Sample()
+	if got := testutil.ToFloat64(BurnRate.WithLabelValues("all", "5m")); math.Abs(got-1.0) > 1e-9 {
+		t.Errorf("expected fleet burn 1.0, got %v", got)
+	}
+}
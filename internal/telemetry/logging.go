@@ -0,0 +1,35 @@
+package telemetry
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultLogFormat is console when out is a terminal and json otherwise, so local runs
+// stay readable while containers emit lines a log pipeline can parse
+func DefaultLogFormat(out *os.File) string {
+	if fi, err := out.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return "console"
+	}
+	return "json"
+}
+
+// NewLogger writes to out as JSON, or human-readable text when format is "console".
+// An unparseable level falls back to info.
+func NewLogger(out io.Writer, format, level string) zerolog.Logger {
+	zerolog.TimeFieldFormat = time.RFC3339
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil || level == "" {
+		lvl = zerolog.InfoLevel
+	}
+	if format == "console" {
+		// colour only when a terminal will render it
+		f, ok := out.(*os.File)
+		noColor := !ok || DefaultLogFormat(f) != "console"
+		out = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339, NoColor: noColor}
+	}
+	return zerolog.New(out).Level(lvl).With().Timestamp().Logger()
+}
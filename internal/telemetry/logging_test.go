@@ -0,0 +1,39 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLoggerFormats(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, "json", "debug")
+	logger.Debug().Str("provider", "mock").Msg("routed")
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", buf.String(), err)
+	}
+	if line["provider"] != "mock" || line["message"] != "routed" || line["level"] != "debug" {
+		t.Errorf("unexpected fields %v", line)
+	}
+	if _, err := time.Parse(time.RFC3339, line["time"].(string)); err != nil {
+		t.Errorf("expected an RFC3339 time, got %v", line["time"])
+	}
+
+	buf.Reset()
+	logger = NewLogger(&buf, "console", "info")
+	logger.Info().Str("provider", "mock").Msg("routed")
+	if json.Valid(buf.Bytes()) || !strings.Contains(buf.String(), "provider=mock") {
+		t.Errorf("expected console text, got %q", buf.String())
+	}
+
+	buf.Reset()
+	logger = NewLogger(&buf, "json", "warn")
+	logger.Info().Msg("dropped")
+	if buf.Len() != 0 {
+		t.Errorf("expected info to be filtered at warn level, got %q", buf.String())
+	}
+}
@@ -1,10 +1,12 @@
 package telemetry
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
@@ -28,11 +30,44 @@ var (
 	CostUSDTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "router_cost_usd_total",
-			Help: "Accumulated provider cost in USD",
+			Help: "Accumulated provider cost in USD by provider and routing policy",
+		},
+		[]string{"provider", "policy"},
+	)
+
+	RequestCostUSD = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "router_request_cost_usd",
+			Help:    "Provider cost in USD of each successful request",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
 		},
 		[]string{"provider"},
 	)
 
+	TokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_tokens_total",
+			Help: "Tokens of successful requests by provider and direction (in = prompt, out = completion), as estimated for billing",
+		},
+		[]string{"provider", "direction"},
+	)
+
+	ProviderRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_provider_retries_total",
+			Help: "Provider call attempts retried after a failure",
+		},
+		[]string{"provider"},
+	)
+
+	ProviderQuotaSaturation = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "router_provider_quota_saturation",
+			Help: "Share of a provider's configured per-minute quota in use (0..1), by limit (requests, tokens)",
+		},
+		[]string{"provider", "limit"},
+	)
+
 	ErrorsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "router_errors_total",
@@ -52,9 +87,17 @@ var (
 	BurnRate = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "router_burn_rate",
-			Help: "Error-budget burn rate over rolling windows",
+			Help: "Error-budget burn rate over rolling windows per provider (provider=\"all\" for the fleet)",
+		},
+		[]string{"provider", "window"},
+	)
+
+	UsageWarningsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_usage_warnings_total",
+			Help: "Tenants crossing a soft usage limit, counted once per tenant per period, by limit",
 		},
-		[]string{"window"},
+		[]string{"limit"},
 	)
 
 	AdminActionsTotal = prometheus.NewCounterVec(
@@ -65,16 +108,117 @@ var (
 		[]string{"action"},
 	)
 
+	ClientCancellationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_client_cancellations_total",
+			Help: "Requests abandoned by the client before the provider call finished",
+		},
+		[]string{"provider", "policy"},
+	)
+
+	ResponseCacheTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_response_cache_total",
+			Help: "Response cache lookups by result (hit or miss)",
+		},
+		[]string{"result"},
+	)
+
+	ContentDedupTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_content_dedup_total",
+			Help: "Deduplicated requests by result (hit when served by an identical request's provider call, miss when making the call)",
+		},
+		[]string{"result"},
+	)
+
+	IdempotencyTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_idempotency_total",
+			Help: "Idempotency-Key lookups by result (hit, miss, stored, conflict; too_large when a response was too big to replay, not_replayable when its key is repeated)",
+		},
+		[]string{"result"},
+	)
+
+	GlobalInflight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "router_global_inflight",
+			Help: "Infer requests currently holding a global concurrency slot",
+		},
+	)
+
+	ShedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "router_shed_total",
+			Help: "Infer requests rejected with 503 because MAX_GLOBAL_CONCURRENCY was reached",
+		},
+	)
+
+	TenantQueueWaitMs = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "router_tenant_queue_wait_ms",
+			Help:    "Time infer requests spent queued for a global concurrency slot, by tenant plan",
+			Buckets: []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		},
+		[]string{"plan"},
+	)
+
+	RequestsByPriority = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_requests_by_priority",
+			Help: "Infer requests reaching global admission by priority class and outcome (admitted, shed)",
+		},
+		[]string{"priority", "outcome"},
+	)
+
+	BedrockRegionRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "router_bedrock_region_requests_total",
+			Help: "Bedrock calls by region and outcome (ok, failover to the next region, error)",
+		},
+		[]string{"region", "outcome"},
+	)
+
 	CanaryStage = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "router_canary_stage",
 			Help: "Current canary traffic percentage",
 		},
 	)
+
+	CostUSDPerMinute = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "router_cost_usd_per_minute",
+			Help: "Spend over the last minute, from router_cost_usd_total",
+		},
+	)
 )
 
 func MustRegisterMetrics() {
-	prometheus.MustRegister(RequestsTotal, LatencyMs, CostUSDTotal, ErrorsTotal, CBState, BurnRate, AdminActionsTotal, CanaryStage)
+	prometheus.MustRegister(RequestsTotal, LatencyMs, CostUSDTotal, RequestCostUSD, TokensTotal, ProviderRetriesTotal, ProviderQuotaSaturation, ErrorsTotal, CBState, BurnRate, UsageWarningsTotal, AdminActionsTotal, ClientCancellationsTotal, ResponseCacheTotal, ContentDedupTotal, IdempotencyTotal, GlobalInflight, ShedTotal, TenantQueueWaitMs, RequestsByPriority, BedrockRegionRequestsTotal, CanaryStage, CostUSDPerMinute)
+}
+
+// ObserveLatency records a LatencyMs observation. When ctx carries a sampled span its
+// trace ID is attached as an exemplar, so a slow bucket links straight to the trace.
+func ObserveLatency(ctx context.Context, provider, policy string, ms float64) {
+	obs := LatencyMs.WithLabelValues(provider, policy)
+	sc := trace.SpanContextFromContext(ctx)
+	if eo, ok := obs.(prometheus.ExemplarObserver); ok && sc.IsValid() && sc.IsSampled() {
+		eo.ObserveWithExemplar(ms, prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+	obs.Observe(ms)
+}
+
+// AddTokens counts a successful request's prompt and completion tokens against provider
+func AddTokens(provider string, in, out int64) {
+	TokensTotal.WithLabelValues(provider, "in").Add(float64(in))
+	TokensTotal.WithLabelValues(provider, "out").Add(float64(out))
 }
 
-func MetricsHandler() http.Handler { return promhttp.Handler() }
+// MetricsHandler serves the default registry, negotiating OpenMetrics with scrapers that
+// ask for it since that's the only format that carries exemplars
+func MetricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+}
@@ -1,10 +1,14 @@
 package telemetry
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestMetricsEndpoint(t *testing.T) {
@@ -20,3 +24,71 @@ func TestMetricsEndpoint(t *testing.T) {
 		t.Fatalf("expected metrics body")
 	}
 }
+
+func TestCostUSDTotalHasPolicyLabel(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(CostUSDTotal)
+	CostUSDTotal.WithLabelValues("mock", "slo_burn_aware").Add(0.25)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "router_cost_usd_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if labels["provider"] == "mock" && labels["policy"] == "slo_burn_aware" {
+				return
+			}
+		}
+	}
+	t.Fatal("expected router_cost_usd_total series with policy label")
+}
+
+func TestObserveLatencyAttachesTraceExemplar(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(LatencyMs)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+	ObserveLatency(ctx, "exemplar-mock", "cheapest", 42)
+	ObserveLatency(context.Background(), "exemplar-mock", "fastest_p95", 42)
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	found := map[string]bool{}
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if labels["provider"] != "exemplar-mock" {
+				continue
+			}
+			for _, b := range m.GetHistogram().GetBucket() {
+				for _, lp := range b.GetExemplar().GetLabel() {
+					if lp.GetName() == "trace_id" && lp.GetValue() == traceID.String() {
+						found[labels["policy"]] = true
+					}
+				}
+			}
+		}
+	}
+	if !found["cheapest"] {
+		t.Error("expected an exemplar carrying the span's trace ID")
+	}
+	if found["fastest_p95"] {
+		t.Error("expected no exemplar without an active span")
+	}
+}
@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/config"
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/providers"
+)
+
+var (
+	sloMu     sync.RWMutex
+	sloTarget = config.DefaultSLOTarget
+)
+
+// SetSLOTarget sets the availability objective used by SLO reports
+func SetSLOTarget(target float64) {
+	sloMu.Lock()
+	defer sloMu.Unlock()
+	sloTarget = target
+}
+
+// SLOTarget returns the availability objective, e.g. 0.99
+func SLOTarget() float64 {
+	sloMu.RLock()
+	defer sloMu.RUnlock()
+	return sloTarget
+}
+
+// burnRate is the error rate as a multiple of the error budget; 1.0 spends the
+// budget exactly over the window
+func burnRate(total, errs int, budget float64) float64 {
+	if total == 0 || budget <= 0 {
+		return 0
+	}
+	return float64(errs) / float64(total) / budget
+}
+
+// ProviderBurnRate is p's burn rate over window against an availability target
+func ProviderBurnRate(p *providers.ResilientProvider, window time.Duration, target float64) float64 {
+	total, errs := p.Stats().CountsSince(window)
+	return burnRate(total, errs, 1-target)
+}
+
+// SLOReport is the fleet-wide view of SLO compliance over a window
+type SLOReport struct {
+	Window    string  `json:"window"`
+	SLOTarget float64 `json:"slo_target"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	BurnRate  float64 `json:"burn_rate"`
+	// p95 of successful calls across all providers
+	P95LatencyMs       int64   `json:"p95_latency_ms"`
+	Availability       float64 `json:"availability"`
+	BudgetRemainingPct float64 `json:"budget_remaining_pct"`
+}
+
+// ComputeSLO summarizes provider stats over window against an availability target.
+// Windows longer than the providers' sample size only see the samples they kept.
+func ComputeSLO(ps []*providers.ResilientProvider, window time.Duration, target float64) SLOReport {
+	r := SLOReport{Window: window.String(), SLOTarget: target, Availability: 1, BudgetRemainingPct: 100}
+	var latencies []int64
+	for _, p := range ps {
+		total, errs := p.Stats().CountsSince(window)
+		r.Requests += total
+		r.Errors += errs
+		latencies = append(latencies, p.Stats().LatenciesSince(window)...)
+	}
+	if r.Requests > 0 {
+		r.ErrorRate = float64(r.Errors) / float64(r.Requests)
+		r.Availability = 1 - r.ErrorRate
+		r.BurnRate = burnRate(r.Requests, r.Errors, 1-target)
+		r.BudgetRemainingPct = math.Max(0, 100*(1-r.BurnRate))
+	}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		idx := int(math.Ceil(0.95*float64(len(latencies)))) - 1
+		r.P95LatencyMs = latencies[max(idx, 0)]
+	}
+	return r
+}
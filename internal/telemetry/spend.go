@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type spendSample struct {
+	at    time.Time
+	total float64
+}
+
+// SpendSampler periodically turns router_cost_usd_total into spend over the last
+// minute, exported as router_cost_usd_per_minute and handed to onSample
+type SpendSampler struct {
+	mu       sync.Mutex
+	samples  []spendSample
+	onSample func(perMinute float64)
+	now      func() time.Time
+}
+
+// NewSpendSampler creates a sampler; onSample may be nil
+func NewSpendSampler(onSample func(perMinute float64)) *SpendSampler {
+	return &SpendSampler{onSample: onSample, now: time.Now}
+}
+
+// Run samples every interval until ctx is cancelled
+func (s *SpendSampler) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	s.Sample()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.Sample()
+		}
+	}
+}
+
+// Sample reads the cost counter once and exports the spend since the oldest sample
+// within the last minute
+func (s *SpendSampler) Sample() {
+	s.mu.Lock()
+	now := s.now()
+	total := costTotal()
+	s.samples = append(s.samples, spendSample{at: now, total: total})
+	// keep the newest sample at least a minute old as the window's baseline
+	cutoff := now.Add(-time.Minute)
+	for len(s.samples) > 1 && !s.samples[1].at.After(cutoff) {
+		s.samples = s.samples[1:]
+	}
+	perMinute := total - s.samples[0].total
+	s.mu.Unlock()
+
+	CostUSDPerMinute.Set(perMinute)
+	if s.onSample != nil {
+		s.onSample(perMinute)
+	}
+}
+
+// costTotal sums router_cost_usd_total across providers and policies
+func costTotal() float64 {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		CostUSDTotal.Collect(ch)
+		close(ch)
+	}()
+	var sum float64
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err == nil && pb.Counter != nil {
+			sum += pb.Counter.GetValue()
+		}
+	}
+	return sum
+}
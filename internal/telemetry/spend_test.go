@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSpendSamplerRollingMinute(t *testing.T) {
+	now := time.Now()
+	var got float64
+	s := NewSpendSampler(func(perMinute float64) { got = perMinute })
+	s.now = func() time.Time { return now }
+	s.Sample()
+
+	CostUSDTotal.WithLabelValues("spend-test", "cheapest").Add(1.5)
+	now = now.Add(30 * time.Second)
+	s.Sample()
+	if math.Abs(got-1.5) > 1e-9 || math.Abs(testutil.ToFloat64(CostUSDPerMinute)-1.5) > 1e-9 {
+		t.Errorf("expected 1.5 spent in the last minute, got %v", got)
+	}
+
+	// the spend ages out once it's more than a minute old
+	CostUSDTotal.WithLabelValues("spend-test", "cheapest").Add(0.25)
+	now = now.Add(45 * time.Second)
+	s.Sample()
+	now = now.Add(45 * time.Second)
+	s.Sample()
+	if math.Abs(got-0.25) > 1e-9 {
+		t.Errorf("expected only the newer 0.25 left in the window, got %v", got)
+	}
+}
@@ -2,12 +2,13 @@ package usage
 
 import (
 	"strings"
-	"unicode/utf8"
+	"unicode"
 )
 
 // TokenEstimator provides token counting estimates for different models
 type TokenEstimator struct {
-	modelRates map[string]float64 // model -> chars per token ratio
+	modelRates map[string]float64   // model -> chars per token ratio
+	tokenizers map[string]Tokenizer // model prefix -> exact tokenizer
 }
 
 func NewTokenEstimator() *TokenEstimator {
@@ -28,15 +29,38 @@ func NewTokenEstimator() *TokenEstimator {
 			// Default fallback
 			"default": 3.8,
 		},
+		tokenizers: make(map[string]Tokenizer),
 	}
 }
 
+// RegisterTokenizer counts tokens exactly for models matching prefix; the
+// longest matching prefix wins and unmatched models keep the ratio estimate.
+// Not safe to call concurrently with estimation, so register at startup.
+func (te *TokenEstimator) RegisterTokenizer(prefix string, tok Tokenizer) {
+	te.tokenizers[prefix] = tok
+}
+
+func (te *TokenEstimator) tokenizerFor(model string) Tokenizer {
+	var best Tokenizer
+	bestLen := -1
+	for prefix, tok := range te.tokenizers {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = tok, len(prefix)
+		}
+	}
+	return best
+}
+
 // EstimateTokens estimates the number of tokens in text for a given model
 func (te *TokenEstimator) EstimateTokens(text, model string) int64 {
 	if text == "" {
 		return 0
 	}
 
+	if tok := te.tokenizerFor(model); tok != nil {
+		return int64(tok.CountTokens(text))
+	}
+
 	// Get the chars-per-token ratio for the model
 	rate, exists := te.modelRates[model]
 	if !exists {
@@ -54,11 +78,14 @@ func (te *TokenEstimator) EstimateTokens(text, model string) int64 {
 		}
 	}
 
-	// Count characters (UTF-8 aware)
-	charCount := float64(utf8.RuneCountInString(text))
+	// Count characters per script (UTF-8 aware); the model ratio is Latin-tuned,
+	// so CJK and emoji are weighted separately
+	counts := countScripts(text)
 
 	// Estimate tokens
-	estimatedTokens := charCount / rate
+	estimatedTokens := float64(counts.other)/rate +
+		float64(counts.cjk)/cjkCharsPerToken +
+		float64(counts.emoji)*tokensPerEmoji
 
 	// Round up to ensure we don't underestimate
 	if estimatedTokens != float64(int64(estimatedTokens)) {
@@ -76,6 +103,17 @@ func (te *TokenEstimator) EstimatePromptTokens(prompt, model string) int64 {
 	return baseTokens + overhead
 }
 
+// EstimateMessagesTokens estimates prompt tokens summed across chat messages
+func (te *TokenEstimator) EstimateMessagesTokens(contents []string, model string) int64 {
+	var baseTokens int64
+	for _, c := range contents {
+		// ~4 tokens of role/turn framing per message
+		baseTokens += te.EstimateTokens(c, model) + 4
+	}
+	overhead := int64(float64(baseTokens) * 0.1) // 10% overhead, as for single prompts
+	return baseTokens + overhead
+}
+
 // EstimateCompletionTokens provides a rough estimate for completion tokens
 // This is much harder to predict accurately, so we use conservative estimates
 func (te *TokenEstimator) EstimateCompletionTokens(prompt, model string) int64 {
@@ -120,3 +158,73 @@ func (te *TokenEstimator) EstimateMaxTokens(prompt, model string, maxTokens int)
 
 	return promptTokens + completionTokens
 }
+
+const (
+	// CJK ideographs, kana and hangul are roughly one token per character
+	cjkCharsPerToken = 1.0
+	// an emoji, including ZWJ sequences, modifiers and flags, costs ~2 tokens
+	tokensPerEmoji = 2.0
+)
+
+type scriptCounts struct {
+	other int // Latin and everything else, priced at the model ratio
+	cjk   int // characters
+	emoji int // sequences, not runes
+}
+
+// countScripts splits text into script classes; emoji sequences joined by ZWJ,
+// variation selectors, skin tones or tag characters count once
+func countScripts(text string) scriptCounts {
+	var c scriptCounts
+	var prev rune
+	inEmoji, flagOpen := false, false
+	for _, r := range text {
+		switch {
+		case inEmoji && (r == zeroWidthJoiner || isEmojiModifier(r)):
+			// extends the current sequence
+		case isRegionalIndicator(r):
+			// two regional indicators form one flag
+			if !flagOpen {
+				c.emoji++
+			}
+			flagOpen = !flagOpen
+			inEmoji = true
+		case isEmoji(r):
+			if !inEmoji || prev != zeroWidthJoiner {
+				c.emoji++
+			}
+			inEmoji, flagOpen = true, false
+		case isCJK(r):
+			c.cjk++
+			inEmoji, flagOpen = false, false
+		default:
+			c.other++
+			inEmoji, flagOpen = false, false
+		}
+		prev = r
+	}
+	return c
+}
+
+const zeroWidthJoiner = '\u200d'
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303f) || // CJK symbols and punctuation
+		(r >= 0xff00 && r <= 0xffef) // halfwidth and fullwidth forms
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1f300 && r <= 0x1faff) || // pictographs, emoticons, transport, supplemental
+		(r >= 0x2600 && r <= 0x27bf) // misc symbols and dingbats
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+func isEmojiModifier(r rune) bool {
+	return r == 0xfe0f || // emoji presentation selector
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // skin tones
+		(r >= 0xe0020 && r <= 0xe007f) // tag sequences (subdivision flags)
+}
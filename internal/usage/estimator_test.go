@@ -0,0 +1,48 @@
+package usage
+
+import "testing"
+
+func TestEstimateTokensScripts(t *testing.T) {
+	te := NewTokenEstimator()
+	cases := []struct {
+		name     string
+		text     string
+		min, max int64
+	}{
+		// 11 chars at gpt-4's 3.5 chars/token, unchanged by the script weighting
+		{"latin", "hello world", 4, 4},
+		// 16 characters; real tokenizers produce ~1 token per ideograph
+		{"chinese", "今天天气很好，我们去公园散步吧。", 12, 24},
+		// 11 characters of mixed kanji and kana
+		{"japanese", "東京は日本の首都です。", 8, 18},
+		// family ZWJ sequence, two party poppers, thumbs up with skin tone, a flag:
+		// 14 runes but 5 emoji
+		{"emoji", "👨‍👩‍👧‍👦🎉🎉👍🏽🇯🇵", 5, 15},
+		// Latin words around CJK: each part is priced by its own ratio
+		{"mixed", "Translate 你好世界 please", 6, 12},
+	}
+	for _, c := range cases {
+		got := te.EstimateTokens(c.text, "gpt-4")
+		if got < c.min || got > c.max {
+			t.Errorf("%s: estimate %d outside [%d, %d]", c.name, got, c.min, c.max)
+		}
+	}
+}
+
+func TestCountScriptsGroupsEmojiSequences(t *testing.T) {
+	cases := []struct {
+		text string
+		want scriptCounts
+	}{
+		{"👨‍👩‍👧‍👦", scriptCounts{emoji: 1}},
+		{"👍🏽👍", scriptCounts{emoji: 2}},
+		{"🇯🇵🇺🇸", scriptCounts{emoji: 2}},
+		{"❤️ ok", scriptCounts{emoji: 1, other: 3}},
+		{"カタカナ한글", scriptCounts{cjk: 6}},
+	}
+	for _, c := range cases {
+		if got := countScripts(c.text); got != c.want {
+			t.Errorf("countScripts(%q) = %+v, want %+v", c.text, got, c.want)
+		}
+	}
+}
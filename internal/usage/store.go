@@ -2,32 +2,48 @@ package usage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/rs/zerolog/log"
+
+	"github.com/ratnathegod/Cost-SLO-Aware-LLM-Inference-Router/internal/awsconfig"
 )
 
 // UsageRecord represents a single API usage record
 type UsageRecord struct {
-	TenantID            string    `json:"tenant_id" dynamodbav:"tenant_id"`
-	Timestamp           time.Time `json:"timestamp" dynamodbav:"timestamp"`
-	RequestID           string    `json:"request_id" dynamodbav:"request_id"`
-	Provider            string    `json:"provider" dynamodbav:"provider"`
-	Model               string    `json:"model" dynamodbav:"model"`
-	EstPromptTokens     int64     `json:"est_prompt_tokens" dynamodbav:"est_prompt_tokens"`
-	EstCompletionTokens int64     `json:"est_completion_tokens" dynamodbav:"est_completion_tokens"`
-	CostUSD             float64   `json:"cost_usd" dynamodbav:"cost_usd"`
-	LatencyMs           int64     `json:"latency_ms" dynamodbav:"latency_ms"`
-	Status              string    `json:"status" dynamodbav:"status"` // "ok" or "error"
-	IdempotencyKey      string    `json:"idempotency_key,omitempty" dynamodbav:"idempotency_key,omitempty"`
+	TenantID            string            `json:"tenant_id" dynamodbav:"tenant_id"`
+	Timestamp           time.Time         `json:"timestamp" dynamodbav:"timestamp"`
+	RequestID           string            `json:"request_id" dynamodbav:"request_id"`
+	Provider            string            `json:"provider" dynamodbav:"provider"`
+	Model               string            `json:"model" dynamodbav:"model"`
+	EstPromptTokens     int64             `json:"est_prompt_tokens" dynamodbav:"est_prompt_tokens"`
+	EstCompletionTokens int64             `json:"est_completion_tokens" dynamodbav:"est_completion_tokens"`
+	CostUSD             float64           `json:"cost_usd" dynamodbav:"cost_usd"`
+	LatencyMs           int64             `json:"latency_ms" dynamodbav:"latency_ms"`
+	Status              string            `json:"status" dynamodbav:"status"` // "ok", "error" or "partial"
+	IdempotencyKey      string            `json:"idempotency_key,omitempty" dynamodbav:"idempotency_key,omitempty"`
+	Cached              bool              `json:"cached,omitempty" dynamodbav:"cached,omitempty"`         // served from the response cache, no provider cost
+	ErrorKind           string            `json:"error_kind,omitempty" dynamodbav:"error_kind,omitempty"` // providers.ErrorKind when Status is "error"
+	Checkpoint          int               `json:"checkpoint,omitempty" dynamodbav:"checkpoint,omitempty"` // numbers the partial rows of a long stream; 0 is the final row
+	Tags                map[string]string `json:"tags,omitempty" dynamodbav:"tags,omitempty"`             // cost attribution tags from X-Cost-Tags
+	Kind                string            `json:"kind,omitempty" dynamodbav:"kind,omitempty"`             // KindEmbeddings, or empty for a completion
 }
 
+// KindEmbeddings marks a usage row for an embeddings request; its tokens are all
+// prompt tokens
+const KindEmbeddings = "embeddings"
+
+// StatusPartial marks a checkpoint row written while a stream is still running. It
+// carries only the tokens since the previous checkpoint; the final row has the rest.
+const StatusPartial = "partial"
+
 // DailyAggregate represents daily usage aggregates
 type DailyAggregate struct {
 	TenantID  string    `json:"tenant_id" dynamodbav:"tenant_id"`
@@ -41,9 +57,28 @@ type DailyAggregate struct {
 	UpdatedAt time.Time `json:"updated_at" dynamodbav:"updated_at"`
 }
 
+// TagAggregate sums one tag value's daily aggregates over a date range
+type TagAggregate struct {
+	Value     string  `json:"value"`
+	Requests  int64   `json:"requests"`
+	Successes int64   `json:"successes"`
+	Failures  int64   `json:"failures"`
+	TokensIn  int64   `json:"tokens_in"`
+	TokensOut int64   `json:"tokens_out"`
+	CostUSD   float64 `json:"cost_usd"`
+}
+
+// dynamoAPI is the part of the DynamoDB client the store uses
+type dynamoAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
 // Store handles usage tracking and aggregation
 type Store struct {
-	ddbClient *dynamodb.Client
+	ddbClient dynamoAPI
 	tableName string
 	enabled   bool
 }
@@ -55,7 +90,7 @@ func NewStore(tableName string) (*Store, error) {
 	}
 
 	if store.enabled {
-		cfg, err := config.LoadDefaultConfig(context.TODO())
+		cfg, err := awsconfig.Load(context.TODO())
 		if err != nil {
 			log.Warn().Err(err).Msg("failed to load AWS config, disabling usage tracking")
 			store.enabled = false
@@ -80,17 +115,58 @@ func (s *Store) RecordUsage(ctx context.Context, record UsageRecord) error {
 	}
 
 	// Update daily aggregate
-	if err := s.updateDailyAggregate(ctx, record); err != nil {
+	if err := s.updateDailyAggregate(ctx, record, "agg#"+record.TenantID+"#daily"); err != nil {
 		log.Error().Err(err).Msg("failed to update daily aggregate")
 		// Don't return error here - detailed record was successful
 	}
 
+	// and each tag value's, once the value is within the key's cardinality limit
+	for k, v := range record.Tags {
+		if err := s.addTagValue(ctx, record.TenantID, k, v); err != nil {
+			log.Warn().Err(err).Str("tenant", record.TenantID).Str("tag", k).Msg("cost tag not aggregated")
+			continue
+		}
+		if err := s.updateDailyAggregate(ctx, record, tagAggregateKey(record.TenantID, k, v)); err != nil {
+			log.Error().Err(err).Str("tag", k).Msg("failed to update tag aggregate")
+		}
+	}
+
 	return nil
 }
 
+// errTooManyTagValues is a new tag value past MaxTagValues for its key
+var errTooManyTagValues = fmt.Errorf("tag key already has %d values", MaxTagValues)
+
+// addTagValue lists value under the tenant's tag key, unless that would take the key
+// past MaxTagValues values
+func (s *Store) addTagValue(ctx context.Context, tenantID, key, value string) error {
+	_, err := s.ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: tagValuesKey(tenantID)},
+			"sk": &types.AttributeValueMemberS{Value: key},
+		},
+		UpdateExpression:         aws.String("ADD #values :values"),
+		ConditionExpression:      aws.String("attribute_not_exists(#values) OR contains(#values, :value) OR size(#values) < :max"),
+		ExpressionAttributeNames: map[string]string{"#values": "tag_values"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":values": &types.AttributeValueMemberSS{Value: []string{value}},
+			":value":  &types.AttributeValueMemberS{Value: value},
+			":max":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", MaxTagValues)},
+		},
+	})
+	if ccf := new(types.ConditionalCheckFailedException); errors.As(err, &ccf) {
+		return errTooManyTagValues
+	}
+	return err
+}
+
 func (s *Store) writeUsageRecord(ctx context.Context, record UsageRecord) error {
 	// Create composite sort key: YYYY-MM-DD#HH:mm:ss#<req_id>
 	sortKey := record.Timestamp.Format("2006-01-02#15:04:05") + "#" + record.RequestID
+	if record.Checkpoint > 0 {
+		sortKey += fmt.Sprintf("#%d", record.Checkpoint)
+	}
 
 	item := map[string]types.AttributeValue{
 		"pk": &types.AttributeValueMemberS{Value: "usage#" + record.TenantID},
@@ -116,45 +192,51 @@ func (s *Store) writeUsageRecord(ctx context.Context, record UsageRecord) error
 	return err
 }
 
-func (s *Store) updateDailyAggregate(ctx context.Context, record UsageRecord) error {
+// updateDailyAggregate adds record to the day's aggregate in partition pk
+func (s *Store) updateDailyAggregate(ctx context.Context, record UsageRecord, pk string) error {
 	date := record.Timestamp.Format("2006-01-02")
 
-	updateExpr := "SET #reqs = if_not_exists(#reqs, :zero) + :one, " +
-		"#updated = :now, " +
+	updateExpr := "SET #updated = :now, " +
 		"#tokens_in = if_not_exists(#tokens_in, :zero) + :prompt_tokens, " +
 		"#tokens_out = if_not_exists(#tokens_out, :zero) + :completion_tokens, " +
 		"#cost = if_not_exists(#cost, :zero_float) + :cost"
 
-	if record.Status == "ok" {
-		updateExpr += ", #successes = if_not_exists(#successes, :zero) + :one"
-	} else {
-		updateExpr += ", #failures = if_not_exists(#failures, :zero) + :one"
-	}
-
 	expressionAttributeNames := map[string]string{
-		"#reqs":       "requests",
-		"#successes":  "successes",
-		"#failures":   "failures",
+		"#updated":    "updated_at",
 		"#tokens_in":  "tokens_in",
 		"#tokens_out": "tokens_out",
 		"#cost":       "cost_usd",
-		"#updated":    "updated_at",
+	}
+
+	// a checkpoint only adds tokens; the request is counted once, by its final row
+	switch record.Status {
+	case StatusPartial:
+	case "ok":
+		updateExpr += ", #reqs = if_not_exists(#reqs, :zero) + :one, #successes = if_not_exists(#successes, :zero) + :one"
+		expressionAttributeNames["#reqs"] = "requests"
+		expressionAttributeNames["#successes"] = "successes"
+	default:
+		updateExpr += ", #reqs = if_not_exists(#reqs, :zero) + :one, #failures = if_not_exists(#failures, :zero) + :one"
+		expressionAttributeNames["#reqs"] = "requests"
+		expressionAttributeNames["#failures"] = "failures"
 	}
 
 	expressionAttributeValues := map[string]types.AttributeValue{
 		":zero":              &types.AttributeValueMemberN{Value: "0"},
 		":zero_float":        &types.AttributeValueMemberN{Value: "0.0"},
-		":one":               &types.AttributeValueMemberN{Value: "1"},
 		":prompt_tokens":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", record.EstPromptTokens)},
 		":completion_tokens": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", record.EstCompletionTokens)},
 		":cost":              &types.AttributeValueMemberN{Value: fmt.Sprintf("%.6f", record.CostUSD)},
 		":now":               &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
 	}
+	if record.Status != StatusPartial {
+		expressionAttributeValues[":one"] = &types.AttributeValueMemberN{Value: "1"}
+	}
 
 	_, err := s.ddbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(s.tableName),
 		Key: map[string]types.AttributeValue{
-			"pk": &types.AttributeValueMemberS{Value: "agg#" + record.TenantID + "#daily"},
+			"pk": &types.AttributeValueMemberS{Value: pk},
 			"sk": &types.AttributeValueMemberS{Value: date},
 		},
 		UpdateExpression:          aws.String(updateExpr),
@@ -171,6 +253,11 @@ func (s *Store) GetDailyUsage(ctx context.Context, tenantID string, since, until
 		return nil, nil
 	}
 
+	return s.queryDailyAggregates(ctx, "agg#"+tenantID+"#daily", since, until)
+}
+
+// queryDailyAggregates reads partition pk's daily aggregates from since to until
+func (s *Store) queryDailyAggregates(ctx context.Context, pk string, since, until time.Time) ([]DailyAggregate, error) {
 	sinceStr := since.Format("2006-01-02")
 	untilStr := until.Format("2006-01-02")
 
@@ -178,7 +265,7 @@ func (s *Store) GetDailyUsage(ctx context.Context, tenantID string, since, until
 		TableName:              aws.String(s.tableName),
 		KeyConditionExpression: aws.String("pk = :pk AND sk BETWEEN :since AND :until"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk":    &types.AttributeValueMemberS{Value: "agg#" + tenantID + "#daily"},
+			":pk":    &types.AttributeValueMemberS{Value: pk},
 			":since": &types.AttributeValueMemberS{Value: sinceStr},
 			":until": &types.AttributeValueMemberS{Value: untilStr},
 		},
@@ -210,6 +297,56 @@ func (s *Store) GetDailyUsage(ctx context.Context, tenantID string, since, until
 	return aggregates, nil
 }
 
+// GetTagUsage sums a tenant's usage per value of tag key from since to until, most
+// expensive value first
+func (s *Store) GetTagUsage(ctx context.Context, tenantID, key string, since, until time.Time) ([]TagAggregate, error) {
+	if !s.enabled {
+		return nil, nil
+	}
+
+	result, err := s.ddbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: tagValuesKey(tenantID)},
+			"sk": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	values, _ := result.Item["tag_values"].(*types.AttributeValueMemberSS)
+	if values == nil {
+		return nil, nil
+	}
+
+	var totals []TagAggregate
+	for _, v := range values.Value {
+		days, err := s.queryDailyAggregates(ctx, tagAggregateKey(tenantID, key, v), since, until)
+		if err != nil {
+			return nil, err
+		}
+		total := TagAggregate{Value: v}
+		for _, d := range days {
+			total.Requests += d.Requests
+			total.Successes += d.Successes
+			total.Failures += d.Failures
+			total.TokensIn += d.TokensIn
+			total.TokensOut += d.TokensOut
+			total.CostUSD += d.CostUSD
+		}
+		if total.Requests > 0 || total.TokensIn > 0 || total.TokensOut > 0 {
+			totals = append(totals, total)
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].CostUSD != totals[j].CostUSD {
+			return totals[i].CostUSD > totals[j].CostUSD
+		}
+		return totals[i].Value < totals[j].Value
+	})
+	return totals, nil
+}
+
 // GetRecentUsage retrieves recent usage records for a tenant
 func (s *Store) GetRecentUsage(ctx context.Context, tenantID string, limit int) ([]UsageRecord, error) {
 	if !s.enabled {
@@ -0,0 +1,165 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// aggDynamo applies the store's aggregate updates to in-memory items, enough to read
+// them back through Query and GetItem
+type aggDynamo struct {
+	items map[string]map[string]types.AttributeValue // pk + "|" + sk
+}
+
+func (d *aggDynamo) item(key map[string]types.AttributeValue) map[string]types.AttributeValue {
+	pk := key["pk"].(*types.AttributeValueMemberS).Value
+	sk := key["sk"].(*types.AttributeValueMemberS).Value
+	if d.items == nil {
+		d.items = make(map[string]map[string]types.AttributeValue)
+	}
+	if d.items[pk+"|"+sk] == nil {
+		d.items[pk+"|"+sk] = map[string]types.AttributeValue{"pk": key["pk"], "sk": key["sk"]}
+	}
+	return d.items[pk+"|"+sk]
+}
+
+func (d *aggDynamo) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: d.item(params.Key)}, nil
+}
+
+func (d *aggDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *aggDynamo) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	item := d.item(params.Key)
+	vals := params.ExpressionAttributeValues
+	if add, ok := vals[":values"].(*types.AttributeValueMemberSS); ok {
+		set, _ := item["tag_values"].(*types.AttributeValueMemberSS)
+		if set == nil {
+			set = &types.AttributeValueMemberSS{}
+		}
+		for _, v := range set.Value {
+			if v == add.Value[0] {
+				return &dynamodb.UpdateItemOutput{}, nil
+			}
+		}
+		if len(set.Value) >= MaxTagValues {
+			return nil, &types.ConditionalCheckFailedException{}
+		}
+		item["tag_values"] = &types.AttributeValueMemberSS{Value: append(set.Value, add.Value[0])}
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+	add := func(attr, val string) {
+		cur := 0.0
+		if n, ok := item[attr].(*types.AttributeValueMemberN); ok {
+			cur, _ = strconv.ParseFloat(n.Value, 64)
+		}
+		delta, _ := strconv.ParseFloat(vals[val].(*types.AttributeValueMemberN).Value, 64)
+		item[attr] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(cur+delta, 'f', -1, 64)}
+	}
+	add("tokens_in", ":prompt_tokens")
+	add("tokens_out", ":completion_tokens")
+	add("cost_usd", ":cost")
+	for _, attr := range params.ExpressionAttributeNames {
+		switch attr {
+		case "requests", "successes", "failures":
+			add(attr, ":one")
+		}
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (d *aggDynamo) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	pk := params.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value
+	var out dynamodb.QueryOutput
+	for key, item := range d.items {
+		if strings.HasPrefix(key, pk+"|") {
+			out.Items = append(out.Items, item)
+		}
+	}
+	return &out, nil
+}
+
+func TestTaggedUsageAccumulatesPerTagValue(t *testing.T) {
+	ddb := &aggDynamo{}
+	store := &Store{ddbClient: ddb, tableName: "usage", enabled: true}
+	ctx := context.Background()
+	now := time.Now()
+
+	records := []UsageRecord{
+		{TenantID: "t1", Timestamp: now, RequestID: "a", Status: "ok", EstPromptTokens: 10, CostUSD: 0.5, Tags: map[string]string{"project": "apollo", "team": "search"}},
+		{TenantID: "t1", Timestamp: now, RequestID: "b", Status: "ok", EstPromptTokens: 20, CostUSD: 0.25, Tags: map[string]string{"project": "apollo"}},
+		{TenantID: "t1", Timestamp: now, RequestID: "c", Status: "error", EstPromptTokens: 5, Tags: map[string]string{"project": "gemini"}},
+		{TenantID: "t1", Timestamp: now, RequestID: "d", Status: "ok", CostUSD: 9},
+	}
+	for _, rec := range records {
+		if err := store.RecordUsage(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apollo := ddb.items[tagAggregateKey("t1", "project", "apollo")+"|"+now.Format("2006-01-02")]
+	if apollo == nil {
+		t.Fatal("expected a daily aggregate for project=apollo")
+	}
+
+	got, err := store.GetTagUsage(ctx, "t1", "project", now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected apollo and gemini, got %+v", got)
+	}
+	if got[0].Value != "apollo" || got[0].Requests != 2 || got[0].Successes != 2 || got[0].TokensIn != 30 || math.Abs(got[0].CostUSD-0.75) > 1e-9 {
+		t.Errorf("unexpected apollo totals %+v", got[0])
+	}
+	if got[1].Value != "gemini" || got[1].Requests != 1 || got[1].Failures != 1 || got[1].CostUSD != 0 {
+		t.Errorf("unexpected gemini totals %+v", got[1])
+	}
+	if teams, _ := store.GetTagUsage(ctx, "t1", "team", now, now); len(teams) != 1 || teams[0].Value != "search" || teams[0].Requests != 1 {
+		t.Errorf("unexpected team totals %+v", teams)
+	}
+}
+
+func TestTagValuesAreCapped(t *testing.T) {
+	ddb := &aggDynamo{}
+	store := &Store{ddbClient: ddb, tableName: "usage", enabled: true}
+	ctx := context.Background()
+	now := time.Now()
+	for i := 0; i <= MaxTagValues; i++ {
+		rec := UsageRecord{TenantID: "t1", Timestamp: now, Status: "ok", Tags: map[string]string{"user": strconv.Itoa(i)}}
+		if err := store.RecordUsage(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, _ := store.GetTagUsage(ctx, "t1", "user", now, now)
+	if len(got) != MaxTagValues {
+		t.Errorf("expected values past the cap to go unaggregated, got %d", len(got))
+	}
+	if ddb.items[tagAggregateKey("t1", "user", strconv.Itoa(MaxTagValues))+"|"+now.Format("2006-01-02")] != nil {
+		t.Error("expected no aggregate for the value past the cap")
+	}
+}
+
+func TestParseCostTags(t *testing.T) {
+	tags, err := ParseCostTags(" project=apollo, team=search ")
+	if err != nil || len(tags) != 2 || tags["project"] != "apollo" || tags["team"] != "search" {
+		t.Errorf("unexpected tags %v, %v", tags, err)
+	}
+	if tags, err := ParseCostTags(""); err != nil || tags != nil {
+		t.Errorf("expected no tags for an empty header, got %v, %v", tags, err)
+	}
+	for _, bad := range []string{"project", "project=", "=apollo", "project=a b", "a=1,a=2", "a=1,b=1,c=1,d=1,e=1,f=1"} {
+		if _, err := ParseCostTags(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CostTagsHeader carries a request's comma-separated key=value cost attribution tags
+const CostTagsHeader = "X-Cost-Tags"
+
+// Tag cardinality limits, so tag aggregates stay bounded: a request carries at most
+// MaxCostTags tags and a tenant's tag key at most MaxTagValues distinct values.
+const (
+	MaxCostTags  = 5
+	MaxTagValues = 100
+)
+
+var tagPart = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ParseCostTags parses an X-Cost-Tags value such as "project=apollo,team=search".
+// Keys and values are 1-64 letters, digits, '_', '.' or '-'; an empty header is no tags.
+func ParseCostTags(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || !tagPart.MatchString(k) || !tagPart.MatchString(v) {
+			return nil, fmt.Errorf("invalid cost tag %q: want key=value of letters, digits, '_', '.' or '-'", pair)
+		}
+		if _, dup := tags[k]; dup {
+			return nil, fmt.Errorf("duplicate cost tag %q", k)
+		}
+		tags[k] = v
+	}
+	if len(tags) > MaxCostTags {
+		return nil, fmt.Errorf("too many cost tags: %d, at most %d", len(tags), MaxCostTags)
+	}
+	return tags, nil
+}
+
+// ValidTagKey reports whether key could name a cost tag
+func ValidTagKey(key string) bool {
+	return tagPart.MatchString(key)
+}
+
+// tagAggregateKey is the partition of one tag value's daily aggregates
+func tagAggregateKey(tenantID, key, value string) string {
+	return "agg#" + tenantID + "#tag#" + key + "=" + value
+}
+
+// tagValuesKey is the partition listing each tag key's values for a tenant
+func tagValuesKey(tenantID string) string {
+	return "agg#" + tenantID + "#tags"
+}
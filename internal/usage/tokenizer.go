@@ -0,0 +1,142 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts tokens exactly for a family of models
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// cl100kPattern is tiktoken's cl100k_base pre-tokenizer without the `\s+(?!\S)`
+// branch, which RE2 can't express; splitPieces restores that behaviour by hand
+var cl100kPattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// BPETokenizer is a tiktoken-compatible byte-level BPE encoder over a rank table
+type BPETokenizer struct {
+	ranks map[string]int
+}
+
+// NewBPETokenizer creates a tokenizer from token bytes -> merge rank
+func NewBPETokenizer(ranks map[string]int) *BPETokenizer {
+	return &BPETokenizer{ranks: ranks}
+}
+
+// LoadBPETokenizer reads a .tiktoken rank file (e.g. cl100k_base.tiktoken)
+func LoadBPETokenizer(path string) (*BPETokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ranks, err := ParseTiktokenRanks(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewBPETokenizer(ranks), nil
+}
+
+// ParseTiktokenRanks parses "<base64 token> <rank>" lines
+func ParseTiktokenRanks(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; s.Scan(); line++ {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected token and rank", line)
+		}
+		tok, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranks[string(tok)] = rank
+	}
+	return ranks, s.Err()
+}
+
+// CountTokens returns the exact number of BPE tokens in text
+func (t *BPETokenizer) CountTokens(text string) int {
+	var n int
+	for _, piece := range splitPieces(text) {
+		if _, ok := t.ranks[piece]; ok {
+			n++
+			continue
+		}
+		n += t.mergeCount(piece)
+	}
+	return n
+}
+
+// mergeCount applies the lowest-rank adjacent merge until none remain, as tiktoken does
+func (t *BPETokenizer) mergeCount(piece string) int {
+	parts := make([]string, 0, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts = append(parts, piece[i:i+1])
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i+1 < len(parts); i++ {
+			if r, ok := t.ranks[parts[i]+parts[i+1]]; ok && (best < 0 || r < bestRank) {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts)
+}
+
+// splitPieces pre-tokenizes text the way cl100k_base does
+func splitPieces(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		loc := cl100kPattern.FindStringIndex(text)
+		if loc == nil || loc[0] != 0 {
+			// unmatched byte; the pattern covers all input, but never loop forever
+			_, size := utf8.DecodeRuneInString(text)
+			pieces = append(pieces, text[:size])
+			text = text[size:]
+			continue
+		}
+		m := text[:loc[1]]
+		// emulate `\s+(?!\S)`: a run of spaces before a word leaves its last space to the word
+		if loc[1] < len(text) && isSpaceOnly(m) && !strings.ContainsAny(m, "\r\n") {
+			if _, size := utf8.DecodeLastRuneInString(m); size < len(m) {
+				m = m[:len(m)-size]
+			}
+		}
+		pieces = append(pieces, m)
+		text = text[len(m):]
+	}
+	return pieces
+}
+
+func isSpaceOnly(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package usage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixtureRanks is a tiny tiktoken-style vocabulary: every single byte plus a few merges
+func fixtureRanks() map[string]int {
+	ranks := make(map[string]int, 263)
+	for i := 0; i < 256; i++ {
+		ranks[string([]byte{byte(i)})] = i
+	}
+	for i, tok := range []string{"he", "ll", "hell", "hello", " w", "or", "ld"} {
+		ranks[tok] = 256 + i
+	}
+	return ranks
+}
+
+func TestBPETokenizerKnownCounts(t *testing.T) {
+	tok := NewBPETokenizer(fixtureRanks())
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"hello world", 4},  // "hello" + " w" "or" "ld"
+		{"hello  world", 5}, // extra space splits off on its own
+		{"yellow", 5},       // y e ll o w
+		{"12345", 5},        // digits group in threes, no digit merges
+		{"héllo", 5},        // é is two bytes
+		{"", 0},
+	}
+	for _, c := range cases {
+		if got := tok.CountTokens(c.text); got != c.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", c.text, got, c.want)
+		}
+	}
+}
+
+func TestSplitPieces(t *testing.T) {
+	cases := []struct {
+		text string
+		want []string
+	}{
+		{"Hello  world", []string{"Hello", " ", " world"}},
+		{" 12345", []string{" ", "123", "45"}},
+		{"it's done.\n", []string{"it", "'s", " done", ".\n"}},
+		{"a   ", []string{"a", "   "}},
+	}
+	for _, c := range cases {
+		if got := splitPieces(c.text); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitPieces(%q) = %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+func TestParseTiktokenRanks(t *testing.T) {
+	var b strings.Builder
+	for tok, rank := range map[string]int{"he": 0, " w": 1, "\xff": 2} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+	}
+	ranks, err := ParseTiktokenRanks(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ranks["he"] != 0 || ranks[" w"] != 1 || ranks["\xff"] != 2 || len(ranks) != 3 {
+		t.Errorf("unexpected ranks %v", ranks)
+	}
+	if _, err := ParseTiktokenRanks(strings.NewReader("aGU= notanumber\n")); err == nil {
+		t.Error("expected error for bad rank")
+	}
+}
+
+func TestEstimatorUsesTokenizerByModelPrefix(t *testing.T) {
+	te := NewTokenEstimator()
+	te.RegisterTokenizer("gpt-4", NewBPETokenizer(fixtureRanks()))
+
+	// exact counts for registered models, including longer names under the prefix
+	for _, model := range []string{"gpt-4", "gpt-4o-mini"} {
+		if got := te.EstimateTokens("hello world", model); got != 4 {
+			t.Errorf("%s: expected exact 4 tokens, got %d", model, got)
+		}
+	}
+	// other models keep the ratio fallback: 11 chars / 3.8 rounds up to 3
+	if got := te.EstimateTokens("hello world", "claude-3-haiku"); got != 3 {
+		t.Errorf("expected ratio estimate 3, got %d", got)
+	}
+
+	// the longest registered prefix wins
+	te.RegisterTokenizer("gpt-4o", NewBPETokenizer(map[string]int{}))
+	if got := te.EstimateTokens("hello world", "gpt-4o"); got != 11 {
+		t.Errorf("expected byte-level count 11 from gpt-4o tokenizer, got %d", got)
+	}
+	if got := te.EstimateTokens("hello world", "gpt-4-turbo"); got != 4 {
+		t.Errorf("expected gpt-4 tokenizer for gpt-4-turbo, got %d", got)
+	}
+}